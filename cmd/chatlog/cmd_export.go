@@ -0,0 +1,122 @@
+package chatlog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/export"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output dir")
+	exportCmd.Flags().StringVarP(&exportTalker, "talker", "t", "", "talkers to export, comma separated (default all sessions)")
+	exportCmd.Flags().StringVar(&exportTime, "time", "all", "time range, e.g. 2024-01-01~2024-06-30, last-3m")
+	exportCmd.Flags().StringVar(&exportType, "type", "", "message types, e.g. text,image,voice,video,file or 49:6")
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", strings.Join(export.Formats, ","), "output formats: jsonl,html,md")
+	exportCmd.Flags().BoolVar(&exportZip, "zip", false, "write one zip per talker")
+	exportCmd.Flags().BoolVar(&exportNoMedia, "no-media", false, "skip images, videos, voices and files")
+	exportCmd.Flags().StringVarP(&exportPlatform, "platform", "p", "", "platform")
+	exportCmd.Flags().IntVarP(&exportVer, "version", "v", 0, "version")
+	exportCmd.Flags().StringVarP(&exportDataDir, "data-dir", "d", "", "data dir")
+	exportCmd.Flags().StringVarP(&exportDataKey, "data-key", "k", "", "data key")
+	exportCmd.Flags().StringVarP(&exportImgKey, "img-key", "i", "", "img key")
+	exportCmd.Flags().StringVarP(&exportWorkDir, "work-dir", "w", "", "work dir")
+}
+
+var (
+	exportOutput   string
+	exportTalker   string
+	exportTime     string
+	exportType     string
+	exportFormat   string
+	exportZip      bool
+	exportNoMedia  bool
+	exportPlatform string
+	exportVer      int
+	exportDataDir  string
+	exportDataKey  string
+	exportImgKey   string
+	exportWorkDir  string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export -o DIR",
+	Short: "Export chat history to an offline archive",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(exportOutput) == 0 {
+			log.Error().Msg("output dir is required")
+			return
+		}
+
+		start, end, ok := util.TimeRangeOf(exportTime)
+		if !ok {
+			log.Error().Msgf("invalid time range: %s", exportTime)
+			return
+		}
+
+		types, err := export.ParseTypes(exportType)
+		if err != nil {
+			log.Err(err).Msg("invalid message type")
+			return
+		}
+
+		formats := util.Str2List(strings.ToLower(exportFormat), ",")
+		for _, f := range formats {
+			if f != export.FormatJSONL && f != export.FormatHTML && f != export.FormatMarkdown {
+				log.Error().Msgf("unsupported format: %s", f)
+				return
+			}
+		}
+
+		opts := export.Options{
+			OutputDir: exportOutput,
+			Talkers:   util.Str2List(exportTalker, ","),
+			Start:     start,
+			End:       end,
+			Types:     types,
+			Formats:   formats,
+			Zip:       exportZip,
+			NoMedia:   exportNoMedia,
+		}
+
+		m := chatlog.New()
+		summary, err := m.CommandExport("", getExportConfig(), opts)
+		if summary != nil {
+			fmt.Printf("exported %d talkers, %d messages, %d media files (%d unresolved) to %s\n",
+				summary.Talkers, summary.Messages, summary.Media, summary.MediaFailed, exportOutput)
+		}
+		if err != nil {
+			log.Err(err).Msg("failed to export")
+			return
+		}
+	},
+}
+
+func getExportConfig() map[string]any {
+	cmdConf := make(map[string]any)
+	if len(exportDataDir) != 0 {
+		cmdConf["data_dir"] = exportDataDir
+	}
+	if len(exportDataKey) != 0 {
+		cmdConf["data_key"] = exportDataKey
+	}
+	if len(exportImgKey) != 0 {
+		cmdConf["img_key"] = exportImgKey
+	}
+	if len(exportWorkDir) != 0 {
+		cmdConf["work_dir"] = exportWorkDir
+	}
+	if len(exportPlatform) != 0 {
+		cmdConf["platform"] = exportPlatform
+	}
+	if exportVer != 0 {
+		cmdConf["version"] = exportVer
+	}
+	return cmdConf
+}
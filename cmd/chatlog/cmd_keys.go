@@ -0,0 +1,265 @@
+package chatlog
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/keystore"
+)
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.PersistentFlags().StringVarP(&keysConfigDir, "config-dir", "c", "", "config dir (default ~/.chatlog or $CHATLOG_DIR)")
+
+	keysRotateCmd.Flags().StringVar(&keysMode, "mode", "", "protect the new master key with: passphrase, keyring, file (default keep current)")
+	keysSetCmd.Flags().StringVarP(&keysDataDir, "data-dir", "d", "", "wechat data dir the keys belong to (required for new accounts)")
+	keysCmd.AddCommand(keysListCmd, keysRotateCmd, keysUnlockCmd, keysSetCmd, keysImportCmd)
+}
+
+var (
+	keysConfigDir string
+	keysMode      string
+	keysDataDir   string
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage data and image keys stored in the encrypted keystore",
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List accounts in the keystore without revealing keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		ks, err := conf.OpenKeystore(keysConfigDir)
+		if err != nil {
+			log.Err(err).Msg("failed to open keystore")
+			return
+		}
+		items := ks.List()
+		if len(items) == 0 {
+			fmt.Printf("no keys stored in %s\n", ks.Path())
+			return
+		}
+
+		status := ks.Mode()
+		if ks.Locked() {
+			status += ", locked"
+		}
+		fmt.Printf("keystore: %s (%s)\n\n", ks.Path(), status)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT\tDATA KEY\tIMAGE KEY\tUPDATED\tDATA DIR")
+		for _, it := range items {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", it.Account, keyState(it.HasDataKey), keyState(it.HasImgKey),
+				it.UpdatedAt.Format(time.DateTime), it.DataDir)
+		}
+		w.Flush()
+	},
+}
+
+var keysUnlockCmd = &cobra.Command{
+	Use:   "unlock <account>",
+	Short: "Decrypt and print the keys of an account",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ks, err := openUnlockedKeystore()
+		if err != nil {
+			log.Err(err).Msg("failed to unlock keystore")
+			return
+		}
+		e, err := ks.Get(args[0])
+		if err != nil {
+			log.Err(err).Msgf("failed to read keys of %s", args[0])
+			return
+		}
+		fmt.Printf("Data Key: [%s]\nImage Key: [%s]\n", e.DataKey, e.ImgKey)
+	},
+}
+
+var keysSetCmd = &cobra.Command{
+	Use:   "set <account>",
+	Short: "Store the data and image keys of an account, read from the terminal or stdin",
+	Long: `Store the data and image keys of an account in the keystore.
+
+The keys are prompted for (or read one per line from stdin) so they never
+appear in the shell history or process list. Leave a key empty to keep the
+stored value.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ks, err := openUnlockedKeystore()
+		if err != nil {
+			log.Err(err).Msg("failed to unlock keystore")
+			return
+		}
+
+		entry := keystore.Entry{Account: args[0]}
+		if cur, err := ks.Get(args[0]); err == nil {
+			entry = *cur
+		} else if err != keystore.ErrNotFound {
+			log.Err(err).Msgf("failed to read keys of %s", args[0])
+			return
+		}
+		if keysDataDir != "" {
+			entry.DataDir = keysDataDir
+		}
+		if entry.DataDir == "" {
+			log.Error().Msg("--data-dir is required for a new account")
+			return
+		}
+
+		for _, key := range []struct {
+			prompt string
+			value  *string
+		}{{"Data key: ", &entry.DataKey}, {"Image key: ", &entry.ImgKey}} {
+			v, err := readPassphrase(key.prompt)
+			if err != nil {
+				log.Err(err).Msg("failed to read key")
+				return
+			}
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if _, err := hex.DecodeString(v); err != nil || keystore.IsRedacted(v) {
+				log.Error().Msgf("%sinvalid hex key", key.prompt)
+				return
+			}
+			*key.value = v
+		}
+
+		if err := ks.Put(entry); err != nil {
+			log.Err(err).Msgf("failed to store keys of %s", args[0])
+			return
+		}
+		fmt.Printf("stored keys of %s (%s) in %s\n", entry.Account, entry.DataDir, ks.Path())
+	},
+}
+
+var keysImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Move plaintext keys from chatlog-server.json and the data dir's chatlog.json into the keystore",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ks, err := openUnlockedKeystore()
+		if err != nil {
+			log.Err(err).Msg("failed to unlock keystore")
+			return
+		}
+		n, err := conf.ImportServerKeys(keysConfigDir, ks)
+		if err != nil {
+			log.Err(err).Msg("failed to import keys")
+			return
+		}
+		if n == 0 {
+			fmt.Println("no plaintext keys found")
+			return
+		}
+		fmt.Printf("moved keys from %d config file(s) into %s\n", n, ks.Path())
+	},
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate [account]",
+	Short: "Re-encrypt an account's entry with a new key, or rotate the master key when no account is given",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ks, err := openUnlockedKeystore()
+		if err != nil {
+			log.Err(err).Msg("failed to unlock keystore")
+			return
+		}
+
+		if len(args) == 1 {
+			if err := ks.Rotate(args[0]); err != nil {
+				log.Err(err).Msgf("failed to rotate %s", args[0])
+				return
+			}
+			fmt.Printf("rotated keys of %s\n", args[0])
+			return
+		}
+
+		mode := keysMode
+		if mode == "" {
+			mode = ks.Mode()
+		}
+		opts := keystore.Options{Mode: mode}
+		if mode == keystore.ModePassphrase {
+			pass, err := readPassphrase("New passphrase: ")
+			if err != nil {
+				log.Err(err).Msg("failed to read passphrase")
+				return
+			}
+			confirm, err := readPassphrase("Confirm passphrase: ")
+			if err != nil {
+				log.Err(err).Msg("failed to read passphrase")
+				return
+			}
+			if pass == "" || pass != confirm {
+				log.Error().Msg("passphrases are empty or do not match")
+				return
+			}
+			opts.Passphrase = pass
+		}
+		if err := ks.RotateMaster(opts); err != nil {
+			log.Err(err).Msg("failed to rotate master key")
+			return
+		}
+		fmt.Printf("rotated master key, keystore is now protected by %s\n", ks.Mode())
+		if ks.Mode() == keystore.ModePassphrase {
+			fmt.Printf("set %s before starting chatlog so it can read the keys\n", keystore.EnvPassphrase)
+		}
+	},
+}
+
+// openUnlockedKeystore 打开密钥库，口令模式下未设置环境变量时提示输入口令
+func openUnlockedKeystore() (*keystore.Store, error) {
+	ks, err := conf.OpenKeystore(keysConfigDir)
+	if err != nil {
+		return nil, err
+	}
+	if !ks.Locked() {
+		return ks, nil
+	}
+	pass, err := readPassphrase("Keystore passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.Unlock(pass); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readPassphrase 从终端读取口令且不回显；标准输入不是终端时读取一行
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func keyState(ok bool) string {
+	if ok {
+		return "set"
+	}
+	return "-"
+}
@@ -0,0 +1,71 @@
+package chatlog
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog"
+)
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+	mcpCmd.Flags().BoolVar(&mcpStdio, "stdio", false, "serve MCP over stdin/stdout")
+	mcpCmd.Flags().StringVarP(&mcpPlatform, "platform", "p", "", "platform")
+	mcpCmd.Flags().IntVarP(&mcpVer, "version", "v", 0, "version")
+	mcpCmd.Flags().StringVarP(&mcpDataDir, "data-dir", "d", "", "data dir")
+	mcpCmd.Flags().StringVarP(&mcpDataKey, "data-key", "k", "", "data key")
+	mcpCmd.Flags().StringVarP(&mcpImgKey, "img-key", "i", "", "img key")
+	mcpCmd.Flags().StringVarP(&mcpWorkDir, "work-dir", "w", "", "work dir")
+}
+
+var (
+	mcpStdio    bool
+	mcpPlatform string
+	mcpVer      int
+	mcpDataDir  string
+	mcpDataKey  string
+	mcpImgKey   string
+	mcpWorkDir  string
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp --stdio",
+	Short: "Serve MCP tools, resources and prompts for desktop clients",
+	Long: `Serve MCP over stdin/stdout so desktop MCP clients can launch chatlog directly.
+Logs are written to stderr; stdout carries protocol messages only.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !mcpStdio {
+			log.Error().Msg("only --stdio is supported, use 'chatlog server' for HTTP transports")
+			return
+		}
+
+		m := chatlog.New()
+		if err := m.CommandMCPStdio("", getMCPConfig()); err != nil {
+			log.Err(err).Msg("failed to serve mcp")
+			return
+		}
+	},
+}
+
+func getMCPConfig() map[string]any {
+	cmdConf := make(map[string]any)
+	if len(mcpDataDir) != 0 {
+		cmdConf["data_dir"] = mcpDataDir
+	}
+	if len(mcpDataKey) != 0 {
+		cmdConf["data_key"] = mcpDataKey
+	}
+	if len(mcpImgKey) != 0 {
+		cmdConf["img_key"] = mcpImgKey
+	}
+	if len(mcpWorkDir) != 0 {
+		cmdConf["work_dir"] = mcpWorkDir
+	}
+	if len(mcpPlatform) != 0 {
+		cmdConf["platform"] = mcpPlatform
+	}
+	if mcpVer != 0 {
+		cmdConf["version"] = mcpVer
+	}
+	return cmdConf
+}
@@ -0,0 +1,100 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/media"
+)
+
+func init() {
+	rootCmd.AddCommand(mediaCmd)
+	mediaCmd.PersistentFlags().StringVarP(&mediaPlatform, "platform", "p", "", "platform")
+	mediaCmd.PersistentFlags().IntVarP(&mediaVer, "version", "v", 0, "version")
+	mediaCmd.PersistentFlags().StringVarP(&mediaDataDir, "data-dir", "d", "", "data dir")
+	mediaCmd.PersistentFlags().StringVarP(&mediaDataKey, "data-key", "k", "", "data key")
+	mediaCmd.PersistentFlags().StringVarP(&mediaImgKey, "img-key", "i", "", "img key")
+	mediaCmd.PersistentFlags().StringVarP(&mediaWorkDir, "work-dir", "w", "", "work dir")
+
+	mediaDecodeCmd.Flags().BoolVar(&mediaForce, "force", false, "decode again even if the cached result is up to date")
+	mediaCmd.AddCommand(mediaDecodeCmd)
+}
+
+var (
+	mediaPlatform string
+	mediaVer      int
+	mediaDataDir  string
+	mediaDataKey  string
+	mediaImgKey   string
+	mediaWorkDir  string
+	mediaForce    bool
+)
+
+var mediaCmd = &cobra.Command{
+	Use:   "media",
+	Short: "Manage decoded media in the work dir",
+}
+
+var mediaDecodeCmd = &cobra.Command{
+	Use:   "decode",
+	Short: "Decode every .dat image into the work dir cache",
+	Long: `Walk msg/attach (FileStorage/MsgAttach for WeChat 3.x) and decode every .dat image into
+<work_dir>/media_cache/decoded, a content-addressed cache with a manifest. The HTTP server serves
+images from this cache. Files that cannot be decoded are recorded with a reason, e.g. missing_key
+when the image key (-i) is not set, and are retried on the next run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var last time.Time
+		report := func(p media.DecodeProgress) {
+			if time.Since(last) < time.Second && p.Done != p.Total {
+				return
+			}
+			last = time.Now()
+			fmt.Fprintf(os.Stderr, "\rdecoding %d/%d (%d failed)", p.Done, p.Total, p.Failed)
+		}
+
+		m := chatlog.New()
+		p, failures, err := m.CommandMediaDecode("", getMediaConfig(), media.DecodeOptions{Force: mediaForce}, report)
+		if p.Total > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+		fmt.Printf("decoded %d images, %d up to date, %d failed\n", p.Decoded, p.Skipped, p.Failed)
+		for _, f := range failures {
+			fmt.Printf("  %s: %s\n", f.Path, f.Error)
+		}
+		if p.Failed > len(failures) {
+			fmt.Printf("  ... and %d more\n", p.Failed-len(failures))
+		}
+		if err != nil {
+			log.Err(err).Msg("failed to decode media")
+			return
+		}
+	},
+}
+
+func getMediaConfig() map[string]any {
+	cmdConf := make(map[string]any)
+	if len(mediaDataDir) != 0 {
+		cmdConf["data_dir"] = mediaDataDir
+	}
+	if len(mediaDataKey) != 0 {
+		cmdConf["data_key"] = mediaDataKey
+	}
+	if len(mediaImgKey) != 0 {
+		cmdConf["img_key"] = mediaImgKey
+	}
+	if len(mediaWorkDir) != 0 {
+		cmdConf["work_dir"] = mediaWorkDir
+	}
+	if len(mediaPlatform) != 0 {
+		cmdConf["platform"] = mediaPlatform
+	}
+	if mediaVer != 0 {
+		cmdConf["version"] = mediaVer
+	}
+	return cmdConf
+}
@@ -19,6 +19,7 @@ func init() {
 	serverCmd.Flags().StringVarP(&serverImgKey, "img-key", "i", "", "img key")
 	serverCmd.Flags().StringVarP(&serverWorkDir, "work-dir", "w", "", "work dir")
 	serverCmd.Flags().BoolVarP(&serverAutoDecrypt, "auto-decrypt", "", false, "auto decrypt")
+	serverCmd.Flags().StringVarP(&serverSnapshot, "snapshot", "", "", "serve an already decrypted work dir read-only, without WeChat or keys")
 }
 
 var (
@@ -30,11 +31,13 @@ var (
 	serverPlatform    string
 	serverVer         int
 	serverAutoDecrypt bool
+	serverSnapshot    string
 )
 
 var serverCmd = &cobra.Command{
-	Use:   "server",
-	Short: "Start HTTP server",
+	Use:     "server",
+	Aliases: []string{"serve"},
+	Short:   "Start HTTP server",
 	Run: func(cmd *cobra.Command, args []string) {
 
 		cmdConf := getServerConfig()
@@ -74,5 +77,8 @@ func getServerConfig() map[string]any {
 	if serverAutoDecrypt {
 		cmdConf["auto_decrypt"] = true
 	}
+	if len(serverSnapshot) != 0 {
+		cmdConf["snapshot"] = serverSnapshot
+	}
 	return cmdConf
 }
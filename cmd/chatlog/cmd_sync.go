@@ -0,0 +1,78 @@
+package chatlog
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+)
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringVar(&syncDriver, "driver", "", "mirror database driver: postgres, sqlite3 (default from config or dsn)")
+	syncCmd.Flags().StringVar(&syncDSN, "dsn", "", "mirror database dsn (default from config mirror.dsn)")
+	syncCmd.Flags().IntVar(&syncBatchSize, "batch-size", 0, "messages per transaction (default 500)")
+	syncCmd.Flags().StringVarP(&syncPlatform, "platform", "p", "", "platform")
+	syncCmd.Flags().IntVarP(&syncVer, "version", "v", 0, "version")
+	syncCmd.Flags().StringVarP(&syncDataDir, "data-dir", "d", "", "data dir")
+	syncCmd.Flags().StringVarP(&syncDataKey, "data-key", "k", "", "data key")
+	syncCmd.Flags().StringVarP(&syncWorkDir, "work-dir", "w", "", "work dir")
+}
+
+var (
+	syncDriver    string
+	syncDSN       string
+	syncBatchSize int
+	syncPlatform  string
+	syncVer       int
+	syncDataDir   string
+	syncDataKey   string
+	syncWorkDir   string
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Mirror messages, contacts and chatrooms into a SQL database",
+	Long: `Mirror messages, contacts and chatrooms into PostgreSQL
+or a local SQLite file. Each talker keeps a checkpoint, so repeated runs only copy new messages.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var mirrorCfg *conf.MirrorConfig
+		if len(syncDSN) != 0 {
+			mirrorCfg = &conf.MirrorConfig{Enabled: true, Driver: syncDriver, DSN: syncDSN, BatchSize: syncBatchSize}
+		}
+
+		m := chatlog.New()
+		summary, err := m.CommandSync("", getSyncConfig(), mirrorCfg)
+		if summary != nil {
+			fmt.Printf("synced %d contacts, %d chatrooms, %d messages in %d talkers\n",
+				summary.Contacts, summary.ChatRooms, summary.Messages, summary.Talkers)
+		}
+		if err != nil {
+			log.Err(err).Msg("failed to sync")
+			return
+		}
+	},
+}
+
+func getSyncConfig() map[string]any {
+	cmdConf := make(map[string]any)
+	if len(syncDataDir) != 0 {
+		cmdConf["data_dir"] = syncDataDir
+	}
+	if len(syncDataKey) != 0 {
+		cmdConf["data_key"] = syncDataKey
+	}
+	if len(syncWorkDir) != 0 {
+		cmdConf["work_dir"] = syncWorkDir
+	}
+	if len(syncPlatform) != 0 {
+		cmdConf["platform"] = syncPlatform
+	}
+	if syncVer != 0 {
+		cmdConf["version"] = syncVer
+	}
+	return cmdConf
+}
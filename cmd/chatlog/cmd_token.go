@@ -0,0 +1,157 @@
+package chatlog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+)
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.PersistentFlags().StringVarP(&tokenConfigDir, "config-dir", "c", "", "config dir (default ~/.chatlog or $CHATLOG_DIR)")
+	tokenCmd.PersistentFlags().BoolVar(&tokenTUI, "tui", false, "edit chatlog.json used by the TUI instead of chatlog-server.json")
+
+	tokenCreateCmd.Flags().StringVarP(&tokenName, "name", "n", "", "token name")
+	tokenCreateCmd.Flags().StringSliceVarP(&tokenScopes, "scope", "s", nil, "scopes: "+strings.Join(conf.Scopes, ", ")+", all")
+	tokenCreateCmd.Flags().DurationVarP(&tokenExpires, "expires", "e", 0, "token lifetime, e.g. 720h (default never)")
+	tokenCmd.AddCommand(tokenCreateCmd, tokenListCmd, tokenRevokeCmd)
+}
+
+var (
+	tokenConfigDir string
+	tokenTUI       bool
+	tokenName      string
+	tokenScopes    []string
+	tokenExpires   time.Duration
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage API tokens for the HTTP and MCP server",
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create --name NAME --scope read-chatlog,mcp [--expires 720h]",
+	Short: "Mint a new API token",
+	Run: func(cmd *cobra.Command, args []string) {
+		auth, cm, err := conf.LoadAuthConfig(tokenConfigDir, tokenTUI)
+		if err != nil {
+			log.Err(err).Msg("failed to load config")
+			return
+		}
+
+		name := strings.TrimSpace(tokenName)
+		if name == "" {
+			log.Error().Msg("token name is required")
+			return
+		}
+		for _, t := range auth.Tokens {
+			if t != nil && t.Name == name {
+				log.Error().Msgf("token %q already exists", name)
+				return
+			}
+		}
+
+		raw, token, err := conf.NewAPIToken(name, tokenScopes, tokenExpires)
+		if err != nil {
+			log.Err(err).Msg("failed to create token")
+			return
+		}
+		auth.Tokens = append(auth.Tokens, token)
+		if err := cm.SetConfig("auth", auth); err != nil {
+			log.Err(err).Msg("failed to save config")
+			return
+		}
+
+		fmt.Printf("id:     %s\n", token.ID)
+		fmt.Printf("name:   %s\n", token.Name)
+		fmt.Printf("scopes: %s\n", strings.Join(token.Scopes, ","))
+		if token.ExpiresAt > 0 {
+			fmt.Printf("expires: %s\n", time.Unix(token.ExpiresAt, 0).Format(time.DateTime))
+		}
+		fmt.Printf("token:  %s\n\n", raw)
+		fmt.Println("The token is shown only once. Restart the server to apply the change.")
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API tokens",
+	Run: func(cmd *cobra.Command, args []string) {
+		auth, _, err := conf.LoadAuthConfig(tokenConfigDir, tokenTUI)
+		if err != nil {
+			log.Err(err).Msg("failed to load config")
+			return
+		}
+		if len(auth.Tokens) == 0 {
+			fmt.Println("no tokens configured, authentication is disabled")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED\tEXPIRES")
+		for _, t := range auth.Tokens {
+			if t == nil {
+				continue
+			}
+			expires := "never"
+			if t.ExpiresAt > 0 {
+				expires = time.Unix(t.ExpiresAt, 0).Format(time.DateTime)
+				if time.Now().Unix() >= t.ExpiresAt {
+					expires += " (expired)"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, strings.Join(t.Scopes, ","),
+				time.Unix(t.CreatedAt, 0).Format(time.DateTime), expires)
+		}
+		w.Flush()
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <id|name>",
+	Short: "Revoke an API token",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		auth, cm, err := conf.LoadAuthConfig(tokenConfigDir, tokenTUI)
+		if err != nil {
+			log.Err(err).Msg("failed to load config")
+			return
+		}
+
+		target := strings.TrimSpace(args[0])
+		kept := make([]*conf.APIToken, 0, len(auth.Tokens))
+		revoked := 0
+		for _, t := range auth.Tokens {
+			if t == nil {
+				continue
+			}
+			if t.ID == target || t.Name == target {
+				revoked++
+				continue
+			}
+			kept = append(kept, t)
+		}
+		if revoked == 0 {
+			log.Error().Msgf("token %q not found", target)
+			return
+		}
+
+		auth.Tokens = kept
+		if err := cm.SetConfig("auth", auth); err != nil {
+			log.Err(err).Msg("failed to save config")
+			return
+		}
+		fmt.Printf("revoked %d token(s). Restart the server to apply the change.\n", revoked)
+		if len(kept) == 0 {
+			fmt.Println("no tokens left, authentication is now disabled")
+		}
+	},
+}
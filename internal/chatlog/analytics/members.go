@@ -0,0 +1,266 @@
+package analytics
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// DefaultMemberWindowDays 是未指定时间范围时统计的天数
+const DefaultMemberWindowDays = 30
+
+// 成员变动类型
+const (
+	EventJoin  = "join"
+	EventLeave = "leave"
+)
+
+// MemberEvent 是从系统消息中识别出的入群/退群事件
+type MemberEvent struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	UserName string    `json:"username,omitempty"` // 无法对应到成员时为空
+	Name     string    `json:"name"`
+	Content  string    `json:"content"`
+}
+
+// MemberActivity 是一个群成员在时间范围内的发言统计
+type MemberActivity struct {
+	UserName     string     `json:"username"`
+	DisplayName  string     `json:"display_name,omitempty"`
+	IsMember     bool       `json:"is_member"` // 仍在当前成员列表中
+	IsOwner      bool       `json:"is_owner,omitempty"`
+	Messages     int        `json:"messages"`
+	Media        int        `json:"media"` // 图片、视频、语音、表情与文件
+	FirstMessage *time.Time `json:"first_message,omitempty"`
+	LastMessage  *time.Time `json:"last_message,omitempty"`
+	Silent       bool       `json:"silent"` // 时间范围内没有发言
+	JoinedAt     *time.Time `json:"joined_at,omitempty"`
+	LeftAt       *time.Time `json:"left_at,omitempty"`
+}
+
+// MemberReport 是群成员活跃度报告
+type MemberReport struct {
+	ChatRoom string            `json:"chatroom"`
+	Name     string            `json:"name,omitempty"`
+	Owner    string            `json:"owner,omitempty"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Members  int               `json:"members"` // 当前成员数
+	Active   int               `json:"active"`  // 当前成员中有发言的人数
+	Silent   int               `json:"silent"`  // 当前成员中没有发言的人数
+	Items    []*MemberActivity `json:"items"`
+	Events   []MemberEvent     `json:"events"`
+}
+
+// MemberOptions 控制成员报告的生成
+type MemberOptions struct {
+	Start, End time.Time
+	Self       string            // 当前账号，用于归属自己发送但缺少发送者的消息
+	Nicknames  map[string]string // username -> 联系人昵称，用于匹配系统消息中的昵称，可包含非成员
+}
+
+// Members 把群成员列表与消息关联，统计每个成员的发言，并从系统消息中识别入群与退群。
+// 当前成员排在前面，按消息数从多到少排序；已不在群中但有发言或变动记录的成员也会列出
+func Members(room *model.ChatRoom, msgs []*model.Message, opts MemberOptions) *MemberReport {
+	report := &MemberReport{
+		ChatRoom: room.Name,
+		Name:     room.DisplayName(),
+		Owner:    room.Owner,
+		Start:    opts.Start,
+		End:      opts.End,
+		Members:  len(room.Users),
+		Items:    []*MemberActivity{},
+		Events:   []MemberEvent{},
+	}
+
+	members := make(map[string]*MemberActivity, len(room.Users))
+	get := func(username string) *MemberActivity {
+		m := members[username]
+		if m == nil {
+			m = &MemberActivity{UserName: username, DisplayName: room.User2DisplayName[username]}
+			if m.DisplayName == "" {
+				m.DisplayName = opts.Nicknames[username]
+			}
+			members[username] = m
+		}
+		return m
+	}
+	for _, u := range room.Users {
+		m := get(u.UserName)
+		m.IsMember = true
+		if u.DisplayName != "" {
+			m.DisplayName = u.DisplayName
+		}
+	}
+	if m := members[room.Owner]; m != nil {
+		m.IsOwner = true
+	}
+
+	resolve := newNameResolver(room, opts.Nicknames)
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		if msg.Type == model.MessageTypeSystem {
+			for _, ev := range ParseMemberEvents(msg.Content) {
+				ev.Time = msg.Time
+				if ev.UserName == "" {
+					ev.UserName = resolve(ev.Name)
+				}
+				report.Events = append(report.Events, ev)
+				if ev.UserName == "" {
+					continue
+				}
+				m := get(ev.UserName)
+				t := msg.Time
+				switch ev.Type {
+				case EventJoin:
+					m.JoinedAt = &t
+				case EventLeave:
+					m.LeftAt = &t
+				}
+			}
+			continue
+		}
+
+		sender := msg.Sender
+		if sender == "" && msg.IsSelf {
+			sender = opts.Self
+		}
+		if sender == "" {
+			continue
+		}
+		m := get(sender)
+		m.Messages++
+		if isMedia(msg) {
+			m.Media++
+		}
+		t := msg.Time
+		if m.FirstMessage == nil || t.Before(*m.FirstMessage) {
+			m.FirstMessage = &t
+		}
+		if m.LastMessage == nil || t.After(*m.LastMessage) {
+			m.LastMessage = &t
+		}
+	}
+
+	for _, m := range members {
+		m.Silent = m.Messages == 0
+		if m.IsMember {
+			if m.Silent {
+				report.Silent++
+			} else {
+				report.Active++
+			}
+		}
+		report.Items = append(report.Items, m)
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.IsMember != b.IsMember {
+			return a.IsMember
+		}
+		if a.Messages != b.Messages {
+			return a.Messages > b.Messages
+		}
+		return a.UserName < b.UserName
+	})
+	sort.SliceStable(report.Events, func(i, j int) bool { return report.Events[i].Time.Before(report.Events[j].Time) })
+	return report
+}
+
+func isMedia(msg *model.Message) bool {
+	switch msg.Type {
+	case model.MessageTypeImage, model.MessageTypeVideo, model.MessageTypeVoice, model.MessageTypeAnimation:
+		return true
+	case model.MessageTypeShare:
+		return msg.SubType == model.MessageSubTypeFile
+	}
+	return false
+}
+
+// newNameResolver 按群昵称与联系人昵称把系统消息中的名字对应到 username，名字不唯一时不做对应；
+// nicknames 可以包含已退群的发言者，以便识别他们的退群记录
+func newNameResolver(room *model.ChatRoom, nicknames map[string]string) func(string) string {
+	index := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	add := func(name, username string) {
+		if name == "" || ambiguous[name] {
+			return
+		}
+		if prev, ok := index[name]; ok && prev != username {
+			delete(index, name)
+			ambiguous[name] = true
+			return
+		}
+		index[name] = username
+	}
+	for _, u := range room.Users {
+		add(u.DisplayName, u.UserName)
+	}
+	for username, name := range nicknames {
+		add(name, username)
+	}
+	return func(name string) string {
+		return index[name]
+	}
+}
+
+var (
+	// 模板类系统消息输出为 "昵称(username)"
+	memberWithID = regexp.MustCompile(`^(.*)\(([^()]+)\)$`)
+	quoteTrimmer = strings.NewReplacer("\"", "", "“", "", "”", "")
+)
+
+// ParseMemberEvents 从系统消息文本中识别入群与退群事件，支持以下形式：
+//
+//	"A"邀请"B、C"加入了群聊 / 你邀请"B"加入了群聊
+//	"B"通过扫描"A"分享的二维码加入群聊
+//	"A"将"B"移出了群聊 / 你将"B"移出了群聊
+//	"B"退出了群聊
+//
+// 返回事件的 Time 为空，由调用方填写
+func ParseMemberEvents(content string) []MemberEvent {
+	content = strings.TrimSpace(content)
+	var typ, seg string
+	switch {
+	case strings.Contains(content, "通过扫描") && strings.Contains(content, "加入群聊"):
+		typ, seg = EventJoin, content[:strings.Index(content, "通过扫描")]
+	case strings.Contains(content, "邀请") && strings.Contains(content, "加入"):
+		i := strings.Index(content, "邀请") + len("邀请")
+		j := strings.LastIndex(content, "加入")
+		if j < i {
+			return nil
+		}
+		typ, seg = EventJoin, content[i:j]
+	case strings.Contains(content, "移出"):
+		i := strings.Index(content, "将")
+		j := strings.Index(content, "移出")
+		if i < 0 || j < i {
+			return nil
+		}
+		typ, seg = EventLeave, content[i+len("将"):j]
+	case strings.Contains(content, "退出了群聊"), strings.Contains(content, "退出群聊"):
+		typ, seg = EventLeave, content[:strings.Index(content, "退出")]
+	default:
+		return nil
+	}
+
+	var events []MemberEvent
+	for _, name := range strings.Split(seg, "、") {
+		name = strings.TrimSpace(quoteTrimmer.Replace(name))
+		if name == "" || name == "你" {
+			continue
+		}
+		ev := MemberEvent{Type: typ, Name: name, Content: content}
+		if m := memberWithID.FindStringSubmatch(name); m != nil {
+			ev.Name, ev.UserName = m[1], m[2]
+		}
+		events = append(events, ev)
+	}
+	return events
+}
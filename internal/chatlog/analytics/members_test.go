@@ -0,0 +1,94 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+func TestParseMemberEvents(t *testing.T) {
+	cases := []struct {
+		content string
+		typ     string
+		names   []string
+		ids     []string
+	}{
+		{`"张三"邀请"李四、王五"加入了群聊`, EventJoin, []string{"李四", "王五"}, []string{"", ""}},
+		{`你邀请“李四”加入了群聊`, EventJoin, []string{"李四"}, []string{""}},
+		{`"赵六"通过扫描"张三"分享的二维码加入群聊`, EventJoin, []string{"赵六"}, []string{""}},
+		{`你将"王五"移出了群聊`, EventLeave, []string{"王五"}, []string{""}},
+		{`"李四"退出了群聊`, EventLeave, []string{"李四"}, []string{""}},
+		{`"张三(wxid_a)"邀请"李四(wxid_b)、王五(wxid_c)"加入了群聊`, EventJoin, []string{"李四", "王五"}, []string{"wxid_b", "wxid_c"}},
+	}
+	for _, tc := range cases {
+		events := ParseMemberEvents(tc.content)
+		if len(events) != len(tc.names) {
+			t.Fatalf("%s: events = %+v", tc.content, events)
+		}
+		for i, ev := range events {
+			if ev.Type != tc.typ || ev.Name != tc.names[i] || ev.UserName != tc.ids[i] {
+				t.Fatalf("%s: event %d = %+v", tc.content, i, ev)
+			}
+		}
+	}
+	if events := ParseMemberEvents(`"张三"修改群名为"周末爬山"`); len(events) != 0 {
+		t.Fatalf("unexpected events = %+v", events)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	room := &model.ChatRoom{
+		Name:  "g@chatroom",
+		Owner: "alice",
+		Users: []model.ChatRoomUser{
+			{UserName: "alice", DisplayName: "群主"},
+			{UserName: "bob"},
+			{UserName: "me"},
+			{UserName: "carol"},
+		},
+		User2DisplayName: map[string]string{"alice": "群主"},
+	}
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+	msgs := []*model.Message{
+		{Time: base, Sender: "alice", Type: model.MessageTypeText},
+		{Time: base.Add(time.Hour), Sender: "alice", Type: model.MessageTypeImage},
+		{Time: base.Add(2 * time.Hour), IsSelf: true, Type: model.MessageTypeShare, SubType: model.MessageSubTypeFile},
+		{Time: base.Add(3 * time.Hour), Sender: "dave", Type: model.MessageTypeText},
+		{Time: base.Add(4 * time.Hour), Sender: "系统消息", Type: model.MessageTypeSystem, Content: `"群主"邀请"小鲍"加入了群聊`},
+		{Time: base.Add(5 * time.Hour), Sender: "系统消息", Type: model.MessageTypeSystem, Content: `"dave"退出了群聊`},
+	}
+
+	report := Members(room, msgs, MemberOptions{Self: "me", Nicknames: map[string]string{"bob": "小鲍", "dave": "dave"}})
+	if report.Members != 4 || report.Active != 2 || report.Silent != 2 {
+		t.Fatalf("counts = %d/%d/%d", report.Members, report.Active, report.Silent)
+	}
+
+	byName := map[string]*MemberActivity{}
+	for _, m := range report.Items {
+		byName[m.UserName] = m
+	}
+	if report.Items[0].UserName != "alice" || report.Items[len(report.Items)-1].UserName != "dave" {
+		t.Fatalf("order = %+v", report.Items)
+	}
+	alice := byName["alice"]
+	if !alice.IsOwner || alice.Messages != 2 || alice.Media != 1 || !alice.FirstMessage.Equal(base) || !alice.LastMessage.Equal(base.Add(time.Hour)) {
+		t.Fatalf("alice = %+v", alice)
+	}
+	if me := byName["me"]; me.Messages != 1 || me.Media != 1 {
+		t.Fatalf("me = %+v", me)
+	}
+	if bob := byName["bob"]; !bob.Silent || bob.JoinedAt == nil || !bob.JoinedAt.Equal(base.Add(4*time.Hour)) {
+		t.Fatalf("bob = %+v", bob)
+	}
+	if carol := byName["carol"]; !carol.Silent || carol.FirstMessage != nil {
+		t.Fatalf("carol = %+v", carol)
+	}
+	// dave 已不在成员列表中，但作为发言者提供了昵称，退群记录仍能对应
+	if dave := byName["dave"]; dave.IsMember || dave.Messages != 1 || dave.LeftAt == nil {
+		t.Fatalf("dave = %+v", dave)
+	}
+	if len(report.Events) != 2 || report.Events[0].UserName != "bob" || report.Events[1].Type != EventLeave || report.Events[1].UserName != "dave" {
+		t.Fatalf("events = %+v", report.Events)
+	}
+}
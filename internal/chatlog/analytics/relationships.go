@@ -0,0 +1,385 @@
+package analytics
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// 亲密度评分（0~100）由五项归一化到 [0,1] 的指标加权求和：
+//
+//	recent      35%  ln(1+近90天消息数) / ln(1+所有联系人中的最大值)
+//	volume      25%  ln(1+总消息数) / ln(1+所有联系人中的最大值)
+//	regularity  15%  活跃天数 / 首末消息之间的天数，上限 1
+//	balance     15%  2*min(发送,接收) / (发送+接收)，双方发言越均衡越高
+//	active      10%  近7天我发送的消息数 / 7，上限 1
+//
+// 前两项取对数，避免个别高频会话把其他联系人的分数压到接近 0。
+// 评分只依赖 IntimacyBase，各数据源对其字段的定义一致：发送即该数据源中 IsSelf 的消息
+// （windowsv3 IsSender=1，darwinv3 mesDes=0，v4 status=2 或发送者不是对方），
+// 近90天/近7天相对于数据集中最新一条消息计算。各数据源的 intimacy_test.go 用同一组数据校验
+const (
+	WeightRecent     = 0.35
+	WeightVolume     = 0.25
+	WeightRegularity = 0.15
+	WeightBalance    = 0.15
+	WeightActive     = 0.10
+)
+
+const (
+	// Quarter 是趋势比较的窗口长度：本季度为参考时间前 90 天，上季度为再往前 90 天
+	Quarter = 90 * 24 * time.Hour
+	// DefaultConversationGap 是划分对话的间隔：超过该时长没有消息，下一条消息视为新对话的开始
+	DefaultConversationGap = 6 * time.Hour
+	// DefaultLimit 与 MaxLimit 限制返回（并逐条分析消息）的联系人数量
+	DefaultLimit = 20
+	MaxLimit     = 100
+
+	// 消息数变化超过该比例才视为上升或下降
+	trendThreshold = 0.2
+)
+
+// 趋势方向
+const (
+	TrendUp       = "up"       // 比上季度多
+	TrendDown     = "down"     // 比上季度少
+	TrendFlat     = "flat"     // 变化不超过 20%
+	TrendNew      = "new"      // 上季度没有消息
+	TrendInactive = "inactive" // 本季度没有消息
+)
+
+// SkipUserNames 是不参与关系分析的系统账号
+var SkipUserNames = map[string]struct{}{
+	"filehelper":    {},
+	"weixin":        {},
+	"notifymessage": {},
+	"fmessage":      {},
+}
+
+// Source 是关系分析所需的只读数据接口，由 *wechatdb.DB 实现
+type Source interface {
+	IntimacyBase() (map[string]*model.IntimacyBase, error)
+	GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
+}
+
+// Options 控制关系分析
+type Options struct {
+	Limit           int                 // 返回的联系人数，默认 DefaultLimit，最大 MaxLimit
+	Exclude         map[string]struct{} // 额外排除的 username（例如当前账号自己）
+	ConversationGap time.Duration       // 对话划分间隔，默认 DefaultConversationGap
+}
+
+// ScoreComponents 是评分各项指标（归一化到 [0,1]）
+type ScoreComponents struct {
+	Recent     float64 `json:"recent"`
+	Volume     float64 `json:"volume"`
+	Regularity float64 `json:"regularity"`
+	Balance    float64 `json:"balance"`
+	Active     float64 `json:"active"`
+}
+
+// Trend 比较本季度与上季度的消息数
+type Trend struct {
+	Current   int     `json:"current"`
+	Previous  int     `json:"previous"`
+	Change    float64 `json:"change"` // (本季度-上季度)/上季度，上季度为 0 时为 0
+	Direction string  `json:"direction"`
+}
+
+// ReplyLatency 是同一对话内回复间隔的中位数（秒），样本不足时为 0
+type ReplyLatency struct {
+	MyMedian     float64 `json:"my_median_seconds"`
+	TheirMedian  float64 `json:"their_median_seconds"`
+	MyReplies    int     `json:"my_replies"`
+	TheirReplies int     `json:"their_replies"`
+}
+
+// Initiator 统计由谁开启对话
+type Initiator struct {
+	Conversations int     `json:"conversations"`
+	ByMe          int     `json:"by_me"`
+	Ratio         float64 `json:"ratio"` // 由我开启的对话占比
+}
+
+// Relationship 是一个联系人的分析结果
+type Relationship struct {
+	Rank          int             `json:"rank"`
+	UserName      string          `json:"username"`
+	Name          string          `json:"name,omitempty"`
+	Score         float64         `json:"score"`
+	Components    ScoreComponents `json:"components"`
+	MsgCount      int64           `json:"msg_count"`
+	SentCount     int64           `json:"sent_count"`
+	ReceivedCount int64           `json:"received_count"`
+	MessagingDays int64           `json:"messaging_days"`
+	FirstMessage  time.Time       `json:"first_message"`
+	LastMessage   time.Time       `json:"last_message"`
+	Trend         Trend           `json:"trend"`
+	ReplyLatency  ReplyLatency    `json:"reply_latency"`
+	Initiator     Initiator       `json:"initiator"`
+}
+
+// Report 是关系分析的结果
+type Report struct {
+	Reference     time.Time       `json:"reference"`      // 参考时间：数据集中最新一条联系人消息
+	QuarterStart  time.Time       `json:"quarter_start"`  // 本季度起点
+	PreviousStart time.Time       `json:"previous_start"` // 上季度起点
+	Candidates    int             `json:"candidates"`     // 参与评分的联系人总数
+	Items         []*Relationship `json:"items"`
+	More          []string        `json:"more,omitempty"` // 比上季度聊得多的联系人，按变化幅度排序
+	Less          []string        `json:"less,omitempty"` // 比上季度聊得少的联系人，按变化幅度排序
+}
+
+// Relationships 按亲密度评分排序联系人，并为排名靠前的联系人计算季度趋势、回复间隔中位数与对话发起比例。
+// 后三项基于 GetMessages 返回的统一消息模型计算，与数据源实现无关
+func Relationships(ctx context.Context, src Source, opts Options) (*Report, error) {
+	bases, err := src.IntimacyBase()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultLimit
+	}
+	if opts.Limit > MaxLimit {
+		opts.Limit = MaxLimit
+	}
+	if opts.ConversationGap <= 0 {
+		opts.ConversationGap = DefaultConversationGap
+	}
+
+	items := Rank(bases, opts.Exclude)
+	report := &Report{Candidates: len(items)}
+	if len(items) == 0 {
+		report.Items = items
+		return report, nil
+	}
+	if len(items) > opts.Limit {
+		items = items[:opts.Limit]
+	}
+
+	var ref int64
+	for _, b := range bases {
+		if b != nil && b.MaxCreateUnix > ref {
+			ref = b.MaxCreateUnix
+		}
+	}
+	report.Reference = time.Unix(ref, 0)
+	report.QuarterStart = report.Reference.Add(-Quarter)
+	report.PreviousStart = report.QuarterStart.Add(-Quarter)
+
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		msgs, err := src.GetMessages(report.PreviousStart, report.Reference.Add(time.Second), item.UserName, "", "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		item.Trend, item.ReplyLatency, item.Initiator = analyze(msgs, report.QuarterStart, opts.ConversationGap)
+	}
+
+	report.Items = items
+	report.More, report.Less = movers(items)
+	return report, nil
+}
+
+// Rank 计算全部联系人的评分并按分数从高到低排序；群聊、系统账号与 exclude 中的账号不参与
+func Rank(bases map[string]*model.IntimacyBase, exclude map[string]struct{}) []*Relationship {
+	var maxRecent, maxVolume int64
+	names := make([]string, 0, len(bases))
+	for name, b := range bases {
+		if b == nil || name == "" || b.MsgCount <= 0 || strings.HasSuffix(name, "@chatroom") {
+			continue
+		}
+		if _, skip := SkipUserNames[name]; skip {
+			continue
+		}
+		if _, skip := exclude[name]; skip {
+			continue
+		}
+		names = append(names, name)
+		maxRecent = max(maxRecent, b.Last90DaysMsg)
+		maxVolume = max(maxVolume, b.MsgCount)
+	}
+
+	items := make([]*Relationship, 0, len(names))
+	for _, name := range names {
+		b := bases[name]
+		comp := Score(b, maxRecent, maxVolume)
+		items = append(items, &Relationship{
+			UserName:      name,
+			Score:         total(comp),
+			Components:    comp,
+			MsgCount:      b.MsgCount,
+			SentCount:     b.SentCount,
+			ReceivedCount: b.ReceivedCount,
+			MessagingDays: b.MessagingDays,
+			FirstMessage:  time.Unix(b.MinCreateUnix, 0),
+			LastMessage:   time.Unix(b.MaxCreateUnix, 0),
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Score != items[j].Score {
+			return items[i].Score > items[j].Score
+		}
+		if items[i].MsgCount != items[j].MsgCount {
+			return items[i].MsgCount > items[j].MsgCount
+		}
+		return items[i].UserName < items[j].UserName
+	})
+	for i, item := range items {
+		item.Rank = i + 1
+	}
+	return items
+}
+
+// Score 计算单个联系人的评分指标，maxRecent/maxVolume 为参与评分的联系人中的最大值
+func Score(b *model.IntimacyBase, maxRecent, maxVolume int64) ScoreComponents {
+	var c ScoreComponents
+	c.Recent = logRatio(b.Last90DaysMsg, maxRecent)
+	c.Volume = logRatio(b.MsgCount, maxVolume)
+	if b.MaxCreateUnix >= b.MinCreateUnix && b.MinCreateUnix > 0 {
+		span := (b.MaxCreateUnix-b.MinCreateUnix)/86400 + 1
+		c.Regularity = math.Min(1, float64(b.MessagingDays)/float64(span))
+	}
+	if sum := b.SentCount + b.ReceivedCount; sum > 0 {
+		c.Balance = 2 * float64(min(b.SentCount, b.ReceivedCount)) / float64(sum)
+	}
+	c.Active = math.Min(1, float64(b.Past7DaysSentMsg)/7)
+	return c
+}
+
+func total(c ScoreComponents) float64 {
+	s := WeightRecent*c.Recent + WeightVolume*c.Volume + WeightRegularity*c.Regularity +
+		WeightBalance*c.Balance + WeightActive*c.Active
+	return round2(100 * s)
+}
+
+func logRatio(v, maxV int64) float64 {
+	if v <= 0 || maxV <= 0 {
+		return 0
+	}
+	return math.Log1p(float64(v)) / math.Log1p(float64(maxV))
+}
+
+// analyze 基于消息序列计算季度趋势、回复间隔与对话发起比例；
+// 相邻两条消息的间隔不超过 gap 时属于同一对话，发送方切换即视为一次回复
+func analyze(msgs []*model.Message, quarterStart time.Time, gap time.Duration) (Trend, ReplyLatency, Initiator) {
+	var trend Trend
+	var latency ReplyLatency
+	var init Initiator
+
+	sorted := make([]*model.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if m != nil {
+			sorted = append(sorted, m)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Time.Equal(sorted[j].Time) {
+			return sorted[i].Time.Before(sorted[j].Time)
+		}
+		return sorted[i].Seq < sorted[j].Seq
+	})
+
+	var mine, theirs []float64
+	var prev *model.Message
+	for _, m := range sorted {
+		if m.Time.Before(quarterStart) {
+			trend.Previous++
+		} else {
+			trend.Current++
+		}
+
+		if prev == nil || m.Time.Sub(prev.Time) > gap {
+			init.Conversations++
+			if m.IsSelf {
+				init.ByMe++
+			}
+		} else if m.IsSelf != prev.IsSelf {
+			d := m.Time.Sub(prev.Time).Seconds()
+			if m.IsSelf {
+				mine = append(mine, d)
+			} else {
+				theirs = append(theirs, d)
+			}
+		}
+		prev = m
+	}
+
+	switch {
+	case trend.Previous == 0 && trend.Current > 0:
+		trend.Direction = TrendNew
+	case trend.Current == 0 && trend.Previous > 0:
+		trend.Direction = TrendInactive
+		trend.Change = -1
+	case trend.Previous == 0:
+		trend.Direction = TrendFlat
+	default:
+		trend.Change = round2(float64(trend.Current-trend.Previous) / float64(trend.Previous))
+		switch {
+		case trend.Change > trendThreshold:
+			trend.Direction = TrendUp
+		case trend.Change < -trendThreshold:
+			trend.Direction = TrendDown
+		default:
+			trend.Direction = TrendFlat
+		}
+	}
+
+	latency.MyReplies, latency.TheirReplies = len(mine), len(theirs)
+	latency.MyMedian, latency.TheirMedian = median(mine), median(theirs)
+	if init.Conversations > 0 {
+		init.Ratio = round2(float64(init.ByMe) / float64(init.Conversations))
+	}
+	return trend, latency, init
+}
+
+// movers 挑出比上季度聊得多与聊得少的联系人，按消息数变化量从大到小排序
+func movers(items []*Relationship) (more, less []string) {
+	var up, down []*Relationship
+	for _, item := range items {
+		switch item.Trend.Direction {
+		case TrendUp, TrendNew:
+			up = append(up, item)
+		case TrendDown, TrendInactive:
+			down = append(down, item)
+		}
+	}
+	delta := func(r *Relationship) int {
+		d := r.Trend.Current - r.Trend.Previous
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	sort.SliceStable(up, func(i, j int) bool { return delta(up[i]) > delta(up[j]) })
+	sort.SliceStable(down, func(i, j int) bool { return delta(down[i]) > delta(down[j]) })
+	for _, r := range up {
+		more = append(more, r.UserName)
+	}
+	for _, r := range down {
+		less = append(less, r.UserName)
+	}
+	return more, less
+}
+
+func median(v []float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	sort.Float64s(v)
+	n := len(v)
+	if n%2 == 1 {
+		return v[n/2]
+	}
+	return (v[n/2-1] + v[n/2]) / 2
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,132 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+type fakeSource struct {
+	bases map[string]*model.IntimacyBase
+	msgs  map[string][]*model.Message
+}
+
+func (f *fakeSource) IntimacyBase() (map[string]*model.IntimacyBase, error) {
+	return f.bases, nil
+}
+
+func (f *fakeSource) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	var out []*model.Message
+	for _, m := range f.msgs[talker] {
+		if !m.Time.Before(start) && m.Time.Before(end) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func TestScore(t *testing.T) {
+	b := &model.IntimacyBase{
+		MsgCount: 100, SentCount: 50, ReceivedCount: 50,
+		MinCreateUnix: 1_700_000_000, MaxCreateUnix: 1_700_000_000 + 9*86400,
+		MessagingDays: 10, Last90DaysMsg: 100, Past7DaysSentMsg: 7,
+	}
+	c := Score(b, 100, 100)
+	want := ScoreComponents{Recent: 1, Volume: 1, Regularity: 1, Balance: 1, Active: 1}
+	if c != want {
+		t.Fatalf("components = %+v, want %+v", c, want)
+	}
+	if s := total(c); s != 100 {
+		t.Fatalf("score = %v, want 100", s)
+	}
+
+	// 单向消息、活跃天数超过跨度（多个分库重复计数）时各项仍在 [0,1]
+	b = &model.IntimacyBase{MsgCount: 10, SentCount: 10, MinCreateUnix: 1_700_000_000, MaxCreateUnix: 1_700_000_000, MessagingDays: 3}
+	c = Score(b, 100, 100)
+	if c.Balance != 0 || c.Regularity != 1 || c.Recent != 0 || c.Active != 0 {
+		t.Fatalf("components = %+v", c)
+	}
+}
+
+func TestRank(t *testing.T) {
+	bases := map[string]*model.IntimacyBase{
+		"close":        {UserName: "close", MsgCount: 500, SentCount: 250, ReceivedCount: 250, MinCreateUnix: 1, MaxCreateUnix: 86400 * 30, MessagingDays: 25, Last90DaysMsg: 300, Past7DaysSentMsg: 20},
+		"distant":      {UserName: "distant", MsgCount: 600, SentCount: 10, ReceivedCount: 590, MinCreateUnix: 1, MaxCreateUnix: 86400 * 300, MessagingDays: 5, Last90DaysMsg: 0},
+		"me":           {UserName: "me", MsgCount: 1000},
+		"filehelper":   {UserName: "filehelper", MsgCount: 1000},
+		"123@chatroom": {UserName: "123@chatroom", MsgCount: 1000},
+	}
+	items := Rank(bases, map[string]struct{}{"me": {}})
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	if items[0].UserName != "close" || items[0].Rank != 1 || items[1].Rank != 2 {
+		t.Fatalf("unexpected order: %s(%v) %s(%v)", items[0].UserName, items[0].Score, items[1].UserName, items[1].Score)
+	}
+}
+
+func TestRelationships(t *testing.T) {
+	ref := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	msg := func(at time.Time, self bool) *model.Message {
+		return &model.Message{Time: at, IsSelf: self}
+	}
+
+	// alice：本季度两次对话，一次由我发起（她 60s、我 120s 回复），一次由她发起（我 30s 回复）
+	day := ref.Add(-10 * 24 * time.Hour)
+	alice := []*model.Message{
+		msg(day, true),
+		msg(day.Add(60*time.Second), false),
+		msg(day.Add(180*time.Second), true),
+		msg(day.Add(24*time.Hour), false),
+		msg(day.Add(24*time.Hour+30*time.Second), true),
+		msg(day.Add(24*time.Hour+40*time.Second), true),
+	}
+	// bob：上季度 10 条，本季度 1 条
+	var bob []*model.Message
+	for i := 0; i < 10; i++ {
+		bob = append(bob, msg(ref.Add(-120*24*time.Hour+time.Duration(i)*time.Minute), i%2 == 0))
+	}
+	bob = append(bob, msg(ref, false))
+
+	src := &fakeSource{
+		bases: map[string]*model.IntimacyBase{
+			"alice": {MsgCount: 6, SentCount: 4, ReceivedCount: 2, MinCreateUnix: day.Unix(), MaxCreateUnix: day.Add(24 * time.Hour).Unix(), MessagingDays: 2, Last90DaysMsg: 6, Past7DaysSentMsg: 0},
+			"bob":   {MsgCount: 11, SentCount: 5, ReceivedCount: 6, MinCreateUnix: bob[0].Time.Unix(), MaxCreateUnix: ref.Unix(), MessagingDays: 2, Last90DaysMsg: 1},
+		},
+		msgs: map[string][]*model.Message{"alice": alice, "bob": bob},
+	}
+
+	report, err := Relationships(context.Background(), src, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Reference.Equal(ref) || report.Candidates != 2 || len(report.Items) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	byName := map[string]*Relationship{}
+	for _, item := range report.Items {
+		byName[item.UserName] = item
+	}
+
+	a := byName["alice"]
+	if a.Trend.Direction != TrendNew || a.Trend.Current != 6 || a.Trend.Previous != 0 {
+		t.Fatalf("alice trend = %+v", a.Trend)
+	}
+	if a.Initiator.Conversations != 2 || a.Initiator.ByMe != 1 || a.Initiator.Ratio != 0.5 {
+		t.Fatalf("alice initiator = %+v", a.Initiator)
+	}
+	if a.ReplyLatency.MyReplies != 2 || a.ReplyLatency.MyMedian != 75 || a.ReplyLatency.TheirReplies != 1 || a.ReplyLatency.TheirMedian != 60 {
+		t.Fatalf("alice latency = %+v", a.ReplyLatency)
+	}
+
+	b := byName["bob"]
+	if b.Trend.Direction != TrendDown || b.Trend.Current != 1 || b.Trend.Previous != 10 || b.Trend.Change != -0.9 {
+		t.Fatalf("bob trend = %+v", b.Trend)
+	}
+	if len(report.More) != 1 || report.More[0] != "alice" || len(report.Less) != 1 || report.Less[0] != "bob" {
+		t.Fatalf("movers = %v / %v", report.More, report.Less)
+	}
+}
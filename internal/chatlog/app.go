@@ -229,7 +229,11 @@ func (a *App) refresh() {
 				a.infoBar.UpdateHTTPServer("[未启动]")
 			}
 			if a.ctx.AutoDecrypt {
-				a.infoBar.UpdateAutoDecrypt("[green][已开启][white]")
+				text := "[green][已开启][white]"
+				if pass := a.m.LastDecryptPass(); pass != nil {
+					text += fmt.Sprintf(" [%s %s: %d/%d 页]", pass.Time.Format("15:04:05"), pass.File, pass.ChangedPages, pass.TotalPages)
+				}
+				a.infoBar.UpdateAutoDecrypt(text)
 			} else {
 				a.infoBar.UpdateAutoDecrypt("[未开启]")
 			}
@@ -593,6 +597,8 @@ func (a *App) refreshSettingsMenu() {
 			providerLabel = "OpenAI 官方服务"
 		case "whispercpp":
 			providerLabel = "Whisper.cpp 本地模型"
+		case "replay":
+			providerLabel = "离线回放 (fixture)"
 		default:
 			providerLabel = speechCfg.Provider
 		}
@@ -609,7 +615,7 @@ func (a *App) refreshSettingsMenu() {
 			if trimmed != "" {
 				current = trimmed
 			}
-			if strings.ToLower(strings.TrimSpace(speechCfg.Provider)) != "whispercpp" {
+			if p := strings.ToLower(strings.TrimSpace(speechCfg.Provider)); p != "whispercpp" && p != "replay" {
 				current = current + " (当前提供商未启用)"
 			}
 		}
@@ -0,0 +1,169 @@
+package conf
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// AccountConfig 是服务模式下额外挂载的账号。每个账号指向一个已解密的工作目录，
+// 使用独立的数据库连接、全文索引和 webhook；鉴权、语音转写和总结沿用服务端配置。
+// 图片密钥不写在配置中，启动时按 DataDir 从密钥库读取。
+type AccountConfig struct {
+	// ID 出现在路由 /api/v1/accounts/:id 与 MCP 工具的 account 参数中，默认取工作目录名
+	ID       string `mapstructure:"id" json:"id"`
+	Name     string `mapstructure:"name" json:"name"`
+	Platform string `mapstructure:"platform" json:"platform"`
+	Version  int    `mapstructure:"version" json:"version"`
+	DataDir  string `mapstructure:"data_dir" json:"data_dir"`
+	WorkDir  string `mapstructure:"work_dir" json:"work_dir"`
+
+	Webhook *Webhook `mapstructure:"webhook" json:"webhook"`
+
+	// Search 为空时沿用服务端的全文检索配置
+	Search *SearchConfig `mapstructure:"search" json:"search"`
+
+	parent *ServerConfig
+	imgKey string
+}
+
+var accountIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// GetAccounts 返回补全默认值后的额外账号，ID 缺失、重复或含有非法字符时返回错误
+func (c *ServerConfig) GetAccounts() ([]*AccountConfig, error) {
+	seen := make(map[string]bool, len(c.Accounts))
+	accounts := make([]*AccountConfig, 0, len(c.Accounts))
+	for i, a := range c.Accounts {
+		if a == nil {
+			continue
+		}
+		a.parent = c
+		a.WorkDir = strings.TrimSpace(a.WorkDir)
+		a.DataDir = strings.TrimSpace(a.DataDir)
+		if a.WorkDir == "" {
+			return nil, fmt.Errorf("accounts[%d]: work_dir is required", i)
+		}
+		if a.ID == "" {
+			a.ID = filepath.Base(filepath.Clean(a.WorkDir))
+		}
+		if !accountIDPattern.MatchString(a.ID) {
+			return nil, fmt.Errorf("accounts[%d]: invalid id %q", i, a.ID)
+		}
+		if seen[a.ID] {
+			return nil, fmt.Errorf("accounts[%d]: duplicate id %q", i, a.ID)
+		}
+		seen[a.ID] = true
+		if a.Platform == "" {
+			a.Platform = c.Platform
+		}
+		if a.Version == 0 {
+			a.Version = c.Version
+		}
+		if a.Name == "" {
+			a.Name = a.ID
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, nil
+}
+
+func (a *AccountConfig) GetDataDir() string {
+	return a.DataDir
+}
+
+func (a *AccountConfig) GetWorkDir() string {
+	return a.WorkDir
+}
+
+func (a *AccountConfig) GetPlatform() string {
+	return a.Platform
+}
+
+func (a *AccountConfig) GetVersion() int {
+	return a.Version
+}
+
+// GetSnapshot 返回空：额外账号的工作目录按普通方式打开
+func (a *AccountConfig) GetSnapshot() string {
+	return ""
+}
+
+// GetDataKey 返回空：额外账号只挂载已解密的工作目录
+func (a *AccountConfig) GetDataKey() string {
+	return ""
+}
+
+// GetImgKey 返回从密钥库读取的图片密钥，密钥库中没有该账号时返回空
+func (a *AccountConfig) GetImgKey() string {
+	return a.imgKey
+}
+
+func (a *AccountConfig) GetHTTPAddr() string {
+	if a.parent == nil {
+		return DefalutHTTPAddr
+	}
+	return a.parent.GetHTTPAddr()
+}
+
+func (a *AccountConfig) GetWebhook() *Webhook {
+	return a.Webhook
+}
+
+func (a *AccountConfig) GetSearch() *SearchConfig {
+	if a.Search == nil && a.parent != nil {
+		return a.parent.Search
+	}
+	return a.Search
+}
+
+// GetMirror 返回空：SQL 镜像只服务主账号
+func (a *AccountConfig) GetMirror() *MirrorConfig {
+	return nil
+}
+
+// GetDigest 返回空：定时摘要只服务主账号
+func (a *AccountConfig) GetDigest() *DigestConfig {
+	return nil
+}
+
+func (a *AccountConfig) GetSpeech() *SpeechConfig {
+	if a.parent == nil {
+		return nil
+	}
+	return a.parent.Speech
+}
+
+func (a *AccountConfig) GetAuth() *AuthConfig {
+	if a.parent == nil {
+		return nil
+	}
+	return a.parent.Auth
+}
+
+func (a *AccountConfig) GetSummarize() *SummarizeConfig {
+	if a.parent == nil {
+		return nil
+	}
+	return a.parent.Summarize
+}
+
+// 额外账号的目录与密钥在运行期间不可修改，以下 Set 方法均为空操作
+
+func (a *AccountConfig) SetHTTPAddr(string) {}
+
+func (a *AccountConfig) SetDataDir(string) {}
+
+func (a *AccountConfig) SetWorkDir(string) {}
+
+func (a *AccountConfig) SetDataKey(string) {}
+
+func (a *AccountConfig) SetImgKey(string) {}
+
+func (a *AccountConfig) IsHTTPEnabled() bool {
+	return true
+}
+
+func (a *AccountConfig) IsAutoDecrypt() bool {
+	return false
+}
@@ -0,0 +1,156 @@
+package conf
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// API token scopes.
+const (
+	ScopeReadChatlog  = "read-chatlog"
+	ScopeReadMedia    = "read-media"
+	ScopeAdminActions = "admin-actions"
+	ScopeMCP          = "mcp"
+	ScopeSummarize    = "summarize"
+	ScopeAll          = "*"
+
+	tokenPrefix = "chatlog_"
+)
+
+var Scopes = []string{ScopeReadChatlog, ScopeReadMedia, ScopeAdminActions, ScopeMCP, ScopeSummarize}
+
+// AuthConfig holds the API tokens accepted by the HTTP and MCP server.
+// Authentication is disabled while no token is configured.
+type AuthConfig struct {
+	Tokens []*APIToken `mapstructure:"tokens" json:"tokens"`
+
+	// AllowedOrigins lists the browser origins (e.g. "https://example.com")
+	// allowed to make cross-origin requests. When empty, any origin is allowed
+	// while authentication is disabled and none once it is enabled; "*" is
+	// ignored while authentication is enabled.
+	AllowedOrigins []string `mapstructure:"allowed_origins" json:"allowed_origins,omitempty"`
+}
+
+// APIToken stores only the SHA-256 of the secret; the plain token is shown once when minted.
+type APIToken struct {
+	ID        string   `mapstructure:"id" json:"id"`
+	Name      string   `mapstructure:"name" json:"name"`
+	Hash      string   `mapstructure:"hash" json:"hash"`
+	Scopes    []string `mapstructure:"scopes" json:"scopes"`
+	CreatedAt int64    `mapstructure:"created_at" json:"created_at"`
+	ExpiresAt int64    `mapstructure:"expires_at" json:"expires_at,omitempty"`
+}
+
+// Enabled reports whether requests must carry a token.
+func (c *AuthConfig) Enabled() bool {
+	return c != nil && len(c.Tokens) > 0
+}
+
+// Lookup returns the unexpired token matching raw, or nil.
+func (c *AuthConfig) Lookup(raw string) *APIToken {
+	raw = strings.TrimSpace(raw)
+	if c == nil || raw == "" {
+		return nil
+	}
+	hash := HashToken(raw)
+	now := time.Now().Unix()
+	for _, t := range c.Tokens {
+		if t == nil || subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		if t.ExpiresAt > 0 && now >= t.ExpiresAt {
+			return nil
+		}
+		return t
+	}
+	return nil
+}
+
+// Allows reports whether the token grants scope.
+func (t *APIToken) Allows(scope string) bool {
+	if t == nil {
+		return false
+	}
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIToken mints a token and returns the plain secret alongside the stored record.
+func NewAPIToken(name string, scopes []string, ttl time.Duration) (string, *APIToken, error) {
+	normalized, err := ParseScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id := make([]byte, 4)
+	secret := make([]byte, 24)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+
+	raw := tokenPrefix + hex.EncodeToString(secret)
+	now := time.Now()
+	t := &APIToken{
+		ID:        hex.EncodeToString(id),
+		Name:      strings.TrimSpace(name),
+		Hash:      HashToken(raw),
+		Scopes:    normalized,
+		CreatedAt: now.Unix(),
+	}
+	if ttl > 0 {
+		t.ExpiresAt = now.Add(ttl).Unix()
+	}
+	return raw, t, nil
+}
+
+// ParseScopes validates and de-duplicates scope names; "all" is accepted for "*".
+func ParseScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(scopes))
+	out := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if s == "all" {
+			s = ScopeAll
+		}
+		valid := s == ScopeAll
+		for _, known := range Scopes {
+			if s == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown scope %q (valid: %s, all)", s, strings.Join(Scopes, ", "))
+		}
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	return out, nil
+}
+
+// HashToken returns the hex SHA-256 used to store and compare tokens.
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(raw)))
+	return hex.EncodeToString(sum[:])
+}
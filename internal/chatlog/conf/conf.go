@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/rs/zerolog/log"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/keystore"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/config"
 )
 
@@ -38,7 +39,13 @@ func LoadTUIConfig(configPath string) (*TUIConfig, *config.Manager, error) {
 	}
 	conf.ConfigDir = tcm.Path
 
-	b, _ := json.Marshal(conf)
+	logged := *conf
+	logged.History = make([]ProcessConfig, len(conf.History))
+	for i, h := range conf.History {
+		h.DataKey, h.ImgKey = keystore.Redact(h.DataKey), keystore.Redact(h.ImgKey)
+		logged.History[i] = h
+	}
+	b, _ := json.Marshal(logged)
 	log.Info().Msgf("tui config: %s", string(b))
 
 	return conf, tcm, nil
@@ -89,7 +96,16 @@ func LoadServiceConfig(configPath string, cmdConf map[string]any) (*ServerConfig
 		}
 	}
 
-	b, _ := json.Marshal(conf)
+	loadKeystoreKeys(scm.Path, scm.Viper.ConfigFileUsed(), conf)
+
+	if err := conf.Speech.Validate(); err != nil {
+		log.Error().Err(err).Msg("invalid speech config")
+		return nil, nil, err
+	}
+
+	logged := *conf
+	logged.DataKey, logged.ImgKey = keystore.Redact(conf.DataKey), keystore.Redact(conf.ImgKey)
+	b, _ := json.Marshal(logged)
 	log.Info().Msgf("server config: %s", string(b))
 
 	return conf, scm, nil
@@ -103,3 +119,34 @@ var DataDirConfigs = map[string]bool{
 	"data_key":     true,
 	"img_key":      true,
 }
+
+// LoadAuthConfig 读取配置文件中的 auth 段，返回可回写的 Manager
+// tui 为 true 时操作 chatlog.json，否则操作 chatlog-server.json
+func LoadAuthConfig(configPath string, tui bool) (*AuthConfig, *config.Manager, error) {
+
+	if configPath == "" {
+		configPath = os.Getenv(EnvConfigDir)
+	}
+
+	name := ServerConfigName
+	if tui {
+		name = ""
+	}
+
+	cm, err := config.New(AppName, configPath, name, "", true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	holder := struct {
+		Auth *AuthConfig `mapstructure:"auth"`
+	}{}
+	if err := cm.Load(&holder); err != nil {
+		return nil, nil, err
+	}
+	if holder.Auth == nil {
+		holder.Auth = &AuthConfig{}
+	}
+
+	return holder.Auth, cm, nil
+}
@@ -0,0 +1,115 @@
+package conf
+
+import "strings"
+
+// DigestConfig 定时为指定会话生成日报/周报摘要，并投递到文件、webhook 或邮件。
+type DigestConfig struct {
+	Enabled bool          `mapstructure:"enabled" json:"enabled"`
+	Items   []*DigestItem `mapstructure:"items" json:"items"`
+
+	// Summary 配置可选的大模型总结，接口与 speech 相同，兼容 OpenAI /chat/completions
+	Summary *DigestSummaryConfig `mapstructure:"summary" json:"summary"`
+
+	// SMTP 为 smtp 类型投递目标共用的发信服务器
+	SMTP *SMTPConfig `mapstructure:"smtp" json:"smtp"`
+}
+
+// DigestItem 描述一个定时摘要任务。
+type DigestItem struct {
+	Name string `mapstructure:"name" json:"name"`
+
+	// Schedule 为 "daily"（默认）或 "weekly"
+	Schedule string `mapstructure:"schedule" json:"schedule"`
+
+	// At 为每天的运行时间，格式 HH:MM，默认 08:00
+	At string `mapstructure:"at" json:"at"`
+
+	// Weekday 为周报的运行日，0 表示星期日，默认星期一
+	Weekday *int `mapstructure:"weekday" json:"weekday"`
+
+	// Talker 为会话 ID、备注或昵称，多个用 ',' 分隔；为空时取时间范围内我参与过的全部会话
+	Talker string `mapstructure:"talker" json:"talker"`
+
+	// Summarize 为 true 且配置了 summary 时调用大模型生成总结
+	Summarize bool `mapstructure:"summarize" json:"summarize"`
+
+	// MaxMessages 为每个会话写入摘要的最近消息条数，默认 200
+	MaxMessages int `mapstructure:"max_messages" json:"max_messages"`
+
+	Targets  []*DigestTarget `mapstructure:"targets" json:"targets"`
+	Disabled bool            `mapstructure:"disabled" json:"disabled"`
+}
+
+// DigestTarget 为摘要的投递目标。
+type DigestTarget struct {
+	// Type 为 "file"、"webhook" 或 "smtp"
+	Type string `mapstructure:"type" json:"type"`
+
+	// Dir 为 file 类型的输出目录，文件名为 <name>_<日期>.md
+	Dir string `mapstructure:"dir" json:"dir"`
+
+	// Webhook 为 webhook 类型的地址与签名密钥，只使用 url 与 secret
+	Webhook *WebhookItem `mapstructure:"webhook" json:"webhook"`
+
+	// To 为 smtp 类型的收件人
+	To []string `mapstructure:"to" json:"to"`
+}
+
+// DigestSummaryConfig 为摘要使用的大模型配置。
+type DigestSummaryConfig struct {
+	LLMConfig `mapstructure:",squash"`
+	Prompt    string `mapstructure:"prompt" json:"prompt"`
+}
+
+// SMTPConfig 为发信服务器配置。
+type SMTPConfig struct {
+	Host     string `mapstructure:"host" json:"host"`
+	Port     int    `mapstructure:"port" json:"port"`
+	Username string `mapstructure:"username" json:"username"`
+	Password string `mapstructure:"password" json:"password"`
+	From     string `mapstructure:"from" json:"from"`
+
+	// TLS 为 true 时直接建立 TLS 连接（通常是 465 端口），否则在服务器支持时使用 STARTTLS
+	TLS bool `mapstructure:"tls" json:"tls"`
+}
+
+// Normalize trims fields and applies schedule defaults.
+func (c *DigestConfig) Normalize() {
+	if c == nil {
+		return
+	}
+	for _, item := range c.Items {
+		if item == nil {
+			continue
+		}
+		item.Name = strings.TrimSpace(item.Name)
+		item.Schedule = strings.ToLower(strings.TrimSpace(item.Schedule))
+		if item.Schedule != "weekly" {
+			item.Schedule = "daily"
+		}
+		item.At = strings.TrimSpace(item.At)
+		if item.At == "" {
+			item.At = "08:00"
+		}
+		if item.MaxMessages <= 0 {
+			item.MaxMessages = 200
+		}
+		for _, t := range item.Targets {
+			if t != nil {
+				t.Type = strings.ToLower(strings.TrimSpace(t.Type))
+			}
+		}
+	}
+	if c.Summary != nil {
+		c.Summary.LLMConfig.Normalize()
+	}
+	if s := c.SMTP; s != nil {
+		s.Host = strings.TrimSpace(s.Host)
+		if s.Port == 0 {
+			s.Port = 587
+			if s.TLS {
+				s.Port = 465
+			}
+		}
+	}
+}
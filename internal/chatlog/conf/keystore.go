@@ -0,0 +1,212 @@
+package conf
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/keystore"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/config"
+)
+
+// OpenKeystore 打开配置目录下的密钥库，口令从环境变量读取
+func OpenKeystore(configPath string) (*keystore.Store, error) {
+	if configPath == "" {
+		configPath = os.Getenv(EnvConfigDir)
+	}
+	cm, err := config.New(AppName, configPath, "", "", false)
+	if err != nil {
+		return nil, err
+	}
+	return keystore.Open(cm.Path, keystore.OptionsFromEnv())
+}
+
+// ImportServerKeys 把 chatlog-server.json 及其数据目录下 chatlog.json 中的明文密钥写入 ks，
+// 并从这两个文件中清除，返回迁移的文件数
+func ImportServerKeys(configPath string, ks *keystore.Store) (int, error) {
+	if configPath == "" {
+		configPath = os.Getenv(EnvConfigDir)
+	}
+	cm, err := config.New(AppName, configPath, ServerConfigName, "", false)
+	if err != nil {
+		return 0, err
+	}
+	configFile := filepath.Join(cm.Path, ServerConfigName+"."+config.DefaultConfigType)
+	raw, err := readJSONConfig(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	dataDir, _ := raw["data_dir"].(string)
+	return migrateServerKeys(ks, configFile, strings.TrimSpace(dataDir))
+}
+
+// plaintextConfigs 返回服务模式可能保存明文密钥的配置文件
+func plaintextConfigs(configFile, dataDir string) []string {
+	var files []string
+	if configFile != "" {
+		files = append(files, configFile)
+	}
+	if dataDir != "" {
+		files = append(files, filepath.Join(dataDir, "chatlog.json"))
+	}
+	return files
+}
+
+// hasPlaintextKeys 判断配置文件中是否还有明文密钥
+func hasPlaintextKeys(configFile, dataDir string) bool {
+	for _, file := range plaintextConfigs(configFile, dataDir) {
+		raw, err := readJSONConfig(file)
+		if err == nil && (stringValue(raw, "data_key") != "" || stringValue(raw, "img_key") != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateServerKeys 把配置文件中的明文密钥按数据目录写入密钥库，写入成功后从文件中清除，
+// 与 TUI 迁移 history 中的密钥一致
+func migrateServerKeys(ks *keystore.Store, configFile, dataDir string) (int, error) {
+	if ks.Locked() {
+		return 0, keystore.ErrLocked
+	}
+	migrated := 0
+	for _, file := range plaintextConfigs(configFile, dataDir) {
+		raw, err := readJSONConfig(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return migrated, err
+		}
+		dataKey, imgKey := stringValue(raw, "data_key"), stringValue(raw, "img_key")
+		if dataKey == "" && imgKey == "" {
+			continue
+		}
+		if keystore.IsRedacted(dataKey) || keystore.IsRedacted(imgKey) {
+			log.Warn().Str("file", file).Msg("skip redacted keys in config")
+			continue
+		}
+		if dataDir == "" {
+			log.Warn().Str("file", file).Msg("data_dir is not set, keys in this config cannot be moved to the keystore")
+			continue
+		}
+
+		entry := keystore.Entry{Account: filepath.Base(filepath.Clean(dataDir)), DataDir: dataDir}
+		if cur := lookupKeys(ks, dataDir); cur != nil {
+			entry = *cur
+		}
+		if dataKey != "" {
+			entry.DataKey = dataKey
+		}
+		if imgKey != "" {
+			entry.ImgKey = imgKey
+		}
+		if err := ks.Put(entry); err != nil {
+			return migrated, err
+		}
+
+		for _, key := range []string{"data_key", "img_key"} {
+			if _, ok := raw[key]; ok {
+				raw[key] = ""
+			}
+		}
+		if err := writeJSONConfig(file, raw); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		log.Info().Int("files", migrated).Str("path", ks.Path()).Msg("migrated plaintext keys to keystore")
+	}
+	return migrated, nil
+}
+
+func readJSONConfig(file string) (map[string]any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]any)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func writeJSONConfig(file string, raw map[string]any) error {
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(file); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func stringValue(raw map[string]any, key string) string {
+	v, _ := raw[key].(string)
+	return strings.TrimSpace(v)
+}
+
+// loadKeystoreKeys 迁移配置文件中的明文密钥后按数据目录从密钥库读取密钥：
+// 主账号只补全配置中缺少的密钥，额外账号的图片密钥只从密钥库读取
+func loadKeystoreKeys(dir, configFile string, c *ServerConfig) {
+	needMain := len(c.DataDir) != 0 && (len(c.DataKey) == 0 || len(c.ImgKey) == 0)
+	var accounts []*AccountConfig
+	for _, a := range c.Accounts {
+		if a != nil && strings.TrimSpace(a.DataDir) != "" {
+			accounts = append(accounts, a)
+		}
+	}
+	migrate := hasPlaintextKeys(configFile, c.DataDir)
+	if !needMain && len(accounts) == 0 && !migrate {
+		return
+	}
+
+	ks, err := keystore.Open(dir, keystore.OptionsFromEnv())
+	if err != nil {
+		log.Warn().Err(err).Msg("open keystore failed")
+		return
+	}
+	if migrate {
+		if _, err := migrateServerKeys(ks, configFile, c.DataDir); err != nil {
+			log.Warn().Err(err).Msg("migrate plaintext keys to keystore failed")
+		}
+	}
+	if needMain {
+		if e := lookupKeys(ks, c.DataDir); e != nil {
+			if len(c.DataKey) == 0 {
+				c.DataKey = e.DataKey
+			}
+			if len(c.ImgKey) == 0 {
+				c.ImgKey = e.ImgKey
+			}
+		}
+	}
+	for _, a := range accounts {
+		if e := lookupKeys(ks, strings.TrimSpace(a.DataDir)); e != nil {
+			a.imgKey = e.ImgKey
+		}
+	}
+}
+
+// lookupKeys 返回 dataDir 对应的密钥，未找到时返回 nil
+func lookupKeys(ks *keystore.Store, dataDir string) *keystore.Entry {
+	e, err := ks.Lookup(dataDir)
+	if err != nil {
+		if err != keystore.ErrNotFound {
+			log.Warn().Err(err).Str("data_dir", dataDir).Msg("load keys from keystore failed")
+		}
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,68 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/keystore"
+)
+
+const (
+	testDataKey = "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"
+	testImgKey  = "0123456789abcdef0123456789abcdef"
+)
+
+func TestImportServerKeys(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := t.TempDir()
+	serverFile := filepath.Join(dir, ServerConfigName+".json")
+	dataDirFile := filepath.Join(dataDir, "chatlog.json")
+	if err := os.WriteFile(serverFile, []byte(`{"data_dir": "`+dataDir+`", "data_key": "`+testDataKey+`", "http_addr": "127.0.0.1:5030"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dataDirFile, []byte(`{"img_key": "`+testImgKey+`", "platform": "windows"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := keystore.Open(filepath.Join(dir, "keys"), keystore.Options{Mode: keystore.ModeFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasPlaintextKeys(serverFile, dataDir) {
+		t.Fatal("plaintext keys not detected")
+	}
+	n, err := ImportServerKeys(dir, ks)
+	if err != nil || n != 2 {
+		t.Fatalf("import = %d, %v", n, err)
+	}
+
+	e, err := ks.Lookup(dataDir)
+	if err != nil || e.DataKey != testDataKey || e.ImgKey != testImgKey || e.Account != filepath.Base(dataDir) {
+		t.Fatalf("keystore entry = %+v, %v", e, err)
+	}
+
+	// 密钥从两个文件中清除，其余配置保留
+	for _, file := range []string{serverFile, dataDirFile} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), testDataKey) || strings.Contains(string(data), testImgKey) {
+			t.Fatalf("%s still contains keys: %s", file, data)
+		}
+	}
+	if raw, _ := readJSONConfig(serverFile); stringValue(raw, "http_addr") != "127.0.0.1:5030" {
+		t.Fatalf("server config = %v", raw)
+	}
+	if info, _ := os.Stat(dataDirFile); info.Mode().Perm() != 0o644 {
+		t.Fatalf("data dir config mode = %v", info.Mode())
+	}
+	if hasPlaintextKeys(serverFile, dataDir) {
+		t.Fatal("plaintext keys left after import")
+	}
+	if n, err := ImportServerKeys(dir, ks); err != nil || n != 0 {
+		t.Fatalf("second import = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,54 @@
+package conf
+
+import "strings"
+
+// SearchConfig controls the full-text message index.
+type SearchConfig struct {
+	// Tokenizer selects CJK segmentation for the FTS index: "bigram" or
+	// "unicode61". Empty keeps the tokenizer of an up-to-date index and uses
+	// "bigram" for new or rebuilt ones. Changing it triggers a full reindex on
+	// next start.
+	Tokenizer string `mapstructure:"tokenizer" json:"tokenizer"`
+
+	// Embedding enables semantic and hybrid search modes.
+	Embedding *EmbeddingConfig `mapstructure:"embedding" json:"embedding"`
+}
+
+// EmbeddingConfig selects the provider used to embed conversation chunks.
+// Changing the model (or dimensions) re-embeds all history on next start.
+type EmbeddingConfig struct {
+	Enabled               bool   `mapstructure:"enabled" json:"enabled"`
+	Provider              string `mapstructure:"provider" json:"provider"`
+	Model                 string `mapstructure:"model" json:"model"`
+	Dimensions            int    `mapstructure:"dimensions" json:"dimensions"`
+	APIKey                string `mapstructure:"api_key" json:"api_key"`
+	BaseURL               string `mapstructure:"base_url" json:"base_url"`
+	Organization          string `mapstructure:"organization" json:"organization"`
+	Proxy                 string `mapstructure:"proxy" json:"proxy"`
+	ServiceURL            string `mapstructure:"service_url" json:"service_url"`
+	RequestTimeoutSeconds int    `mapstructure:"request_timeout_seconds" json:"request_timeout_seconds"`
+}
+
+// Normalize trims fields and applies provider defaults.
+func (c *EmbeddingConfig) Normalize() {
+	if c == nil {
+		return
+	}
+	c.Provider = strings.ToLower(strings.TrimSpace(c.Provider))
+	c.Model = strings.TrimSpace(c.Model)
+	c.APIKey = strings.TrimSpace(c.APIKey)
+	c.BaseURL = strings.TrimSpace(c.BaseURL)
+	c.Organization = strings.TrimSpace(c.Organization)
+	c.Proxy = strings.TrimSpace(c.Proxy)
+	c.ServiceURL = strings.TrimSpace(c.ServiceURL)
+
+	switch c.Provider {
+	case "webservice", "local", "ollama", "http":
+		c.Provider = "webservice"
+		if c.ServiceURL == "" {
+			c.ServiceURL = "http://127.0.0.1:11434"
+		}
+	default:
+		c.Provider = "openai"
+	}
+}
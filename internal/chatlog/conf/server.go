@@ -5,18 +5,30 @@ const (
 )
 
 type ServerConfig struct {
-	Type        string        `mapstructure:"type"`
-	Platform    string        `mapstructure:"platform"`
-	Version     int           `mapstructure:"version"`
-	FullVersion string        `mapstructure:"full_version"`
-	DataDir     string        `mapstructure:"data_dir"`
-	DataKey     string        `mapstructure:"data_key"`
-	ImgKey      string        `mapstructure:"img_key"`
-	WorkDir     string        `mapstructure:"work_dir"`
-	HTTPAddr    string        `mapstructure:"http_addr"`
-	AutoDecrypt bool          `mapstructure:"auto_decrypt"`
-	Webhook     *Webhook      `mapstructure:"webhook"`
-	Speech      *SpeechConfig `mapstructure:"speech"`
+	Type        string           `mapstructure:"type"`
+	Platform    string           `mapstructure:"platform"`
+	Version     int              `mapstructure:"version"`
+	FullVersion string           `mapstructure:"full_version"`
+	DataDir     string           `mapstructure:"data_dir"`
+	DataKey     string           `mapstructure:"data_key"`
+	ImgKey      string           `mapstructure:"img_key"`
+	WorkDir     string           `mapstructure:"work_dir"`
+	HTTPAddr    string           `mapstructure:"http_addr"`
+	AutoDecrypt bool             `mapstructure:"auto_decrypt"`
+	Webhook     *Webhook         `mapstructure:"webhook"`
+	Speech      *SpeechConfig    `mapstructure:"speech"`
+	Search      *SearchConfig    `mapstructure:"search"`
+	Auth        *AuthConfig      `mapstructure:"auth"`
+	Mirror      *MirrorConfig    `mapstructure:"mirror"`
+	Digest      *DigestConfig    `mapstructure:"digest"`
+	Summarize   *SummarizeConfig `mapstructure:"summarize"`
+
+	// Accounts 为同一服务中额外挂载的已解密账号，见 AccountConfig
+	Accounts []*AccountConfig `mapstructure:"accounts"`
+
+	// Snapshot 为只读打开的已解密工作目录，设置后不需要微信、数据目录与密钥；
+	// WorkDir 此时只用于保存索引与缓存
+	Snapshot string `mapstructure:"snapshot"`
 }
 
 var ServerDefaults = map[string]any{}
@@ -37,6 +49,10 @@ func (c *ServerConfig) GetVersion() int {
 	return c.Version
 }
 
+func (c *ServerConfig) GetSnapshot() string {
+	return c.Snapshot
+}
+
 func (c *ServerConfig) GetDataKey() string {
 	return c.DataKey
 }
@@ -64,6 +80,26 @@ func (c *ServerConfig) GetSpeech() *SpeechConfig {
 	return c.Speech
 }
 
+func (c *ServerConfig) GetSearch() *SearchConfig {
+	return c.Search
+}
+
+func (c *ServerConfig) GetAuth() *AuthConfig {
+	return c.Auth
+}
+
+func (c *ServerConfig) GetMirror() *MirrorConfig {
+	return c.Mirror
+}
+
+func (c *ServerConfig) GetDigest() *DigestConfig {
+	return c.Digest
+}
+
+func (c *ServerConfig) GetSummarize() *SummarizeConfig {
+	return c.Summarize
+}
+
 func (c *ServerConfig) SetHTTPAddr(addr string) {
 	c.HTTPAddr = addr
 }
@@ -1,6 +1,9 @@
 package conf
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"strings"
 
 	"github.com/takeaway1/chatlog-TCOTC/internal/whisper"
@@ -37,6 +40,7 @@ type SpeechConfig struct {
 	WordTimestamps        bool           `mapstructure:"word_timestamps" json:"word_timestamps"`
 	VADFilter             bool           `mapstructure:"vad_filter" json:"vad_filter"`
 	RequestTimeoutSeconds int            `mapstructure:"request_timeout_seconds" json:"request_timeout_seconds"`
+	ReplayDir             string         `mapstructure:"replay_dir" json:"replay_dir"`
 	OpenAI                OpenAISettings `mapstructure:"openai" json:"openai"`
 }
 
@@ -75,6 +79,7 @@ func (c *SpeechConfig) Normalize() {
 	c.Model = strings.TrimSpace(c.Model)
 	c.ServiceURL = strings.TrimSpace(c.ServiceURL)
 	c.ServiceOutput = strings.TrimSpace(c.ServiceOutput)
+	c.ReplayDir = strings.TrimSpace(c.ReplayDir)
 
 	switch c.Provider {
 	case "webservice", "local", "docker", "http", "whisper-asr":
@@ -87,6 +92,8 @@ func (c *SpeechConfig) Normalize() {
 		c.ServiceOutput = strings.ToLower(c.ServiceOutput)
 	case "whispercpp", "whisper.cpp", "cpp":
 		c.Provider = "whispercpp"
+	case "replay", "fixture":
+		c.Provider = "replay"
 	default:
 		if c.Provider != "openai" {
 			c.Provider = "openai"
@@ -94,6 +101,28 @@ func (c *SpeechConfig) Normalize() {
 	}
 }
 
+// Validate reports configuration errors that can be detected at startup.
+func (c *SpeechConfig) Validate() error {
+	if c == nil || !c.Enabled {
+		return nil
+	}
+	switch strings.ToLower(strings.TrimSpace(c.Provider)) {
+	case "replay", "fixture":
+		dir := strings.TrimSpace(c.ReplayDir)
+		if dir == "" {
+			return errors.New("speech.replay_dir is required for the replay provider")
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("speech.replay_dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("speech.replay_dir %s is not a directory", dir)
+		}
+	}
+	return nil
+}
+
 // PrepareForSave syncs the flattened OpenAI fields back into the legacy nested structure.
 func (c *SpeechConfig) PrepareForSave() {
 	if c == nil {
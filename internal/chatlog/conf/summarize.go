@@ -0,0 +1,51 @@
+package conf
+
+import "strings"
+
+// LLMConfig 为 OpenAI 兼容的对话补全接口配置，摘要与总结功能共用。
+type LLMConfig struct {
+	Model                 string `mapstructure:"model" json:"model"`
+	APIKey                string `mapstructure:"api_key" json:"api_key"`
+	BaseURL               string `mapstructure:"base_url" json:"base_url"`
+	Organization          string `mapstructure:"organization" json:"organization"`
+	Proxy                 string `mapstructure:"proxy" json:"proxy"`
+	RequestTimeoutSeconds int    `mapstructure:"request_timeout_seconds" json:"request_timeout_seconds"`
+}
+
+// Normalize trims connection fields.
+func (c *LLMConfig) Normalize() {
+	if c == nil {
+		return
+	}
+	c.Model = strings.TrimSpace(c.Model)
+	c.APIKey = strings.TrimSpace(c.APIKey)
+	c.BaseURL = strings.TrimSpace(c.BaseURL)
+	c.Organization = strings.TrimSpace(c.Organization)
+	c.Proxy = strings.TrimSpace(c.Proxy)
+}
+
+// SummarizeConfig 启用服务端的会话总结接口。
+type SummarizeConfig struct {
+	Enabled   bool `mapstructure:"enabled" json:"enabled"`
+	LLMConfig `mapstructure:",squash"`
+
+	// ChunkTokens 为单次请求中聊天记录的 token 预算，超出时分块总结再合并，默认 6000
+	ChunkTokens int `mapstructure:"chunk_tokens" json:"chunk_tokens"`
+
+	// MaxChunks 为单次总结允许的最大分块数，超出时返回错误而不调用模型，默认 20
+	MaxChunks int `mapstructure:"max_chunks" json:"max_chunks"`
+}
+
+// Normalize trims fields and applies defaults.
+func (c *SummarizeConfig) Normalize() {
+	if c == nil {
+		return
+	}
+	c.LLMConfig.Normalize()
+	if c.ChunkTokens <= 0 {
+		c.ChunkTokens = 6000
+	}
+	if c.MaxChunks <= 0 {
+		c.MaxChunks = 20
+	}
+}
@@ -1,10 +1,15 @@
 package conf
 
 type TUIConfig struct {
-	ConfigDir   string          `mapstructure:"-" json:"config_dir"`
-	LastAccount string          `mapstructure:"last_account" json:"last_account"`
-	History     []ProcessConfig `mapstructure:"history" json:"history"`
-	Webhook     *Webhook        `mapstructure:"webhook" json:"webhook"`
+	ConfigDir   string           `mapstructure:"-" json:"config_dir"`
+	LastAccount string           `mapstructure:"last_account" json:"last_account"`
+	History     []ProcessConfig  `mapstructure:"history" json:"history"`
+	Webhook     *Webhook         `mapstructure:"webhook" json:"webhook"`
+	Search      *SearchConfig    `mapstructure:"search" json:"search"`
+	Auth        *AuthConfig      `mapstructure:"auth" json:"auth"`
+	Mirror      *MirrorConfig    `mapstructure:"mirror" json:"mirror"`
+	Digest      *DigestConfig    `mapstructure:"digest" json:"digest"`
+	Summarize   *SummarizeConfig `mapstructure:"summarize" json:"summarize"`
 }
 
 var TUIDefaults = map[string]any{}
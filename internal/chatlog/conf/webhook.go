@@ -4,6 +4,9 @@ type Webhook struct {
 	Host    string         `mapstructure:"host"`
 	DelayMs int64          `mapstructure:"delay_ms"`
 	Items   []*WebhookItem `mapstructure:"items"`
+
+	// MaxAttempts 为单次投递的最大尝试次数，超过后写入死信文件；默认 8
+	MaxAttempts int `mapstructure:"max_attempts"`
 }
 
 type WebhookItem struct {
@@ -13,4 +16,8 @@ type WebhookItem struct {
 	Sender   string `mapstructure:"sender"`
 	Keyword  string `mapstructure:"keyword"`
 	Disabled bool   `mapstructure:"disabled"`
+
+	// Secret 非空时，请求头 X-Chatlog-Signature 携带
+	// sha256=HMAC-SHA256(secret, "<X-Chatlog-Timestamp>.<body>") 的十六进制值
+	Secret string `mapstructure:"secret"`
 }
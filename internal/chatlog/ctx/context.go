@@ -10,6 +10,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/keystore"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechat"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/config"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
@@ -24,6 +25,7 @@ const (
 type Context struct {
 	conf *conf.TUIConfig
 	cm   *config.Manager
+	ks   *keystore.Store
 	mu   sync.RWMutex
 
 	History    map[string]conf.ProcessConfig
@@ -74,12 +76,22 @@ func New(configPath string) (*Context, error) {
 		cm:   tcm,
 	}
 
+	if ks, err := keystore.Open(tcm.Path, keystore.OptionsFromEnv()); err != nil {
+		log.Warn().Err(err).Msg("open keystore failed, keys will not be saved")
+	} else {
+		if ks.Locked() {
+			log.Warn().Err(keystore.ErrLocked).Msg("keystore locked")
+		}
+		ctx.ks = ks
+	}
+
 	ctx.loadConfig()
 
 	return ctx, nil
 }
 
 func (c *Context) loadConfig() {
+	c.migrateKeys()
 	c.History = c.conf.ParseHistory()
 	c.SwitchHistory(c.conf.LastAccount)
 	c.Refresh()
@@ -167,6 +179,18 @@ func (c *Context) SwitchHistory(account string) {
 		c.FullVersion = history.FullVersion
 		c.DataKey = history.DataKey
 		c.ImgKey = history.ImgKey
+		if c.ks != nil && (c.DataKey == "" || c.ImgKey == "") {
+			if e, err := c.ks.Get(account); err == nil {
+				if c.DataKey == "" {
+					c.DataKey = e.DataKey
+				}
+				if c.ImgKey == "" {
+					c.ImgKey = e.ImgKey
+				}
+			} else if err != keystore.ErrNotFound {
+				log.Warn().Err(err).Str("account", account).Msg("load keys from keystore failed")
+			}
+		}
 		c.DataDir = history.DataDir
 		c.WorkDir = history.WorkDir
 		c.HTTPEnabled = history.HTTPEnabled
@@ -232,6 +256,11 @@ func (c *Context) GetWorkDir() string {
 	return c.WorkDir
 }
 
+// GetSnapshot 返回空：TUI 模式总是打开自己解密的工作目录
+func (c *Context) GetSnapshot() string {
+	return ""
+}
+
 func (c *Context) GetPlatform() string {
 	return c.Platform
 }
@@ -267,6 +296,26 @@ func (c *Context) GetWebhook() *conf.Webhook {
 	return c.conf.Webhook
 }
 
+func (c *Context) GetSearch() *conf.SearchConfig {
+	return c.conf.Search
+}
+
+func (c *Context) GetAuth() *conf.AuthConfig {
+	return c.conf.Auth
+}
+
+func (c *Context) GetMirror() *conf.MirrorConfig {
+	return c.conf.Mirror
+}
+
+func (c *Context) GetDigest() *conf.DigestConfig {
+	return c.conf.Digest
+}
+
+func (c *Context) GetSummarize() *conf.SummarizeConfig {
+	return c.conf.Summarize
+}
+
 func (c *Context) GetSpeech() *conf.SpeechConfig {
 	return c.speech
 }
@@ -366,6 +415,18 @@ func (c *Context) UpdateConfig() {
 		HTTPAddr:    c.HTTPAddr,
 	}
 
+	// 密钥只保存在密钥库中；密钥库不可用时保留配置文件中原有的内容，不写入新的明文密钥
+	saved := c.saveKeys(pconf)
+	pconf.DataKey, pconf.ImgKey = "", ""
+	if !saved {
+		for _, v := range c.conf.History {
+			if v.Account == c.Account {
+				pconf.DataKey, pconf.ImgKey = v.DataKey, v.ImgKey
+				break
+			}
+		}
+	}
+
 	if c.conf.History == nil {
 		c.conf.History = make([]conf.ProcessConfig, 0)
 	}
@@ -396,10 +457,70 @@ func (c *Context) UpdateConfig() {
 	}
 
 	if len(pconf.DataDir) != 0 {
-		if b, err := json.Marshal(pconf); err == nil {
-			if err := os.WriteFile(filepath.Join(pconf.DataDir, "chatlog.json"), b, 0644); err != nil {
-				log.Error().Err(err).Msg("save chatlog.json failed")
+		writeDataDirConfig(pconf)
+	}
+}
+
+// writeDataDirConfig 在数据目录下保存 chatlog.json，供服务模式读取
+func writeDataDirConfig(pconf conf.ProcessConfig) {
+	if b, err := json.Marshal(pconf); err == nil {
+		if err := os.WriteFile(filepath.Join(pconf.DataDir, "chatlog.json"), b, 0644); err != nil {
+			log.Error().Err(err).Msg("save chatlog.json failed")
+		}
+	}
+}
+
+// saveKeys 把当前账号的密钥写入密钥库，没有需要保存的密钥时也返回 true
+func (c *Context) saveKeys(pconf conf.ProcessConfig) bool {
+	if pconf.Account == "" || (pconf.DataKey == "" && pconf.ImgKey == "") {
+		return true
+	}
+	if c.ks == nil {
+		log.Warn().Msg("keystore unavailable, keys are kept in memory only")
+		return false
+	}
+	err := c.ks.Put(keystore.Entry{
+		Account: pconf.Account,
+		DataDir: pconf.DataDir,
+		DataKey: pconf.DataKey,
+		ImgKey:  pconf.ImgKey,
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("save keys to keystore failed, keys are kept in memory only")
+		return false
+	}
+	return true
+}
+
+// migrateKeys 把旧版本写入配置文件的明文密钥迁移到密钥库，并从配置文件和数据目录的 chatlog.json 中清除
+func (c *Context) migrateKeys() {
+	if c.ks == nil || c.ks.Locked() {
+		return
+	}
+	migrated := 0
+	for i, h := range c.conf.History {
+		if h.DataKey == "" && h.ImgKey == "" {
+			continue
+		}
+		if err := c.ks.Put(keystore.Entry{Account: h.Account, DataDir: h.DataDir, DataKey: h.DataKey, ImgKey: h.ImgKey}); err != nil {
+			log.Warn().Err(err).Str("account", h.Account).Msg("migrate keys to keystore failed")
+			continue
+		}
+		h.DataKey, h.ImgKey = "", ""
+		c.conf.History[i] = h
+		if h.DataDir != "" {
+			if _, err := os.Stat(filepath.Join(h.DataDir, "chatlog.json")); err == nil {
+				writeDataDirConfig(h)
 			}
 		}
+		migrated++
+	}
+	if migrated == 0 {
+		return
+	}
+	if err := c.cm.SetConfig("history", c.conf.History); err != nil {
+		log.Error().Err(err).Msg("set history failed")
+		return
 	}
+	log.Info().Int("accounts", migrated).Str("path", c.ks.Path()).Msg("migrated plaintext keys to keystore")
 }
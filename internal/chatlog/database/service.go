@@ -2,15 +2,21 @@ package database
 
 import (
 	"context"
+	"path/filepath"
 	"time"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/digest"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/mirror"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/transcript"
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/webhook"
+	"github.com/takeaway1/chatlog-TCOTC/internal/embedding"
 	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
 	"github.com/takeaway1/chatlog-TCOTC/internal/model"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/indexer"
 )
 
 const (
@@ -25,15 +31,26 @@ type Service struct {
 	StateMsg      string
 	conf          Config
 	db            *wechatdb.DB
+	embedder      embedding.Embedder
 	webhook       *webhook.Service
 	webhookCancel context.CancelFunc
+	mirror        *mirror.Mirror
+	mirrorCancel  context.CancelFunc
+	digest        *digest.Scheduler
+	digestCancel  context.CancelFunc
+	digestQueue   *webhook.Queue
+	transcripts   *transcript.Store
 }
 
 type Config interface {
 	GetWorkDir() string
+	GetSnapshot() string
 	GetPlatform() string
 	GetVersion() int
 	GetWebhook() *conf.Webhook
+	GetSearch() *conf.SearchConfig
+	GetMirror() *conf.MirrorConfig
+	GetDigest() *conf.DigestConfig
 }
 
 func NewService(conf Config) *Service {
@@ -44,20 +61,45 @@ func NewService(conf Config) *Service {
 }
 
 func (s *Service) Start() error {
-	db, err := wechatdb.New(s.conf.GetWorkDir(), s.conf.GetPlatform(), s.conf.GetVersion())
+	var indexOpts indexer.Options
+	if search := s.conf.GetSearch(); search != nil {
+		indexOpts.Tokenizer = search.Tokenizer
+		s.initEmbedder(search.Embedding)
+		indexOpts.Embedder = s.embedder
+	}
+	s.openTranscripts()
+	if s.transcripts != nil {
+		indexOpts.Transcript = s.transcripts.Lookup
+	}
+	var db *wechatdb.DB
+	var err error
+	if snapshot := s.conf.GetSnapshot(); snapshot != "" {
+		// 快照只读打开，索引写入工作目录，与普通模式的 <WorkDir>/indexes/messages 保持一致
+		db, err = wechatdb.NewReadOnly(snapshot, filepath.Join(s.conf.GetWorkDir(), "indexes", "messages"), s.conf.GetPlatform(), s.conf.GetVersion(), indexOpts)
+	} else {
+		db, err = wechatdb.New(s.conf.GetWorkDir(), s.conf.GetPlatform(), s.conf.GetVersion(), indexOpts)
+	}
 	if err != nil {
+		s.closeEmbedder()
+		s.closeTranscripts()
 		return err
 	}
 	s.SetReady()
 	s.db = db
 	s.initWebhook()
+	s.initMirror()
+	s.initDigest()
 	return nil
 }
 
 func (s *Service) Stop() error {
+	s.closeDigest()
+	s.closeMirror()
 	if s.db != nil {
 		s.db.Close()
 	}
+	s.closeEmbedder()
+	s.closeTranscripts()
 	s.SetInit()
 	s.db = nil
 	if s.webhookCancel != nil {
@@ -67,6 +109,74 @@ func (s *Service) Stop() error {
 	return nil
 }
 
+func (s *Service) initEmbedder(cfg *conf.EmbeddingConfig) {
+	s.closeEmbedder()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+
+	cfg.Normalize()
+	timeout := time.Duration(cfg.RequestTimeoutSeconds) * time.Second
+
+	switch cfg.Provider {
+	case "openai":
+		embedder, err := embedding.NewOpenAIEmbedder(embedding.OpenAIConfig{
+			Model:          cfg.Model,
+			APIKey:         cfg.APIKey,
+			BaseURL:        cfg.BaseURL,
+			Organization:   cfg.Organization,
+			ProxyURL:       cfg.Proxy,
+			Dimensions:     cfg.Dimensions,
+			RequestTimeout: timeout,
+		})
+		if err != nil {
+			log.Err(err).Msg("initialise openai embedder failed")
+			return
+		}
+		s.embedder = embedder
+	case "webservice":
+		embedder, err := embedding.NewWebServiceEmbedder(embedding.WebServiceConfig{
+			BaseURL:        cfg.ServiceURL,
+			Model:          cfg.Model,
+			RequestTimeout: timeout,
+		})
+		if err != nil {
+			log.Err(err).Msg("initialise webservice embedder failed")
+			return
+		}
+		s.embedder = embedder
+	}
+
+	if s.embedder != nil {
+		log.Info().Str("provider", cfg.Provider).Str("model", s.embedder.ModelName()).Msg("semantic search embedder initialised")
+	}
+}
+
+// openTranscripts 打开工作目录下的语音转写缓存，失败时仅记录日志，转写仍可实时进行
+func (s *Service) openTranscripts() {
+	s.closeTranscripts()
+	store, err := transcript.Open(s.conf.GetWorkDir())
+	if err != nil {
+		log.Warn().Err(err).Msg("open transcript store failed")
+		return
+	}
+	s.transcripts = store
+}
+
+func (s *Service) closeTranscripts() {
+	if s.transcripts != nil {
+		s.transcripts.Close()
+		s.transcripts = nil
+	}
+}
+
+func (s *Service) closeEmbedder() {
+	if s.embedder != nil {
+		s.embedder.Close()
+		s.embedder = nil
+	}
+}
+
 func (s *Service) SetInit() {
 	s.State = StateInit
 }
@@ -97,6 +207,17 @@ func (s *Service) GetWorkDir() string {
 	return s.conf.GetWorkDir()
 }
 
+// GetDBDir 返回解密后数据库所在目录：快照模式下为快照目录，否则为工作目录
+func (s *Service) GetDBDir() string {
+	if s == nil || s.conf == nil {
+		return ""
+	}
+	if snapshot := s.conf.GetSnapshot(); snapshot != "" {
+		return snapshot
+	}
+	return s.conf.GetWorkDir()
+}
+
 func (s *Service) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
 	return s.db.GetMessages(start, end, talker, sender, keyword, limit, offset)
 }
@@ -108,6 +229,19 @@ func (s *Service) SearchMessages(req *model.SearchRequest) (*model.SearchRespons
 	return s.db.SearchMessages(req)
 }
 
+// IndexMessages 重新索引指定消息，例如语音转写完成后
+func (s *Service) IndexMessages(messages []*model.Message) error {
+	if s.db == nil {
+		return errors.InvalidArg("index before db ready")
+	}
+	return s.db.IndexMessages(messages)
+}
+
+// GetTranscripts exposes the voice transcript cache; nil when it could not be opened.
+func (s *Service) GetTranscripts() *transcript.Store {
+	return s.transcripts
+}
+
 func (s *Service) GetContacts(key string, limit, offset int) (*wechatdb.GetContactsResp, error) {
 	return s.db.GetContacts(key, limit, offset)
 }
@@ -129,6 +263,17 @@ func (s *Service) GetAvatar(username string, size string) (*model.Avatar, error)
 	return s.db.GetAvatar(username, size)
 }
 
+// GetWebhookQueue exposes the outbound webhook queue; nil when neither webhooks
+// nor webhook digest targets are configured.
+func (s *Service) GetWebhookQueue() *webhook.Queue {
+	if s.webhook != nil {
+		if q := s.webhook.Queue(); q != nil {
+			return q
+		}
+	}
+	return s.digestQueue
+}
+
 func (s *Service) initWebhook() error {
 	if s.webhook == nil {
 		return nil
@@ -146,10 +291,104 @@ func (s *Service) initWebhook() error {
 	return nil
 }
 
+// initMirror 按配置打开 SQL 镜像库，并在消息、联系人、群聊文件变化时增量同步
+func (s *Service) initMirror() {
+	cfg := s.conf.GetMirror()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	m, err := mirror.Open(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("open sql mirror failed")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mirror = m
+	s.mirrorCancel = cancel
+
+	w := mirror.NewWatcher(ctx, m, s.db)
+	for _, group := range []string{"message", "contact", "chatroom"} {
+		if err := s.db.SetCallback(group, w.Callback(group)); err != nil {
+			log.Warn().Err(err).Msgf("set mirror callback for %s failed", group)
+		}
+	}
+	log.Info().Str("driver", cfg.Driver).Msg("sql mirror enabled")
+}
+
+func (s *Service) closeMirror() {
+	if s.mirrorCancel != nil {
+		s.mirrorCancel()
+		s.mirrorCancel = nil
+	}
+	if s.mirror != nil {
+		s.mirror.Close()
+		s.mirror = nil
+	}
+}
+
+// initDigest 按配置启动定时摘要
+func (s *Service) initDigest() {
+	cfg := s.conf.GetDigest()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var stateDir string
+	queue := s.GetWebhookQueue()
+	if workDir := s.conf.GetWorkDir(); workDir != "" {
+		stateDir = filepath.Join(workDir, "digest")
+		// 未配置消息 webhook 时单独打开同目录的队列，webhook 摘要同样可重试、可重放死信
+		if queue == nil && hasWebhookTarget(cfg) {
+			q, err := webhook.NewQueue(filepath.Join(workDir, "webhook"), 0)
+			if err != nil {
+				log.Error().Err(err).Msg("open digest webhook queue failed")
+			} else {
+				queue = q
+				s.digestQueue = q
+				go q.Run(ctx)
+			}
+		}
+	}
+	s.digest = digest.New(cfg, s.db, queue, stateDir)
+	s.digestCancel = cancel
+	go s.digest.Run(ctx)
+	log.Info().Int("items", len(s.digest.Status())).Msg("digest scheduler enabled")
+}
+
+func hasWebhookTarget(cfg *conf.DigestConfig) bool {
+	for _, item := range cfg.Items {
+		if item == nil || item.Disabled {
+			continue
+		}
+		for _, target := range item.Targets {
+			if target != nil && target.Type == "webhook" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Service) closeDigest() {
+	if s.digestCancel != nil {
+		s.digestCancel()
+		s.digestCancel = nil
+	}
+	s.digest = nil
+	s.digestQueue = nil
+}
+
+// GetDigest exposes the digest scheduler; nil when digests are not configured.
+func (s *Service) GetDigest() *digest.Scheduler {
+	return s.digest
+}
+
 // Close closes the database connection
 func (s *Service) Close() {
-	// Add cleanup code if needed
+	s.closeDigest()
+	s.closeMirror()
 	s.db.Close()
+	s.closeTranscripts()
 	if s.webhookCancel != nil {
 		s.webhookCancel()
 		s.webhookCancel = nil
@@ -0,0 +1,257 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/webhook"
+)
+
+// Deliverer 把摘要投递到一个目标
+type Deliverer interface {
+	Deliver(ctx context.Context, d *Digest) error
+	String() string
+}
+
+// NewDeliverer 按投递目标配置创建 Deliverer，smtp 类型使用全局的 SMTP 配置，
+// webhook 类型在 queue 不为 nil 时经由持久化队列投递
+func NewDeliverer(target *conf.DigestTarget, smtpCfg *conf.SMTPConfig, queue *webhook.Queue) (Deliverer, error) {
+	switch target.Type {
+	case "file":
+		if strings.TrimSpace(target.Dir) == "" {
+			return nil, fmt.Errorf("digest file target requires dir")
+		}
+		return &FileDeliverer{Dir: target.Dir}, nil
+	case "webhook":
+		if target.Webhook == nil || strings.TrimSpace(target.Webhook.URL) == "" {
+			return nil, fmt.Errorf("digest webhook target requires webhook.url")
+		}
+		w := &WebhookDeliverer{URL: target.Webhook.URL, Secret: target.Webhook.Secret, Queue: queue, Client: &http.Client{Timeout: 30 * time.Second}}
+		if queue != nil {
+			w.Hook = webhook.HookID("digest", target.Webhook)
+			queue.SetSecret(w.Hook, w.Secret)
+		}
+		return w, nil
+	case "smtp", "email", "mail":
+		if smtpCfg == nil || smtpCfg.Host == "" {
+			return nil, fmt.Errorf("digest smtp target requires digest.smtp.host")
+		}
+		if len(target.To) == 0 {
+			return nil, fmt.Errorf("digest smtp target requires to")
+		}
+		return &SMTPDeliverer{Config: *smtpCfg, To: target.To}, nil
+	}
+	return nil, fmt.Errorf("unknown digest target type: %s", target.Type)
+}
+
+// FileDeliverer 把摘要写入 <Dir>/<name>_<日期>.md，同一天重复生成时覆盖
+type FileDeliverer struct {
+	Dir string
+}
+
+func (f *FileDeliverer) Deliver(_ context.Context, d *Digest) error {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(f.Path(d), []byte(d.Text()), 0o644)
+}
+
+// Path 返回摘要文件路径
+func (f *FileDeliverer) Path(d *Digest) string {
+	name := d.Name
+	if name == "" {
+		name = "digest"
+	}
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return filepath.Join(f.Dir, fmt.Sprintf("%s_%s.md", name, d.End.Format("2006-01-02")))
+}
+
+func (f *FileDeliverer) String() string {
+	return "file:" + f.Dir
+}
+
+// WebhookDeliverer 以 JSON POST 摘要，签名方式与消息 webhook 相同。
+// Queue 不为 nil 时摘要写入消息 webhook 的持久化队列，失败按队列的退避策略重试，
+// 重试耗尽后进入死信；否则直接请求一次
+type WebhookDeliverer struct {
+	URL    string
+	Secret string
+	Queue  *webhook.Queue
+	Client *http.Client
+
+	// Hook 为写入队列时的 hook 标识，队列据此查找签名密钥，密钥本身不落盘
+	Hook string
+}
+
+// WebhookPayload 为 webhook 投递的请求体
+type WebhookPayload struct {
+	Type string `json:"type"`
+	*Digest
+	Text string `json:"text"`
+}
+
+func (w *WebhookDeliverer) Deliver(ctx context.Context, d *Digest) error {
+	body, err := json.Marshal(WebhookPayload{Type: "digest", Digest: d, Text: d.Text()})
+	if err != nil {
+		return err
+	}
+	if w.Queue != nil {
+		_, err := w.Queue.Enqueue(w.URL, w.Hook, body)
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.HeaderTimestamp, timestamp)
+	if w.Secret != "" {
+		req.Header.Set(webhook.HeaderSignature, webhook.Sign(w.Secret, timestamp, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *WebhookDeliverer) String() string {
+	return "webhook:" + w.URL
+}
+
+// smtpTimeout 为一次发信的总时限，服务器无响应时不会一直占用调度循环
+const smtpTimeout = 2 * time.Minute
+
+// SMTPDeliverer 以纯文本邮件发送摘要
+type SMTPDeliverer struct {
+	Config conf.SMTPConfig
+	To     []string
+}
+
+func (m *SMTPDeliverer) Deliver(ctx context.Context, d *Digest) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(m.Config.Host, strconv.Itoa(m.Config.Port))
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if m.Config.TLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: m.Config.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	// 截止时间取 ctx 与 smtpTimeout 中较早者，之后的读写超时即失败
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, m.Config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if !m.Config.TLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: m.Config.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if m.Config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.Config.Username, m.Config.Password, m.Config.Host)); err != nil {
+			return err
+		}
+	}
+
+	from := m.from()
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(strings.TrimSpace(to)); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.message(from, d)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (m *SMTPDeliverer) from() string {
+	if m.Config.From != "" {
+		return m.Config.From
+	}
+	return m.Config.Username
+}
+
+// message 组装 MIME 邮件，主题按 RFC 2047 编码，正文为 base64 编码的 UTF-8 文本
+func (m *SMTPDeliverer) message(from string, d *Digest) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("From: " + from + "\r\n")
+	buf.WriteString("To: " + strings.Join(m.To, ", ") + "\r\n")
+	buf.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", d.Title) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(d.Text()))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
+
+func (m *SMTPDeliverer) String() string {
+	return "smtp:" + strings.Join(m.To, ",")
+}
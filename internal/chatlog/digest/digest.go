@@ -0,0 +1,227 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+)
+
+// Source 为生成摘要所需的数据访问，*wechatdb.DB 与 database.Service 均已实现
+type Source interface {
+	GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error)
+	GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
+}
+
+// Group 是一个会话在时间范围内的消息
+type Group struct {
+	Talker     string           `json:"talker"`
+	TalkerName string           `json:"talker_name,omitempty"`
+	Messages   []*model.Message `json:"-"`
+}
+
+// Collect 返回 [start, end] 内至少有一条我发送的消息的会话，即聊天日记的分组方式
+func Collect(src Source, start, end time.Time, talker string) ([]*Group, error) {
+	sessionsResp, err := src.GetSessions(talker, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*Group, 0)
+	for _, sess := range sessionsResp.Items {
+		msgs, err := src.GetMessages(start, end, sess.UserName, "", "", 0, 0)
+		if err != nil || len(msgs) == 0 {
+			continue
+		}
+		hasSelf := false
+		for _, m := range msgs {
+			if m.IsSelf {
+				hasSelf = true
+				break
+			}
+		}
+		if !hasSelf {
+			continue
+		}
+		groups = append(groups, &Group{Talker: sess.UserName, TalkerName: sess.NickName, Messages: msgs})
+	}
+	return groups, nil
+}
+
+// CollectTalkers 返回指定会话在 [start, end] 内的全部消息，talkers 为 ID、备注或昵称，不要求我参与过
+func CollectTalkers(src Source, start, end time.Time, talkers []string) ([]*Group, error) {
+	groups := make([]*Group, 0, len(talkers))
+	seen := make(map[string]*Group)
+	for _, talker := range talkers {
+		talker = strings.TrimSpace(talker)
+		if talker == "" {
+			continue
+		}
+		msgs, err := src.GetMessages(start, end, talker, "", "", 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			g := seen[m.Talker]
+			if g == nil {
+				g = &Group{Talker: m.Talker, TalkerName: m.TalkerName}
+				seen[m.Talker] = g
+				groups = append(groups, g)
+			}
+			g.Messages = append(g.Messages, m)
+		}
+	}
+	return groups, nil
+}
+
+// Write 按会话分组输出消息，limit > 0 时每个会话只保留最近 limit 条
+func Write(buf *bytes.Buffer, groups []*Group, limit int) {
+	for _, g := range groups {
+		header := g.Talker
+		if g.TalkerName != "" {
+			header = fmt.Sprintf("%s(%s)", g.TalkerName, g.Talker)
+		}
+		msgs := g.Messages
+		if limit > 0 && len(msgs) > limit {
+			msgs = msgs[len(msgs)-limit:]
+		}
+		buf.WriteString(fmt.Sprintf("[%s] - %d条\n", header, len(g.Messages)))
+		for _, m := range msgs {
+			sender := m.Sender
+			if m.IsSelf {
+				sender = "我"
+			}
+			if m.SenderName != "" {
+				sender = fmt.Sprintf("%s(%s)", m.SenderName, sender)
+			}
+			buf.WriteString(m.Time.Format("2006-01-02 15:04:05"))
+			buf.WriteString(" ")
+			buf.WriteString(sender)
+			buf.WriteString(" ")
+			buf.WriteString(m.PlainTextContent())
+			buf.WriteString("\n")
+		}
+		buf.WriteString("-----------------------------\n")
+	}
+}
+
+// Fit 计算总条数不超过 total 时每个会话可保留的条数，供 Write 使用。
+// 消息少的会话全部保留，剩余额度平均分给其余会话；会话数超过 total 时只保留前 total 个会话
+func Fit(groups []*Group, total int) ([]*Group, int) {
+	if total <= 0 {
+		return groups, 0
+	}
+	if len(groups) > total {
+		groups = groups[:total]
+	}
+	count := func(limit int) int {
+		n := 0
+		for _, g := range groups {
+			n += min(len(g.Messages), limit)
+		}
+		return n
+	}
+	hi := 1
+	for _, g := range groups {
+		hi = max(hi, len(g.Messages))
+	}
+	// count 随 limit 单调递增，二分查找满足预算的最大 limit
+	lo := 1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if count(mid) <= total {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return groups, lo
+}
+
+// Digest 是一次生成的摘要
+type Digest struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Title    string    `json:"title"`
+	Groups   []*Group  `json:"groups"`
+	Messages int       `json:"messages"`
+	Summary  string    `json:"summary,omitempty"`
+
+	// Transcript 为按会话分组的聊天记录，Summary 为空时作为正文
+	Transcript string `json:"transcript"`
+}
+
+// Build 收集 item 在 [start, end] 内的消息并生成摘要，summarizer 为 nil 或 item 未开启总结时只输出聊天记录
+func Build(ctx context.Context, src Source, summarizer Summarizer, item *conf.DigestItem, start, end time.Time) (*Digest, error) {
+	var groups []*Group
+	var err error
+	if strings.TrimSpace(item.Talker) == "" {
+		groups, err = Collect(src, start, end, "")
+	} else {
+		groups, err = CollectTalkers(src, start, end, strings.Split(item.Talker, ","))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Digest{
+		Name:     item.Name,
+		Schedule: item.Schedule,
+		Start:    start,
+		End:      end,
+		Title:    Title(item, start, end),
+		Groups:   groups,
+	}
+	for _, g := range groups {
+		d.Messages += len(g.Messages)
+	}
+
+	buf := &bytes.Buffer{}
+	Write(buf, groups, item.MaxMessages)
+	d.Transcript = buf.String()
+
+	if item.Summarize && summarizer != nil && d.Messages > 0 {
+		summary, err := summarizer.Summarize(ctx, d.Title, d.Transcript)
+		if err != nil {
+			return nil, err
+		}
+		d.Summary = strings.TrimSpace(summary)
+	}
+	return d, nil
+}
+
+// Title 返回摘要标题，如 "项目群 日报 2024-05-01"
+func Title(item *conf.DigestItem, start, end time.Time) string {
+	name := item.Name
+	if name == "" {
+		name = "聊天"
+	}
+	if item.Schedule == "weekly" {
+		return fmt.Sprintf("%s 周报 %s ~ %s", name, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("%s 日报 %s", name, end.Format("2006-01-02"))
+}
+
+// Text 返回用于文件与邮件的正文
+func (d *Digest) Text() string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# " + d.Title + "\n\n")
+	buf.WriteString(fmt.Sprintf("%s ~ %s，%d 个会话，%d 条消息\n\n",
+		d.Start.Format("2006-01-02 15:04"), d.End.Format("2006-01-02 15:04"), len(d.Groups), d.Messages))
+	if d.Messages == 0 {
+		buf.WriteString("时间范围内没有消息\n")
+		return buf.String()
+	}
+	if d.Summary != "" {
+		buf.WriteString("## 总结\n\n" + d.Summary + "\n\n## 聊天记录\n\n")
+	}
+	buf.WriteString(d.Transcript)
+	return buf.String()
+}
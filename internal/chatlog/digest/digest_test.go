@@ -0,0 +1,409 @@
+package digest
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/webhook"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+)
+
+type fakeSource struct {
+	sessions []*model.Session
+	messages map[string][]*model.Message
+}
+
+func (f *fakeSource) GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
+	return &wechatdb.GetSessionsResp{Items: f.sessions}, nil
+}
+
+func (f *fakeSource) GetMessages(start, end time.Time, talker, sender, keyword string, limit, offset int) ([]*model.Message, error) {
+	var out []*model.Message
+	for _, m := range f.messages[talker] {
+		if !m.Time.Before(start) && !m.Time.After(end) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+type fakeSummarizer struct{ transcript string }
+
+func (f *fakeSummarizer) Summarize(ctx context.Context, title, transcript string) (string, error) {
+	f.transcript = transcript
+	return "  今天讨论了发布计划  ", nil
+}
+
+var base = time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local) // 星期三
+
+func newSource() *fakeSource {
+	return &fakeSource{
+		sessions: []*model.Session{{UserName: "alice", NickName: "Alice"}, {UserName: "g@chatroom", NickName: "项目群"}},
+		messages: map[string][]*model.Message{
+			"alice": {
+				{Talker: "alice", TalkerName: "Alice", Sender: "alice", Time: base.Add(-3 * time.Hour), Type: model.MessageTypeText, Content: "在吗"},
+				{Talker: "alice", TalkerName: "Alice", IsSelf: true, Time: base.Add(-2 * time.Hour), Type: model.MessageTypeText, Content: "在"},
+			},
+			"g@chatroom": {
+				{Talker: "g@chatroom", TalkerName: "项目群", Sender: "bob", Time: base.Add(-26 * time.Hour), Type: model.MessageTypeText, Content: "昨天的"},
+				{Talker: "g@chatroom", TalkerName: "项目群", Sender: "bob", Time: base.Add(-time.Hour), Type: model.MessageTypeText, Content: "周五发布"},
+			},
+		},
+	}
+}
+
+func TestNext(t *testing.T) {
+	daily := &conf.DigestItem{Schedule: "daily"}
+	if got := Next(daily, 8, 0, base.Add(-time.Minute)); !got.Equal(base) {
+		t.Fatalf("daily before = %v", got)
+	}
+	if got := Next(daily, 8, 0, base); !got.Equal(base.AddDate(0, 0, 1)) {
+		t.Fatalf("daily at = %v", got)
+	}
+
+	weekly := &conf.DigestItem{Schedule: "weekly"}
+	if got := Next(weekly, 8, 0, base); got.Weekday() != time.Monday || !got.Equal(base.AddDate(0, 0, 5)) {
+		t.Fatalf("weekly = %v", got)
+	}
+	wd := 3
+	weekly.Weekday = &wd
+	if got := Next(weekly, 9, 30, base); !got.Equal(base.Add(90 * time.Minute)) {
+		t.Fatalf("weekly same day = %v", got)
+	}
+	if got := Next(weekly, 8, 0, base); !got.Equal(base.AddDate(0, 0, 7)) {
+		t.Fatalf("weekly next week = %v", got)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	src := newSource()
+
+	// 未指定会话时按日记口径，只包含我参与过的会话
+	item := &conf.DigestItem{Name: "日记", Schedule: "daily", MaxMessages: 200}
+	d, err := Build(context.Background(), src, nil, item, WindowStart(item, base), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Groups) != 1 || d.Groups[0].Talker != "alice" || d.Messages != 2 || d.Summary != "" {
+		t.Fatalf("digest = %+v", d)
+	}
+	if d.Title != "日记 日报 2024-05-01" || !strings.Contains(d.Text(), "[Alice(alice)] - 2条") {
+		t.Fatalf("text = %s", d.Text())
+	}
+
+	// 指定会话时不要求我参与，并按配置调用总结
+	item = &conf.DigestItem{Name: "项目群", Schedule: "daily", Talker: "g@chatroom", Summarize: true, MaxMessages: 200}
+	summarizer := &fakeSummarizer{}
+	d, err = Build(context.Background(), src, summarizer, item, WindowStart(item, base), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Messages != 1 || d.Summary != "今天讨论了发布计划" || !strings.Contains(summarizer.transcript, "周五发布") {
+		t.Fatalf("digest = %+v", d)
+	}
+	if text := d.Text(); !strings.Contains(text, "## 总结\n\n今天讨论了发布计划") || strings.Contains(text, "昨天的") {
+		t.Fatalf("text = %s", text)
+	}
+}
+
+func TestFit(t *testing.T) {
+	group := func(n int) *Group { return &Group{Messages: make([]*model.Message, n)} }
+	groups := []*Group{group(2), group(50), group(100)}
+
+	// 小会话全部保留，其余会话平分剩余额度
+	kept, limit := Fit(groups, 60)
+	if len(kept) != 3 || limit != 29 {
+		t.Fatalf("fit = %d groups, limit %d", len(kept), limit)
+	}
+	if _, limit := Fit(groups, 1000); limit != 100 {
+		t.Fatalf("fit within budget limit = %d", limit)
+	}
+	if kept, limit := Fit(groups, 2); len(kept) != 2 || limit != 1 {
+		t.Fatalf("fit more groups than budget = %d groups, limit %d", len(kept), limit)
+	}
+}
+
+func TestSchedulerRunNow(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &conf.DigestConfig{
+		Enabled: true,
+		Items: []*conf.DigestItem{{
+			Name:    "项目群",
+			Talker:  "g@chatroom",
+			Targets: []*conf.DigestTarget{{Type: "file", Dir: dir}, {Type: "smtp", To: []string{"me@example.com"}}},
+		}},
+	}
+	s := New(cfg, newSource(), nil, "")
+	s.now = func() time.Time { return base }
+
+	status := s.Status()
+	// smtp 目标缺少服务器配置，被跳过
+	if len(status) != 1 || len(status[0].Targets) != 1 || !status[0].Next.Equal(base.AddDate(0, 0, 1)) {
+		t.Fatalf("status = %+v", status)
+	}
+
+	if _, err := s.RunNow(context.Background(), "missing"); err == nil {
+		t.Fatal("expected error for unknown digest")
+	}
+	d, err := s.RunNow(context.Background(), "项目群")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile((&FileDeliverer{Dir: dir}).Path(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# 项目群 日报 2024-05-01") {
+		t.Fatalf("file = %s", data)
+	}
+	if st := s.Status()[0]; st.LastRun == nil || st.Messages != 1 || st.LastError != "" {
+		t.Fatalf("status = %+v", st)
+	}
+}
+
+func TestSchedulerCatchUp(t *testing.T) {
+	dir, stateDir := t.TempDir(), t.TempDir()
+	cfg := &conf.DigestConfig{
+		Enabled: true,
+		Items:   []*conf.DigestItem{{Name: "项目群", Talker: "g@chatroom", Targets: []*conf.DigestTarget{{Type: "file", Dir: dir}}}},
+	}
+	// 上次运行在两天前，今天 08:00 的摘要在进程停止期间错过了
+	state, _ := json.Marshal(map[string]time.Time{"项目群": base.AddDate(0, 0, -2)})
+	if err := os.WriteFile(filepath.Join(stateDir, stateFileName), state, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(cfg, newSource(), nil, stateDir)
+	s.now = func() time.Time { return base.Add(3 * time.Hour) }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	path := (&FileDeliverer{Dir: dir}).Path(&Digest{Name: "项目群", End: base})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("missed digest was not caught up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 补跑的统计窗口截止到错过的计划时间，而不是启动时间
+	if !strings.Contains(string(data), "2024-04-30 08:00 ~ 2024-05-01 08:00") {
+		t.Fatalf("file = %s", data)
+	}
+	if got := s.loadState()["项目群"]; !got.Equal(base) {
+		t.Fatalf("state = %v", got)
+	}
+	if next := s.Status()[0].Next; !next.Equal(base.AddDate(0, 0, 1)) {
+		t.Fatalf("next = %v", next)
+	}
+}
+
+func TestWebhookDeliverer(t *testing.T) {
+	var payload WebhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		want := webhook.Sign("s3cret", r.Header.Get(webhook.HeaderTimestamp), body)
+		if r.Header.Get(webhook.HeaderSignature) != want {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &payload)
+	}))
+	defer srv.Close()
+
+	d, err := NewDeliverer(&conf.DigestTarget{Type: "webhook", Webhook: &conf.WebhookItem{URL: srv.URL, Secret: "s3cret"}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := &Digest{Name: "n", Title: "n 日报 2024-05-01", End: base, Messages: 0}
+	if err := d.Deliver(context.Background(), digest); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Type != "digest" || payload.Digest == nil || payload.Title != digest.Title || !strings.Contains(payload.Text, "没有消息") {
+		t.Fatalf("payload = %+v", payload)
+	}
+}
+
+func TestWebhookDelivererQueue(t *testing.T) {
+	received := make(chan WebhookPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(webhook.HeaderSignature) != webhook.Sign("s3cret", r.Header.Get(webhook.HeaderTimestamp), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload WebhookPayload
+		json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer srv.Close()
+
+	queue, err := webhook.NewQueue(t.TempDir(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDeliverer(&conf.DigestTarget{Type: "webhook", Webhook: &conf.WebhookItem{URL: srv.URL, Secret: "s3cret"}}, nil, queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := &Digest{Name: "n", Title: "n 日报 2024-05-01", End: base}
+	if err := d.Deliver(context.Background(), digest); err != nil {
+		t.Fatal(err)
+	}
+	// 投递只写入队列，由队列负责发送与重试
+	if queue.Pending() != 1 {
+		t.Fatalf("pending = %d", queue.Pending())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+	select {
+	case payload := <-received:
+		if payload.Type != "digest" || payload.Title != digest.Title {
+			t.Fatalf("payload = %+v", payload)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("queued digest was not delivered")
+	}
+}
+
+// serveSMTP 是一个最小的本地 SMTP 替身，返回收到的 DATA 内容
+func serveSMTP(t *testing.T) (port int, received <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				ch <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, ch
+}
+
+func TestSMTPDeliverer(t *testing.T) {
+	port, received := serveSMTP(t)
+	d, err := NewDeliverer(&conf.DigestTarget{Type: "smtp", To: []string{"me@example.com"}},
+		&conf.SMTPConfig{Host: "127.0.0.1", Port: port, From: "chatlog@example.com"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := &Digest{Name: "n", Title: "项目群 日报 2024-05-01", End: base, Messages: 1, Transcript: "[项目群] - 1条\n"}
+	if err := d.Deliver(context.Background(), digest); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-received
+	header, body, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok || !strings.Contains(header, "To: me@example.com") || !strings.Contains(header, "Subject: =?UTF-8?b?") {
+		t.Fatalf("message = %s", msg)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(decoded), "[项目群] - 1条") {
+		t.Fatalf("body = %s", decoded)
+	}
+}
+
+func TestOpenAISummarizer(t *testing.T) {
+	var req struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","object":"chat.completion","created":`+strconv.FormatInt(base.Unix(), 10)+
+			`,"model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"总结内容"}}]}`)
+	}))
+	defer srv.Close()
+
+	s, err := NewOpenAISummarizer(&conf.DigestSummaryConfig{LLMConfig: conf.LLMConfig{Model: "m", APIKey: "k", BaseURL: srv.URL}, Prompt: "请总结"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Summarize(context.Background(), "标题", "记录")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "总结内容" || req.Model != "m" || len(req.Messages) != 2 || req.Messages[0].Content != "请总结" || req.Messages[1].Content != "标题\n\n记录" {
+		t.Fatalf("got %q, request %+v", got, req)
+	}
+}
@@ -0,0 +1,316 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/webhook"
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+)
+
+// stateFileName 记录每个任务最近一次运行的统计截止时间，用于补跑进程停止期间错过的摘要
+const stateFileName = "state.json"
+
+// Status 是一个摘要任务的运行状态
+type Status struct {
+	Name      string     `json:"name"`
+	Schedule  string     `json:"schedule"`
+	Talker    string     `json:"talker,omitempty"`
+	Targets   []string   `json:"targets"`
+	Next      time.Time  `json:"next"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	Messages  int        `json:"messages"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type job struct {
+	item    *conf.DigestItem
+	hour    int
+	minute  int
+	targets []Deliverer
+	status  Status
+
+	// last 为最近一次运行的统计截止时间
+	last time.Time
+}
+
+// Scheduler 按配置定时生成摘要并投递；每个任务在运行时间到达时汇总之前一天（周报为七天）的消息
+type Scheduler struct {
+	src        Source
+	summarizer Summarizer
+	now        func() time.Time
+	stateDir   string
+
+	mu   sync.Mutex
+	jobs []*job
+}
+
+// New 按配置创建调度器，配置错误的任务会被跳过并记录日志。
+// queue 不为 nil 时 webhook 目标经由该队列投递；stateDir 不为空时在其中记录运行进度，
+// 启动时补跑停止期间错过的最近一次摘要
+func New(cfg *conf.DigestConfig, src Source, queue *webhook.Queue, stateDir string) *Scheduler {
+	s := &Scheduler{src: src, now: time.Now, stateDir: stateDir}
+	if cfg == nil {
+		return s
+	}
+	cfg.Normalize()
+
+	if cfg.Summary != nil {
+		summarizer, err := NewOpenAISummarizer(cfg.Summary)
+		if err != nil {
+			log.Err(err).Msg("initialise digest summarizer failed")
+		} else {
+			s.summarizer = summarizer
+		}
+	}
+
+	for i, item := range cfg.Items {
+		if item == nil || item.Disabled {
+			continue
+		}
+		if item.Name == "" {
+			item.Name = fmt.Sprintf("digest%d", i+1)
+		}
+		at, err := time.Parse("15:04", item.At)
+		if err != nil {
+			log.Error().Str("name", item.Name).Str("at", item.At).Msg("invalid digest time, skipped")
+			continue
+		}
+		j := &job{item: item, hour: at.Hour(), minute: at.Minute()}
+		for _, target := range item.Targets {
+			if target == nil {
+				continue
+			}
+			d, err := NewDeliverer(target, cfg.SMTP, queue)
+			if err != nil {
+				log.Error().Err(err).Str("name", item.Name).Msg("invalid digest target, skipped")
+				continue
+			}
+			j.targets = append(j.targets, d)
+			j.status.Targets = append(j.status.Targets, d.String())
+		}
+		j.status.Name = item.Name
+		j.status.Schedule = item.Schedule
+		j.status.Talker = item.Talker
+		s.jobs = append(s.jobs, j)
+	}
+
+	state := s.loadState()
+	for _, j := range s.jobs {
+		j.last = state[j.item.Name]
+	}
+	return s
+}
+
+// SetSummarizer 替换总结器，便于测试或使用其他实现
+func (s *Scheduler) SetSummarizer(summarizer Summarizer) {
+	s.summarizer = summarizer
+}
+
+// Run 阻塞运行调度循环，直到 ctx 取消
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.jobs) == 0 {
+		return
+	}
+	for {
+		now := s.now()
+		var wake time.Time
+		s.mu.Lock()
+		for _, j := range s.jobs {
+			if j.status.Next.IsZero() {
+				j.status.Next = Next(j.item, j.hour, j.minute, now)
+				// 上次运行早于最近一次计划时间，说明停止期间错过了，立即补跑
+				if prev := Prev(j.item, j.hour, j.minute, now); !j.last.IsZero() && j.last.Before(prev) {
+					log.Info().Str("name", j.item.Name).Time("missed", prev).Msg("catch up missed digest")
+					j.status.Next = prev
+				}
+			}
+			if wake.IsZero() || j.status.Next.Before(wake) {
+				wake = j.status.Next
+			}
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wake.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now = s.now()
+		for _, j := range s.jobs {
+			s.mu.Lock()
+			due := !j.status.Next.After(now)
+			at := j.status.Next
+			s.mu.Unlock()
+			if !due {
+				continue
+			}
+			s.run(ctx, j, at)
+			s.mu.Lock()
+			j.status.Next = Next(j.item, j.hour, j.minute, now)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// RunNow 立即生成名为 name 的摘要并投递，时间范围截止到当前时间
+func (s *Scheduler) RunNow(ctx context.Context, name string) (*Digest, error) {
+	for _, j := range s.jobs {
+		if j.item.Name == name {
+			return s.run(ctx, j, s.now())
+		}
+	}
+	return nil, errors.InvalidArg("name")
+}
+
+// Status 返回全部任务的运行状态
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		st := j.status
+		if st.Next.IsZero() {
+			st.Next = Next(j.item, j.hour, j.minute, s.now())
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// run 生成并投递一次摘要，单个目标失败不影响其他目标
+func (s *Scheduler) run(ctx context.Context, j *job, end time.Time) (*Digest, error) {
+	start := WindowStart(j.item, end)
+	d, err := Build(ctx, s.src, s.summarizer, j.item, start, end)
+
+	var errs []string
+	if err == nil {
+		for _, target := range j.targets {
+			if derr := target.Deliver(ctx, d); derr != nil {
+				log.Error().Err(derr).Str("name", j.item.Name).Str("target", target.String()).Msg("deliver digest failed")
+				errs = append(errs, fmt.Sprintf("%s: %v", target.String(), derr))
+			}
+		}
+	} else {
+		log.Error().Err(err).Str("name", j.item.Name).Msg("build digest failed")
+		errs = append(errs, err.Error())
+	}
+
+	s.mu.Lock()
+	ran := s.now()
+	j.status.LastRun = &ran
+	j.status.LastError = ""
+	j.status.Messages = 0
+	if d != nil {
+		j.status.Messages = d.Messages
+	}
+	if len(errs) > 0 {
+		j.status.LastError = fmt.Sprint(errs)
+	}
+	if end.After(j.last) {
+		j.last = end
+	}
+	state := make(map[string]time.Time, len(s.jobs))
+	for _, other := range s.jobs {
+		if !other.last.IsZero() {
+			state[other.item.Name] = other.last
+		}
+	}
+	// 持锁写入，RunNow 与调度循环并发时不会交错写同一个文件
+	s.saveState(state)
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("name", j.item.Name).Int("messages", d.Messages).Msg("digest delivered")
+	return d, nil
+}
+
+// Next 返回 after 之后的下一次运行时间
+func Next(item *conf.DigestItem, hour, minute int, after time.Time) time.Time {
+	next := time.Date(after.Year(), after.Month(), after.Day(), hour, minute, 0, 0, after.Location())
+	if item.Schedule == "weekly" {
+		weekday := time.Monday
+		if item.Weekday != nil {
+			weekday = time.Weekday(((*item.Weekday % 7) + 7) % 7)
+		}
+		next = next.AddDate(0, 0, (int(weekday)-int(next.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Prev 返回不晚于 at 的最近一次计划运行时间
+func Prev(item *conf.DigestItem, hour, minute int, at time.Time) time.Time {
+	next := Next(item, hour, minute, at)
+	if item.Schedule == "weekly" {
+		return next.AddDate(0, 0, -7)
+	}
+	return next.AddDate(0, 0, -1)
+}
+
+// loadState 读取各任务最近一次运行的统计截止时间，文件不存在时返回空
+func (s *Scheduler) loadState() map[string]time.Time {
+	state := make(map[string]time.Time)
+	if s.stateDir == "" {
+		return state
+	}
+	data, err := os.ReadFile(filepath.Join(s.stateDir, stateFileName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Err(err).Msg("read digest state failed")
+		}
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Warn().Err(err).Msg("parse digest state failed")
+	}
+	return state
+}
+
+// saveState 先写临时文件再替换，避免进程中断留下损坏的状态文件
+func (s *Scheduler) saveState(state map[string]time.Time) {
+	if s.stateDir == "" {
+		return
+	}
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = os.MkdirAll(s.stateDir, 0o755)
+	}
+	path := filepath.Join(s.stateDir, stateFileName)
+	if err == nil {
+		err = os.WriteFile(path+".tmp", data, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("save digest state failed")
+	}
+}
+
+// WindowStart 返回截止到 end 的统计起点：日报为前一天，周报为前七天
+func WindowStart(item *conf.DigestItem, end time.Time) time.Time {
+	if item.Schedule == "weekly" {
+		return end.AddDate(0, 0, -7)
+	}
+	return end.AddDate(0, 0, -1)
+}
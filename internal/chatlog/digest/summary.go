@@ -0,0 +1,55 @@
+package digest
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/llm"
+)
+
+// DefaultPrompt 为未配置 prompt 时使用的总结要求
+const DefaultPrompt = `请总结下面的聊天记录，按以下结构输出：
+1. 主要话题（每个话题一两句话，标注主要参与者）
+2. 已达成的结论或决定
+3. 待办事项与负责人（没有则写“无”）
+4. 值得我关注、需要我回复的消息
+
+只依据聊天记录中的事实，不要编造。`
+
+// Summarizer 把聊天记录总结为一段文本
+type Summarizer interface {
+	Summarize(ctx context.Context, title, transcript string) (string, error)
+}
+
+// OpenAISummarizer 调用 OpenAI 或兼容服务的 /chat/completions 接口
+type OpenAISummarizer struct {
+	client llm.Client
+	prompt string
+}
+
+// NewOpenAISummarizer 按配置创建总结器
+func NewOpenAISummarizer(cfg *conf.DigestSummaryConfig) (*OpenAISummarizer, error) {
+	client, err := llm.NewOpenAIClient(llm.OpenAIConfig{
+		Model:          cfg.Model,
+		APIKey:         cfg.APIKey,
+		BaseURL:        cfg.BaseURL,
+		Organization:   cfg.Organization,
+		ProxyURL:       cfg.Proxy,
+		RequestTimeout: time.Duration(cfg.RequestTimeoutSeconds) * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	prompt := strings.TrimSpace(cfg.Prompt)
+	if prompt == "" {
+		prompt = DefaultPrompt
+	}
+	return &OpenAISummarizer{client: client, prompt: prompt}, nil
+}
+
+// Summarize 以配置的 prompt 作为系统消息，聊天记录作为用户消息请求总结
+func (s *OpenAISummarizer) Summarize(ctx context.Context, title, transcript string) (string, error) {
+	return s.client.Complete(ctx, s.prompt, title+"\n\n"+transcript)
+}
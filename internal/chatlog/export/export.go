@@ -0,0 +1,324 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+)
+
+// 支持的消息输出格式
+const (
+	FormatJSONL    = "jsonl"
+	FormatHTML     = "html"
+	FormatMarkdown = "md"
+)
+
+var Formats = []string{FormatJSONL, FormatHTML, FormatMarkdown}
+
+const pageSize = 2000
+
+// DB 是导出所需的只读数据接口，由 *wechatdb.DB 实现
+type DB interface {
+	GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
+	GetContacts(key string, limit, offset int) (*wechatdb.GetContactsResp, error)
+	GetChatRooms(key string, limit, offset int) (*wechatdb.GetChatRoomsResp, error)
+	GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error)
+	GetMedia(_type string, key string) (*model.Media, error)
+	GetAvatar(username string, size string) (*model.Avatar, error)
+}
+
+// TypeFilter 匹配消息类型；SubType 为 0 时匹配该类型下的全部子类型
+type TypeFilter struct {
+	Type    int64
+	SubType int64
+}
+
+type Options struct {
+	OutputDir string
+	DataDir   string // 微信数据目录，用于读取图片、视频、文件
+	Talkers   []string
+	Start     time.Time
+	End       time.Time
+	Types     []TypeFilter
+	Formats   []string
+	Zip       bool
+	NoMedia   bool
+}
+
+type Summary struct {
+	Talkers     int `json:"talkers"`
+	Messages    int `json:"messages"`
+	Media       int `json:"media"`
+	MediaFailed int `json:"mediaFailed"`
+}
+
+// Run 按会话导出聊天记录，每个会话一个目录（或 zip），根目录附带 contacts.json、chatrooms.json，
+// 导出 HTML 时另有 index.html 作为离线浏览入口
+func Run(ctx context.Context, db DB, opts Options) (*Summary, error) {
+	if strings.TrimSpace(opts.OutputDir) == "" {
+		return nil, fmt.Errorf("output dir is required")
+	}
+	if len(opts.Formats) == 0 {
+		opts.Formats = Formats
+	}
+	if err := os.MkdirAll(opts.OutputDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if err := writeDirectory(db, opts.OutputDir); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	talkers := opts.Talkers
+	sessions, err := db.GetSessions("", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, sess := range sessions.Items {
+		names[sess.UserName] = sess.NickName
+		if len(opts.Talkers) == 0 {
+			talkers = append(talkers, sess.UserName)
+		}
+	}
+
+	summary := &Summary{}
+	used := make(map[string]int)
+	var index []indexEntry
+	for _, talker := range talkers {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		dirName := archiveName(talker, names[talker], used)
+		n, err := exportTalker(ctx, db, opts, talker, names[talker], dirName, summary)
+		if err != nil {
+			return summary, fmt.Errorf("export %s: %w", talker, err)
+		}
+		if n > 0 {
+			summary.Talkers++
+			title := talker
+			if name := names[talker]; name != "" && name != talker {
+				title = name + " (" + talker + ")"
+			}
+			index = append(index, indexEntry{Href: dirName + "/messages.html", Title: title, Messages: n})
+			log.Info().Str("talker", talker).Int("messages", n).Msg("exported")
+		}
+	}
+
+	// zip 模式下各会话不在同一目录，不生成索引页
+	if !opts.Zip && hasFormat(opts.Formats, FormatHTML) {
+		if err := writeIndex(filepath.Join(opts.OutputDir, "index.html"), index); err != nil {
+			return summary, err
+		}
+	}
+
+	return summary, nil
+}
+
+func writeDirectory(db DB, dir string) error {
+	contacts, err := db.GetContacts("", 0, 0)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(dir, "contacts.json"), contacts.Items); err != nil {
+		return err
+	}
+	chatRooms, err := db.GetChatRooms("", 0, 0)
+	if err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(dir, "chatrooms.json"), chatRooms.Items)
+}
+
+func exportTalker(ctx context.Context, db DB, opts Options, talker, talkerName, dirName string, summary *Summary) (int, error) {
+	dir := filepath.Join(opts.OutputDir, dirName)
+	if opts.Zip {
+		dir = filepath.Join(opts.OutputDir, "."+dirName+".tmp")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+
+	media := &mediaResolver{db: db, dataDir: opts.DataDir, dir: dir}
+	var html *htmlRenderer
+	if opts.NoMedia {
+		html = newHTMLRenderer(db, nil, dir)
+	} else {
+		html = newHTMLRenderer(db, media, dir)
+	}
+	if !opts.Zip {
+		html.back = "../index.html"
+	}
+	w, err := newTalkerWriter(dir, talker, talkerName, opts.Formats, html)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for offset := 0; ; offset += pageSize {
+		if err := ctx.Err(); err != nil {
+			w.Close()
+			return count, err
+		}
+		messages, err := db.GetMessages(opts.Start, opts.End, talker, "", "", pageSize, offset)
+		if err != nil {
+			w.Close()
+			return count, err
+		}
+		for _, m := range messages {
+			if !matchType(opts.Types, m) {
+				continue
+			}
+			if talkerName == "" && m.TalkerName != "" {
+				talkerName = m.TalkerName
+				w.talkerName = talkerName
+			}
+			rel := ""
+			if !opts.NoMedia {
+				rel, err = media.Resolve(m)
+				if err != nil {
+					summary.MediaFailed++
+					log.Debug().Err(err).Str("talker", talker).Int64("seq", m.Seq).Msg("resolve media failed")
+				} else if rel != "" {
+					summary.Media++
+				}
+			}
+			if err := w.Write(m, rel); err != nil {
+				w.Close()
+				return count, err
+			}
+			count++
+		}
+		if len(messages) < pageSize {
+			break
+		}
+	}
+	if err := w.Close(); err != nil {
+		return count, err
+	}
+	summary.Messages += count
+
+	if count == 0 {
+		os.RemoveAll(dir)
+		return 0, nil
+	}
+	if opts.Zip {
+		defer os.RemoveAll(dir)
+		if err := zipDir(dir, filepath.Join(opts.OutputDir, dirName+".zip")); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+func hasFormat(formats []string, format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func matchType(filters []TypeFilter, m *model.Message) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if f.Type == m.Type && (f.SubType == 0 || f.SubType == m.SubType) {
+			return true
+		}
+	}
+	return false
+}
+
+var typeNames = map[string]TypeFilter{
+	"text":     {Type: model.MessageTypeText},
+	"image":    {Type: model.MessageTypeImage},
+	"voice":    {Type: model.MessageTypeVoice},
+	"card":     {Type: model.MessageTypeCard},
+	"video":    {Type: model.MessageTypeVideo},
+	"emoji":    {Type: model.MessageTypeAnimation},
+	"location": {Type: model.MessageTypeLocation},
+	"share":    {Type: model.MessageTypeShare},
+	"file":     {Type: model.MessageTypeShare, SubType: model.MessageSubTypeFile},
+	"voip":     {Type: model.MessageTypeVOIP},
+	"system":   {Type: model.MessageTypeSystem},
+}
+
+// ParseTypes 解析逗号分隔的消息类型，支持名称（text,image,voice,video,file...）与 type 或 type:subtype 数字
+func ParseTypes(str string) ([]TypeFilter, error) {
+	var filters []TypeFilter
+	for _, part := range strings.Split(str, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		if f, ok := typeNames[part]; ok {
+			filters = append(filters, f)
+			continue
+		}
+		typ, sub, _ := strings.Cut(part, ":")
+		t, err := strconv.ParseInt(typ, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unknown message type %q", part)
+		}
+		f := TypeFilter{Type: t}
+		if sub != "" {
+			if f.SubType, err = strconv.ParseInt(sub, 10, 64); err != nil {
+				return nil, fmt.Errorf("unknown message type %q", part)
+			}
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+var unsafeName = regexp.MustCompile(`[\\/:*?"<>|\x00-\x1f]+`)
+
+// archiveName 生成会话目录名：昵称_ID，去除文件系统不允许的字符并避免重名
+func archiveName(talker, name string, used map[string]int) string {
+	base := unsafeName.ReplaceAllString(talker, "_")
+	if name = strings.TrimSpace(unsafeName.ReplaceAllString(name, "_")); name != "" && name != talker {
+		if r := []rune(name); len(r) > 40 {
+			name = string(r[:40])
+		}
+		base = name + "_" + base
+	}
+	used[base]++
+	if n := used[base]; n > 1 {
+		return fmt.Sprintf("%s_%d", base, n)
+	}
+	return base
+}
+
+func writeJSON(path string, v any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,251 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+)
+
+type fakeDB struct {
+	messages map[string][]*model.Message
+}
+
+func (f *fakeDB) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	msgs := f.messages[talker]
+	if offset >= len(msgs) {
+		return nil, nil
+	}
+	msgs = msgs[offset:]
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+func (f *fakeDB) GetContacts(key string, limit, offset int) (*wechatdb.GetContactsResp, error) {
+	return &wechatdb.GetContactsResp{Items: []*model.Contact{{UserName: "alice", NickName: "Alice"}}}, nil
+}
+
+func (f *fakeDB) GetChatRooms(key string, limit, offset int) (*wechatdb.GetChatRoomsResp, error) {
+	return &wechatdb.GetChatRoomsResp{}, nil
+}
+
+func (f *fakeDB) GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
+	return &wechatdb.GetSessionsResp{Items: []*model.Session{
+		{UserName: "alice", NickName: "Alice"},
+		{UserName: "bob", NickName: "Bob/B"},
+	}}, nil
+}
+
+func (f *fakeDB) GetMedia(_type string, key string) (*model.Media, error) {
+	return &model.Media{Type: _type, Key: key, Data: []byte("not silk")}, nil
+}
+
+func (f *fakeDB) GetAvatar(username string, size string) (*model.Avatar, error) {
+	if username == "remote" {
+		return &model.Avatar{Username: username, URL: "https://wx.qlogo.cn/remote.jpg"}, nil
+	}
+	if username != "alice" {
+		return nil, nil
+	}
+	return &model.Avatar{Username: username, ContentType: "image/png", Data: []byte("png")}, nil
+}
+
+func newFakeDB() *fakeDB {
+	t0 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+	return &fakeDB{messages: map[string][]*model.Message{
+		"alice": {
+			{Seq: 1, Time: t0, Talker: "alice", Sender: "alice", SenderName: "Alice", Type: model.MessageTypeText, Content: "hello <b>"},
+			{Seq: 2, Time: t0.Add(time.Minute), Talker: "alice", IsSelf: true, Sender: "me", Type: model.MessageTypeVoice, Contents: map[string]interface{}{"voice": "v1"}},
+		},
+		"bob": {
+			{Seq: 3, Time: t0, Talker: "bob", Sender: "bob", Type: model.MessageTypeText, Content: "hi"},
+			{Seq: 4, Time: t0, Talker: "bob", Sender: "bob", Type: model.MessageTypeShare, SubType: model.MessageSubTypeQuote, Content: "agreed",
+				Contents: map[string]interface{}{"refer": &model.Message{Type: model.MessageTypeText, Sender: "me", SenderName: "Me", Content: "quoted text"}}},
+			{Seq: 5, Time: t0, Talker: "bob", Sender: "bob", Type: model.MessageTypeShare, SubType: model.MessageSubTypeMergeForward,
+				Contents: map[string]interface{}{"title": "群聊的聊天记录", "recordInfo": &model.RecordInfo{DataList: model.DataList{DataItems: []model.DataItem{
+					{DataType: "1", SourceName: "carol", DataDesc: "first"},
+					{DataType: "17", SourceName: "dave", DataTitle: "inner", RecordXML: &model.RecordXML{RecordInfo: model.RecordInfo{DataList: model.DataList{DataItems: []model.DataItem{
+						{DataType: "1", SourceName: "erin", DataDesc: "nested"},
+					}}}}},
+				}}}}},
+		},
+	}}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	n := 0
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestRunDirectory(t *testing.T) {
+	out := t.TempDir()
+	summary, err := Run(context.Background(), newFakeDB(), Options{OutputDir: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Talkers != 2 || summary.Messages != 5 || summary.Media != 1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	dir := filepath.Join(out, "Alice_alice")
+	if n := countLines(t, filepath.Join(dir, "messages.jsonl")); n != 2 {
+		t.Fatalf("jsonl lines = %d", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "media", "2.silk")); err != nil {
+		t.Fatalf("voice not exported: %v", err)
+	}
+	html, _ := os.ReadFile(filepath.Join(dir, "messages.html"))
+	if !strings.Contains(string(html), "hello &lt;b&gt;") || !strings.Contains(string(html), `src="media/2.silk"`) {
+		t.Fatalf("unexpected html: %s", html)
+	}
+	if !strings.Contains(string(html), `src="avatars/alice.png"`) || !strings.Contains(string(html), `href="../index.html"`) {
+		t.Fatalf("missing avatar or back link: %s", html)
+	}
+	if _, err := os.Stat(filepath.Join(out, "Bob_B_bob", "messages.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	bob, _ := os.ReadFile(filepath.Join(out, "Bob_B_bob", "messages.html"))
+	for _, want := range []string{`<blockquote class="refer">`, "quoted text", `<summary>群聊的聊天记录</summary>`, `<summary>inner</summary>`, "nested"} {
+		if !strings.Contains(string(bob), want) {
+			t.Fatalf("bob html missing %q: %s", want, bob)
+		}
+	}
+	index, _ := os.ReadFile(filepath.Join(out, "index.html"))
+	if !strings.Contains(string(index), `href="Alice_alice/messages.html"`) {
+		t.Fatalf("unexpected index: %s", index)
+	}
+	if _, err := os.Stat(filepath.Join(out, "contacts.json")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunZipWithFilters(t *testing.T) {
+	out := t.TempDir()
+	types, err := ParseTypes("voice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := Run(context.Background(), newFakeDB(), Options{
+		OutputDir: out,
+		Types:     types,
+		Formats:   []string{FormatJSONL},
+		Zip:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Talkers != 1 || summary.Messages != 1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	zr, err := zip.OpenReader(filepath.Join(out, "Alice_alice.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	names := make([]string, 0, len(zr.File))
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); !strings.Contains(got, "messages.jsonl") || !strings.Contains(got, "media/2.silk") {
+		t.Fatalf("unexpected zip entries: %s", got)
+	}
+
+	entries, _ := os.ReadDir(out)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") || e.Name() == "Bob_B_bob.zip" {
+			t.Fatalf("unexpected leftover %s", e.Name())
+		}
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	filters, err := ParseTypes("text, file,49:5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TypeFilter{{Type: 1}, {Type: 49, SubType: 6}, {Type: 49, SubType: 5}}
+	if len(filters) != len(want) {
+		t.Fatalf("got %v", filters)
+	}
+	for i := range want {
+		if filters[i] != want[i] {
+			t.Fatalf("filter %d = %v, want %v", i, filters[i], want[i])
+		}
+	}
+	if _, err := ParseTypes("sticker"); err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+}
+
+func TestHTMLLinksAndAvatars(t *testing.T) {
+	dir := t.TempDir()
+	h := newHTMLRenderer(newFakeDB(), &mediaResolver{}, dir)
+
+	var out strings.Builder
+	buf := bufio.NewWriter(&out)
+	h.writeRecord(buf, &model.RecordInfo{DataList: model.DataList{DataItems: []model.DataItem{
+		{DataType: "5", DataTitle: "ok", Link: "https://example.com/a?b=1"},
+		{DataType: "5", DataTitle: "xss", Link: "javascript:alert(1)"},
+		{DataType: "5", DataTitle: "data", Link: "data:text/html,<script>"},
+	}}}, "links", "r")
+	if err := h.writeMessage(buf, &model.Message{Sender: "remote", SenderName: "Remote", Type: model.MessageTypeText, Content: "hi"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	buf.Flush()
+	html := out.String()
+
+	if !strings.Contains(html, `<a href="https://example.com/a?b=1"`) {
+		t.Fatalf("http link missing: %s", html)
+	}
+	if strings.Contains(html, `href="javascript:`) || strings.Contains(html, `href="data:`) {
+		t.Fatalf("unsafe link rendered as anchor: %s", html)
+	}
+	if strings.Contains(html, "qlogo.cn") || !strings.Contains(html, `<span class="avatar">R</span>`) {
+		t.Fatalf("remote avatar should fall back to the local placeholder: %s", html)
+	}
+}
+
+func TestFindPathStaysInDataDir(t *testing.T) {
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	if err := os.MkdirAll(filepath.Join(dataDir, "msg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "msg", "a.dat"), []byte("img"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret.dat"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &mediaResolver{dataDir: dataDir}
+	if got := r.findPath("image", "msg/a"); got != filepath.Join(dataDir, "msg", "a.dat") {
+		t.Fatalf("findPath = %q", got)
+	}
+	for _, rel := range []string{"../secret", "../secret.dat", "msg/../../secret"} {
+		if got := r.findPath("image", rel); got != "" {
+			t.Fatalf("findPath(%q) = %q, want empty", rel, got)
+		}
+	}
+}
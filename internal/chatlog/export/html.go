@@ -0,0 +1,259 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// htmlRenderer 生成可离线浏览的 HTML：头像与媒体均写入归档目录并以相对路径引用，
+// 引用消息与合并转发渲染为嵌套块
+type htmlRenderer struct {
+	db      DB
+	media   *mediaResolver // nil 表示不导出媒体
+	dir     string
+	back    string // 返回索引页的链接，zip 模式下为空
+	avatars map[string]string
+}
+
+func newHTMLRenderer(db DB, media *mediaResolver, dir string) *htmlRenderer {
+	return &htmlRenderer{db: db, media: media, dir: dir, avatars: make(map[string]string)}
+}
+
+// avatar 返回头像的相对路径；v3 仅有远程 URL 或获取失败时返回空串，
+// 由调用方显示本地占位头像，归档页面不引用任何远程资源
+func (h *htmlRenderer) avatar(username string) string {
+	if h.media == nil || username == "" {
+		return ""
+	}
+	if rel, ok := h.avatars[username]; ok {
+		return rel
+	}
+	rel := ""
+	if avatar, err := h.db.GetAvatar(username, "small"); err == nil && avatar != nil {
+		switch {
+		case len(avatar.Data) > 0:
+			ext := ".jpg"
+			if strings.Contains(avatar.ContentType, "png") {
+				ext = ".png"
+			}
+			name := unsafeName.ReplaceAllString(username, "_") + ext
+			if err := os.MkdirAll(filepath.Join(h.dir, "avatars"), 0o755); err == nil {
+				if err := os.WriteFile(filepath.Join(h.dir, "avatars", name), avatar.Data, 0o644); err == nil {
+					rel = "avatars/" + name
+				}
+			}
+		}
+	}
+	h.avatars[username] = rel
+	return rel
+}
+
+func (h *htmlRenderer) writeMessage(buf *bufio.Writer, m *model.Message, media string) error {
+	class := "msg"
+	if m.IsSelf {
+		class += " self"
+	}
+	buf.WriteString(`<div class="` + class + `">`)
+	if src := h.avatar(m.Sender); src != "" {
+		buf.WriteString(`<img class="avatar" src="` + template.HTMLEscapeString(src) + `" alt="" onerror="this.style.visibility='hidden'"/>`)
+	} else {
+		buf.WriteString(`<span class="avatar">` + template.HTMLEscapeString(initial(m)) + `</span>`)
+	}
+	buf.WriteString(`<div class="bubble"><div class="meta"><span class="sender">`)
+	buf.WriteString(template.HTMLEscapeString(senderDisplay(m)))
+	buf.WriteString(`</span><span class="time">` + m.Time.Format("2006-01-02 15:04:05") + `</span></div>`)
+	h.writeContent(buf, m, media, fmt.Sprint(m.Seq))
+	_, err := buf.WriteString("</div></div>\n")
+	return err
+}
+
+func initial(m *model.Message) string {
+	name := m.SenderName
+	if name == "" {
+		name = m.Sender
+	}
+	for _, r := range name {
+		return string(r)
+	}
+	return "?"
+}
+
+// writeContent 渲染消息正文；base 用于命名嵌套记录中的媒体文件
+func (h *htmlRenderer) writeContent(buf *bufio.Writer, m *model.Message, media, base string) {
+	src := template.HTMLEscapeString(media)
+	switch {
+	case media != "" && m.Type == model.MessageTypeImage:
+		buf.WriteString(`<a href="` + src + `"><img class="media" src="` + src + `" loading="lazy" alt="图片"/></a>`)
+	case media != "" && m.Type == model.MessageTypeVideo:
+		buf.WriteString(`<video class="media" controls preload="none" src="` + src + `"></video>`)
+	case media != "" && m.Type == model.MessageTypeVoice:
+		buf.WriteString(`<audio controls preload="none" src="` + src + `"></audio>`)
+	case media != "":
+		buf.WriteString(`<a class="file" href="` + src + `">` + template.HTMLEscapeString(fmt.Sprintf("[文件|%v]", m.Contents["title"])) + `</a>`)
+	case m.Type == model.MessageTypeShare && m.SubType == model.MessageSubTypeQuote:
+		buf.WriteString(`<pre>` + template.HTMLEscapeString(m.Content) + `</pre>`)
+		if refer, ok := m.Contents["refer"].(*model.Message); ok && refer != nil {
+			buf.WriteString(`<blockquote class="refer"><div class="meta"><span class="sender">`)
+			buf.WriteString(template.HTMLEscapeString(senderDisplay(refer)))
+			buf.WriteString(`</span></div>`)
+			h.writeContent(buf, refer, "", base+"_refer")
+			buf.WriteString(`</blockquote>`)
+		}
+	case m.Type == model.MessageTypeShare && m.Contents["recordInfo"] != nil:
+		if info, ok := m.Contents["recordInfo"].(*model.RecordInfo); ok && info != nil {
+			title, _ := m.Contents["title"].(string)
+			h.writeRecord(buf, info, title, base)
+			return
+		}
+		buf.WriteString(`<pre>` + template.HTMLEscapeString(offlineText(m)) + `</pre>`)
+	default:
+		buf.WriteString(`<pre>` + template.HTMLEscapeString(offlineText(m)) + `</pre>`)
+	}
+}
+
+// writeRecord 渲染合并转发/笔记，嵌套的合并转发递归展开
+func (h *htmlRenderer) writeRecord(buf *bufio.Writer, info *model.RecordInfo, title, base string) {
+	if title == "" {
+		title = info.Title
+	}
+	if title == "" {
+		title = "聊天记录"
+	}
+	buf.WriteString(`<details class="record"><summary>` + template.HTMLEscapeString(title) + `</summary>`)
+	for i, item := range info.DataList.DataItems {
+		if item.DataType == "8" && item.DataFmt == ".htm" {
+			continue
+		}
+		itemBase := fmt.Sprintf("%s_%d", base, i)
+		buf.WriteString(`<div class="record-item"><div class="meta"><span class="sender">` + template.HTMLEscapeString(item.SourceName) + `</span><span class="time">` + template.HTMLEscapeString(item.SourceTime) + `</span></div>`)
+		switch item.DataType {
+		case "17":
+			if item.RecordXML != nil {
+				h.writeRecord(buf, &item.RecordXML.RecordInfo, item.DataTitle, itemBase)
+			}
+		case "2":
+			if rel := h.saveItem("image", item.FullMD5, itemBase); rel != "" {
+				src := template.HTMLEscapeString(rel)
+				buf.WriteString(`<a href="` + src + `"><img class="media" src="` + src + `" loading="lazy" alt="图片"/></a>`)
+			} else {
+				buf.WriteString(`<pre>[图片]</pre>`)
+			}
+		case "4":
+			if rel := h.saveItem("video", item.FullMD5, itemBase); rel != "" {
+				buf.WriteString(`<video class="media" controls preload="none" src="` + template.HTMLEscapeString(rel) + `"></video>`)
+			} else {
+				buf.WriteString(`<pre>[视频]</pre>`)
+			}
+		case "8":
+			label := template.HTMLEscapeString("[文件|" + item.DataTitle + "]")
+			if rel := h.saveItem("file", item.FullMD5, itemBase); rel != "" {
+				buf.WriteString(`<a class="file" href="` + template.HTMLEscapeString(rel) + `">` + label + `</a>`)
+			} else {
+				buf.WriteString(`<pre>` + label + `</pre>`)
+			}
+		case "5":
+			label := template.HTMLEscapeString("[链接|" + item.DataTitle + "]")
+			if href := safeLink(item.Link); href != "" {
+				buf.WriteString(`<a href="` + template.HTMLEscapeString(href) + `" rel="noopener noreferrer">` + label + `</a>`)
+			} else {
+				buf.WriteString(`<pre>` + label + " " + template.HTMLEscapeString(item.Link) + `</pre>`)
+			}
+		case "6":
+			buf.WriteString(`<pre>` + template.HTMLEscapeString("[位置|"+item.Location.PoiName+"]") + `</pre>`)
+		default:
+			buf.WriteString(`<pre>` + template.HTMLEscapeString(item.DataDesc) + `</pre>`)
+		}
+		buf.WriteString(`</div>`)
+	}
+	buf.WriteString(`</details>`)
+}
+
+// safeLink 只允许 http/https 链接，其余（javascript:、data: 等）返回空串，以纯文本显示
+func safeLink(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.String()
+	}
+	return ""
+}
+
+func (h *htmlRenderer) saveItem(_type, key, base string) string {
+	if h.media == nil || key == "" {
+		return ""
+	}
+	rel, err := h.media.save(_type, []string{key}, base)
+	if err != nil {
+		return ""
+	}
+	return rel
+}
+
+func writeHTMLHeader(buf *bufio.Writer, title, back string) {
+	t := template.HTMLEscapeString(title)
+	buf.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"/><meta name="viewport" content="width=device-width,initial-scale=1"/><title>` + t + `</title>` + htmlStyle + `</head><body>`)
+	if back != "" {
+		buf.WriteString(`<a class="back" href="` + template.HTMLEscapeString(back) + `">&larr;</a>`)
+	}
+	buf.WriteString(`<h1>` + t + "</h1>\n")
+}
+
+const htmlStyle = `<style>
+body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,"PingFang SC","Microsoft YaHei",sans-serif;background:#ededed;margin:0 auto;padding:16px;color:#222;max-width:960px}
+h1{font-size:18px;margin:0 0 16px;display:inline-block}
+a.back{margin-right:12px;text-decoration:none;font-size:18px}
+.msg{display:flex;gap:8px;margin:0 0 12px;align-items:flex-start}
+.msg.self{flex-direction:row-reverse}
+.avatar{width:36px;height:36px;border-radius:4px;flex:none;background:#c8c8c8;color:#fff;text-align:center;line-height:36px;object-fit:cover}
+.bubble{background:#fff;border-radius:6px;padding:8px 12px;max-width:75%}
+.msg.self .bubble{background:#95ec69}
+.meta{font-size:12px;color:#888;margin-bottom:4px}
+.sender{font-weight:600;color:#555;margin-right:8px}
+pre{white-space:pre-wrap;word-break:break-word;margin:0;font:inherit}
+img.media,video.media{max-width:320px;max-height:320px;border-radius:4px;display:block}
+blockquote.refer{margin:6px 0 0;padding:4px 8px;border-left:3px solid #bbb;background:rgba(0,0,0,.04);color:#555}
+details.record{border:1px solid #ddd;border-radius:4px;padding:4px 8px;background:#fafafa}
+details.record summary{cursor:pointer;font-weight:600}
+.record-item{padding:6px 0;border-top:1px dashed #ddd}
+ul.index{list-style:none;padding:0}
+ul.index li{background:#fff;margin:0 0 6px;padding:8px 12px;border-radius:6px}
+ul.index .count{color:#888;font-size:12px;margin-left:8px}
+</style>`
+
+const htmlFooter = "</body></html>\n"
+
+type indexEntry struct {
+	Href     string
+	Title    string
+	Messages int
+}
+
+// writeIndex 在导出根目录生成会话列表页
+func writeIndex(path string, entries []indexEntry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	buf := bufio.NewWriter(f)
+	writeHTMLHeader(buf, "Chatlog", "")
+	buf.WriteString(`<ul class="index">`)
+	for _, e := range entries {
+		buf.WriteString(`<li><a href="` + template.HTMLEscapeString(e.Href) + `">` + template.HTMLEscapeString(e.Title) + `</a><span class="count">` + fmt.Sprintf("%d 条消息", e.Messages) + `</span></li>`)
+	}
+	buf.WriteString("</ul>" + htmlFooter)
+	if err := buf.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
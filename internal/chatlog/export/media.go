@@ -0,0 +1,168 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/silk"
+)
+
+// mediaResolver 把消息引用的多媒体解码后写入 <dir>/media，返回相对路径
+type mediaResolver struct {
+	db      DB
+	dataDir string
+	dir     string
+}
+
+// mediaKeys 返回消息的多媒体类型与候选 key，顺序与 PlainTextContent 中的链接一致
+func mediaKeys(m *model.Message) (string, []string) {
+	pick := func(names ...string) []string {
+		keys := make([]string, 0, len(names))
+		for _, name := range names {
+			if v, ok := m.Contents[name].(string); ok && v != "" {
+				keys = append(keys, v)
+			}
+		}
+		return keys
+	}
+	switch m.Type {
+	case model.MessageTypeImage:
+		return "image", pick("md5", "path", "thumbpath")
+	case model.MessageTypeVideo:
+		return "video", pick("md5", "rawmd5", "path")
+	case model.MessageTypeVoice:
+		if v, ok := m.Contents["voice"]; ok {
+			return "voice", []string{fmt.Sprint(v)}
+		}
+	case model.MessageTypeShare:
+		if m.SubType == model.MessageSubTypeFile {
+			return "file", pick("md5")
+		}
+	}
+	return "", nil
+}
+
+func (r *mediaResolver) Resolve(m *model.Message) (string, error) {
+	_type, keys := mediaKeys(m)
+	if _type == "" || len(keys) == 0 {
+		return "", nil
+	}
+	return r.save(_type, keys, strconv.FormatInt(m.Seq, 10))
+}
+
+// save 依次尝试 keys，将第一个可用的媒体写入 media/<base>.<ext>，文件保留原始文件名
+func (r *mediaResolver) save(_type string, keys []string, base string) (string, error) {
+	var lastErr error
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		data, ext, name, err := r.load(_type, key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		fileName := base + "." + ext
+		if _type == "file" && name != "" {
+			fileName = base + "_" + unsafeName.ReplaceAllString(name, "_")
+		}
+		if err := os.MkdirAll(filepath.Join(r.dir, "media"), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(r.dir, "media", fileName), data, 0o644); err != nil {
+			return "", err
+		}
+		return "media/" + fileName, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("%s not found", _type)
+	}
+	return "", lastErr
+}
+
+// load 读取并解码单个 key 对应的媒体内容，返回数据、扩展名与原始文件名
+func (r *mediaResolver) load(_type, key string) ([]byte, string, string, error) {
+	if _type == "voice" {
+		media, err := r.db.GetMedia(_type, key)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if out, err := silk.Silk2MP3(media.Data); err == nil {
+			return out, "mp3", "", nil
+		}
+		return media.Data, "silk", "", nil
+	}
+
+	if r.dataDir == "" {
+		return nil, "", "", fmt.Errorf("data dir is required for %s", _type)
+	}
+
+	var path, name string
+	if strings.Contains(key, "/") {
+		path = r.findPath(_type, key)
+	}
+	if path == "" {
+		media, err := r.db.GetMedia(_type, key)
+		if err != nil {
+			return nil, "", "", err
+		}
+		path = r.findPath(_type, media.Path)
+		name = media.Name
+	}
+	if path == "" {
+		return nil, "", "", fmt.Errorf("%s %s not found in data dir", _type, key)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", "", err
+	}
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if ext == "dat" {
+		out, imgExt, err := dat2img.Dat2Image(data)
+		if err != nil {
+			return nil, "", "", err
+		}
+		data, ext = out, imgExt
+	}
+	if ext == "" {
+		ext = "bin"
+	}
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	return data, ext, name, nil
+}
+
+// findPath 与 HTTP 服务的 /image、/video 路由一致，补全数据目录中省略的后缀
+// 含 .. 的路径拼接后可能跳出数据目录，此时视为不存在
+func (r *mediaResolver) findPath(_type, rel string) string {
+	if rel == "" {
+		return ""
+	}
+	abs := filepath.Join(r.dataDir, rel)
+	if inside, err := filepath.Rel(r.dataDir, abs); err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+		return abs
+	}
+	var suffixes []string
+	switch _type {
+	case "image":
+		suffixes = []string{"_h.dat", ".dat", "_t.dat"}
+	case "video":
+		suffixes = []string{".mp4", "_thumb.jpg"}
+	}
+	for _, suffix := range suffixes {
+		if _, err := os.Stat(abs + suffix); err == nil {
+			return abs + suffix
+		}
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// record 是 JSONL 中的一行：原始消息加上归档内的媒体相对路径
+type record struct {
+	*model.Message
+	Media string `json:"media,omitempty"`
+}
+
+type output struct {
+	file    *os.File
+	buf     *bufio.Writer
+	started bool
+}
+
+// talkerWriter 将同一会话的消息同时写入多个格式
+type talkerWriter struct {
+	talker     string
+	talkerName string
+	outputs    map[string]*output
+	html       *htmlRenderer
+	lastDay    string
+}
+
+func newTalkerWriter(dir, talker, talkerName string, formats []string, html *htmlRenderer) (*talkerWriter, error) {
+	w := &talkerWriter{
+		talker:     talker,
+		talkerName: talkerName,
+		outputs:    make(map[string]*output, len(formats)),
+		html:       html,
+	}
+	for _, format := range formats {
+		f, err := os.Create(filepath.Join(dir, "messages."+format))
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		w.outputs[format] = &output{file: f, buf: bufio.NewWriter(f)}
+	}
+	return w, nil
+}
+
+func (w *talkerWriter) title() string {
+	if w.talkerName != "" && w.talkerName != w.talker {
+		return fmt.Sprintf("%s (%s)", w.talkerName, w.talker)
+	}
+	return w.talker
+}
+
+func (w *talkerWriter) Write(m *model.Message, media string) error {
+	for format, out := range w.outputs {
+		var err error
+		switch format {
+		case FormatJSONL:
+			err = writeJSONL(out.buf, m, media)
+		case FormatHTML:
+			if !out.started {
+				writeHTMLHeader(out.buf, w.title(), w.html.back)
+			}
+			err = w.html.writeMessage(out.buf, m, media)
+		case FormatMarkdown:
+			if !out.started {
+				fmt.Fprintf(out.buf, "# %s\n\n", w.title())
+			}
+			err = writeMarkdownMessage(out.buf, m, media, &w.lastDay)
+		}
+		out.started = true
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *talkerWriter) Close() error {
+	var firstErr error
+	for format, out := range w.outputs {
+		if format == FormatHTML && out.started {
+			out.buf.WriteString(htmlFooter)
+		}
+		if err := out.buf.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := out.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	w.outputs = nil
+	return firstErr
+}
+
+func writeJSONL(buf *bufio.Writer, m *model.Message, media string) error {
+	b, err := json.Marshal(record{Message: m, Media: media})
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return buf.WriteByte('\n')
+}
+
+func senderDisplay(m *model.Message) string {
+	sender := m.Sender
+	if m.IsSelf {
+		sender = "我"
+	}
+	if m.SenderName != "" {
+		sender = m.SenderName + "(" + sender + ")"
+	}
+	return sender
+}
+
+// 去掉 PlainTextContent 中指向本地 HTTP 服务的链接，离线归档中这些链接无法访问
+var serviceLinkPattern = regexp.MustCompile(`!?\[([^\]]+)\]\(http://[^/)]+/(?:image|video|voice|file)/[^)]*\)`)
+
+func offlineText(m *model.Message) string {
+	return serviceLinkPattern.ReplaceAllString(m.PlainTextContent(), "[$1]")
+}
+
+func writeMarkdownMessage(buf *bufio.Writer, m *model.Message, media string, lastDay *string) error {
+	if day := m.Time.Format("2006-01-02"); day != *lastDay {
+		*lastDay = day
+		fmt.Fprintf(buf, "## %s\n\n", day)
+	}
+	fmt.Fprintf(buf, "**%s** %s\n\n", senderDisplay(m), m.Time.Format("15:04:05"))
+
+	content := offlineText(m)
+	if media != "" {
+		switch m.Type {
+		case model.MessageTypeImage:
+			content = fmt.Sprintf("![图片](%s)", media)
+		case model.MessageTypeVideo:
+			content = fmt.Sprintf("[视频](%s)", media)
+		case model.MessageTypeVoice:
+			content = fmt.Sprintf("[%s](%s)", strings.Trim(content, "[]"), media)
+		default:
+			content = fmt.Sprintf("[文件|%v](%s)", m.Contents["title"], media)
+		}
+	}
+	// 两个空格结尾保持 Markdown 中的换行
+	content = strings.ReplaceAll(content, "\n", "  \n")
+	_, err := fmt.Fprintf(buf, "%s\n\n", content)
+	return err
+}
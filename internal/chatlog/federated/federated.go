@@ -0,0 +1,170 @@
+// Package federated 合并多个账号的搜索结果与聊天记录。
+//
+// 同一个群聊会出现在多个账号的数据库中，合并时按会话、发送者、时间与内容识别
+// 重复消息，只保留第一次出现的结果，并在 AlsoIn 中记录其余账号。
+// 单聊消息不做去重：两个账号与同一联系人的对话是不同的消息。
+package federated
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+const (
+	// OrderScore 按相关度排序（分值越小越靠前），为搜索的默认排序
+	OrderScore = "score"
+	// OrderTime 按时间倒序排序
+	OrderTime = "time"
+)
+
+// HitSource 是单个账号返回的搜索结果
+type HitSource struct {
+	Account string
+	Hits    []*model.SearchHit
+}
+
+// MessageSource 是单个账号返回的聊天记录
+type MessageSource struct {
+	Account  string
+	Messages []*model.Message
+}
+
+// Hit 是带来源账号的搜索命中
+type Hit struct {
+	Account string   `json:"account"`
+	AlsoIn  []string `json:"also_in,omitempty"`
+	*model.SearchHit
+}
+
+// Message 是带来源账号的消息
+type Message struct {
+	Account string   `json:"account"`
+	AlsoIn  []string `json:"also_in,omitempty"`
+	*model.Message
+}
+
+// Key 返回识别跨账号重复消息的键，非群聊消息返回空串
+func Key(m *model.Message) string {
+	if m == nil || !strings.HasSuffix(m.Talker, "@chatroom") {
+		return ""
+	}
+	var md5 string
+	if m.Contents != nil {
+		md5 = fmt.Sprint(m.Contents["md5"])
+	}
+	return fmt.Sprintf("%s\x00%s\x00%d\x00%d:%d\x00%s\x00%s", m.Talker, m.Sender, m.Time.Unix(), m.Type, m.SubType, m.Content, md5)
+}
+
+// dedup 记录已出现的消息。同一账号内的相同消息（例如同一秒连发两次）都会保留，
+// 只有来自其他账号且尚未被该账号匹配过的消息才视为重复。
+type dedup struct {
+	seen map[string][]*entry
+}
+
+type entry struct {
+	account string
+	alsoIn  *[]string
+}
+
+func newDedup() *dedup {
+	return &dedup{seen: make(map[string][]*entry)}
+}
+
+// add 返回 true 表示 m 是新消息；重复时把 account 追加到已有结果的 AlsoIn
+func (d *dedup) add(account string, m *model.Message, alsoIn *[]string) bool {
+	key := Key(m)
+	if key == "" {
+		return true
+	}
+	for _, e := range d.seen[key] {
+		if e.account != account && !slices.Contains(*e.alsoIn, account) {
+			*e.alsoIn = append(*e.alsoIn, account)
+			return false
+		}
+	}
+	d.seen[key] = append(d.seen[key], &entry{account: account, alsoIn: alsoIn})
+	return true
+}
+
+// MergeHits 合并各账号的搜索结果并去重，返回排序后的结果与被去重的条数。
+// 去重时优先保留排序靠前的命中，因此先排序再去重。
+func MergeHits(sources []HitSource, order string) ([]*Hit, int) {
+	all := make([]*Hit, 0)
+	for _, src := range sources {
+		for _, h := range src.Hits {
+			if h == nil || h.Message == nil {
+				continue
+			}
+			all = append(all, &Hit{Account: src.Account, SearchHit: h})
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if order != OrderTime && a.Score != b.Score {
+			return a.Score < b.Score
+		}
+		return a.Message.Time.After(b.Message.Time)
+	})
+
+	d := newDedup()
+	merged := all[:0]
+	for _, h := range all {
+		if d.add(h.Account, h.Message, &h.AlsoIn) {
+			merged = append(merged, h)
+		}
+	}
+	return merged, len(all) - len(merged)
+}
+
+// MergeMessages 按时间正序合并各账号的聊天记录并去重，返回合并结果与被去重的条数。
+// 时间相同的消息按账号顺序与消息序号排列。
+func MergeMessages(sources []MessageSource) ([]*Message, int) {
+	all := make([]*Message, 0)
+	rank := make(map[string]int, len(sources))
+	for i, src := range sources {
+		rank[src.Account] = i
+		for _, m := range src.Messages {
+			if m == nil {
+				continue
+			}
+			all = append(all, &Message{Account: src.Account, Message: m})
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if rank[a.Account] != rank[b.Account] {
+			return rank[a.Account] < rank[b.Account]
+		}
+		return a.Seq < b.Seq
+	})
+
+	d := newDedup()
+	merged := all[:0]
+	for _, m := range all {
+		if d.add(m.Account, m.Message, &m.AlsoIn) {
+			merged = append(merged, m)
+		}
+	}
+	return merged, len(all) - len(merged)
+}
+
+// Page 返回 [offset, offset+limit) 区间，limit 为 0 时返回 offset 之后的全部元素
+func Page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,105 @@
+package federated
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+var base = time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+
+func msg(talker, sender, content string, sec int) *model.Message {
+	return &model.Message{Talker: talker, Sender: sender, Content: content, Type: model.MessageTypeText, Time: base.Add(time.Duration(sec) * time.Second)}
+}
+
+func TestMergeHits(t *testing.T) {
+	shared := "123@chatroom"
+	sources := []HitSource{
+		{Account: "a", Hits: []*model.SearchHit{
+			{Message: msg(shared, "wxid_x", "项目进度", 10), Score: -2},
+			{Message: msg("wxid_y", "wxid_y", "项目进度", 20), Score: -1},
+		}},
+		{Account: "b", Hits: []*model.SearchHit{
+			// 同一群消息在 b 中分值更高，保留 b 的结果
+			{Message: msg(shared, "wxid_x", "项目进度", 10), Score: -3},
+			// 单聊不去重
+			{Message: msg("wxid_y", "wxid_y", "项目进度", 20), Score: -1},
+		}},
+	}
+
+	hits, dups := MergeHits(sources, OrderScore)
+	if len(hits) != 3 || dups != 1 {
+		t.Fatalf("hits = %d, dups = %d", len(hits), dups)
+	}
+	if hits[0].Account != "b" || len(hits[0].AlsoIn) != 1 || hits[0].AlsoIn[0] != "a" {
+		t.Fatalf("first hit = %+v", hits[0])
+	}
+
+	data, err := json.Marshal(hits[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"account":"b"`) || !strings.Contains(string(data), `"score":-3`) {
+		t.Fatalf("json = %s", data)
+	}
+}
+
+func TestMergeHitsByTime(t *testing.T) {
+	sources := []HitSource{
+		{Account: "a", Hits: []*model.SearchHit{{Message: msg("1@chatroom", "x", "old", 1), Score: -9}}},
+		{Account: "b", Hits: []*model.SearchHit{{Message: msg("2@chatroom", "x", "new", 5), Score: -1}}},
+	}
+	hits, _ := MergeHits(sources, OrderTime)
+	if hits[0].Message.Content != "new" || hits[1].Message.Content != "old" {
+		t.Fatalf("order = %s, %s", hits[0].Message.Content, hits[1].Message.Content)
+	}
+}
+
+func TestMergeMessages(t *testing.T) {
+	room := "123@chatroom"
+	sources := []MessageSource{
+		{Account: "a", Messages: []*model.Message{
+			msg(room, "x", "hi", 1),
+			msg(room, "x", "+1", 3),
+			msg(room, "x", "+1", 3),
+		}},
+		{Account: "b", Messages: []*model.Message{
+			msg(room, "x", "hi", 1),
+			msg(room, "x", "+1", 3),
+			msg(room, "y", "only b", 2),
+		}},
+		{Account: "c", Messages: []*model.Message{
+			msg(room, "x", "hi", 1),
+		}},
+	}
+
+	merged, dups := MergeMessages(sources)
+	var got []string
+	for _, m := range merged {
+		got = append(got, m.Account+":"+m.Content)
+	}
+	// 同一账号内的重复消息都保留，其他账号的副本合并到 AlsoIn
+	want := "a:hi,b:only b,a:+1,a:+1"
+	if strings.Join(got, ",") != want || dups != 3 {
+		t.Fatalf("got %s (dups %d), want %s", strings.Join(got, ","), dups, want)
+	}
+	if strings.Join(merged[0].AlsoIn, ",") != "b,c" {
+		t.Fatalf("also in = %v", merged[0].AlsoIn)
+	}
+}
+
+func TestPage(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	if got := Page(items, 2, 1); len(got) != 2 || got[0] != 2 {
+		t.Fatalf("page = %v", got)
+	}
+	if got := Page(items, 0, 3); len(got) != 2 {
+		t.Fatalf("page = %v", got)
+	}
+	if got := Page(items, 2, 10); len(got) != 0 {
+		t.Fatalf("page = %v", got)
+	}
+}
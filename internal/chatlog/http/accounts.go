@@ -0,0 +1,205 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/database"
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
+)
+
+// mountedAccount 是挂载在主服务下的额外账号，拥有独立的数据库与路由
+type mountedAccount struct {
+	id      string
+	name    string
+	service *Service
+}
+
+// accountMediaPrefixes 为 /accounts/:id/*path 允许转发的媒体路由
+var accountMediaPrefixes = []string{"/image/", "/video/", "/file/", "/voice/", "/data/", "/avatar/"}
+
+// MountAccount 把另一个账号的服务挂载到 /api/v1/accounts/:id 与 /accounts/:id 下，
+// 并为 MCP 工具增加 account 参数。需在 ListenAndServe 之前调用。
+func (s *Service) MountAccount(id, name string, child *Service) error {
+	if id == "" || child == nil {
+		return errors.InvalidArg("account")
+	}
+	if id == s.currentAccountID() || s.account(id) != nil {
+		return fmt.Errorf("duplicate account id %q", id)
+	}
+	s.accounts = append(s.accounts, &mountedAccount{id: id, name: name, service: child})
+	s.addMCPTools()
+	return nil
+}
+
+// SetImageKeys 设置解密微信 4.0 图片使用的密钥，额外账号各自使用自己的密钥。需在 ListenAndServe 之前调用。
+func (s *Service) SetImageKeys(keys *dat2img.Keys) {
+	s.imgKeys = keys
+}
+
+// account 按 ID 查找账号服务，主账号的 ID 返回自身
+func (s *Service) account(id string) *Service {
+	if id == s.currentAccountID() {
+		return s
+	}
+	for _, a := range s.accounts {
+		if a.id == id {
+			return a.service
+		}
+	}
+	return nil
+}
+
+func (s *Service) accountIDs() []string {
+	ids := []string{s.currentAccountID()}
+	for _, a := range s.accounts {
+		ids = append(ids, a.id)
+	}
+	return ids
+}
+
+type accountInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Platform string `json:"platform,omitempty"`
+	Version  int    `json:"version,omitempty"`
+	WorkDir  string `json:"work_dir"`
+	State    string `json:"state"`
+	StateMsg string `json:"state_msg,omitempty"`
+	Default  bool   `json:"default"`
+}
+
+func (s *Service) accountInfo(id, name string) accountInfo {
+	info := accountInfo{ID: id, Name: name, WorkDir: s.db.GetWorkDir()}
+	if pc, ok := s.conf.(interface {
+		GetPlatform() string
+		GetVersion() int
+	}); ok {
+		info.Platform, info.Version = pc.GetPlatform(), pc.GetVersion()
+	}
+	switch s.db.State {
+	case database.StateInit:
+		info.State = "init"
+	case database.StateDecrypting:
+		info.State = "decrypting"
+	case database.StateReady:
+		info.State = "ready"
+	case database.StateError:
+		info.State, info.StateMsg = "error", s.db.StateMsg
+	}
+	return info
+}
+
+// GET /api/v1/accounts
+func (s *Service) handleAccounts(c *gin.Context) {
+	id := s.currentAccountID()
+	primary := s.accountInfo(id, id)
+	primary.Default = true
+	items := []accountInfo{primary}
+	for _, a := range s.accounts {
+		items = append(items, a.service.accountInfo(a.id, a.name))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// ANY /api/v1/accounts/:id/*path
+func (s *Service) handleAccountAPI(c *gin.Context) {
+	s.serveAccount(c, "/api/v1"+c.Param("path"))
+}
+
+// GET /accounts/:id/*path
+func (s *Service) handleAccountMedia(c *gin.Context) {
+	path := c.Param("path")
+	for _, prefix := range accountMediaPrefixes {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		// 媒体路由本身不检查数据库状态，额外账号可能仍在打开数据库
+		if target := s.account(c.Param("id")); target != nil && target != s && target.db.State != database.StateReady {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "database is not ready"})
+			return
+		}
+		s.serveAccount(c, path)
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+}
+
+// serveAccount 把请求改写为 path 后交给账号自己的路由处理，鉴权由账号路由完成。
+// Host 追加 /accounts/:id，使返回的媒体链接指向同一账号。
+func (s *Service) serveAccount(c *gin.Context, path string) {
+	id := c.Param("id")
+	target := s.account(id)
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "account not found: " + id})
+		return
+	}
+	req := c.Request.Clone(c.Request.Context())
+	req.URL.Path = path
+	req.URL.RawPath = ""
+	req.Host = c.Request.Host + "/accounts/" + id
+	target.router.ServeHTTP(c.Writer, req)
+	c.Abort()
+}
+
+var ListAccountsTool = mcp.NewTool(
+	"list_accounts",
+	mcp.WithDescription(`列出当前服务挂载的微信账号及其数据库状态。其余工具的 account 参数取值为此处返回的 ID，为空时使用默认账号。`),
+)
+
+func (s *Service) handleMCPListAccounts(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("ID,Name,State,Default\n")
+	id := s.currentAccountID()
+	buf.WriteString(fmt.Sprintf("%s,%s,%s,%t\n", id, id, s.accountInfo(id, id).State, true))
+	for _, a := range s.accounts {
+		buf.WriteString(fmt.Sprintf("%s,%s,%s,%t\n", a.id, a.name, a.service.accountInfo(a.id, a.name).State, false))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: buf.String(),
+			},
+		},
+	}, nil
+}
+
+// withAccountArg 复制工具定义并增加可选的 account 参数，不修改包级变量中的 schema
+func (s *Service) withAccountArg(tool mcp.Tool) mcp.Tool {
+	props := make(map[string]any, len(tool.InputSchema.Properties)+1)
+	for k, v := range tool.InputSchema.Properties {
+		props[k] = v
+	}
+	props["account"] = map[string]any{
+		"type":        "string",
+		"description": "要查询的账号 ID，可通过 list_accounts 获取；为空时使用默认账号",
+		"enum":        s.accountIDs(),
+	}
+	tool.InputSchema.Properties = props
+	return tool
+}
+
+// accountToolHandler 根据 account 参数把工具调用分发到对应账号
+func (s *Service) accountToolHandler(h mcpToolHandler) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target := s
+		if id := request.GetString("account", ""); id != "" {
+			if target = s.account(id); target == nil {
+				return errors.ErrMCPTool(fmt.Errorf("account %q not found, available: %s", id, strings.Join(s.accountIDs(), ", "))), nil
+			}
+			if target != s && target.db.State != database.StateReady {
+				return errors.ErrMCPTool(fmt.Errorf("database of account %q is not ready", id)), nil
+			}
+		}
+		return h(target, ctx, request)
+	}
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/database"
+)
+
+// newAccountServices 创建主账号 wxid_main 与挂载的额外账号 team，数据库均未打开
+func newAccountServices(t *testing.T, auth *conf.AuthConfig) (*Service, *Service) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &conf.ServerConfig{
+		WorkDir:  filepath.Join(dir, "wxid_main"),
+		Auth:     auth,
+		Accounts: []*conf.AccountConfig{{ID: "team", WorkDir: filepath.Join(dir, "wxid_team")}},
+	}
+	accounts, err := cfg.GetAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := NewService(cfg, database.NewService(cfg), nil)
+	child := NewService(accounts[0], database.NewService(accounts[0]), nil)
+	if err := main.MountAccount("team", "团队号", child); err != nil {
+		t.Fatal(err)
+	}
+	if err := main.MountAccount("team", "重复", child); err == nil {
+		t.Fatal("expected error for duplicate account id")
+	}
+	return main, child
+}
+
+func TestServeAccount(t *testing.T) {
+	raw, token := mintToken(t, conf.ScopeReadChatlog)
+	main, child := newAccountServices(t, &conf.AuthConfig{Tokens: []*conf.APIToken{token}})
+
+	serve := func(path, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		main.router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve("/api/v1/accounts/missing/accounts", raw); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown account: status = %d", w.Code)
+	}
+	// 鉴权由账号自己的路由完成，额外账号沿用主账号的 token
+	if w := serve("/api/v1/accounts/team/accounts", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("missing token: status = %d", w.Code)
+	}
+
+	// 请求改写为账号路由下的 /api/v1/accounts，返回的是额外账号自己的信息
+	w := serve("/api/v1/accounts/team/accounts", raw)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Items []accountInfo `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].WorkDir != child.db.GetWorkDir() || resp.Items[0].State != "init" {
+		t.Fatalf("items = %+v", resp.Items)
+	}
+
+	// 额外账号的数据库未就绪时，媒体路由直接返回 503
+	if w := serve("/accounts/team/image/abc", raw); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("media of unready account: status = %d", w.Code)
+	}
+	if w := serve("/accounts/team/unknown/abc", raw); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown media route: status = %d", w.Code)
+	}
+}
+
+func TestAccountToolHandler(t *testing.T) {
+	main, child := newAccountServices(t, nil)
+
+	var got *Service
+	handler := main.accountToolHandler(func(s *Service, ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		got = s
+		return mcp.NewToolResultText("ok"), nil
+	})
+	call := func(account string) *mcp.CallToolResult {
+		got = nil
+		request := mcp.CallToolRequest{}
+		if account != "" {
+			request.Params.Arguments = map[string]any{"account": account}
+		}
+		result, err := handler(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := call(""); result.IsError || got != main {
+		t.Fatalf("default account: error = %v, target = %p", result.IsError, got)
+	}
+	if result := call("wxid_main"); result.IsError || got != main {
+		t.Fatalf("main account by id: error = %v", result.IsError)
+	}
+	if result := call("missing"); !result.IsError || got != nil {
+		t.Fatal("unknown account should be rejected")
+	}
+	if result := call("team"); !result.IsError || got != nil {
+		t.Fatal("account with an unready database should be rejected")
+	}
+
+	child.db.State = database.StateReady
+	if result := call("team"); result.IsError || got != child {
+		t.Fatalf("ready account: error = %v", result.IsError)
+	}
+}
@@ -0,0 +1,382 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/analytics"
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
+)
+
+// GET /api/v1/stats?talker=xxx&time=2024-01-01~2024-06-30
+// 返回单个会话（联系人或群聊）的发送者排行、消息类型分布、小时/星期热力图、月度趋势、媒体数量与最活跃的日期；
+// 统计由各数据源的 SQL 聚合得到，time 为空时统计全部消息
+func (s *Service) handleTalkerStats(c *gin.Context) {
+	params := struct {
+		Talker string `form:"talker"`
+		Time   string `form:"time"`
+	}{}
+	if err := c.BindQuery(&params); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	talker := strings.TrimSpace(params.Talker)
+	if talker == "" {
+		errors.Err(c, errors.InvalidArg("talker"))
+		return
+	}
+
+	start, end := time.Unix(0, 0), time.Now()
+	if strings.TrimSpace(params.Time) != "" {
+		var ok bool
+		start, end, ok = util.TimeRangeOf(params.Time)
+		if !ok {
+			errors.Err(c, errors.InvalidArg("time"))
+			return
+		}
+	}
+
+	stats, err := s.db.GetDB().TalkerStats(talker, start, end)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// GET /api/v1/analytics/relationships?limit=20&format=(json|text)
+// 按亲密度评分排序联系人，附带季度趋势、回复间隔中位数与对话发起比例，评分规则见 analytics 包
+func (s *Service) handleRelationships(c *gin.Context) {
+	params := struct {
+		Limit  int    `form:"limit"`
+		Format string `form:"format"`
+	}{}
+	if err := c.BindQuery(&params); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	report, err := s.relationships(c.Request.Context(), params.Limit)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	switch strings.ToLower(params.Format) {
+	case "text":
+		buf := &bytes.Buffer{}
+		writeRelationships(buf, report)
+		c.String(http.StatusOK, buf.String())
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// relationships 生成关系分析报告，并用联系人备注/昵称填充显示名
+func (s *Service) relationships(ctx context.Context, limit int) (*analytics.Report, error) {
+	exclude := map[string]struct{}{}
+	if id := s.currentAccountID(); id != "" {
+		exclude[id] = struct{}{}
+		exclude[trimWxidSuffix(id)] = struct{}{}
+	}
+
+	report, err := analytics.Relationships(ctx, s.db.GetDB(), analytics.Options{Limit: limit, Exclude: exclude})
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Items) == 0 {
+		return report, nil
+	}
+
+	if clist, err := s.db.GetContacts("", 0, 0); err == nil && clist != nil {
+		names := make(map[string]string, len(clist.Items))
+		for _, ct := range clist.Items {
+			if ct == nil {
+				continue
+			}
+			if name := strings.TrimSpace(ct.Remark); name != "" {
+				names[ct.UserName] = name
+			} else if name := strings.TrimSpace(ct.NickName); name != "" {
+				names[ct.UserName] = name
+			}
+		}
+		for _, item := range report.Items {
+			item.Name = names[item.UserName]
+		}
+	}
+	return report, nil
+}
+
+// writeRelationships 以文本形式输出关系分析报告，供 MCP 与 format=text 使用
+func writeRelationships(buf *bytes.Buffer, report *analytics.Report) {
+	if len(report.Items) == 0 {
+		buf.WriteString("没有可分析的联系人")
+		return
+	}
+
+	display := func(r *analytics.Relationship) string {
+		if r.Name != "" {
+			return fmt.Sprintf("%s(%s)", r.Name, r.UserName)
+		}
+		return r.UserName
+	}
+	byName := make(map[string]*analytics.Relationship, len(report.Items))
+	for _, item := range report.Items {
+		byName[item.UserName] = item
+	}
+
+	buf.WriteString(fmt.Sprintf("参考时间 %s，本季度 %s 起，上季度 %s 起；共 %d 位联系人参与评分\n",
+		report.Reference.Format("2006-01-02 15:04"), report.QuarterStart.Format("2006-01-02"),
+		report.PreviousStart.Format("2006-01-02"), report.Candidates))
+	for _, r := range report.Items {
+		buf.WriteString(fmt.Sprintf("#%d %s 评分 %.2f\n", r.Rank, display(r), r.Score))
+		buf.WriteString(fmt.Sprintf("  消息 %d（我发 %d / 对方 %d），活跃 %d 天，%s ~ %s\n",
+			r.MsgCount, r.SentCount, r.ReceivedCount, r.MessagingDays,
+			r.FirstMessage.Format("2006-01-02"), r.LastMessage.Format("2006-01-02")))
+		buf.WriteString(fmt.Sprintf("  本季度 %d 条 / 上季度 %d 条（%s）\n", r.Trend.Current, r.Trend.Previous, trendText(r.Trend)))
+		buf.WriteString(fmt.Sprintf("  回复间隔中位数：我 %s（%d 次），对方 %s（%d 次）\n",
+			latencyText(r.ReplyLatency.MyMedian, r.ReplyLatency.MyReplies), r.ReplyLatency.MyReplies,
+			latencyText(r.ReplyLatency.TheirMedian, r.ReplyLatency.TheirReplies), r.ReplyLatency.TheirReplies))
+		buf.WriteString(fmt.Sprintf("  对话 %d 次，由我发起 %d 次（%.0f%%）\n",
+			r.Initiator.Conversations, r.Initiator.ByMe, r.Initiator.Ratio*100))
+	}
+
+	names := func(list []string) string {
+		out := make([]string, 0, len(list))
+		for _, name := range list {
+			if r := byName[name]; r != nil {
+				out = append(out, display(r))
+			}
+		}
+		return strings.Join(out, "、")
+	}
+	if len(report.More) > 0 {
+		buf.WriteString("比上季度聊得多：" + names(report.More) + "\n")
+	}
+	if len(report.Less) > 0 {
+		buf.WriteString("比上季度聊得少：" + names(report.Less) + "\n")
+	}
+}
+
+func trendText(t analytics.Trend) string {
+	switch t.Direction {
+	case analytics.TrendNew:
+		return "新增"
+	case analytics.TrendInactive:
+		return "本季度无消息"
+	case analytics.TrendUp:
+		return fmt.Sprintf("上升 %.0f%%", t.Change*100)
+	case analytics.TrendDown:
+		return fmt.Sprintf("下降 %.0f%%", -t.Change*100)
+	}
+	return "持平"
+}
+
+func latencyText(seconds float64, n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// GET /api/v1/chatroom/members?talker=xxx@chatroom&time=2024-01-01~2024-06-30&format=(json|csv)
+// 列出群成员在时间范围内的首末发言时间、消息数与媒体数，标记没有发言的成员，并从系统消息中识别入群与退群；
+// time 为空时统计最近 30 天
+func (s *Service) handleChatRoomMembers(c *gin.Context) {
+	params := struct {
+		Talker string `form:"talker"`
+		Time   string `form:"time"`
+		Format string `form:"format"`
+	}{}
+	if err := c.BindQuery(&params); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	report, err := s.chatRoomMembers(params.Talker, params.Time)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	switch strings.ToLower(params.Format) {
+	case "csv":
+		c.Writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=members_%s_%s.csv", report.ChatRoom, time.Now().Format("20060102_150405")))
+		csvWriter := csv.NewWriter(c.Writer)
+		csvWriter.Write([]string{"UserName", "DisplayName", "IsMember", "IsOwner", "Messages", "Media", "FirstMessage", "LastMessage", "Silent", "JoinedAt", "LeftAt"})
+		for _, m := range report.Items {
+			csvWriter.Write([]string{
+				m.UserName,
+				m.DisplayName,
+				strconv.FormatBool(m.IsMember),
+				strconv.FormatBool(m.IsOwner),
+				strconv.Itoa(m.Messages),
+				strconv.Itoa(m.Media),
+				formatTimePtr(m.FirstMessage),
+				formatTimePtr(m.LastMessage),
+				strconv.FormatBool(m.Silent),
+				formatTimePtr(m.JoinedAt),
+				formatTimePtr(m.LeftAt),
+			})
+		}
+		csvWriter.Flush()
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// chatRoomMembers 解析群聊与时间范围，读取范围内的全部消息生成成员活跃度报告
+func (s *Service) chatRoomMembers(talker, timeRange string) (*analytics.MemberReport, error) {
+	talker = strings.TrimSpace(talker)
+	if talker == "" {
+		return nil, errors.InvalidArg("talker")
+	}
+
+	end := time.Now()
+	start := end.AddDate(0, 0, -analytics.DefaultMemberWindowDays)
+	if strings.TrimSpace(timeRange) != "" {
+		var ok bool
+		start, end, ok = util.TimeRangeOf(timeRange)
+		if !ok {
+			return nil, errors.InvalidArg("time")
+		}
+	}
+
+	rooms, err := s.db.GetChatRooms(talker, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	var room *model.ChatRoom
+	for _, r := range rooms.Items {
+		if r != nil && r.Name == talker {
+			room = r
+			break
+		}
+	}
+	if room == nil && len(rooms.Items) > 0 {
+		room = rooms.Items[0]
+	}
+	if room == nil {
+		return nil, errors.ChatRoomNotFound(talker)
+	}
+
+	msgs, err := s.db.GetMessages(start, end, room.Name, "", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	nicknames := make(map[string]string, len(room.Users))
+	if clist, err := s.db.GetContacts("", 0, 0); err == nil && clist != nil {
+		// 当前成员与范围内的发言者，后者用于识别已退群成员的退群记录
+		members := make(map[string]struct{}, len(room.Users))
+		for _, u := range room.Users {
+			members[u.UserName] = struct{}{}
+		}
+		for _, msg := range msgs {
+			if msg != nil && msg.Sender != "" {
+				members[msg.Sender] = struct{}{}
+			}
+		}
+		for _, ct := range clist.Items {
+			if ct == nil {
+				continue
+			}
+			if _, ok := members[ct.UserName]; ok && ct.NickName != "" {
+				nicknames[ct.UserName] = ct.NickName
+			}
+		}
+	}
+
+	return analytics.Members(room, msgs, analytics.MemberOptions{
+		Start:     start,
+		End:       end,
+		Self:      trimWxidSuffix(s.currentAccountID()),
+		Nicknames: nicknames,
+	}), nil
+}
+
+// writeChatRoomMembers 以文本形式输出群成员报告，供 MCP 使用
+func writeChatRoomMembers(buf *bytes.Buffer, report *analytics.MemberReport) {
+	title := report.ChatRoom
+	if report.Name != "" && report.Name != report.ChatRoom {
+		title = fmt.Sprintf("%s(%s)", report.Name, report.ChatRoom)
+	}
+	buf.WriteString(fmt.Sprintf("%s %s ~ %s：当前 %d 位成员，%d 位发言，%d 位未发言\n",
+		title, report.Start.Format("2006-01-02"), report.End.Format("2006-01-02"),
+		report.Members, report.Active, report.Silent))
+
+	display := func(m *analytics.MemberActivity) string {
+		if m.DisplayName != "" {
+			return fmt.Sprintf("%s(%s)", m.DisplayName, m.UserName)
+		}
+		return m.UserName
+	}
+	var silent, former []string
+	for _, m := range report.Items {
+		switch {
+		case !m.IsMember && m.Messages == 0:
+			continue
+		case m.Silent:
+			silent = append(silent, display(m))
+			continue
+		}
+		line := fmt.Sprintf("%s 消息 %d 条（媒体 %d），%s ~ %s", display(m), m.Messages, m.Media,
+			m.FirstMessage.Format("2006-01-02 15:04"), m.LastMessage.Format("2006-01-02 15:04"))
+		if !m.IsMember {
+			former = append(former, line)
+			continue
+		}
+		if m.IsOwner {
+			line += "，群主"
+		}
+		buf.WriteString(line + "\n")
+	}
+	if len(former) > 0 {
+		buf.WriteString("已不在群中：\n" + strings.Join(former, "\n") + "\n")
+	}
+	if len(silent) > 0 {
+		buf.WriteString("未发言：" + strings.Join(silent, "、") + "\n")
+	}
+	if len(report.Events) > 0 {
+		buf.WriteString("成员变动：\n")
+		for _, ev := range report.Events {
+			action := "入群"
+			if ev.Type == analytics.EventLeave {
+				action = "退群"
+			}
+			name := ev.Name
+			if ev.UserName != "" {
+				name = fmt.Sprintf("%s(%s)", ev.Name, ev.UserName)
+			}
+			buf.WriteString(fmt.Sprintf("%s %s %s\n", ev.Time.Format("2006-01-02 15:04"), action, name))
+		}
+	}
+}
+
+// trimWxidSuffix 去掉 v3 wxid 目录可能带有的第二段后缀，如 wxid_xxx_yyyy
+func trimWxidSuffix(id string) string {
+	if rest, ok := strings.CutPrefix(id, "wxid_"); ok {
+		if idx := strings.Index(rest, "_"); idx >= 0 {
+			return id[:len("wxid_")+idx]
+		}
+	}
+	return id
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
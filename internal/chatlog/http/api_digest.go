@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/digest"
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+)
+
+// GET /api/v1/digest
+// 返回定时摘要任务的下次运行时间、上次运行结果与投递目标
+func (s *Service) handleDigestStatus(c *gin.Context) {
+	scheduler := s.db.GetDigest()
+	if scheduler == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "items": []digest.Status{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "items": scheduler.Status()})
+}
+
+// POST /api/v1/digest?name=xxx
+// 立即生成并投递指定摘要，时间范围截止到当前时间，返回生成的摘要
+func (s *Service) handleDigestRun(c *gin.Context) {
+	scheduler := s.db.GetDigest()
+	if scheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "digest not configured"})
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		errors.Err(c, errors.InvalidArg("name"))
+		return
+	}
+
+	d, err := scheduler.RunNow(c.Request.Context(), name)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, digest.WebhookPayload{Type: "digest", Digest: d, Text: d.Text()})
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/media"
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
+)
+
+type mediaInfoResponse struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Name string `json:"name,omitempty"`
+	*media.Info
+}
+
+func (s *Service) mediaPipeline() *media.Pipeline {
+	workDir := s.conf.GetWorkDir()
+	if workDir == "" {
+		workDir = filepath.Join(os.TempDir(), "chatlog")
+	}
+	return media.New(filepath.Join(workDir, media.CacheDirName), s.imgKeys)
+}
+
+// decodedCache 返回当前工作目录的图片解码缓存，工作目录未设置时返回 nil
+func (s *Service) decodedCache() *media.DecodedCache {
+	workDir := s.conf.GetWorkDir()
+	if workDir == "" {
+		return nil
+	}
+	dir := filepath.Join(workDir, media.CacheDirName)
+
+	s.decodedMu.Lock()
+	defer s.decodedMu.Unlock()
+	if s.decoded != nil && s.decodedDir == dir {
+		return s.decoded
+	}
+	cache, err := media.OpenDecodedCache(dir, s.imgKeys)
+	if err != nil {
+		log.Debug().Err(err).Msg("open decoded media cache failed")
+		return nil
+	}
+	s.decoded, s.decodedDir = cache, dir
+	return cache
+}
+
+// serveDecoded 从解码缓存返回 .dat 图片，未命中时返回 false
+func (s *Service) serveDecoded(c *gin.Context, path string) bool {
+	cache := s.decodedCache()
+	if cache == nil {
+		return false
+	}
+	rel, err := filepath.Rel(s.conf.GetDataDir(), path)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	blob, ext, ok := cache.Lookup(rel, info)
+	if !ok {
+		return false
+	}
+	c.Header("Content-Type", media.MIME(ext))
+	c.File(blob)
+	return true
+}
+
+// GET /api/v1/media/decode
+// 返回批量解码任务的进度与缓存统计
+func (s *Service) handleMediaDecodeStatus(c *gin.Context) {
+	resp := gin.H{"progress": s.decodeJobs.Progress()}
+	if cache := s.decodedCache(); cache != nil {
+		resp["stats"] = cache.Stats()
+		resp["failures"] = cache.Failures(100)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// POST /api/v1/media/decode
+// 在后台把数据目录中的 .dat 图片解码到缓存；force=1 时全部重新解码
+func (s *Service) handleMediaDecodeStart(c *gin.Context) {
+	cache := s.decodedCache()
+	if cache == nil || s.conf.GetDataDir() == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "data dir or work dir not configured"})
+		return
+	}
+	opts := media.DecodeOptions{Force: c.Query("force") != ""}
+	if err := s.decodeJobs.Start(s.conf.GetDataDir(), cache, opts); err != nil {
+		if errors.Is(err, media.ErrDecodeRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, s.decodeJobs.Progress())
+}
+
+// DELETE /api/v1/media/decode
+// 取消正在运行的解码任务
+func (s *Service) handleMediaDecodeCancel(c *gin.Context) {
+	s.decodeJobs.Cancel()
+	c.JSON(http.StatusOK, s.decodeJobs.Progress())
+}
+
+// GET /api/v1/media/:key/info
+// 返回媒体的格式、编码、尺寸与时长；type 可限定为 image、video 或 file，默认依次尝试
+func (s *Service) handleMediaInfo(c *gin.Context) {
+	key := strings.TrimSpace(c.Param("key"))
+	if key == "" {
+		errors.Err(c, errors.InvalidArg("key"))
+		return
+	}
+	types := []string{"image", "video", "file"}
+	if t := strings.TrimSpace(c.Query("type")); t != "" {
+		types = util.Str2List(t, ",")
+	}
+
+	lastErr := error(errors.ErrMediaNotFound)
+	for _, _type := range types {
+		m, err := s.db.GetMedia(_type, key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		rel, err := s.findPath(_type, m.Path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		info, err := s.mediaPipeline().Probe(filepath.Join(s.conf.GetDataDir(), rel))
+		if err != nil && info == nil {
+			lastErr = err
+			continue
+		}
+		c.JSON(http.StatusOK, mediaInfoResponse{Key: key, Type: _type, Path: rel, Name: m.Name, Info: info})
+		return
+	}
+	errors.Err(c, lastErr)
+}
+
+// serveThumbnail 返回缓存的 JPEG 缩略图，thumb 参数为长边像素数，1 表示默认尺寸
+func (s *Service) serveThumbnail(c *gin.Context, path string) {
+	size, _ := strconv.Atoi(c.Query("thumb"))
+	if size <= 1 {
+		size = media.DefaultThumbSize
+	}
+	data, err := s.mediaPipeline().Thumbnail(c.Request.Context(), path, size)
+	if err != nil {
+		if errors.Is(err, media.ErrUnsupported) || errors.Is(err, media.ErrNoFFmpeg) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		log.Debug().Err(err).Str("path", path).Msg("generate thumbnail failed")
+		errors.Err(c, err)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=86400")
+	serveBytes(c, filepath.Base(path)+".jpg", "image/jpeg", data, time.Time{})
+}
+
+// servePlayable 把 wxgf/HEVC 内容封装或转码为浏览器可播放的 MP4，支持 Range 请求
+func (s *Service) servePlayable(c *gin.Context, path string) {
+	out, err := s.mediaPipeline().Playable(c.Request.Context(), path)
+	if err != nil {
+		if errors.Is(err, media.ErrUnsupported) || errors.Is(err, media.ErrNoFFmpeg) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		log.Debug().Err(err).Str("path", path).Msg("prepare playable video failed")
+		errors.Err(c, err)
+		return
+	}
+	c.Header("Content-Type", "video/mp4")
+	c.File(out)
+}
+
+// serveBytes 通过 http.ServeContent 输出内存中的数据，以支持 Range 与条件请求
+func serveBytes(c *gin.Context, name, contentType string, data []byte, modTime time.Time) {
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, name, modTime, bytes.NewReader(data))
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/keystore"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/wechat"
 )
 
 type settingRequest struct {
@@ -17,15 +19,17 @@ type settingRequest struct {
 	Speech   *conf.SpeechConfig `json:"speech"`
 }
 
+// settingResponse 中的密钥只返回脱敏后的内容
 type settingResponse struct {
-	HTTPAddr    string             `json:"http_addr"`
-	HTTPEnabled bool               `json:"http_enabled"`
-	WorkDir     string             `json:"work_dir"`
-	DataDir     string             `json:"data_dir"`
-	DataKey     string             `json:"data_key"`
-	ImgKey      string             `json:"img_key"`
-	AutoDecrypt bool               `json:"auto_decrypt"`
-	Speech      *conf.SpeechConfig `json:"speech"`
+	HTTPAddr    string              `json:"http_addr"`
+	HTTPEnabled bool                `json:"http_enabled"`
+	WorkDir     string              `json:"work_dir"`
+	DataDir     string              `json:"data_dir"`
+	DataKey     string              `json:"data_key"`
+	ImgKey      string              `json:"img_key"`
+	AutoDecrypt bool                `json:"auto_decrypt"`
+	Speech      *conf.SpeechConfig  `json:"speech"`
+	LastDecrypt *wechat.DecryptPass `json:"last_decrypt,omitempty"`
 }
 
 func (s *Service) handleGetSetting(c *gin.Context) {
@@ -38,6 +42,13 @@ func (s *Service) handleUpdateSetting(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload", "detail": err.Error()})
 		return
 	}
+	// 脱敏后的密钥是 GET 的返回值，原样提交会用它覆盖真实密钥
+	for name, key := range map[string]*string{"data_key": req.DataKey, "img_key": req.ImgKey} {
+		if key != nil && keystore.IsRedacted(*key) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "redacted key rejected", "detail": name + " is a redacted value; omit it to keep the current key"})
+			return
+		}
+	}
 
 	if req.HTTPAddr != nil {
 		if s.control == nil {
@@ -88,8 +99,8 @@ func (s *Service) buildSettingResponse() settingResponse {
 		HTTPEnabled: s.conf.IsHTTPEnabled(),
 		WorkDir:     s.conf.GetWorkDir(),
 		DataDir:     s.conf.GetDataDir(),
-		DataKey:     s.conf.GetDataKey(),
-		ImgKey:      s.conf.GetImgKey(),
+		DataKey:     keystore.Redact(s.conf.GetDataKey()),
+		ImgKey:      keystore.Redact(s.conf.GetImgKey()),
 		AutoDecrypt: s.conf.IsAutoDecrypt(),
 	}
 
@@ -98,5 +109,9 @@ func (s *Service) buildSettingResponse() settingResponse {
 		resp.Speech = &copyCfg
 	}
 
+	if s.control != nil {
+		resp.LastDecrypt = s.control.LastDecryptPass()
+	}
+
 	return resp
 }
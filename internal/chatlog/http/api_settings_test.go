@@ -0,0 +1,25 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateSettingRejectsRedactedKeys(t *testing.T) {
+	// 校验发生在修改任何配置之前，conf 为空也不会被访问
+	s := &Service{}
+	r := gin.New()
+	r.POST("/setting", s.handleUpdateSetting)
+
+	for _, body := range []string{`{"data_key":"********eeff"}`, `{"img_key":"********"}`} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/setting", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "redacted") {
+			t.Fatalf("%s: status = %d (%s)", body, w.Code, w.Body.String())
+		}
+	}
+}
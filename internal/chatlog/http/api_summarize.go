@@ -0,0 +1,159 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/summarize"
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/llm"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
+)
+
+// initSummarize 按配置创建会话总结使用的大模型客户端，未启用时总结接口返回 503
+func (s *Service) initSummarize(cfg Config) {
+	s.summarizeMu.Lock()
+	defer s.summarizeMu.Unlock()
+	s.summarizeLLM = nil
+
+	sc := cfg.GetSummarize()
+	if sc == nil || !sc.Enabled {
+		return
+	}
+	sc.Normalize()
+	client, err := llm.NewOpenAIClient(llm.OpenAIConfig{
+		Model:          sc.Model,
+		APIKey:         sc.APIKey,
+		BaseURL:        sc.BaseURL,
+		Organization:   sc.Organization,
+		ProxyURL:       sc.Proxy,
+		RequestTimeout: time.Duration(sc.RequestTimeoutSeconds) * time.Second,
+	})
+	if err != nil {
+		log.Err(err).Msg("initialise summarize llm client failed")
+		return
+	}
+	s.summarizeLLM = client
+	s.summarizeChunk = sc.ChunkTokens
+	s.summarizeMaxChunks = sc.MaxChunks
+	log.Info().Str("model", client.ModelName()).Msg("conversation summarization enabled")
+}
+
+// summarizer 返回绑定当前工作目录缓存的总结器，未启用时返回 nil
+func (s *Service) summarizer() *summarize.Summarizer {
+	s.summarizeMu.Lock()
+	defer s.summarizeMu.Unlock()
+	if s.summarizeLLM == nil {
+		return nil
+	}
+
+	if workDir := s.conf.GetWorkDir(); workDir != "" && (s.summaryCache == nil || s.summaryDir != workDir) {
+		if s.summaryCache != nil {
+			s.summaryCache.Close()
+			s.summaryCache = nil
+		}
+		cache, err := summarize.OpenCache(workDir)
+		if err != nil {
+			log.Debug().Err(err).Msg("open summary cache failed")
+		} else {
+			s.summaryCache, s.summaryDir = cache, workDir
+		}
+	}
+	return summarize.New(s.summarizeLLM, s.summarizeChunk, s.summarizeMaxChunks, s.summaryCache)
+}
+
+// GET /api/v1/summarize?talker=xxx&time=2024-01-01~2024-01-31&refresh=false&format=(json|text)
+// 服务端总结会话：按 token 预算分块，逐块总结后合并，总结中的 [seq] 对应 citations 中的原始消息；
+// 结果按 (talker, 时间范围, 模型) 缓存，消息变化或 refresh=true 时重新生成
+func (s *Service) handleSummarize(c *gin.Context) {
+	params := struct {
+		Talker  string `form:"talker"`
+		Time    string `form:"time"`
+		Refresh bool   `form:"refresh"`
+		Format  string `form:"format"`
+	}{}
+	if err := c.BindQuery(&params); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	res, err := s.summarize(c.Request.Context(), params.Talker, params.Time, params.Refresh)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	switch strings.ToLower(params.Format) {
+	case "text":
+		c.String(http.StatusOK, summaryText(res))
+	default:
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// summarize 读取 talker 在时间范围内的全部消息并生成总结，time 为空时总结今天的消息
+func (s *Service) summarize(ctx context.Context, talker, timeRange string, refresh bool) (*summarize.Result, error) {
+	summarizer := s.summarizer()
+	if summarizer == nil {
+		return nil, errors.New(nil, http.StatusServiceUnavailable, "summarize not configured")
+	}
+	talker = strings.TrimSpace(talker)
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	if strings.TrimSpace(timeRange) == "" {
+		timeRange = "today"
+	}
+	start, end, ok := util.TimeRangeOf(timeRange)
+	if !ok {
+		return nil, errors.InvalidArg("time")
+	}
+
+	msgs, err := s.db.GetMessages(start, end, talker, "", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) > 0 && !strings.Contains(talker, ",") {
+		talker = msgs[0].Talker
+	}
+	return summarizer.Summarize(ctx, talker, start, end, msgs, refresh)
+}
+
+// summaryText 以文本形式输出总结与引用的消息，供 MCP 与 format=text 使用
+func summaryText(res *summarize.Result) string {
+	title := res.Talker
+	if res.TalkerName != "" {
+		title = fmt.Sprintf("%s(%s)", res.TalkerName, res.Talker)
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s %s ~ %s，%d 条消息", title,
+		res.Start.Format("2006-01-02 15:04"), res.End.Format("2006-01-02 15:04"), res.Messages))
+	if res.Messages == 0 {
+		b.WriteString("，没有可总结的内容\n")
+		return b.String()
+	}
+	b.WriteString(fmt.Sprintf("，分 %d 段总结（%s", res.Chunks, res.Model))
+	if res.Cached {
+		b.WriteString("，缓存")
+	}
+	b.WriteString("）\n\n")
+	b.WriteString(res.Summary)
+	b.WriteString("\n")
+	if len(res.Citations) > 0 {
+		b.WriteString("\n引用的消息：\n")
+		for _, ct := range res.Citations {
+			sender := ct.Sender
+			if ct.SenderName != "" {
+				sender = ct.SenderName
+			}
+			b.WriteString(fmt.Sprintf("[%d] %s %s: %s\n", ct.Seq, ct.Time.Format("2006-01-02 15:04:05"), sender, ct.Content))
+		}
+	}
+	return b.String()
+}
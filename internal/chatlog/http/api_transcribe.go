@@ -0,0 +1,166 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/transcript"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/whisper"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
+)
+
+// 单次请求中即时转写的语音条数上限，其余语音只使用已缓存的转写
+const inlineTranscribeLimit = 20
+
+// voiceSource 为读取语音与转写缓存所需的数据访问，database.Service 已实现
+type voiceSource interface {
+	GetTranscripts() *transcript.Store
+	GetMedia(_type string, key string) (*model.Media, error)
+}
+
+type transcribeStartRequest struct {
+	Talker string `json:"talker"`
+	Time   string `json:"time"`
+}
+
+// GET /api/v1/transcribe
+// 返回当前（或最近一次）批量转写任务的进度
+func (s *Service) handleTranscribeStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.transcribeJobs.Progress())
+}
+
+// POST /api/v1/transcribe
+// 在后台转写语音消息；body 为 {"talker": "...", "time": "..."}，也可使用同名查询参数，均为空时转写全部会话
+func (s *Service) handleTranscribeStart(c *gin.Context) {
+	if s.speechTranscriber == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "speech transcription not enabled"})
+		return
+	}
+	store := s.db.GetTranscripts()
+	if store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "transcript store unavailable"})
+		return
+	}
+
+	var req transcribeStartRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload", "detail": err.Error()})
+			return
+		}
+	}
+	if talker := strings.TrimSpace(c.Query("talker")); talker != "" {
+		req.Talker = talker
+	}
+	if t := strings.TrimSpace(c.Query("time")); t != "" {
+		req.Time = t
+	}
+
+	opts := transcript.JobOptions{
+		Talkers: util.Str2List(req.Talker, ","),
+		Model:   s.speechModel,
+		Options: s.speechOptions,
+	}
+	if strings.TrimSpace(req.Time) != "" {
+		start, end, ok := util.TimeRangeOf(req.Time)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid time range"})
+			return
+		}
+		opts.Start, opts.End = start, end
+	}
+
+	if err := s.transcribeJobs.Start(s.db, store, s.speechTranscriber, opts); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, transcript.ErrJobRunning) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "progress": s.transcribeJobs.Progress()})
+		return
+	}
+	c.JSON(http.StatusAccepted, s.transcribeJobs.Progress())
+}
+
+// DELETE /api/v1/transcribe
+// 取消正在运行的批量转写任务，已完成的转写会保留
+func (s *Service) handleTranscribeCancel(c *gin.Context) {
+	s.transcribeJobs.Cancel()
+	c.JSON(http.StatusOK, s.transcribeJobs.Progress())
+}
+
+// transcribeVoice 返回语音的转写结果，优先读取缓存（refresh 时跳过），未命中时调用转写服务并写入缓存
+func (s *Service) transcribeVoice(ctx context.Context, store *transcript.Store, key string, data []byte, opts whisper.Options, refresh bool) (*transcript.Entry, bool, error) {
+	optionsKey := transcript.OptionsKey(opts)
+	if store != nil && !refresh {
+		if cached, err := store.Get(key, s.speechModel, optionsKey); err == nil && cached != nil {
+			return cached, true, nil
+		}
+	}
+
+	res, err := s.speechTranscriber.TranscribeSilk(ctx, data, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	entry := transcript.NewEntry(key, s.speechModel, optionsKey, res)
+	if store != nil {
+		if err := store.Put(entry); err != nil {
+			log.Warn().Err(err).Str("media_key", key).Msg("save voice transcript failed")
+		}
+	}
+	return entry, false, nil
+}
+
+// attachTranscripts 将语音转写写入消息的 transcript / transcript_segments 字段，
+// 文本、CSV 输出会把转写接在语音链接之后，JSON 输出另含分段时间轴。
+// budget 为本次请求剩余的即时转写条数，同一请求内多次调用共用一份，
+// 由调用方以 inlineTranscribeLimit 初始化
+func (s *Service) attachTranscripts(ctx context.Context, messages []*model.Message, budget *int) {
+	s.attachTranscriptsFrom(ctx, s.db, messages, budget)
+}
+
+func (s *Service) attachTranscriptsFrom(ctx context.Context, src voiceSource, messages []*model.Message, budget *int) {
+	store := src.GetTranscripts()
+	optionsKey := transcript.OptionsKey(s.speechOptions)
+	for _, m := range messages {
+		key := transcript.VoiceKey(m)
+		if key == "" {
+			continue
+		}
+
+		var entry *transcript.Entry
+		if store != nil {
+			entry, _ = store.Get(key, s.speechModel, optionsKey)
+		}
+		if entry == nil && s.speechTranscriber != nil && *budget > 0 && ctx.Err() == nil {
+			*budget--
+			if media, err := src.GetMedia("voice", key); err == nil && len(media.Data) > 0 {
+				itemCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+				entry, _, err = s.transcribeVoice(itemCtx, store, key, media.Data, s.speechOptions, false)
+				cancel()
+				if err != nil {
+					log.Debug().Err(err).Str("media_key", key).Msg("inline voice transcription failed")
+				}
+			}
+		}
+		if entry == nil && store != nil {
+			// 其他模型或参数转写的结果也可用于展示
+			if text := store.Lookup(key); text != "" {
+				entry = &transcript.Entry{Key: key, Text: text}
+			}
+		}
+		if entry == nil || strings.TrimSpace(entry.Text) == "" {
+			continue
+		}
+		m.Contents["transcript"] = strings.TrimSpace(entry.Text)
+		if len(entry.Segments) > 1 {
+			m.Contents["transcript_segments"] = entry.Segments
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/transcript"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/whisper"
+)
+
+type voiceStub struct{}
+
+func (voiceStub) GetTranscripts() *transcript.Store { return nil }
+
+func (voiceStub) GetMedia(_type string, key string) (*model.Media, error) {
+	return &model.Media{Type: _type, Data: []byte(key)}, nil
+}
+
+type countingTranscriber struct{ calls int }
+
+func (c *countingTranscriber) Close() {}
+
+func (c *countingTranscriber) TranscribePCM(ctx context.Context, samples []float32, sampleRate int, opts whisper.Options) (*whisper.Result, error) {
+	return nil, nil
+}
+
+func (c *countingTranscriber) TranscribeSilk(ctx context.Context, silkData []byte, opts whisper.Options) (*whisper.Result, error) {
+	c.calls++
+	return &whisper.Result{Text: "转写" + string(silkData)}, nil
+}
+
+func voiceMessages(talker string, n int) []*model.Message {
+	msgs := make([]*model.Message, n)
+	for i := range msgs {
+		msgs[i] = &model.Message{Talker: talker, Type: model.MessageTypeVoice, Contents: map[string]interface{}{"voice": fmt.Sprintf("%s-%d", talker, i)}}
+	}
+	return msgs
+}
+
+func TestAttachTranscriptsSharesBudget(t *testing.T) {
+	tr := &countingTranscriber{}
+	s := &Service{speechTranscriber: tr, speechModel: "stub"}
+
+	// 分组输出时多个会话共用同一份额度
+	budget := inlineTranscribeLimit
+	groups := [][]*model.Message{voiceMessages("a", 15), voiceMessages("b", 15)}
+	for _, msgs := range groups {
+		s.attachTranscriptsFrom(context.Background(), voiceStub{}, msgs, &budget)
+	}
+
+	if tr.calls != inlineTranscribeLimit || budget != 0 {
+		t.Fatalf("transcribed %d voices (budget left %d), want %d", tr.calls, budget, inlineTranscribeLimit)
+	}
+	attached := 0
+	for _, msgs := range groups {
+		for _, m := range msgs {
+			if m.Contents["transcript"] != nil {
+				attached++
+			}
+		}
+	}
+	if attached != inlineTranscribeLimit {
+		t.Fatalf("attached %d transcripts", attached)
+	}
+}
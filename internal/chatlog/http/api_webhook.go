@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/webhook"
+)
+
+type webhookReplayRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// GET /api/v1/webhook
+// 返回待投递数量与死信列表（含最后一次失败原因），便于排查接收方问题
+func (s *Service) handleWebhookStatus(c *gin.Context) {
+	queue := s.db.GetWebhookQueue()
+	if queue == nil {
+		c.JSON(http.StatusOK, gin.H{"pending": 0, "dead_letters": []*webhook.Delivery{}})
+		return
+	}
+
+	letters, err := queue.DeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pending": queue.Pending(), "dead_letters": letters})
+}
+
+// POST /api/v1/webhook
+// 将死信重新放回投递队列；body 为 {"ids": [...]}，也可使用 ?id=a,b；均为空时重放全部
+func (s *Service) handleWebhookReplay(c *gin.Context) {
+	queue := s.db.GetWebhookQueue()
+	if queue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "webhook not configured"})
+		return
+	}
+
+	var req webhookReplayRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload", "detail": err.Error()})
+			return
+		}
+	}
+	if ids := strings.TrimSpace(c.Query("id")); ids != "" {
+		req.IDs = append(req.IDs, strings.Split(ids, ",")...)
+	}
+
+	replayed, err := queue.Replay(req.IDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "replayed": replayed})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"replayed": replayed, "pending": queue.Pending()})
+}
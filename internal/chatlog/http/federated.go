@@ -0,0 +1,304 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/database"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/federated"
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
+)
+
+// maxFederatedWindow 限制联合查询时每个账号返回的条数（offset+limit），超出时返回 400
+const maxFederatedWindow = 1000
+
+// federatedSource 为联合查询在单个账号上使用的数据访问，database.Service 已实现
+type federatedSource interface {
+	SearchMessages(req *model.SearchRequest) (*model.SearchResponse, error)
+	GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
+}
+
+type accountTarget struct {
+	id    string
+	src   federatedSource
+	ready bool
+}
+
+// targetResolver 把 accounts 参数解析为查询目标
+type targetResolver func(ids string) ([]accountTarget, error)
+
+func newAccountTarget(id string, svc *Service) accountTarget {
+	return accountTarget{id: id, src: svc.db, ready: svc.db.State == database.StateReady}
+}
+
+// federatedTargets 解析逗号分隔的账号 ID，为空时返回主账号与全部挂载账号
+func (s *Service) federatedTargets(ids string) ([]accountTarget, error) {
+	if strings.TrimSpace(ids) == "" {
+		targets := []accountTarget{newAccountTarget(s.currentAccountID(), s)}
+		for _, a := range s.accounts {
+			targets = append(targets, newAccountTarget(a.id, a.service))
+		}
+		return targets, nil
+	}
+	var targets []accountTarget
+	seen := make(map[string]bool)
+	for _, id := range util.Str2List(ids, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		svc := s.account(id)
+		if svc == nil {
+			return nil, errors.InvalidArg("accounts")
+		}
+		seen[id] = true
+		targets = append(targets, newAccountTarget(id, svc))
+	}
+	if len(targets) == 0 {
+		return nil, errors.InvalidArg("accounts")
+	}
+	return targets, nil
+}
+
+// fanOut 并发地在每个账号上执行 fn，数据库未就绪或出错的账号记录到返回的 map 中
+func fanOut[T any](targets []accountTarget, fn func(federatedSource) (T, error)) ([]T, map[string]string) {
+	results := make([]T, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		if !t.ready {
+			errs[i] = fmt.Errorf("database is not ready")
+			continue
+		}
+		wg.Add(1)
+		go func(i int, src federatedSource) {
+			defer wg.Done()
+			results[i], errs[i] = fn(src)
+		}(i, t.src)
+	}
+	wg.Wait()
+
+	failed := make(map[string]string)
+	for i, err := range errs {
+		if err != nil {
+			failed[targets[i].id] = err.Error()
+		}
+	}
+	return results, failed
+}
+
+// accountHost 返回账号媒体链接使用的 host，与 /accounts/:id 路由对应
+func (s *Service) accountHost(c *gin.Context, id string) string {
+	if id == s.currentAccountID() {
+		return c.Request.Host
+	}
+	return c.Request.Host + "/accounts/" + id
+}
+
+func targetIDs(targets []accountTarget) []string {
+	ids := make([]string, len(targets))
+	for i, t := range targets {
+		ids[i] = t.id
+	}
+	return ids
+}
+
+// checkFederatedWindow 拒绝超出单账号取数上限的分页，避免静默截断结果
+func checkFederatedWindow(limit, offset int) error {
+	if limit > 0 && offset+limit > maxFederatedWindow {
+		return errors.Newf(nil, http.StatusBadRequest, "offset+limit must not exceed %d for federated queries", maxFederatedWindow)
+	}
+	return nil
+}
+
+// GET /api/v1/federated/search
+func (s *Service) handleFederatedSearch(c *gin.Context) {
+	s.federatedSearch(c, s.federatedTargets)
+}
+
+func (s *Service) federatedSearch(c *gin.Context, resolve targetResolver) {
+	q := struct {
+		searchParams
+		Order    string `form:"order"`
+		Accounts string `form:"accounts"`
+	}{}
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	req, err := q.request()
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	order := strings.ToLower(strings.TrimSpace(q.Order))
+	switch order {
+	case "":
+		order = federated.OrderScore
+	case federated.OrderScore, federated.OrderTime:
+	default:
+		errors.Err(c, errors.InvalidArg("order"))
+		return
+	}
+	if err := checkFederatedWindow(req.Limit, req.Offset); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	targets, err := resolve(q.Accounts)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	// 每个账号取前 offset+limit 条，合并后再分页
+	window := req.Offset + req.Limit
+	started := time.Now()
+	results, failed := fanOut(targets, func(src federatedSource) (*model.SearchResponse, error) {
+		r := req.Clone()
+		r.Limit, r.Offset = window, 0
+		return src.SearchMessages(r)
+	})
+
+	sources := make([]federated.HitSource, 0, len(targets))
+	total := 0
+	for i, resp := range results {
+		if resp == nil {
+			continue
+		}
+		total += resp.Total
+		sources = append(sources, federated.HitSource{Account: targets[i].id, Hits: resp.Hits})
+	}
+	merged, dups := federated.MergeHits(sources, order)
+	hits := federated.Page(merged, req.Limit, req.Offset)
+
+	if strings.EqualFold(strings.TrimSpace(q.Format), "text") {
+		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(c.Writer, "关键词: %s\n账号: %s\n总命中: %d, 本页: %d\n", req.Query, strings.Join(targetIDs(targets), ", "), max(total-dups, 0), len(hits))
+		for id, msg := range failed {
+			fmt.Fprintf(c.Writer, "账号 %s 查询失败: %s\n", id, msg)
+		}
+		fmt.Fprintln(c.Writer, strings.Repeat("-", 60))
+		for idx, hit := range hits {
+			msg := hit.Message
+			msg.SetContent("host", s.accountHost(c, hit.Account))
+			title := msg.Talker
+			if msg.TalkerName != "" {
+				title = fmt.Sprintf("%s (%s)", msg.TalkerName, msg.Talker)
+			}
+			fmt.Fprintf(c.Writer, "[%d] [%s] %s @ %s\n", idx+1, hit.Account, msg.Time.Format("2006-01-02 15:04:05"), title)
+			fmt.Fprintf(c.Writer, "%s\n", msg.PlainTextContent())
+			fmt.Fprintln(c.Writer, strings.Repeat("-", 60))
+		}
+		return
+	}
+
+	resp := gin.H{
+		"total":       max(total-dups, 0),
+		"duplicates":  dups,
+		"hits":        hits,
+		"limit":       req.Limit,
+		"offset":      req.Offset,
+		"query":       req.Query,
+		"talker":      req.Talker,
+		"sender":      req.Sender,
+		"start":       req.Start,
+		"end":         req.End,
+		"mode":        req.Mode,
+		"order":       order,
+		"accounts":    targetIDs(targets),
+		"duration_ms": time.Since(started).Milliseconds(),
+	}
+	if len(failed) > 0 {
+		resp["errors"] = failed
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GET /api/v1/federated/chatlog
+func (s *Service) handleFederatedChatlog(c *gin.Context) {
+	s.federatedChatlog(c, s.federatedTargets)
+}
+
+func (s *Service) federatedChatlog(c *gin.Context, resolve targetResolver) {
+	q := struct {
+		Time     string `form:"time"`
+		Talker   string `form:"talker"`
+		Sender   string `form:"sender"`
+		Keyword  string `form:"keyword"`
+		Limit    int    `form:"limit"`
+		Offset   int    `form:"offset"`
+		Format   string `form:"format"`
+		Accounts string `form:"accounts"`
+	}{}
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	start, end, ok := util.TimeRangeOf(q.Time)
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+	if strings.TrimSpace(q.Talker) == "" {
+		errors.Err(c, errors.InvalidArg("talker"))
+		return
+	}
+	q.Limit, q.Offset = max(q.Limit, 0), max(q.Offset, 0)
+	if err := checkFederatedWindow(q.Limit, q.Offset); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	targets, err := resolve(q.Accounts)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	// limit 为 0 时取时间范围内的全部消息
+	window := 0
+	if q.Limit > 0 {
+		window = q.Offset + q.Limit
+	}
+	results, failed := fanOut(targets, func(src federatedSource) ([]*model.Message, error) {
+		return src.GetMessages(start, end, q.Talker, q.Sender, q.Keyword, window, 0)
+	})
+
+	sources := make([]federated.MessageSource, 0, len(targets))
+	for i, msgs := range results {
+		sources = append(sources, federated.MessageSource{Account: targets[i].id, Messages: msgs})
+	}
+	merged, dups := federated.MergeMessages(sources)
+	messages := federated.Page(merged, q.Limit, q.Offset)
+
+	if strings.EqualFold(strings.TrimSpace(q.Format), "text") {
+		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for id, msg := range failed {
+			fmt.Fprintf(c.Writer, "账号 %s 查询失败: %s\n", id, msg)
+		}
+		timeFormat := util.PerfectTimeFormat(start, end)
+		for _, m := range messages {
+			c.Writer.WriteString("[" + m.Account + "] " + m.PlainText(strings.Contains(q.Talker, ","), timeFormat, s.accountHost(c, m.Account)) + "\n")
+		}
+		return
+	}
+
+	resp := gin.H{
+		"total":      len(merged),
+		"duplicates": dups,
+		"messages":   messages,
+		"limit":      q.Limit,
+		"offset":     q.Offset,
+		"accounts":   targetIDs(targets),
+	}
+	if len(failed) > 0 {
+		resp["errors"] = failed
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,176 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// fedSource 返回固定的结果，并记录每次查询的 limit
+type fedSource struct {
+	hits     []*model.SearchHit
+	messages []*model.Message
+
+	mu     sync.Mutex
+	limits []int
+}
+
+func (f *fedSource) record(limit int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.limits = append(f.limits, limit)
+}
+
+func (f *fedSource) SearchMessages(req *model.SearchRequest) (*model.SearchResponse, error) {
+	f.record(req.Limit)
+	hits := f.hits
+	if len(hits) > req.Limit {
+		hits = hits[:req.Limit]
+	}
+	return &model.SearchResponse{Total: len(f.hits), Hits: hits}, nil
+}
+
+func (f *fedSource) GetMessages(start, end time.Time, talker, sender, keyword string, limit, offset int) ([]*model.Message, error) {
+	f.record(limit)
+	msgs := f.messages
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+var fedBase = time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+
+func fedMessage(talker, content string, sec int) *model.Message {
+	return &model.Message{Talker: talker, Sender: "wxid_x", Content: content, Type: model.MessageTypeText, Time: fedBase.Add(time.Duration(sec) * time.Second)}
+}
+
+// fedRouter 把两个联合查询接口挂在固定的查询目标上：a、b 已就绪，c 的数据库未就绪
+func fedRouter(a, b *fedSource) *gin.Engine {
+	s := &Service{conf: &conf.ServerConfig{}}
+	resolve := func(ids string) ([]accountTarget, error) {
+		return []accountTarget{{id: "a", src: a, ready: true}, {id: "b", src: b, ready: true}, {id: "c", src: &fedSource{}}}, nil
+	}
+	r := gin.New()
+	r.GET("/search", func(c *gin.Context) { s.federatedSearch(c, resolve) })
+	r.GET("/chatlog", func(c *gin.Context) { s.federatedChatlog(c, resolve) })
+	return r
+}
+
+func fedGet(r *gin.Engine, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	return w
+}
+
+func TestFederatedSearchHandler(t *testing.T) {
+	shared := fedMessage("123@chatroom", "项目进度", 10)
+	a := &fedSource{hits: []*model.SearchHit{
+		{Message: shared, Score: -2},
+		{Message: fedMessage("wxid_y", "项目进度", 20), Score: -1},
+	}}
+	b := &fedSource{hits: []*model.SearchHit{
+		{Message: fedMessage("123@chatroom", "项目进度", 10), Score: -3},
+		{Message: fedMessage("wxid_z", "项目进度", 30), Score: -0.5},
+	}}
+	r := fedRouter(a, b)
+
+	w := fedGet(r, "/search?q=项目&limit=2&offset=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Total      int               `json:"total"`
+		Duplicates int               `json:"duplicates"`
+		Hits       []json.RawMessage `json:"hits"`
+		Errors     map[string]string `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	// 每个账号取前 offset+limit 条，合并去重后 3 条，跳过第一条
+	if resp.Total != 3 || resp.Duplicates != 1 || len(resp.Hits) != 2 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if a.limits[0] != 3 || b.limits[0] != 3 {
+		t.Fatalf("window = %v, %v", a.limits, b.limits)
+	}
+	if resp.Errors["c"] == "" {
+		t.Fatalf("unready account should be reported: %v", resp.Errors)
+	}
+
+	// 超出单账号取数上限时拒绝，而不是静默截断
+	if w := fedGet(r, "/search?q=项目&limit=200&offset=900"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "offset+limit") {
+		t.Fatalf("window exceeded: status = %d (%s)", w.Code, w.Body.String())
+	}
+	if w := fedGet(r, "/search?q=项目&order=bad"); w.Code != http.StatusBadRequest {
+		t.Fatalf("bad order: status = %d", w.Code)
+	}
+	if len(a.limits) != 1 {
+		t.Fatalf("rejected requests should not query accounts: %v", a.limits)
+	}
+
+	w = fedGet(r, "/search?q=项目&format=text")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "账号 c 查询失败") || !strings.Contains(w.Body.String(), "[1] [b]") {
+		t.Fatalf("text = %s", w.Body.String())
+	}
+}
+
+func TestFederatedChatlogHandler(t *testing.T) {
+	talker := "123@chatroom"
+	a := &fedSource{messages: []*model.Message{fedMessage(talker, "早", 10), fedMessage(talker, "开会", 30)}}
+	b := &fedSource{messages: []*model.Message{fedMessage(talker, "早", 10), fedMessage(talker, "收到", 20)}}
+	r := fedRouter(a, b)
+
+	if w := fedGet(r, "/chatlog?time=2024-05-01"); w.Code != http.StatusBadRequest {
+		t.Fatalf("missing talker: status = %d", w.Code)
+	}
+	if w := fedGet(r, "/chatlog?time=2024-05-01&talker="+talker+"&limit=500&offset=600"); w.Code != http.StatusBadRequest {
+		t.Fatalf("window exceeded: status = %d (%s)", w.Code, w.Body.String())
+	}
+
+	w := fedGet(r, "/chatlog?time=2024-05-01&talker="+talker+"&limit=2&offset=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Total      int `json:"total"`
+		Duplicates int `json:"duplicates"`
+		Messages   []struct {
+			Account string   `json:"account"`
+			AlsoIn  []string `json:"also_in"`
+			Content string   `json:"content"`
+		} `json:"messages"`
+		Errors map[string]string `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 3 || resp.Duplicates != 1 || len(resp.Messages) != 2 || resp.Errors["c"] == "" {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if resp.Messages[0].Content != "收到" || resp.Messages[1].Content != "开会" {
+		t.Fatalf("messages = %+v", resp.Messages)
+	}
+	if a.limits[0] != 3 {
+		t.Fatalf("window = %v", a.limits)
+	}
+
+	// limit 为 0 时不限制单账号条数
+	if w := fedGet(r, "/chatlog?time=2024-05-01&talker="+talker); w.Code != http.StatusOK || a.limits[1] != 0 {
+		t.Fatalf("status = %d, limits = %v", w.Code, a.limits)
+	}
+	w = fedGet(r, "/chatlog?time=2024-05-01&talker="+talker+"&format=text")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "[a] ") || !strings.Contains(w.Body.String(), "账号 c 查询失败") {
+		t.Fatalf("text = %s", w.Body.String())
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/digest"
 	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
 	"github.com/takeaway1/chatlog-TCOTC/internal/model"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
@@ -20,17 +21,54 @@ import (
 )
 
 func (s *Service) initMCPServer() {
-	s.mcpServer = server.NewMCPServer(conf.AppName, version.Version)
-	s.mcpServer.AddTool(ContactTool, s.handleMCPContact)
-	s.mcpServer.AddTool(ChatRoomTool, s.handleMCPChatRoom)
-	s.mcpServer.AddTool(RecentChatTool, s.handleMCPRecentChat)
-	s.mcpServer.AddTool(ChatLogTool, s.handleMCPChatLog)
-	s.mcpServer.AddTool(CurrentTimeTool, s.handleMCPCurrentTime)
-	s.mcpServer.AddTool(DiaryTool, s.handleMCPDiary)
-	s.mcpSSEServer = server.NewSSEServer(s.mcpServer)
+	s.mcpServer = server.NewMCPServer(conf.AppName, version.Version,
+		server.WithResourceCapabilities(false, false),
+		server.WithPromptCapabilities(false),
+	)
+	s.addMCPTools()
+	s.initMCPResources()
+	// 保留 /sse?token=... 的查询参数，使客户端回调的 /message 端点同样通过鉴权
+	s.mcpSSEServer = server.NewSSEServer(s.mcpServer, server.WithAppendQueryToMessageEndpoint())
 	s.mcpStreamableServer = server.NewStreamableHTTPServer(s.mcpServer)
 }
 
+type mcpToolHandler func(s *Service, ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+// mcpTools 为所有账号共用的 MCP 工具，挂载多个账号时由 account 参数选择目标账号
+var mcpTools = []struct {
+	tool    mcp.Tool
+	handler mcpToolHandler
+}{
+	{ContactTool, (*Service).handleMCPContact},
+	{ChatRoomTool, (*Service).handleMCPChatRoom},
+	{RecentChatTool, (*Service).handleMCPRecentChat},
+	{ChatLogTool, (*Service).handleMCPChatLog},
+	{CurrentTimeTool, (*Service).handleMCPCurrentTime},
+	{DiaryTool, (*Service).handleMCPDiary},
+	{SearchTool, (*Service).handleMCPSearch},
+	{SemanticSearchTool, (*Service).handleMCPSemanticSearch},
+	{RelationshipsTool, (*Service).handleMCPRelationships},
+	{ChatRoomMembersTool, (*Service).handleMCPChatRoomMembers},
+	{SummarizeTool, (*Service).handleMCPSummarize},
+}
+
+// addMCPTools 注册（或在挂载账号后重新注册）MCP 工具
+func (s *Service) addMCPTools() {
+	for _, t := range mcpTools {
+		tool := t.tool
+		if len(s.accounts) > 0 {
+			tool = s.withAccountArg(tool)
+		}
+		s.mcpServer.AddTool(tool, s.accountToolHandler(t.handler))
+	}
+	s.mcpServer.AddTool(ListAccountsTool, s.handleMCPListAccounts)
+}
+
+// ServeStdio 通过标准输入输出提供 MCP 服务，供桌面客户端直接启动 chatlog
+func (s *Service) ServeStdio() error {
+	return server.ServeStdio(s.mcpServer)
+}
+
 var ContactTool = mcp.NewTool(
 	"query_contact",
 	mcp.WithDescription(`查询用户的联系人信息。可以通过姓名、备注名或ID进行查询，返回匹配的联系人列表。当用户询问某人的联系方式、想了解联系人信息或需要查找特定联系人时使用此工具。参数为空时，将返回联系人列表`),
@@ -144,6 +182,7 @@ var ChatLogTool = mcp.NewTool(
 2. 后续步骤：必须移除keyword参数，分别查询每个时间点前后的完整对话
 3. 错误示例：对所有找到的关键词消息一次性查询大范围上下文
 4. 正确示例：对每个时间点T分别执行查询"T前后15-30分钟"（不带keyword）`)),
+	mcp.WithBoolean("include_transcripts", mcp.Description(`是否附带语音消息的转写文本，转写内容紧跟在语音链接之后。需要在配置中启用语音转写；未缓存的语音会即时转写，单次最多 20 条`)),
 )
 
 var CurrentTimeTool = mcp.NewTool(
@@ -182,6 +221,71 @@ var DiaryTool = mcp.NewTool(
 	mcp.WithString("talker", mcp.Description("可选，会话筛选（多个用','分隔）")),
 )
 
+var SearchTool = mcp.NewTool(
+	"search_chat",
+	mcp.WithDescription(`在全文索引中按关键词检索聊天记录，返回按相关度排序的命中消息。比 query_chat_log 的 keyword 参数更快，并且可以跨会话检索。
+
+返回格式：每条结果包含排名、分值、会话、发送者、时间、seq 以及高亮片段（命中词以【】标出）。结果未取完时末尾会给出下一页的 offset。需要上下文时，使用 query_chat_log 按返回的 talker 与时间再次查询。
+
+注意：首次使用时索引可能仍在构建，此时返回构建进度，请稍后重试。`),
+	mcp.WithString("query", mcp.Description("检索关键词，多个词用空格分隔"), mcp.Required()),
+	mcp.WithString("talker", mcp.Description("可选，限定会话（ID/备注/昵称，多个用','分隔）")),
+	mcp.WithString("sender", mcp.Description("可选，限定发送者（多个用','分隔）")),
+	mcp.WithString("time", mcp.Description(`可选，时间范围，格式同 query_chat_log，例如 "2023-04-01~2023-04-30"`)),
+	mcp.WithNumber("limit", mcp.Description("返回条数，默认 20，最大 100")),
+	mcp.WithNumber("offset", mcp.Description("分页偏移，默认 0")),
+)
+
+var SemanticSearchTool = mcp.NewTool(
+	"semantic_search_chat",
+	mcp.WithDescription(`按语义检索聊天记录，返回与问题意思最接近的若干段对话（每段为同一会话中时间相邻的一组消息）。适用于关键词难以命中的问题，例如"我们争论发布日期的那次对话"、"谁推荐过那家餐厅"。
+
+mode 说明：
+- hybrid（默认）：融合语义相似度与关键词（BM25）排名，兼顾意思相近与字面命中
+- semantic：仅按语义相似度排序
+
+返回格式：每条结果包含排名、分值、会话、时间区间与 seq 区间以及对话片段。需要完整上下文时，使用 query_chat_log 按返回的 talker 与时间区间再次查询。
+
+注意：需要在配置中启用 search.embedding，否则返回错误。`),
+	mcp.WithString("query", mcp.Description("自然语言描述的检索问题"), mcp.Required()),
+	mcp.WithString("mode", mcp.Description("检索方式：hybrid（默认）或 semantic")),
+	mcp.WithString("talker", mcp.Description("可选，限定会话（ID/备注/昵称，多个用','分隔）")),
+	mcp.WithString("sender", mcp.Description("可选，限定发送者（多个用','分隔）")),
+	mcp.WithString("time", mcp.Description(`可选，时间范围，格式同 query_chat_log，例如 "2023-04-01~2023-04-30"`)),
+	mcp.WithNumber("limit", mcp.Description("返回条数，默认 10，最大 50")),
+	mcp.WithNumber("offset", mcp.Description("分页偏移，默认 0")),
+)
+
+var RelationshipsTool = mcp.NewTool(
+	"query_relationships",
+	mcp.WithDescription(`分析与联系人（不含群聊）的关系：按亲密度评分排序，并给出与上季度相比的消息数变化、双方回复间隔中位数以及由谁发起对话的比例。适用于"我最近和谁聊得最多"、"和谁联系变少了"、"谁回我消息最快"等问题。
+
+评分（0~100）= 35% 近90天消息数 + 25% 总消息数（两项均取对数后与最高者相比）+ 15% 活跃天数占比 + 15% 双方发言均衡度 + 10% 近7天我发送的消息数。
+季度以数据中最新一条联系人消息为参考时间，每 90 天为一个季度；超过 6 小时没有消息视为新对话。
+
+返回格式：每位联系人一段，包含排名、评分、消息统计、季度趋势、回复间隔与发起比例，末尾列出比上季度聊得多与聊得少的联系人。`),
+	mcp.WithNumber("limit", mcp.Description("返回的联系人数，默认 20，最大 100")),
+)
+
+var ChatRoomMembersTool = mcp.NewTool(
+	"query_group_members",
+	mcp.WithDescription(`分析群成员的发言情况：列出每位成员在时间范围内的消息数、媒体数（图片、视频、语音、表情、文件）与首末发言时间，列出没有发言的成员（潜水成员），并从系统消息中识别入群与退群记录。适用于"群里谁最活跃"、"哪些人从来不说话"、"最近谁进群/退群了"等问题。
+
+返回格式：首行为群名与统计范围，随后每位发言成员一行，接着列出已退群但有发言的成员、未发言成员和成员变动记录。`),
+	mcp.WithString("talker", mcp.Description("群聊 ID、备注或名称"), mcp.Required()),
+	mcp.WithString("time", mcp.Description(`可选，时间范围，格式同 query_chat_log，例如 "2023-04-01~2023-04-30"，默认最近 30 天`)),
+)
+
+var SummarizeTool = mcp.NewTool(
+	"summarize_chat",
+	mcp.WithDescription(`在服务端总结某个联系人或群聊在一段时间内的聊天记录。聊天记录较长（例如活跃群聊的一整周）时，优先使用此工具，而不是用 query_chat_log 取回全部原文后自行总结。
+
+服务端会按 token 预算把消息分段，逐段总结后再合并。总结中方括号内的数字是原始消息的 seq，末尾附有被引用消息的原文。需要查看某条引用的上下文时，可以用 query_chat_log 查询该消息附近的时间。相同会话与时间范围的结果会被缓存，消息没有变化时直接返回。`),
+	mcp.WithString("talker", mcp.Description("联系人或群聊的 ID、备注或昵称"), mcp.Required()),
+	mcp.WithString("time", mcp.Description(`时间范围，格式同 query_chat_log，例如 "2023-04-01~2023-04-30"、"last-7d"，默认 today`)),
+	mcp.WithBoolean("refresh", mcp.Description("为 true 时忽略缓存重新总结")),
+)
+
 type ContactRequest struct {
 	Keyword string `json:"keyword"`
 	Limit   int    `json:"limit"`
@@ -287,13 +391,14 @@ func (s *Service) handleMCPRecentChat(ctx context.Context, request mcp.CallToolR
 }
 
 type ChatLogRequest struct {
-	Time    string `form:"time"`
-	Talker  string `form:"talker"`
-	Sender  string `form:"sender"`
-	Keyword string `form:"keyword"`
-	Limit   int    `form:"limit"`
-	Offset  int    `form:"offset"`
-	Format  string `form:"format"`
+	Time               string `form:"time"`
+	Talker             string `form:"talker"`
+	Sender             string `form:"sender"`
+	Keyword            string `form:"keyword"`
+	Limit              int    `form:"limit"`
+	Offset             int    `form:"offset"`
+	Format             string `form:"format"`
+	IncludeTranscripts bool   `form:"include_transcripts" json:"include_transcripts"`
 }
 
 func (s *Service) handleMCPChatLog(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -324,6 +429,10 @@ func (s *Service) handleMCPChatLog(ctx context.Context, request mcp.CallToolRequ
 		log.Error().Err(err).Msg("Failed to get messages")
 		return errors.ErrMCPTool(err), nil
 	}
+	if req.IncludeTranscripts {
+		budget := inlineTranscribeLimit
+		s.attachTranscripts(ctx, messages, &budget)
+	}
 
 	buf := &bytes.Buffer{}
 	if len(messages) == 0 {
@@ -375,63 +484,295 @@ func (s *Service) handleMCPDiary(ctx context.Context, request mcp.CallToolReques
 	end := time.Now()
 	start := end.Add(-time.Duration(hours) * time.Hour)
 
-	sessionsResp, err := s.db.GetSessions(req.Talker, 0, 0)
+	groups, err := digest.Collect(s.db, start, end, req.Talker)
 	if err != nil {
 		return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent{Type: "text", Text: "获取会话失败: " + err.Error()}}}, nil
 	}
 
-	type grouped struct {
-		Talker     string
-		TalkerName string
-		Messages   []*model.Message
+	buf := &bytes.Buffer{}
+	if len(groups) == 0 {
+		buf.WriteString(fmt.Sprintf("最近%dh没有我参与的会话", hours))
+	} else {
+		digest.Write(buf, groups, 0)
 	}
-	groups := make([]*grouped, 0)
+	return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent{Type: "text", Text: buf.String()}}}, nil
+}
 
-	for _, sess := range sessionsResp.Items {
-		msgs, err := s.db.GetMessages(start, end, sess.UserName, "", "", 0, 0)
-		if err != nil || len(msgs) == 0 {
-			continue
-		}
-		hasSelf := false
-		for _, m := range msgs {
-			if m.IsSelf {
-				hasSelf = true
-				break
-			}
-		}
-		if !hasSelf {
-			continue
+type RelationshipsRequest struct {
+	Limit int `json:"limit"`
+}
+
+func (s *Service) handleMCPRelationships(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var req RelationshipsRequest
+	_ = request.BindArguments(&req) // 可选参数，忽略绑定错误
+
+	report, err := s.relationships(ctx, req.Limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to analyze relationships")
+		return errors.ErrMCPTool(err), nil
+	}
+
+	buf := &bytes.Buffer{}
+	writeRelationships(buf, report)
+	return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent{Type: "text", Text: buf.String()}}}, nil
+}
+
+type ChatRoomMembersRequest struct {
+	Talker string `json:"talker"`
+	Time   string `json:"time"`
+}
+
+func (s *Service) handleMCPChatRoomMembers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var req ChatRoomMembersRequest
+	if err := request.BindArguments(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to bind arguments")
+		return errors.ErrMCPTool(err), nil
+	}
+
+	report, err := s.chatRoomMembers(req.Talker, req.Time)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to analyze chatroom members")
+		return errors.ErrMCPTool(err), nil
+	}
+
+	buf := &bytes.Buffer{}
+	writeChatRoomMembers(buf, report)
+	return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent{Type: "text", Text: buf.String()}}}, nil
+}
+
+type SummarizeRequest struct {
+	Talker  string `json:"talker"`
+	Time    string `json:"time"`
+	Refresh bool   `json:"refresh"`
+}
+
+func (s *Service) handleMCPSummarize(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var req SummarizeRequest
+	if err := request.BindArguments(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to bind arguments")
+		return errors.ErrMCPTool(err), nil
+	}
+
+	res, err := s.summarize(ctx, req.Talker, req.Time, req.Refresh)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to summarize chat")
+		return errors.ErrMCPTool(err), nil
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent{Type: "text", Text: summaryText(res)}}}, nil
+}
+
+type SemanticSearchRequest struct {
+	Query  string `json:"query"`
+	Mode   string `json:"mode"`
+	Talker string `json:"talker"`
+	Sender string `json:"sender"`
+	Time   string `json:"time"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+func (s *Service) handleMCPSemanticSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var req SemanticSearchRequest
+	if err := request.BindArguments(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to bind arguments")
+		return errors.ErrMCPTool(err), nil
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(req.Mode))
+	if mode == "" {
+		mode = model.SearchModeHybrid
+	}
+	if mode != model.SearchModeHybrid && mode != model.SearchModeSemantic {
+		return errors.ErrMCPTool(errors.InvalidArg("mode")), nil
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 50 {
+		limit = 50
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	searchReq := &model.SearchRequest{
+		Query:  strings.TrimSpace(req.Query),
+		Talker: strings.TrimSpace(req.Talker),
+		Sender: strings.TrimSpace(req.Sender),
+		Limit:  limit,
+		Offset: offset,
+		Mode:   mode,
+	}
+	if searchReq.Query == "" {
+		return errors.ErrMCPTool(errors.InvalidArg("query")), nil
+	}
+	if strings.TrimSpace(req.Time) != "" {
+		start, end, ok := util.TimeRangeOf(req.Time)
+		if !ok {
+			return errors.ErrMCPTool(errors.InvalidArg("time")), nil
 		}
-		groups = append(groups, &grouped{Talker: sess.UserName, TalkerName: sess.NickName, Messages: msgs})
+		searchReq.Start = start
+		searchReq.End = end
+	}
+
+	resp, err := s.db.SearchMessages(searchReq)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to search messages")
+		return errors.ErrMCPTool(err), nil
 	}
 
 	buf := &bytes.Buffer{}
-	if len(groups) == 0 {
-		buf.WriteString(fmt.Sprintf("最近%dh没有我参与的会话", hours))
+	if resp == nil || len(resp.Hits) == 0 {
+		buf.WriteString("未找到相关的对话")
+		if resp != nil && resp.Index != nil && resp.Index.Vector != nil && resp.Index.Vector.Pending > 0 {
+			buf.WriteString(fmt.Sprintf("（向量索引仍有 %d 段待嵌入，结果可能不完整）", resp.Index.Vector.Pending))
+		}
 	} else {
-		for _, g := range groups {
-			header := g.Talker
-			if g.TalkerName != "" {
-				header = fmt.Sprintf("%s(%s)", g.TalkerName, g.Talker)
+		for i, hit := range resp.Hits {
+			if hit == nil || hit.Message == nil {
+				continue
 			}
-			buf.WriteString(fmt.Sprintf("[%s] - %d条\n", header, len(g.Messages)))
-			for _, m := range g.Messages {
-				sender := m.Sender
-				if m.IsSelf {
-					sender = "我"
-				}
-				if m.SenderName != "" {
-					sender = fmt.Sprintf("%s(%s)", m.SenderName, sender)
-				}
-				buf.WriteString(m.Time.Format("2006-01-02 15:04:05"))
-				buf.WriteString(" ")
-				buf.WriteString(sender)
-				buf.WriteString(" ")
-				buf.WriteString(m.PlainTextContent())
-				buf.WriteString("\n")
+			msg := hit.Message
+			talker := msg.Talker
+			if msg.TalkerName != "" {
+				talker = fmt.Sprintf("%s(%s)", msg.TalkerName, msg.Talker)
 			}
-			buf.WriteString("-----------------------------\n")
+			timeLabel := msg.Time.Format("2006-01-02 15:04:05")
+			seqLabel := strconv.FormatInt(msg.Seq, 10)
+			if hit.Range != nil {
+				timeLabel = hit.Range.Start.Format("2006-01-02 15:04:05") + " ~ " + hit.Range.End.Format("2006-01-02 15:04:05")
+				seqLabel = fmt.Sprintf("%d~%d", hit.Range.StartSeq, hit.Range.EndSeq)
+			}
+			buf.WriteString(fmt.Sprintf("#%d score=%.4f [%s] %s seq=%s\n", offset+i+1, hit.Score, talker, timeLabel, seqLabel))
+			buf.WriteString(stripMark(hit.Snippet))
+			buf.WriteString("\n-----------------------------\n")
+		}
+		if resp.Total > offset+len(resp.Hits) {
+			buf.WriteString(fmt.Sprintf("共 %d 条候选，使用 offset=%d 获取更多\n", resp.Total, offset+len(resp.Hits)))
+		}
+	}
+
+	return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent{Type: "text", Text: buf.String()}}}, nil
+}
+
+type SearchRequest struct {
+	Query  string `json:"query"`
+	Talker string `json:"talker"`
+	Sender string `json:"sender"`
+	Time   string `json:"time"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+func (s *Service) handleMCPSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var req SearchRequest
+	if err := request.BindArguments(&req); err != nil {
+		log.Error().Err(err).Msg("Failed to bind arguments")
+		return errors.ErrMCPTool(err), nil
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	searchReq := &model.SearchRequest{
+		Query:  strings.TrimSpace(req.Query),
+		Talker: strings.TrimSpace(req.Talker),
+		Sender: strings.TrimSpace(req.Sender),
+		Limit:  limit,
+		Offset: offset,
+		Mode:   model.SearchModeKeyword,
+	}
+	if searchReq.Query == "" {
+		return errors.ErrMCPTool(errors.InvalidArg("query")), nil
+	}
+	if strings.TrimSpace(req.Time) != "" {
+		start, end, ok := util.TimeRangeOf(req.Time)
+		if !ok {
+			return errors.ErrMCPTool(errors.InvalidArg("time")), nil
+		}
+		searchReq.Start = start
+		searchReq.End = end
+	}
+
+	resp, err := s.db.SearchMessages(searchReq)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to search messages")
+		return errors.ErrMCPTool(err), nil
+	}
+
+	buf := &bytes.Buffer{}
+	if resp == nil || len(resp.Hits) == 0 {
+		if resp != nil && resp.Index != nil && !resp.Index.Ready {
+			buf.WriteString(indexStatusText(resp.Index))
+		} else {
+			buf.WriteString("未找到符合查询条件的聊天记录")
 		}
+		return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent{Type: "text", Text: buf.String()}}}, nil
 	}
+
+	for i, hit := range resp.Hits {
+		if hit == nil || hit.Message == nil {
+			continue
+		}
+		msg := hit.Message
+		talker := msg.Talker
+		if msg.TalkerName != "" {
+			talker = fmt.Sprintf("%s(%s)", msg.TalkerName, msg.Talker)
+		}
+		sender := msg.Sender
+		if msg.IsSelf {
+			sender = "我"
+		}
+		if msg.SenderName != "" {
+			sender = fmt.Sprintf("%s(%s)", msg.SenderName, sender)
+		}
+		buf.WriteString(fmt.Sprintf("#%d score=%.4f [%s] %s %s seq=%d\n",
+			offset+i+1, hit.Score, talker, sender, msg.Time.Format("2006-01-02 15:04:05"), msg.Seq))
+		buf.WriteString(markToBrackets(hit.Snippet))
+		buf.WriteString("\n-----------------------------\n")
+	}
+	if next := offset + len(resp.Hits); resp.Total > next {
+		buf.WriteString(fmt.Sprintf("共 %d 条结果，使用 offset=%d 获取更多\n", resp.Total, next))
+	} else {
+		buf.WriteString(fmt.Sprintf("共 %d 条结果\n", resp.Total))
+	}
+	if resp.Index != nil && resp.Index.InProgress {
+		buf.WriteString(indexStatusText(resp.Index))
+		buf.WriteString("\n")
+	}
+
 	return &mcp.CallToolResult{Content: []mcp.Content{mcp.TextContent{Type: "text", Text: buf.String()}}}, nil
 }
+
+// indexStatusText 描述全文索引尚未就绪或正在更新时的状态
+func indexStatusText(status *model.SearchIndexStatus) string {
+	switch {
+	case status.LastError != "":
+		return "全文索引构建失败: " + status.LastError
+	case status.InProgress:
+		return fmt.Sprintf("全文索引正在构建（%.0f%%），结果可能不完整，请稍后重试", status.Progress*100)
+	case !status.Ready:
+		return "全文索引尚未就绪，请稍后重试"
+	}
+	return ""
+}
+
+func markToBrackets(s string) string {
+	return strings.NewReplacer("<mark>", "【", "</mark>", "】").Replace(s)
+}
+
+func stripMark(s string) string {
+	return strings.NewReplacer("<mark>", "", "</mark>", "").Replace(s)
+}
@@ -0,0 +1,250 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/digest"
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
+)
+
+// 单个 prompt 内嵌的聊天记录总条数上限，避免超出客户端上下文
+const promptMaxMessages = 3000
+
+var SessionsResource = mcp.NewResource(
+	"chatlog://sessions",
+	"最近会话",
+	mcp.WithResourceDescription("最近会话列表，每行包含会话名称、ID、最后一条消息与时间"),
+	mcp.WithMIMEType("text/plain"),
+)
+
+var ChatLogResource = mcp.NewResourceTemplate(
+	"chatlog://talker/{id}/{date}",
+	"会话聊天记录",
+	mcp.WithTemplateDescription(`指定会话在某一天或某个时间范围内的聊天记录。id 为联系人/群聊 ID、备注或昵称（群 ID 中的 @ 需编码为 %40）；date 格式同 query_chat_log 的 time 参数，如 2024-05-01、today、yesterday、2024-05-01~2024-05-07`),
+	mcp.WithTemplateMIMEType("text/plain"),
+)
+
+var ContactResource = mcp.NewResourceTemplate(
+	"contact://{id}",
+	"联系人",
+	mcp.WithTemplateDescription("联系人或群聊的资料：ID、微信号、备注、昵称；群聊另含群主与成员列表"),
+	mcp.WithTemplateMIMEType("text/plain"),
+)
+
+var SummarizeGroupPrompt = mcp.NewPrompt(
+	"summarize_group_today",
+	mcp.WithPromptDescription("总结某个群聊今天的讨论：主要话题、结论、待办事项与相关成员"),
+	mcp.WithArgument("talker", mcp.ArgumentDescription("群聊 ID、备注或名称"), mcp.RequiredArgument()),
+)
+
+var WeeklyReportPrompt = mcp.NewPrompt(
+	"weekly_report",
+	mcp.WithPromptDescription("根据最近 7 天我参与的会话起草一份周报"),
+	mcp.WithArgument("talker", mcp.ArgumentDescription("可选，只统计指定会话（多个用','分隔）")),
+	mcp.WithArgument("focus", mcp.ArgumentDescription("可选，周报侧重点，如某个项目名称")),
+)
+
+func (s *Service) initMCPResources() {
+	s.mcpServer.AddResource(SessionsResource, s.handleMCPSessionsResource)
+	s.mcpServer.AddResourceTemplate(ChatLogResource, s.handleMCPChatLogResource)
+	s.mcpServer.AddResourceTemplate(ContactResource, s.handleMCPContactResource)
+	s.mcpServer.AddPrompt(SummarizeGroupPrompt, s.handleMCPSummarizeGroupPrompt)
+	s.mcpServer.AddPrompt(WeeklyReportPrompt, s.handleMCPWeeklyReportPrompt)
+}
+
+func (s *Service) handleMCPSessionsResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	data, err := s.db.GetSessions("", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	for _, session := range data.Items {
+		buf.WriteString(session.PlainText(120))
+		buf.WriteString("\n")
+	}
+	return []mcp.ResourceContents{textResource(request.Params.URI, buf.String())}, nil
+}
+
+func (s *Service) handleMCPChatLogResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	talker := resourceArg(request, "id")
+	date := resourceArg(request, "date")
+	if talker == "" {
+		return nil, errors.InvalidArg("id")
+	}
+	start, end, ok := util.TimeRangeOf(date)
+	if !ok {
+		return nil, errors.InvalidArg("date")
+	}
+
+	messages, err := s.db.GetMessages(start, end, talker, "", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if len(messages) == 0 {
+		buf.WriteString("未找到符合查询条件的聊天记录")
+	}
+	for _, m := range messages {
+		buf.WriteString(m.PlainText(false, util.PerfectTimeFormat(start, end), ""))
+		buf.WriteString("\n")
+	}
+	return []mcp.ResourceContents{textResource(request.Params.URI, buf.String())}, nil
+}
+
+func (s *Service) handleMCPContactResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id := resourceArg(request, "id")
+	if id == "" {
+		return nil, errors.InvalidArg("id")
+	}
+
+	buf := &bytes.Buffer{}
+	if strings.HasSuffix(id, "@chatroom") {
+		rooms, err := s.db.GetChatRooms(id, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rooms.Items) == 0 {
+			return nil, errors.TalkerNotFound(id)
+		}
+		room := rooms.Items[0]
+		buf.WriteString(fmt.Sprintf("Name: %s\nRemark: %s\nNickName: %s\nOwner: %s\nUserCount: %d\nUsers:\n", room.Name, room.Remark, room.NickName, room.Owner, len(room.Users)))
+		for _, u := range room.Users {
+			buf.WriteString(fmt.Sprintf("- %s %s\n", u.UserName, u.DisplayName))
+		}
+		return []mcp.ResourceContents{textResource(request.Params.URI, buf.String())}, nil
+	}
+
+	contacts, err := s.db.GetContacts(id, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(contacts.Items) == 0 {
+		return nil, errors.TalkerNotFound(id)
+	}
+	c := contacts.Items[0]
+	buf.WriteString(fmt.Sprintf("UserName: %s\nAlias: %s\nRemark: %s\nNickName: %s\nIsFriend: %v\n", c.UserName, c.Alias, c.Remark, c.NickName, c.IsFriend))
+	return []mcp.ResourceContents{textResource(request.Params.URI, buf.String())}, nil
+}
+
+func (s *Service) handleMCPSummarizeGroupPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	talker := strings.TrimSpace(request.Params.Arguments["talker"])
+	if talker == "" {
+		return nil, errors.InvalidArg("talker")
+	}
+	start, end, _ := util.TimeRangeOf("today")
+	return summarizeGroupPrompt(s.db, talker, start, end)
+}
+
+// summarizeGroupPrompt 内嵌群聊在 [start, end] 内最近 promptMaxMessages 条消息
+func summarizeGroupPrompt(src digest.Source, talker string, start, end time.Time) (*mcp.GetPromptResult, error) {
+	messages, err := src.GetMessages(start, end, talker, "", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	name := talker
+	if len(messages) > 0 && messages[0].TalkerName != "" {
+		name = messages[0].TalkerName
+	}
+	buf := &bytes.Buffer{}
+	if omitted := len(messages) - promptMaxMessages; omitted > 0 {
+		messages = messages[omitted:]
+		buf.WriteString(fmt.Sprintf("（省略了更早的 %d 条消息）\n", omitted))
+	}
+	for _, m := range messages {
+		buf.WriteString(m.PlainText(false, "15:04:05", ""))
+		buf.WriteString("\n")
+	}
+	if len(messages) == 0 {
+		buf.WriteString("今天没有聊天记录")
+	}
+
+	instruction := fmt.Sprintf(`请总结群聊「%s」今天（%s）的讨论，按以下结构输出：
+1. 主要话题（每个话题一两句话，标注主要参与者）
+2. 已达成的结论或决定
+3. 待办事项与负责人（没有则写“无”）
+4. 值得我关注、需要我回复的消息
+
+聊天记录见下方附件。`, name, start.Format("2006-01-02"))
+
+	return &mcp.GetPromptResult{
+		Description: "总结群聊今天的讨论",
+		Messages: []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(instruction)),
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(textResource(
+				"chatlog://talker/"+url.PathEscape(talker)+"/today", buf.String()))),
+		},
+	}, nil
+}
+
+func (s *Service) handleMCPWeeklyReportPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	end := time.Now()
+	start := end.AddDate(0, 0, -7)
+	return weeklyReportPrompt(s.db, start, end, request.Params.Arguments["talker"], request.Params.Arguments["focus"])
+}
+
+// weeklyReportPrompt 内嵌 [start, end] 内我参与的会话，所有会话合计不超过 promptMaxMessages 条
+func weeklyReportPrompt(src digest.Source, start, end time.Time, talker, focus string) (*mcp.GetPromptResult, error) {
+	groups, err := digest.Collect(src, start, end, talker)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	kept, limit := digest.Fit(groups, promptMaxMessages)
+	if omitted := len(groups) - len(kept); omitted > 0 {
+		buf.WriteString(fmt.Sprintf("（省略了 %d 个会话）\n", omitted))
+	}
+	digest.Write(buf, kept, limit)
+	if len(groups) == 0 {
+		buf.WriteString("最近 7 天没有我参与的会话")
+	}
+
+	instruction := fmt.Sprintf(`请根据我最近 7 天（%s ~ %s）参与的聊天记录，起草一份工作周报：
+1. 本周完成的工作
+2. 进行中的事项与进展
+3. 遇到的问题与需要协调的资源
+4. 下周计划
+
+只依据聊天记录中的事实，不确定的内容请标注“待确认”。`, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if focus = strings.TrimSpace(focus); focus != "" {
+		instruction += "\n重点关注：" + focus
+	}
+
+	return &mcp.GetPromptResult{
+		Description: "起草周报",
+		Messages: []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(instruction)),
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(textResource("chatlog://diary/7d", buf.String()))),
+		},
+	}, nil
+}
+
+func textResource(uri, text string) mcp.TextResourceContents {
+	return mcp.TextResourceContents{URI: uri, MIMEType: "text/plain", Text: text}
+}
+
+// resourceArg 读取 URI 模板变量，mcp-go 以 []string 形式传入，并对 %40 等编码解码
+func resourceArg(request mcp.ReadResourceRequest, name string) string {
+	var v string
+	switch x := request.Params.Arguments[name].(type) {
+	case string:
+		v = x
+	case []string:
+		if len(x) > 0 {
+			v = x[0]
+		}
+	}
+	if unescaped, err := url.PathUnescape(v); err == nil {
+		v = unescaped
+	}
+	return strings.TrimSpace(v)
+}
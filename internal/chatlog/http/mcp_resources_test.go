@@ -0,0 +1,115 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+)
+
+type promptSource struct {
+	sessions []*model.Session
+	messages map[string][]*model.Message
+}
+
+func (p *promptSource) GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
+	return &wechatdb.GetSessionsResp{Items: p.sessions}, nil
+}
+
+func (p *promptSource) GetMessages(start, end time.Time, talker, sender, keyword string, limit, offset int) ([]*model.Message, error) {
+	msgs := p.messages[talker]
+	if offset > len(msgs) {
+		offset = len(msgs)
+	}
+	msgs = msgs[offset:]
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+var promptBase = time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+
+func promptMessages(talker string, n int, self bool) []*model.Message {
+	msgs := make([]*model.Message, n)
+	for i := range msgs {
+		msgs[i] = &model.Message{
+			Talker:     talker,
+			TalkerName: "群" + talker,
+			Sender:     "bob",
+			IsSelf:     self && i == 0,
+			Time:       promptBase.Add(time.Duration(i) * time.Second),
+			Type:       model.MessageTypeText,
+			Content:    fmt.Sprintf("第%d条", i+1),
+		}
+	}
+	return msgs
+}
+
+func promptAttachment(t *testing.T, result *mcp.GetPromptResult) string {
+	t.Helper()
+	res, ok := result.Messages[1].Content.(mcp.EmbeddedResource)
+	if !ok {
+		t.Fatalf("attachment = %T", result.Messages[1].Content)
+	}
+	return res.Resource.(mcp.TextResourceContents).Text
+}
+
+func TestSummarizeGroupPromptKeepsNewest(t *testing.T) {
+	src := &promptSource{messages: map[string][]*model.Message{"g@chatroom": promptMessages("g@chatroom", promptMaxMessages+5, false)}}
+
+	result, err := summarizeGroupPrompt(src, "g@chatroom", promptBase, promptBase.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := promptAttachment(t, result)
+	if !strings.HasPrefix(text, "（省略了更早的 5 条消息）") {
+		t.Fatalf("attachment should note omitted messages: %.60s", text)
+	}
+	if strings.Contains(text, "\n第5条\n") || !strings.Contains(text, "\n第6条\n") || !strings.Contains(text, fmt.Sprintf("\n第%d条\n", promptMaxMessages+5)) {
+		t.Fatal("attachment should keep the newest messages")
+	}
+	if lines := strings.Count(text, "\n"); lines != 3*promptMaxMessages+1 {
+		t.Fatalf("attachment lines = %d", lines)
+	}
+	if instruction := result.Messages[0].Content.(mcp.TextContent).Text; !strings.Contains(instruction, "群聊「群g@chatroom」") {
+		t.Fatalf("instruction = %s", instruction)
+	}
+}
+
+func TestWeeklyReportPromptBudget(t *testing.T) {
+	src := &promptSource{
+		sessions: []*model.Session{{UserName: "a"}, {UserName: "b"}, {UserName: "c"}},
+		messages: map[string][]*model.Message{
+			"a": promptMessages("a", 10, true),
+			"b": promptMessages("b", promptMaxMessages, true),
+			"c": promptMessages("c", promptMaxMessages, true),
+		},
+	}
+
+	result, err := weeklyReportPrompt(src, promptBase, promptBase.AddDate(0, 0, 7), "", "发布")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := promptAttachment(t, result)
+	var lines int
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "2024-") {
+			lines++
+		}
+	}
+	if lines != promptMaxMessages {
+		t.Fatalf("weekly prompt embeds %d messages, budget %d", lines, promptMaxMessages)
+	}
+	if !strings.Contains(text, "[a] - 10条") || !strings.Contains(text, fmt.Sprintf("[c] - %d条", promptMaxMessages)) {
+		t.Fatal("every group should be kept within the budget")
+	}
+	if instruction := result.Messages[0].Content.(mcp.TextContent).Text; !strings.HasSuffix(instruction, "重点关注：发布") {
+		t.Fatalf("instruction = %s", instruction)
+	}
+}
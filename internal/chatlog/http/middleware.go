@@ -1,18 +1,79 @@
 package http
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/database"
 )
 
-func corsMiddleware() gin.HandlerFunc {
+const authCookieName = "chatlog_token"
+
+// accessLogger writes gin's access log to w, skipping /health. The token
+// query parameter is redacted: MCP clients carry it on every /message call.
+func accessLogger(w io.Writer) gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Formatter: accessLogFormatter,
+		Output:    w,
+		SkipPaths: []string{"/health"},
+	})
+}
+
+// accessLogFormatter is gin's default format without colors
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redactToken(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactToken replaces the value of every token query parameter in path
+func redactToken(path string) string {
+	base, query, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil && name == "token" {
+			params[i] = key + "=REDACTED"
+		}
+	}
+	return base + "?" + strings.Join(params, "&")
+}
+
+// corsMiddleware allows any origin while authentication is off. Once tokens
+// are configured only origins listed in auth.allowed_origins are echoed back,
+// so other sites cannot use the browser's token cookie to read chat history.
+func (s *Service) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token")
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		switch origin := s.allowedOrigin(c.GetHeader("Origin")); origin {
+		case "":
+		case "*":
+			header.Set("Access-Control-Allow-Origin", "*")
+		default:
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		header.Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, Mcp-Session-Id")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -23,6 +84,34 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// allowedOrigin returns the Access-Control-Allow-Origin value for origin,
+// or "" when the origin is not allowed. "*" is ignored while auth is enabled.
+func (s *Service) allowedOrigin(origin string) string {
+	auth := s.conf.GetAuth()
+	var allowed []string
+	if auth != nil {
+		allowed = auth.AllowedOrigins
+	}
+	if len(allowed) == 0 && !auth.Enabled() {
+		return "*"
+	}
+
+	origin = strings.TrimSpace(origin)
+	for _, a := range allowed {
+		a = strings.TrimRight(strings.TrimSpace(a), "/")
+		if a == "*" {
+			if !auth.Enabled() {
+				return "*"
+			}
+			continue
+		}
+		if origin != "" && strings.EqualFold(a, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
 func (s *Service) checkDBStateMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		switch s.db.State {
@@ -43,3 +132,67 @@ func (s *Service) checkDBStateMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// requireScope rejects requests whose API token lacks scope. Tokens are read
+// from "Authorization: Bearer", the token query parameter (for <img> tags and
+// MCP clients that cannot set headers) or the cookie set by a previous
+// ?token= visit. With no tokens configured every request passes.
+func (s *Service) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := s.conf.GetAuth()
+		if !auth.Enabled() {
+			c.Next()
+			return
+		}
+
+		raw, fromQuery := requestToken(c)
+		if raw == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="chatlog"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing api token"})
+			return
+		}
+		token := auth.Lookup(raw)
+		if token == nil {
+			c.Header("WWW-Authenticate", `Bearer realm="chatlog", error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired api token"})
+			return
+		}
+		if !token.Allows(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token lacks scope: " + scope})
+			return
+		}
+
+		if fromQuery {
+			c.SetSameSite(http.SameSiteStrictMode)
+			c.SetCookie(authCookieName, raw, 0, "/", "", false, true)
+		}
+		c.Next()
+	}
+}
+
+// rememberTokenMiddleware lets the web UI be opened as /?token=... once: the
+// token is stored in an HttpOnly cookie and sent with later API and media calls.
+func (s *Service) rememberTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if raw := strings.TrimSpace(c.Query("token")); raw != "" && s.conf.GetAuth().Lookup(raw) != nil {
+			c.SetSameSite(http.SameSiteStrictMode)
+			c.SetCookie(authCookieName, raw, 0, "/", "", false, true)
+		}
+		c.Next()
+	}
+}
+
+func requestToken(c *gin.Context) (string, bool) {
+	if header := c.GetHeader("Authorization"); header != "" {
+		if scheme, value, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(value), false
+		}
+	}
+	if raw := strings.TrimSpace(c.Query("token")); raw != "" {
+		return raw, true
+	}
+	if raw, err := c.Cookie(authCookieName); err == nil {
+		return strings.TrimSpace(raw), false
+	}
+	return "", false
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func mintToken(t *testing.T, scopes ...string) (string, *conf.APIToken) {
+	t.Helper()
+	raw, token, err := conf.NewAPIToken("test", scopes, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw, token
+}
+
+func scopedRouter(s *Service, scope string) *gin.Engine {
+	r := gin.New()
+	r.Use(s.corsMiddleware())
+	r.GET("/x", s.requireScope(scope), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	return r
+}
+
+func TestRequireScope(t *testing.T) {
+	readRaw, readToken := mintToken(t, conf.ScopeReadChatlog)
+	mediaRaw, mediaToken := mintToken(t, conf.ScopeReadMedia)
+	expiredRaw, expiredToken := mintToken(t, conf.ScopeReadChatlog)
+	expiredToken.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	// 已吊销的 token 不再出现在配置中
+	revokedRaw, _ := mintToken(t, conf.ScopeReadChatlog)
+
+	cfg := &conf.ServerConfig{Auth: &conf.AuthConfig{Tokens: []*conf.APIToken{readToken, mediaToken, expiredToken}}}
+	r := scopedRouter(&Service{conf: cfg}, conf.ScopeReadChatlog)
+
+	cases := []struct {
+		name   string
+		header string
+		query  string
+		want   int
+	}{
+		{name: "missing token", want: http.StatusUnauthorized},
+		{name: "revoked token", header: "Bearer " + revokedRaw, want: http.StatusUnauthorized},
+		{name: "expired token", header: "Bearer " + expiredRaw, want: http.StatusUnauthorized},
+		{name: "wrong scope", header: "Bearer " + mediaRaw, want: http.StatusForbidden},
+		{name: "right scope", header: "Bearer " + readRaw, want: http.StatusOK},
+		{name: "query token", query: "?token=" + readRaw, want: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/x"+tc.query, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tc.want, w.Body.String())
+			}
+			if tc.query != "" && w.Result().Cookies()[0].Name != authCookieName {
+				t.Fatal("query token should be remembered in a cookie")
+			}
+		})
+	}
+
+	// 未配置 token 时不做校验
+	open := scopedRouter(&Service{conf: &conf.ServerConfig{}}, conf.ScopeAdminActions)
+	w := httptest.NewRecorder()
+	open.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("auth disabled: status = %d", w.Code)
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	_, token := mintToken(t, conf.ScopeAll)
+	cases := []struct {
+		name   string
+		auth   *conf.AuthConfig
+		origin string
+		want   string
+	}{
+		{name: "auth disabled", origin: "https://evil.example", want: "*"},
+		{name: "auth without origins", auth: &conf.AuthConfig{Tokens: []*conf.APIToken{token}}, origin: "https://evil.example", want: ""},
+		{name: "wildcard ignored with auth", auth: &conf.AuthConfig{Tokens: []*conf.APIToken{token}, AllowedOrigins: []string{"*"}}, origin: "https://evil.example", want: ""},
+		{name: "listed origin", auth: &conf.AuthConfig{Tokens: []*conf.APIToken{token}, AllowedOrigins: []string{"https://app.example/"}}, origin: "https://app.example", want: "https://app.example"},
+		{name: "unlisted origin", auth: &conf.AuthConfig{AllowedOrigins: []string{"https://app.example"}}, origin: "https://evil.example", want: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := scopedRouter(&Service{conf: &conf.ServerConfig{Auth: tc.auth}}, conf.ScopeReadChatlog)
+			req := httptest.NewRequest(http.MethodOptions, "/x", nil)
+			req.Header.Set("Origin", tc.origin)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tc.want {
+				t.Fatalf("allow origin = %q, want %q", got, tc.want)
+			}
+			if credentials := w.Header().Get("Access-Control-Allow-Credentials"); (credentials == "true") != (tc.want != "" && tc.want != "*") {
+				t.Fatalf("allow credentials = %q for origin %q", credentials, tc.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogRedactsToken(t *testing.T) {
+	var buf bytes.Buffer
+	r := gin.New()
+	r.Use(accessLogger(&buf))
+	r.POST("/message", func(c *gin.Context) { c.Status(http.StatusAccepted) })
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, url := range []string{"/message?sessionId=abc&token=s3cret", "/message?to%6Ben=s3cret&token=", "/health?token=s3cret"} {
+		method := http.MethodPost
+		if strings.HasPrefix(url, "/health") {
+			method = http.MethodGet
+		}
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, url, nil))
+	}
+
+	logged := buf.String()
+	if strings.Contains(logged, "s3cret") {
+		t.Fatalf("access log leaks the token: %s", logged)
+	}
+	if !strings.Contains(logged, `"/message?sessionId=abc&token=REDACTED"`) || !strings.Contains(logged, `"/message?to%6Ben=REDACTED&token=REDACTED"`) {
+		t.Fatalf("access log = %s", logged)
+	}
+	if strings.Contains(logged, "/health") {
+		t.Fatal("/health should not be logged")
+	}
+}
@@ -21,10 +21,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
 	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
 	"github.com/takeaway1/chatlog-TCOTC/internal/model"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
-	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util/silk"
 )
 
@@ -167,7 +167,7 @@ func (s *Service) initBaseRouter() {
 	staticDir, _ := fs.Sub(EFS, "static")
 	s.router.StaticFS("/static", http.FS(staticDir))
 	s.router.StaticFileFS("/favicon.ico", "./favicon.ico", http.FS(staticDir))
-	s.router.StaticFileFS("/", "./index.htm", http.FS(staticDir))
+	s.router.GET("/", s.rememberTokenMiddleware(), func(c *gin.Context) { c.FileFromFS("./index.htm", http.FS(staticDir)) })
 	s.router.GET("/health", func(ctx *gin.Context) { ctx.JSON(http.StatusOK, gin.H{"status": "ok"}) })
 	s.router.NoRoute(func(c *gin.Context) {
 		path := c.Request.URL.Path
@@ -181,21 +181,33 @@ func (s *Service) initBaseRouter() {
 }
 
 func (s *Service) initMediaRouter() {
-	s.router.GET("/image/*key", func(c *gin.Context) { s.handleMedia(c, "image") })
-	s.router.GET("/video/*key", func(c *gin.Context) { s.handleMedia(c, "video") })
-	s.router.GET("/file/*key", func(c *gin.Context) { s.handleMedia(c, "file") })
-	s.router.GET("/voice/*key", func(c *gin.Context) { s.handleMedia(c, "voice") })
-	s.router.GET("/data/*path", s.handleMediaData)
-	s.router.GET("/avatar/:username", s.handleAvatar)
+	media := s.router.Group("", s.requireScope(conf.ScopeReadMedia))
+	media.GET("/image/*key", func(c *gin.Context) { s.handleMedia(c, "image") })
+	media.GET("/video/*key", func(c *gin.Context) { s.handleMedia(c, "video") })
+	media.GET("/file/*key", func(c *gin.Context) { s.handleMedia(c, "file") })
+	media.GET("/voice/*key", func(c *gin.Context) { s.handleMedia(c, "voice") })
+	media.GET("/data/*path", s.handleMediaData)
+	media.GET("/avatar/:username", s.handleAvatar)
+	s.router.GET("/accounts/:id/*path", s.handleAccountMedia)
 }
 
 func (s *Service) initAPIRouter() {
 	api := s.router.Group("/api/v1")
 	{
-		api.GET("/setting", s.handleGetSetting)
-		api.POST("/setting", s.handleUpdateSetting)
-
-		actions := api.Group("/actions")
+		admin := api.Group("", s.requireScope(conf.ScopeAdminActions))
+		admin.GET("/setting", s.handleGetSetting)
+		admin.POST("/setting", s.handleUpdateSetting)
+		admin.POST("/webhook", s.checkDBStateMiddleware(), s.handleWebhookReplay)
+		admin.GET("/transcribe", s.handleTranscribeStatus)
+		admin.POST("/transcribe", s.checkDBStateMiddleware(), s.handleTranscribeStart)
+		admin.DELETE("/transcribe", s.handleTranscribeCancel)
+		admin.GET("/media/decode", s.handleMediaDecodeStatus)
+		admin.POST("/media/decode", s.handleMediaDecodeStart)
+		admin.DELETE("/media/decode", s.handleMediaDecodeCancel)
+		admin.GET("/digest", s.handleDigestStatus)
+		admin.POST("/digest", s.checkDBStateMiddleware(), s.handleDigestRun)
+
+		actions := admin.Group("/actions")
 		actions.POST("/get-data-key", s.handleActionGetDataKey)
 		actions.POST("/decrypt", s.handleActionDecrypt)
 		actions.POST("/http/start", s.handleActionStartHTTP)
@@ -203,7 +215,7 @@ func (s *Service) initAPIRouter() {
 		actions.POST("/auto-decrypt/start", s.handleActionStartAutoDecrypt)
 		actions.POST("/auto-decrypt/stop", s.handleActionStopAutoDecrypt)
 
-		dataAPI := api.Group("", s.checkDBStateMiddleware())
+		dataAPI := api.Group("", s.requireScope(conf.ScopeReadChatlog), s.checkDBStateMiddleware())
 		dataAPI.GET("/chatlog", s.handleChatlog)
 		dataAPI.GET("/contact", s.handleContacts)
 		dataAPI.GET("/chatroom", s.handleChatRooms)
@@ -211,13 +223,29 @@ func (s *Service) initAPIRouter() {
 		dataAPI.GET("/diary", s.handleDiary)
 		dataAPI.GET("/dashboard", s.handleDashboard)
 		dataAPI.GET("/search", s.handleSearch)
+		dataAPI.GET("/webhook", s.handleWebhookStatus)
+		dataAPI.GET("/analytics/relationships", s.handleRelationships)
+		dataAPI.GET("/stats", s.handleTalkerStats)
+		dataAPI.GET("/chatroom/members", s.handleChatRoomMembers)
+
+		// 总结会调用外部大模型并产生费用，单独授权
+		api.GET("/summarize", s.requireScope(conf.ScopeSummarize), s.checkDBStateMiddleware(), s.handleSummarize)
+
+		mediaAPI := api.Group("/media", s.requireScope(conf.ScopeReadMedia), s.checkDBStateMiddleware())
+		mediaAPI.GET("/:key/info", s.handleMediaInfo)
+
+		api.GET("/accounts", s.requireScope(conf.ScopeReadChatlog), s.handleAccounts)
+		api.GET("/federated/search", s.requireScope(conf.ScopeReadChatlog), s.handleFederatedSearch)
+		api.GET("/federated/chatlog", s.requireScope(conf.ScopeReadChatlog), s.handleFederatedChatlog)
+		api.Any("/accounts/:id/*path", s.handleAccountAPI)
 	}
 }
 
 func (s *Service) initMCPRouter() {
-	s.router.Any("/mcp", func(c *gin.Context) { s.mcpStreamableServer.ServeHTTP(c.Writer, c.Request) })
-	s.router.Any("/sse", func(c *gin.Context) { s.mcpSSEServer.ServeHTTP(c.Writer, c.Request) })
-	s.router.Any("/message", func(c *gin.Context) { s.mcpSSEServer.ServeHTTP(c.Writer, c.Request) })
+	mcpGroup := s.router.Group("", s.requireScope(conf.ScopeMCP))
+	mcpGroup.Any("/mcp", func(c *gin.Context) { s.mcpStreamableServer.ServeHTTP(c.Writer, c.Request) })
+	mcpGroup.Any("/sse", func(c *gin.Context) { s.mcpSSEServer.ServeHTTP(c.Writer, c.Request) })
+	mcpGroup.Any("/message", func(c *gin.Context) { s.mcpSSEServer.ServeHTTP(c.Writer, c.Request) })
 }
 
 // GET /api/v1/dashboard
@@ -234,7 +262,7 @@ func (s *Service) handleDashboard(c *gin.Context) {
 	dataDir := s.conf.GetDataDir()
 	workDir := dataDir
 	if s.db != nil {
-		if wd := s.db.GetWorkDir(); wd != "" {
+		if wd := s.db.GetDBDir(); wd != "" {
 			workDir = wd
 		}
 	}
@@ -242,31 +270,8 @@ func (s *Service) handleDashboard(c *gin.Context) {
 	dbSize := estimateDBSize(workDir)
 
 	// 当前账号昵称（overview.user）：优先从 WorkDir/DataDir 路径中提取 wxid_***，再用联系人 NickName 映射；找不到则回退 wxid
-	extractWxid := func(p string) string {
-		p = strings.TrimSpace(p)
-		if p == "" {
-			return ""
-		}
-		// 遍历路径片段，优先返回形如 wxid_ 开头的片段
-		parts := strings.Split(filepath.Clean(p), string(filepath.Separator))
-		for _, seg := range parts {
-			if strings.HasPrefix(strings.ToLower(seg), "wxid_") {
-				return seg
-			}
-		}
-		// 兜底返回最后一段
-		return filepath.Base(filepath.Clean(p))
-	}
-
 	currentUser := ""
-	accountID := ""
-	// 先从 WorkDir 提取（更贴近实际解密目录结构），再从 DataDir 提取
-	if wd := s.db.GetWorkDir(); wd != "" && accountID == "" {
-		accountID = extractWxid(wd)
-	}
-	if accountID == "" {
-		accountID = extractWxid(dataDir)
-	}
+	accountID := s.currentAccountID()
 
 	// 若拿到候选 accountID，则尝试用联系人映射 NickName
 	if accountID != "" && accountID != "." && accountID != string(filepath.Separator) {
@@ -328,9 +333,16 @@ func (s *Service) handleDashboard(c *gin.Context) {
 		Status    string `json:"status"`
 		CreatedAt string `json:"created_at"`
 	}
+	type MediaStats struct {
+		DecodedImages     int            `json:"decoded_images"`
+		UndecodableImages int            `json:"undecodable_images"`
+		FailureReasons    map[string]int `json:"failure_reasons,omitempty"`
+		LastDecode        int64          `json:"last_decode"`
+	}
 	type Overview struct {
 		User       string           `json:"user"`
 		DBStats    DBStats          `json:"dbStats"`
+		MediaStats MediaStats       `json:"mediaStats"`
 		MsgStats   MsgStats         `json:"msgStats"`
 		MsgTypes   map[string]int64 `json:"msgTypes"`
 		Groups     []OverviewGroup  `json:"groups"`
@@ -744,10 +756,23 @@ func (s *Service) handleDashboard(c *gin.Context) {
 		RelationshipNetwork: RelationshipNetwork{Nodes: relationshipNodes},
 	}
 
+	// 图片批量解码结果，未运行过 chatlog media decode 时为 0
+	mediaStats := MediaStats{}
+	if cache := s.decodedCache(); cache != nil {
+		st := cache.Stats()
+		mediaStats.DecodedImages = st.Decoded
+		mediaStats.UndecodableImages = st.Failed
+		mediaStats.FailureReasons = st.Reasons
+		if !st.UpdatedAt.IsZero() {
+			mediaStats.LastDecode = st.UpdatedAt.Unix()
+		}
+	}
+
 	resp := Dashboard{
 		Overview: Overview{
 			User:       currentUser,
 			DBStats:    DBStats{DbSizeMB: roundMB(dbSize), DirSizeMB: roundMB(dirSize)},
+			MediaStats: mediaStats,
 			MsgStats:   MsgStats{TotalMsgs: gstats.Total, SentMsgs: gstats.Sent, ReceivedMsgs: gstats.Received, UniqueMsgTypes: uniqueTypes},
 			MsgTypes:   msgTypes,
 			Groups:     overviewGroups,
@@ -846,26 +871,31 @@ func estimateDBSize(workDir string) int64 {
 	return total
 }
 
-func (s *Service) handleSearch(c *gin.Context) {
-	params := struct {
-		Query  string `form:"q"`
-		Talker string `form:"talker"`
-		Sender string `form:"sender"`
-		Time   string `form:"time"`
-		Start  string `form:"start"`
-		End    string `form:"end"`
-		Limit  int    `form:"limit"`
-		Offset int    `form:"offset"`
-		Format string `form:"format"`
-	}{}
-
-	if err := c.BindQuery(&params); err != nil {
-		errors.Err(c, err)
-		return
-	}
+// searchParams 为 /api/v1/search 与联合搜索共用的查询参数
+type searchParams struct {
+	Query  string `form:"q"`
+	Talker string `form:"talker"`
+	Sender string `form:"sender"`
+	Time   string `form:"time"`
+	Start  string `form:"start"`
+	End    string `form:"end"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+	Format string `form:"format"`
+	Mode   string `form:"mode"`
+}
 
+// request 校验参数并生成搜索请求，limit 默认 20、最大 200
+func (params searchParams) request() (*model.SearchRequest, error) {
 	query := strings.TrimSpace(params.Query)
 
+	mode := strings.ToLower(strings.TrimSpace(params.Mode))
+	switch mode {
+	case "", model.SearchModeKeyword, model.SearchModeSemantic, model.SearchModeHybrid:
+	default:
+		return nil, errors.InvalidArg("mode")
+	}
+
 	talker := strings.TrimSpace(params.Talker)
 
 	limit := params.Limit
@@ -886,13 +916,13 @@ func (s *Service) handleSearch(c *gin.Context) {
 		Sender: strings.TrimSpace(params.Sender),
 		Limit:  limit,
 		Offset: offset,
+		Mode:   mode,
 	}
 
 	if params.Time != "" {
 		start, end, ok := util.TimeRangeOf(params.Time)
 		if !ok {
-			errors.Err(c, errors.InvalidArg("time"))
-			return
+			return nil, errors.InvalidArg("time")
 		}
 		req.Start = start
 		req.End = end
@@ -900,24 +930,21 @@ func (s *Service) handleSearch(c *gin.Context) {
 		if params.Start != "" && params.End != "" {
 			start, end, ok := util.TimeRangeOf(params.Start + "~" + params.End)
 			if !ok {
-				errors.Err(c, errors.InvalidArg("time"))
-				return
+				return nil, errors.InvalidArg("time")
 			}
 			req.Start = start
 			req.End = end
 		} else if params.Start != "" {
 			start, end, ok := util.TimeRangeOf(params.Start)
 			if !ok {
-				errors.Err(c, errors.InvalidArg("start"))
-				return
+				return nil, errors.InvalidArg("start")
 			}
 			req.Start = start
 			req.End = end
 		} else if params.End != "" {
 			start, end, ok := util.TimeRangeOf(params.End)
 			if !ok {
-				errors.Err(c, errors.InvalidArg("end"))
-				return
+				return nil, errors.InvalidArg("end")
 			}
 			req.Start = start
 			req.End = end
@@ -928,6 +955,22 @@ func (s *Service) handleSearch(c *gin.Context) {
 		req.Start, req.End = req.End, req.Start
 	}
 
+	return req, nil
+}
+
+func (s *Service) handleSearch(c *gin.Context) {
+	var params searchParams
+	if err := c.BindQuery(&params); err != nil {
+		errors.Err(c, err)
+		return
+	}
+	req, err := params.request()
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	limit, offset := req.Limit, req.Offset
+
 	resp, err := s.db.SearchMessages(req)
 	if err != nil {
 		errors.Err(c, err)
@@ -1111,6 +1154,8 @@ func (s *Service) handleChatlog(c *gin.Context) {
 		Limit   int    `form:"limit"`
 		Offset  int    `form:"offset"`
 		Format  string `form:"format"`
+
+		IncludeTranscripts bool `form:"include_transcripts"`
 	}{}
 
 	if err := c.BindQuery(&q); err != nil {
@@ -1147,11 +1192,15 @@ func (s *Service) handleChatlog(c *gin.Context) {
 			Messages   []*model.Message `json:"messages"`
 		}
 		groups := make([]*grouped, 0)
+		budget := inlineTranscribeLimit
 		for _, sess := range sessionsResp.Items {
 			msgs, err := s.db.GetMessages(start, end, sess.UserName, q.Sender, q.Keyword, 0, 0)
 			if err != nil || len(msgs) == 0 {
 				continue
 			}
+			if q.IncludeTranscripts {
+				s.attachTranscripts(c.Request.Context(), msgs, &budget)
+			}
 			groups = append(groups, &grouped{Talker: sess.UserName, TalkerName: sess.NickName, Messages: msgs})
 		}
 		switch format {
@@ -1235,6 +1284,10 @@ func (s *Service) handleChatlog(c *gin.Context) {
 		errors.Err(c, err)
 		return
 	}
+	if q.IncludeTranscripts {
+		budget := inlineTranscribeLimit
+		s.attachTranscripts(c.Request.Context(), messages, &budget)
+	}
 	switch format {
 	case "html":
 		c.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -1370,6 +1423,31 @@ func (s *Service) handleContacts(c *gin.Context) {
 	}
 }
 
+// currentAccountID 从数据库目录/DataDir 路径中提取当前账号的 wxid，优先使用数据库目录（工作目录或快照，更贴近实际解密目录结构）
+func (s *Service) currentAccountID() string {
+	if wd := s.db.GetDBDir(); wd != "" {
+		if id := extractWxid(wd); id != "" {
+			return id
+		}
+	}
+	return extractWxid(s.conf.GetDataDir())
+}
+
+// extractWxid 遍历路径片段，优先返回形如 wxid_ 开头的片段，兜底返回最后一段
+func extractWxid(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return ""
+	}
+	parts := strings.Split(filepath.Clean(p), string(filepath.Separator))
+	for _, seg := range parts {
+		if strings.HasPrefix(strings.ToLower(seg), "wxid_") {
+			return seg
+		}
+	}
+	return filepath.Base(filepath.Clean(p))
+}
+
 // composeAvatarURL builds a relative URL that the server can serve for any username
 func (s *Service) composeAvatarURL(username string) string {
 	if username == "" {
@@ -1538,6 +1616,8 @@ func (s *Service) handleDiary(c *gin.Context) {
 		Date   string `form:"date"`
 		Talker string `form:"talker"`
 		Format string `form:"format"`
+
+		IncludeTranscripts bool `form:"include_transcripts"`
 	}{}
 	if err := c.BindQuery(&q); err != nil {
 		errors.Err(c, err)
@@ -1574,6 +1654,7 @@ func (s *Service) handleDiary(c *gin.Context) {
 		Messages   []*model.Message `json:"messages"`
 	}
 	groups := make([]*grouped, 0)
+	budget := inlineTranscribeLimit
 
 	for _, sess := range sessionsResp.Items {
 		msgs, err := s.db.GetMessages(start, end, sess.UserName, "", "", 0, 0)
@@ -1590,6 +1671,9 @@ func (s *Service) handleDiary(c *gin.Context) {
 		if !hasSelf {
 			continue
 		}
+		if q.IncludeTranscripts {
+			s.attachTranscripts(c.Request.Context(), msgs, &budget)
+		}
 		groups = append(groups, &grouped{Talker: sess.UserName, TalkerName: sess.NickName, Messages: msgs})
 	}
 
@@ -1689,7 +1773,7 @@ func (s *Service) handleMedia(c *gin.Context, _type string) {
 	for _, k := range keys {
 		if strings.Contains(k, "/") {
 			if absolutePath, err := s.findPath(_type, k); err == nil {
-				c.Redirect(http.StatusFound, "/data/"+absolutePath)
+				c.Redirect(http.StatusFound, "/data/"+absolutePath+rawQuery(c))
 				return
 			}
 		}
@@ -1711,7 +1795,7 @@ func (s *Service) handleMedia(c *gin.Context, _type string) {
 			s.HandleVoice(c, media.Data)
 			return
 		default:
-			c.Redirect(http.StatusFound, "/data/"+media.Path)
+			c.Redirect(http.StatusFound, "/data/"+media.Path+rawQuery(c))
 			return
 		}
 	}
@@ -1761,7 +1845,7 @@ func (s *Service) handleVoiceTranscription(c *gin.Context, key string, media *mo
 		}
 	}
 
-	res, err := s.speechTranscriber.TranscribeSilk(ctx, media.Data, opts)
+	entry, cached, err := s.transcribeVoice(ctx, s.db.GetTranscripts(), key, media.Data, opts, c.Query("refresh") != "")
 	if err != nil {
 		if ctx.Err() != nil {
 			c.JSON(http.StatusRequestTimeout, gin.H{"error": "transcription cancelled"})
@@ -1771,17 +1855,18 @@ func (s *Service) handleVoiceTranscription(c *gin.Context, key string, media *mo
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "transcription failed"})
 		return
 	}
-	if res == nil {
-		c.JSON(http.StatusOK, gin.H{"key": key, "text": "", "language": opts.Language, "duration": 0})
-		return
+	language := entry.Language
+	if language == "" {
+		language = opts.Language
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"key":      key,
-		"text":     res.Text,
-		"language": res.Language,
-		"duration": res.Duration.Seconds(),
-		"segments": res.Segments,
+		"text":     entry.Text,
+		"language": language,
+		"duration": entry.Duration.Seconds(),
+		"segments": entry.Segments,
+		"cached":   cached,
 	})
 }
 
@@ -1819,6 +1904,15 @@ func (s *Service) handleMediaData(c *gin.Context) {
 		return
 	}
 
+	if c.Query("thumb") != "" {
+		s.serveThumbnail(c, absolutePath)
+		return
+	}
+	if c.Query("format") == "mp4" {
+		s.servePlayable(c, absolutePath)
+		return
+	}
+
 	ext := strings.ToLower(filepath.Ext(absolutePath))
 	switch {
 	case ext == ".dat":
@@ -1832,34 +1926,53 @@ func (s *Service) handleMediaData(c *gin.Context) {
 
 func (s *Service) HandleDatFile(c *gin.Context, path string) {
 
+	// 优先使用批量解码生成的缓存
+	if s.serveDecoded(c, path) {
+		return
+	}
+
 	b, err := os.ReadFile(path)
 	if err != nil {
 		errors.Err(c, err)
 		return
 	}
-	out, ext, err := dat2img.Dat2Image(b)
+	out, ext, err := s.imgKeys.Dat2Image(b)
 	if err != nil {
 		c.File(path)
 		return
 	}
 
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + "." + ext
+
 	switch ext {
 	case "jpg", "jpeg":
-		c.Data(http.StatusOK, "image/jpeg", out)
+		serveBytes(c, name, "image/jpeg", out, modTime)
 	case "png":
-		c.Data(http.StatusOK, "image/png", out)
+		serveBytes(c, name, "image/png", out, modTime)
 	case "gif":
-		c.Data(http.StatusOK, "image/gif", out)
+		serveBytes(c, name, "image/gif", out, modTime)
 	case "bmp":
-		c.Data(http.StatusOK, "image/bmp", out)
+		serveBytes(c, name, "image/bmp", out, modTime)
 	case "mp4":
-		c.Data(http.StatusOK, "video/mp4", out)
+		serveBytes(c, name, "video/mp4", out, modTime)
 	default:
-		c.Data(http.StatusOK, "image/jpg", out)
+		serveBytes(c, name, "image/jpg", out, modTime)
 		// c.File(path)
 	}
 }
 
+// rawQuery 返回带 ? 前缀的原始查询串，重定向时保留 thumb、format 等参数
+func rawQuery(c *gin.Context) string {
+	if c.Request.URL.RawQuery == "" {
+		return ""
+	}
+	return "?" + c.Request.URL.RawQuery
+}
+
 func (s *Service) HandleVoice(c *gin.Context, data []byte) {
 	out, err := silk.Silk2MP3(data)
 	if err != nil {
@@ -1876,6 +1989,11 @@ var (
 
 func messageHTMLPlaceholder(m *model.Message) string {
 	content := m.PlainTextContent()
+	// 语音转写单独放入结果区域，避免与链接文本混排
+	transcriptText, _ := m.Contents["transcript"].(string)
+	if m.Type == model.MessageTypeVoice && transcriptText != "" {
+		content = strings.TrimSuffix(content, " "+transcriptText)
+	}
 	return placeholderPattern.ReplaceAllStringFunc(content, func(s string) string {
 		matches := placeholderPattern.FindStringSubmatch(s)
 		if len(matches) != 3 {
@@ -1910,6 +2028,9 @@ func messageHTMLPlaceholder(m *model.Message) string {
 		escapedURL := template.HTMLEscapeString(url)
 		anchor := `<a class="` + className + `" href="` + escapedURL + `" target="_blank">` + anchorText + `</a>`
 		if left == "语音" {
+			if transcriptText != "" {
+				return `<span class="voice-entry">` + anchor + `<span class="voice-transcribe-result" aria-live="polite">` + template.HTMLEscapeString(transcriptText) + `</span></span>`
+			}
 			return `<span class="voice-entry">` + anchor + `<button type="button" class="voice-transcribe-btn">转文字</button><span class="voice-transcribe-result" aria-live="polite"></span></span>`
 		}
 		return anchor
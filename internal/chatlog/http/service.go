@@ -2,8 +2,10 @@ package http
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,8 +14,14 @@ import (
 
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/database"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/media"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/summarize"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/transcript"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/wechat"
 	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/llm"
 	"github.com/takeaway1/chatlog-TCOTC/internal/whisper"
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
 )
 
 type Service struct {
@@ -30,6 +38,25 @@ type Service struct {
 
 	speechTranscriber whisper.Transcriber
 	speechOptions     whisper.Options
+	speechModel       string
+	transcribeJobs    transcript.Runner
+
+	decodedMu  sync.Mutex
+	decoded    *media.DecodedCache
+	decodedDir string
+	decodeJobs media.DecodeRunner
+
+	// imgKeys 为 nil 时使用进程全局的图片密钥
+	imgKeys *dat2img.Keys
+
+	summarizeMu        sync.Mutex
+	summarizeLLM       llm.Client
+	summarizeChunk     int
+	summarizeMaxChunks int
+	summaryCache       *summarize.Cache
+	summaryDir         string
+
+	accounts []*mountedAccount
 }
 
 type Config interface {
@@ -46,6 +73,8 @@ type Config interface {
 	IsHTTPEnabled() bool
 	IsAutoDecrypt() bool
 	GetSpeech() *conf.SpeechConfig
+	GetAuth() *conf.AuthConfig
+	GetSummarize() *conf.SummarizeConfig
 }
 
 type Control interface {
@@ -57,6 +86,7 @@ type Control interface {
 	StopAutoDecrypt() error
 	SaveSpeechConfig(cfg *conf.SpeechConfig) error
 	SetHTTPAddr(addr string) error
+	LastDecryptPass() *wechat.DecryptPass
 }
 
 func NewService(conf Config, db *database.Service, control Control) *Service {
@@ -72,8 +102,7 @@ func NewService(conf Config, db *database.Service, control Control) *Service {
 	router.Use(
 		errors.RecoveryMiddleware(),
 		errors.ErrorHandlerMiddleware(),
-		gin.LoggerWithWriter(log.Logger, "/health"),
-		corsMiddleware(),
+		accessLogger(log.Logger),
 	)
 
 	s := &Service{
@@ -82,14 +111,18 @@ func NewService(conf Config, db *database.Service, control Control) *Service {
 		control: control,
 		router:  router,
 	}
+	router.Use(s.corsMiddleware())
 
 	s.initMCPServer()
 	s.initRouter()
 	s.initSpeech(conf)
+	s.initSummarize(conf)
 	return s
 }
 
 func (s *Service) initSpeech(cfg Config) {
+	// 后台转写任务持有旧的 transcriber，重新初始化前先停止
+	s.transcribeJobs.Cancel()
 	if s.speechTranscriber != nil {
 		s.speechTranscriber.Close()
 		s.speechTranscriber = nil
@@ -103,6 +136,7 @@ func (s *Service) initSpeech(cfg Config) {
 	speechCfg.Normalize()
 
 	opts := speechCfg.ToOptions()
+	s.speechModel = speechCfg.Provider + ":" + speechCfg.Model
 	timeout := time.Duration(speechCfg.RequestTimeoutSeconds) * time.Second
 
 	provider := strings.ToLower(speechCfg.Provider)
@@ -155,6 +189,18 @@ func (s *Service) initSpeech(cfg Config) {
 		s.speechTranscriber = transcriber
 		s.speechOptions = opts
 		log.Info().Str("model_path", modelPath).Msg("speech transcription backend initialised via whisper.cpp")
+	case "replay":
+		transcriber, err := whisper.NewReplayTranscriber(whisper.ReplayConfig{
+			Dir:            speechCfg.ReplayDir,
+			DefaultOptions: opts,
+		})
+		if err != nil {
+			log.Err(err).Msg("initialise replay transcriber failed")
+			return
+		}
+		s.speechTranscriber = transcriber
+		s.speechOptions = opts
+		log.Info().Str("dir", transcriber.Dir()).Msg("speech transcription backend initialised via replay fixtures")
 	default:
 		log.Warn().Str("provider", speechCfg.Provider).Msg("unsupported speech provider; speech transcription disabled")
 	}
@@ -179,6 +225,7 @@ func (s *Service) Start() error {
 	}()
 
 	log.Info().Msg("Starting HTTP server on " + s.conf.GetHTTPAddr())
+	s.warnUnauthenticated()
 
 	return nil
 }
@@ -191,9 +238,25 @@ func (s *Service) ListenAndServe() error {
 	}
 
 	log.Info().Msg("Starting HTTP server on " + s.conf.GetHTTPAddr())
+	s.warnUnauthenticated()
 	return s.server.ListenAndServe()
 }
 
+// warnUnauthenticated 在未配置 token 且监听非回环地址时给出提示
+func (s *Service) warnUnauthenticated() {
+	if s.conf.GetAuth().Enabled() {
+		return
+	}
+	host, _, err := net.SplitHostPort(s.conf.GetHTTPAddr())
+	if err != nil {
+		return
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return
+	}
+	log.Warn().Msg("HTTP server is reachable from the network without authentication; create a token with `chatlog token create`")
+}
+
 func (s *Service) Stop() error {
 
 	if s.server == nil {
@@ -209,10 +272,18 @@ func (s *Service) Stop() error {
 		return nil
 	}
 
+	s.transcribeJobs.Cancel()
+	s.decodeJobs.Cancel()
 	if s.speechTranscriber != nil {
 		s.speechTranscriber.Close()
 		s.speechTranscriber = nil
 	}
+	s.summarizeMu.Lock()
+	if s.summaryCache != nil {
+		s.summaryCache.Close()
+		s.summaryCache = nil
+	}
+	s.summarizeMu.Unlock()
 
 	log.Info().Msg("HTTP server stopped")
 	return nil
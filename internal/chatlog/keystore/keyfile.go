@@ -0,0 +1,31 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keyFileName 为本地密钥文件，在没有系统密钥链的环境（如无桌面环境的 Linux）中保存主密钥
+const keyFileName = "keystore.key"
+
+func keyFilePath(dir, slot string) string {
+	return filepath.Join(dir, slotName(keyFileName, slot))
+}
+
+func writeKeyFile(dir, slot string, master []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(keyFilePath(dir, slot), []byte(hex.EncodeToString(master)), 0o600)
+}
+
+func readKeyFile(dir, slot string) ([]byte, error) {
+	data, err := os.ReadFile(keyFilePath(dir, slot))
+	if err != nil {
+		return nil, fmt.Errorf("read keystore key file: %w", err)
+	}
+	return hex.DecodeString(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,41 @@
+package keystore
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	keyringService = "chatlog"
+	keyringAccount = "keystore"
+)
+
+// keyring 把主密钥交给操作系统保管：macOS 钥匙串、Linux Secret Service、Windows DPAPI
+type keyring interface {
+	// Available 表示当前环境可以使用系统密钥链
+	Available() bool
+	Get() ([]byte, error)
+	Set(secret []byte) error
+	Delete() error
+}
+
+// 主密钥有两个槽位，轮换时新主密钥写入当前未使用的槽位，keystore.json 切换成功后才删除旧槽位，
+// 任何一步失败或中途崩溃时 keystore.json 引用的主密钥都仍然存在
+const altSlot = "alt"
+
+// nextSlot 返回轮换时使用的另一个槽位
+func nextSlot(slot string) string {
+	if slot == altSlot {
+		return ""
+	}
+	return altSlot
+}
+
+// slotName 返回槽位对应的名称：默认槽位为 name 本身，备用槽位在扩展名前加 ".alt"
+func slotName(name, slot string) string {
+	if slot == "" {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + slot + ext
+}
@@ -0,0 +1,49 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// darwinKeyring 通过 security 命令读写登录钥匙串
+type darwinKeyring struct {
+	account string
+}
+
+func newKeyring(dir, slot string) keyring {
+	return darwinKeyring{account: slotName(keyringAccount, slot)}
+}
+
+func (darwinKeyring) Available() bool {
+	_, err := exec.LookPath("security")
+	return err == nil
+}
+
+func (k darwinKeyring) Get() ([]byte, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keyringService, "-a", k.account, "-w").Output()
+	if err != nil {
+		return nil, fmt.Errorf("read keychain: %w", err)
+	}
+	return hex.DecodeString(strings.TrimSpace(string(out)))
+}
+
+// Set 中 -w 放在最后且不带值，security 会从标准输入读取密码（输入与确认各一次），
+// 避免主密钥出现在命令行参数中被 ps 等看到
+func (k darwinKeyring) Set(secret []byte) error {
+	encoded := hex.EncodeToString(secret)
+	cmd := exec.Command("security", "add-generic-password", "-U", "-s", keyringService, "-a", k.account, "-w")
+	cmd.Stdin = strings.NewReader(encoded + "\n" + encoded + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("write keychain: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (k darwinKeyring) Delete() error {
+	if out, err := exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", k.account).CombinedOutput(); err != nil {
+		return fmt.Errorf("delete keychain item: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
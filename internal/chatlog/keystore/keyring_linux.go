@@ -0,0 +1,50 @@
+package keystore
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// linuxKeyring 通过 secret-tool（libsecret）读写 Secret Service，需要桌面会话的 D-Bus
+type linuxKeyring struct {
+	account string
+}
+
+func newKeyring(dir, slot string) keyring {
+	return linuxKeyring{account: slotName(keyringAccount, slot)}
+}
+
+func (linuxKeyring) Available() bool {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return false
+	}
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+func (k linuxKeyring) Get() ([]byte, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keyringService, "account", k.account).Output()
+	if err != nil {
+		return nil, fmt.Errorf("read secret service: %w", err)
+	}
+	return hex.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func (k linuxKeyring) Set(secret []byte) error {
+	cmd := exec.Command("secret-tool", "store", "--label=chatlog keystore", "service", keyringService, "account", k.account)
+	cmd.Stdin = strings.NewReader(hex.EncodeToString(secret))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("write secret service: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (k linuxKeyring) Delete() error {
+	if out, err := exec.Command("secret-tool", "clear", "service", keyringService, "account", k.account).CombinedOutput(); err != nil {
+		return fmt.Errorf("clear secret service: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
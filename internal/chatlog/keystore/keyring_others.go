@@ -0,0 +1,27 @@
+//go:build !darwin && !linux && !windows
+
+package keystore
+
+import "errors"
+
+type noKeyring struct{}
+
+func newKeyring(dir, slot string) keyring {
+	return noKeyring{}
+}
+
+func (noKeyring) Available() bool {
+	return false
+}
+
+func (noKeyring) Get() ([]byte, error) {
+	return nil, errors.New("system keyring is not supported on this platform")
+}
+
+func (noKeyring) Set(secret []byte) error {
+	return errors.New("system keyring is not supported on this platform")
+}
+
+func (noKeyring) Delete() error {
+	return nil
+}
@@ -0,0 +1,70 @@
+package keystore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// dpapiFileName 保存经 DPAPI 加密的主密钥，只有当前 Windows 用户能够解密
+const dpapiFileName = "keystore.dpapi"
+
+type windowsKeyring struct {
+	path string
+}
+
+func newKeyring(dir, slot string) keyring {
+	return windowsKeyring{path: filepath.Join(dir, slotName(dpapiFileName, slot))}
+}
+
+func (windowsKeyring) Available() bool {
+	return true
+}
+
+func (k windowsKeyring) Get() ([]byte, error) {
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return nil, fmt.Errorf("read dpapi blob: %w", err)
+	}
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(newBlob(data), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, fmt.Errorf("dpapi unprotect: %w", err)
+	}
+	return takeBlob(&out), nil
+}
+
+func (k windowsKeyring) Set(secret []byte) error {
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(newBlob(secret), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return fmt.Errorf("dpapi protect: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(k.path, takeBlob(&out), 0o600)
+}
+
+func (k windowsKeyring) Delete() error {
+	if err := os.Remove(k.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func newBlob(b []byte) *windows.DataBlob {
+	if len(b) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+}
+
+// takeBlob 复制系统分配的输出并释放
+func takeBlob(blob *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(blob.Data)))
+	out := make([]byte, blob.Size)
+	copy(out, unsafe.Slice(blob.Data, blob.Size))
+	return out
+}
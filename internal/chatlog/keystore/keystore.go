@@ -0,0 +1,616 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// FileName 为密钥库在配置目录下的文件名
+	FileName = "keystore.json"
+
+	// EnvPassphrase 设置后新建的密钥库使用口令保护，已有的口令密钥库也从这里读取口令
+	EnvPassphrase = "CHATLOG_KEYSTORE_PASSPHRASE"
+
+	// 主密钥的保护方式
+	ModePassphrase = "passphrase"
+	ModeKeyring    = "keyring"
+	ModeFile       = "file"
+
+	fileVersion = 1
+	keySize     = 32
+	checkText   = "chatlog-keystore"
+
+	// scrypt 参数，解锁一次约需 100ms
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	ErrLocked        = errors.New("keystore is locked, set " + EnvPassphrase + " or run `chatlog keys unlock`")
+	ErrBadPassphrase = errors.New("incorrect keystore passphrase")
+	ErrNotFound      = errors.New("no keys stored for this account")
+)
+
+// Options 控制密钥库的打开方式
+type Options struct {
+	// Mode 为新建密钥库时的保护方式，为空时自动选择：
+	// 提供了口令则使用口令，否则优先使用系统密钥链，不可用时（如无桌面环境的 Linux）退回到本地密钥文件
+	Mode string
+
+	// Passphrase 为口令模式下的口令
+	Passphrase string
+}
+
+// OptionsFromEnv 从环境变量读取口令
+func OptionsFromEnv() Options {
+	return Options{Passphrase: os.Getenv(EnvPassphrase)}
+}
+
+// Entry 是某个账号的密钥
+type Entry struct {
+	Account   string    `json:"account"`
+	DataDir   string    `json:"data_dir"`
+	DataKey   string    `json:"data_key"`
+	ImgKey    string    `json:"img_key"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Info 是不含密钥的条目摘要，列出时无需解锁
+type Info struct {
+	Account    string    `json:"account"`
+	DataDir    string    `json:"data_dir"`
+	HasDataKey bool      `json:"has_data_key"`
+	HasImgKey  bool      `json:"has_img_key"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// storeFile 是 keystore.json 的内容
+// 每个条目使用独立的数据密钥（DEK）加密，DEK 再由主密钥加密，轮换单个账号时只需更换该账号的 DEK
+type storeFile struct {
+	Version int      `json:"version"`
+	Mode    string   `json:"mode"`
+	Slot    string   `json:"slot,omitempty"`
+	Salt    string   `json:"salt,omitempty"`
+	Check   string   `json:"check"`
+	Entries []*entry `json:"entries"`
+}
+
+type entry struct {
+	Account   string `json:"account"`
+	DataDir   string `json:"data_dir"`
+	DEK       string `json:"dek"`
+	DataKey   string `json:"data_key,omitempty"`
+	ImgKey    string `json:"img_key,omitempty"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Store 把微信数据密钥和图片密钥加密保存在配置目录下的 keystore.json 中
+type Store struct {
+	dir  string
+	path string
+	opts Options
+
+	mu     sync.Mutex
+	file   *storeFile
+	master []byte
+}
+
+// Open 打开 dir 下的密钥库，文件不存在时返回空密钥库，首次写入时才创建
+// 口令模式下未提供口令时密钥库处于锁定状态，可以列出条目但不能读写密钥
+func Open(dir string, opts Options) (*Store, error) {
+	s := &Store{
+		dir:  dir,
+		path: filepath.Join(dir, FileName),
+		opts: opts,
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	file := &storeFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	s.file = file
+
+	if file.Mode == ModePassphrase && opts.Passphrase == "" {
+		return s, nil
+	}
+	if err := s.unlock(opts.Passphrase); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path 返回密钥库文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Mode 返回主密钥的保护方式，密钥库尚未创建时返回空
+func (s *Store) Mode() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return ""
+	}
+	return s.file.Mode
+}
+
+// Locked 表示密钥库已存在但尚未解锁
+func (s *Store) Locked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file != nil && s.master == nil
+}
+
+// Unlock 使用口令解锁密钥库
+func (s *Store) Unlock(passphrase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil || s.master != nil {
+		return nil
+	}
+	return s.unlock(passphrase)
+}
+
+func (s *Store) unlock(passphrase string) error {
+	master, err := loadMaster(s.dir, s.file, passphrase)
+	if err != nil {
+		return err
+	}
+	if _, err := open(master, s.file.Check, "check"); err != nil {
+		if s.file.Mode == ModePassphrase {
+			return ErrBadPassphrase
+		}
+		return fmt.Errorf("keystore master key mismatch: %w", err)
+	}
+	s.master = master
+	return nil
+}
+
+// List 返回全部条目的摘要，按账号排序
+func (s *Store) List() []Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	out := make([]Info, 0, len(s.file.Entries))
+	for _, e := range s.file.Entries {
+		out = append(out, Info{
+			Account:    e.Account,
+			DataDir:    e.DataDir,
+			HasDataKey: e.DataKey != "",
+			HasImgKey:  e.ImgKey != "",
+			UpdatedAt:  time.Unix(e.UpdatedAt, 0),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Account < out[j].Account })
+	return out
+}
+
+// Get 返回账号的密钥
+func (s *Store) Get(account string) (*Entry, error) {
+	return s.find(func(e *entry) bool { return e.Account == account })
+}
+
+// Lookup 按数据目录查找密钥，供不记录账号的服务模式使用
+func (s *Store) Lookup(dataDir string) (*Entry, error) {
+	dataDir = filepath.Clean(dataDir)
+	return s.find(func(e *entry) bool { return e.DataDir != "" && filepath.Clean(e.DataDir) == dataDir })
+}
+
+func (s *Store) find(match func(e *entry) bool) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil, ErrNotFound
+	}
+	for _, e := range s.file.Entries {
+		if match(e) {
+			if s.master == nil {
+				return nil, ErrLocked
+			}
+			return s.decrypt(e)
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Put 保存账号的密钥，已有条目时覆盖，内容未变化时不写文件
+func (s *Store) Put(in Entry) error {
+	if in.Account == "" {
+		return errors.New("account is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.init(); err != nil {
+		return err
+	}
+	if s.master == nil {
+		return ErrLocked
+	}
+
+	var cur *entry
+	for _, e := range s.file.Entries {
+		if e.Account == in.Account {
+			cur = e
+			break
+		}
+	}
+	if cur != nil {
+		if old, err := s.decrypt(cur); err == nil && old.DataDir == in.DataDir && old.DataKey == in.DataKey && old.ImgKey == in.ImgKey {
+			return nil
+		}
+	}
+
+	e, err := s.encrypt(in)
+	if err != nil {
+		return err
+	}
+	if cur != nil {
+		*cur = *e
+	} else {
+		s.file.Entries = append(s.file.Entries, e)
+	}
+	return s.save()
+}
+
+// Delete 删除账号的密钥
+func (s *Store) Delete(account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return ErrNotFound
+	}
+	for i, e := range s.file.Entries {
+		if e.Account == account {
+			s.file.Entries = append(s.file.Entries[:i], s.file.Entries[i+1:]...)
+			return s.save()
+		}
+	}
+	return ErrNotFound
+}
+
+// Rotate 为账号生成新的数据密钥（DEK）并重新加密该账号的条目
+func (s *Store) Rotate(account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return ErrNotFound
+	}
+	if s.master == nil {
+		return ErrLocked
+	}
+	for i, e := range s.file.Entries {
+		if e.Account != account {
+			continue
+		}
+		plain, err := s.decrypt(e)
+		if err != nil {
+			return err
+		}
+		ne, err := s.encrypt(*plain)
+		if err != nil {
+			return err
+		}
+		s.file.Entries[i] = ne
+		return s.save()
+	}
+	return ErrNotFound
+}
+
+// RotateMaster 生成新的主密钥并按 opts 指定的方式保护，所有条目的 DEK 重新加密
+// 可用于更换口令，或在口令、系统密钥链、本地密钥文件之间切换。
+// 新主密钥保存在另一个槽位，新的 keystore.json 写入成功后才删除旧主密钥
+func (s *Store) RotateMaster(opts Options) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.init(); err != nil {
+		return err
+	}
+	if s.master == nil {
+		return ErrLocked
+	}
+
+	deks := make([][]byte, len(s.file.Entries))
+	for i, e := range s.file.Entries {
+		dek, err := open(s.master, e.DEK, "dek:"+e.Account)
+		if err != nil {
+			return fmt.Errorf("unwrap key of %s: %w", e.Account, err)
+		}
+		deks[i] = dek
+	}
+
+	prev := s.file
+	file, master, err := newMaster(s.dir, nextSlot(prev.Slot), opts)
+	if err != nil {
+		return err
+	}
+	file.Entries = make([]*entry, len(prev.Entries))
+	for i, e := range prev.Entries {
+		ne := *e
+		if ne.DEK, err = seal(master, deks[i], "dek:"+e.Account); err != nil {
+			dropMaster(s.dir, file)
+			return err
+		}
+		file.Entries[i] = &ne
+	}
+
+	if err := s.write(file); err != nil {
+		dropMaster(s.dir, file)
+		return err
+	}
+	s.file, s.master, s.opts = file, master, opts
+	dropMaster(s.dir, prev)
+	return nil
+}
+
+// dropMaster 删除 file 引用的已保存主密钥，口令模式没有需要删除的内容
+func dropMaster(dir string, file *storeFile) {
+	var err error
+	switch file.Mode {
+	case ModeKeyring:
+		err = newKeyring(dir, file.Slot).Delete()
+	case ModeFile:
+		if err = os.Remove(keyFilePath(dir, file.Slot)); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("mode", file.Mode).Msg("remove old keystore master key failed")
+	}
+}
+
+// init 在首次写入时创建密钥库
+func (s *Store) init() error {
+	if s.file != nil {
+		return nil
+	}
+	file, master, err := newMaster(s.dir, "", s.opts)
+	if err != nil {
+		return err
+	}
+	s.file, s.master = file, master
+	return nil
+}
+
+func (s *Store) save() error {
+	return s.write(s.file)
+}
+
+// write 把 file 写入临时文件并同步到磁盘后再替换 keystore.json
+func (s *Store) write(file *storeFile) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// encrypt 使用新生成的 DEK 加密条目
+func (s *Store) encrypt(in Entry) (*entry, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	e := &entry{Account: in.Account, DataDir: in.DataDir, UpdatedAt: time.Now().Unix()}
+	var err error
+	if e.DEK, err = seal(s.master, dek, "dek:"+in.Account); err != nil {
+		return nil, err
+	}
+	if in.DataKey != "" {
+		if e.DataKey, err = seal(dek, []byte(in.DataKey), "data_key:"+in.Account); err != nil {
+			return nil, err
+		}
+	}
+	if in.ImgKey != "" {
+		if e.ImgKey, err = seal(dek, []byte(in.ImgKey), "img_key:"+in.Account); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (s *Store) decrypt(e *entry) (*Entry, error) {
+	dek, err := open(s.master, e.DEK, "dek:"+e.Account)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key of %s: %w", e.Account, err)
+	}
+	out := &Entry{Account: e.Account, DataDir: e.DataDir, UpdatedAt: time.Unix(e.UpdatedAt, 0)}
+	if e.DataKey != "" {
+		b, err := open(dek, e.DataKey, "data_key:"+e.Account)
+		if err != nil {
+			return nil, err
+		}
+		out.DataKey = string(b)
+	}
+	if e.ImgKey != "" {
+		b, err := open(dek, e.ImgKey, "img_key:"+e.Account)
+		if err != nil {
+			return nil, err
+		}
+		out.ImgKey = string(b)
+	}
+	return out, nil
+}
+
+// newMaster 生成新的主密钥并按 opts 保存到 slot 槽位，返回尚未写入条目的文件头
+func newMaster(dir, slot string, opts Options) (*storeFile, []byte, error) {
+	mode := opts.Mode
+	if mode == "" {
+		switch {
+		case opts.Passphrase != "":
+			mode = ModePassphrase
+		case newKeyring(dir, slot).Available():
+			mode = ModeKeyring
+		default:
+			mode = ModeFile
+		}
+	}
+
+	file := &storeFile{Version: fileVersion, Mode: mode}
+	var master []byte
+	switch mode {
+	case ModePassphrase:
+		if opts.Passphrase == "" {
+			return nil, nil, errors.New("passphrase is required")
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, nil, err
+		}
+		file.Salt = base64.StdEncoding.EncodeToString(salt)
+		var err error
+		if master, err = deriveKey(opts.Passphrase, salt); err != nil {
+			return nil, nil, err
+		}
+	case ModeKeyring, ModeFile:
+		master = make([]byte, keySize)
+		if _, err := rand.Read(master); err != nil {
+			return nil, nil, err
+		}
+		var err error
+		if mode == ModeKeyring {
+			err = newKeyring(dir, slot).Set(master)
+		} else {
+			err = writeKeyFile(dir, slot, master)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("save keystore master key: %w", err)
+		}
+		file.Slot = slot
+	default:
+		return nil, nil, fmt.Errorf("unknown keystore mode %q", mode)
+	}
+
+	check, err := seal(master, []byte(checkText), "check")
+	if err != nil {
+		return nil, nil, err
+	}
+	file.Check = check
+	return file, master, nil
+}
+
+// loadMaster 按文件记录的方式取回主密钥
+func loadMaster(dir string, file *storeFile, passphrase string) ([]byte, error) {
+	switch file.Mode {
+	case ModePassphrase:
+		if passphrase == "" {
+			return nil, ErrLocked
+		}
+		salt, err := base64.StdEncoding.DecodeString(file.Salt)
+		if err != nil {
+			return nil, err
+		}
+		return deriveKey(passphrase, salt)
+	case ModeKeyring:
+		return newKeyring(dir, file.Slot).Get()
+	case ModeFile:
+		return readKeyFile(dir, file.Slot)
+	default:
+		return nil, fmt.Errorf("unknown keystore mode %q", file.Mode)
+	}
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+}
+
+// seal 使用 AES-256-GCM 加密，label 作为附加数据，防止密文在条目或字段之间被挪用
+func seal(key, plain []byte, label string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plain, []byte(label))), nil
+}
+
+func open(key []byte, sealed, label string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(label))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// redactedPrefix 为脱敏后密钥的固定前缀
+const redactedPrefix = "********"
+
+// Redact 隐藏密钥内容，只保留末尾 4 个字符便于核对，用于 API 响应和日志
+func Redact(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 8 {
+		return redactedPrefix
+	}
+	return redactedPrefix + key[len(key)-4:]
+}
+
+// IsRedacted 判断 key 是否为 Redact 的输出，客户端把读取到的设置原样提交时不能当作新密钥保存
+func IsRedacted(key string) bool {
+	return strings.HasPrefix(strings.TrimSpace(key), redactedPrefix)
+}
@@ -0,0 +1,206 @@
+package keystore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testDataKey = "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"
+	testImgKey  = "0123456789abcdef0123456789abcdef"
+)
+
+func TestFileMode(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open(dir, Options{Mode: ModeFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ks.Mode() != "" || ks.Locked() {
+		t.Fatalf("new store: mode = %q, locked = %v", ks.Mode(), ks.Locked())
+	}
+	if err := ks.Put(Entry{Account: "wxid_a", DataDir: "/data/a", DataKey: testDataKey, ImgKey: testImgKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 文件中不能出现明文密钥
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), testDataKey) || strings.Contains(string(data), testImgKey) {
+		t.Fatalf("keystore contains plaintext keys: %s", data)
+	}
+
+	ks, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := ks.Lookup("/data/a/")
+	if err != nil || e.Account != "wxid_a" || e.DataKey != testDataKey || e.ImgKey != testImgKey {
+		t.Fatalf("lookup = %+v, %v", e, err)
+	}
+	if _, err := ks.Get("wxid_b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing account err = %v", err)
+	}
+	if list := ks.List(); len(list) != 1 || !list[0].HasDataKey || !list[0].HasImgKey {
+		t.Fatalf("list = %+v", list)
+	}
+}
+
+func TestPassphraseMode(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open(dir, Options{Passphrase: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put(Entry{Account: "wxid_a", DataKey: testDataKey}); err != nil {
+		t.Fatal(err)
+	}
+	if ks.Mode() != ModePassphrase {
+		t.Fatalf("mode = %q", ks.Mode())
+	}
+
+	// 未提供口令时处于锁定状态，仍可列出条目
+	locked, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !locked.Locked() || len(locked.List()) != 1 {
+		t.Fatalf("locked = %v, list = %+v", locked.Locked(), locked.List())
+	}
+	if _, err := locked.Get("wxid_a"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("get while locked err = %v", err)
+	}
+	if err := locked.Put(Entry{Account: "wxid_b", DataKey: testDataKey}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("put while locked err = %v", err)
+	}
+	if err := locked.Unlock("wrong"); !errors.Is(err, ErrBadPassphrase) {
+		t.Fatalf("wrong passphrase err = %v", err)
+	}
+	if err := locked.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := locked.Get("wxid_a"); err != nil || e.DataKey != testDataKey || e.ImgKey != "" {
+		t.Fatalf("get = %+v, %v", e, err)
+	}
+
+	if _, err := Open(dir, Options{Passphrase: "wrong"}); !errors.Is(err, ErrBadPassphrase) {
+		t.Fatalf("open with wrong passphrase err = %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open(dir, Options{Mode: ModeFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Put(Entry{Account: "wxid_a", DataKey: testDataKey})
+	ks.Put(Entry{Account: "wxid_b", ImgKey: testImgKey})
+
+	before := ks.file.Entries[0].DEK
+	if err := ks.Rotate("wxid_a"); err != nil {
+		t.Fatal(err)
+	}
+	if ks.file.Entries[0].DEK == before {
+		t.Fatal("rotate kept the old DEK")
+	}
+	if err := ks.Rotate("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("rotate missing err = %v", err)
+	}
+
+	// 切换到口令保护后旧的密钥文件被删除，条目仍可读取
+	if err := ks.RotateMaster(Options{Mode: ModePassphrase, Passphrase: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, keyFileName)); !os.IsNotExist(err) {
+		t.Fatalf("key file still exists: %v", err)
+	}
+	reopened, err := Open(dir, Options{Passphrase: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := reopened.Get("wxid_a")
+	if err != nil || a.DataKey != testDataKey {
+		t.Fatalf("wxid_a = %+v, %v", a, err)
+	}
+	b, err := reopened.Get("wxid_b")
+	if err != nil || b.ImgKey != testImgKey {
+		t.Fatalf("wxid_b = %+v, %v", b, err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	if got := Redact(testDataKey); got != "********eeff" {
+		t.Fatalf("redact = %q", got)
+	}
+	if Redact("") != "" || Redact("abc") != "********" {
+		t.Fatal("unexpected redaction of short keys")
+	}
+	if !IsRedacted(Redact(testDataKey)) || !IsRedacted(Redact("abc")) || IsRedacted(testDataKey) || IsRedacted("") {
+		t.Fatal("unexpected redaction detection")
+	}
+}
+
+func TestRotateMasterKeepsOldMasterUntilSaved(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := Open(dir, Options{Mode: ModeFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put(Entry{Account: "wxid_a", DataKey: testDataKey}); err != nil {
+		t.Fatal(err)
+	}
+	oldKey := filepath.Join(dir, keyFileName)
+	newKey := filepath.Join(dir, "keystore.alt.key")
+
+	// keystore.json 写入失败时旧主密钥不受影响，新槽位被清理
+	if err := os.Mkdir(filepath.Join(dir, FileName+".tmp"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.RotateMaster(Options{Mode: ModeFile}); err == nil {
+		t.Fatal("expected rotate to fail while keystore.json cannot be written")
+	}
+	if _, err := os.Stat(newKey); !os.IsNotExist(err) {
+		t.Fatalf("new master left behind: %v", err)
+	}
+	for _, store := range []*Store{ks, mustOpen(t, dir)} {
+		if e, err := store.Get("wxid_a"); err != nil || e.DataKey != testDataKey {
+			t.Fatalf("after failed rotate = %+v, %v", e, err)
+		}
+	}
+
+	// 成功后切换到另一个槽位并删除旧主密钥，再次轮换时回到默认槽位
+	os.RemoveAll(filepath.Join(dir, FileName+".tmp"))
+	if err := ks.RotateMaster(Options{Mode: ModeFile}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(oldKey); !os.IsNotExist(err) {
+		t.Fatalf("old master still exists: %v", err)
+	}
+	if e, err := mustOpen(t, dir).Get("wxid_a"); err != nil || e.DataKey != testDataKey {
+		t.Fatalf("after rotate = %+v, %v", e, err)
+	}
+	if err := ks.RotateMaster(Options{Mode: ModeFile}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(newKey); !os.IsNotExist(err) {
+		t.Fatalf("alternate master still exists: %v", err)
+	}
+	if e, err := mustOpen(t, dir).Get("wxid_a"); err != nil || e.DataKey != testDataKey {
+		t.Fatalf("after second rotate = %+v, %v", e, err)
+	}
+}
+
+func mustOpen(t *testing.T, dir string) *Store {
+	t.Helper()
+	ks, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
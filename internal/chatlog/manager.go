@@ -2,9 +2,12 @@ package chatlog
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -15,10 +18,15 @@ import (
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/ctx"
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/database"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/export"
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/http"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/media"
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/mirror"
 	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/wechat"
 	"github.com/takeaway1/chatlog-TCOTC/internal/tray"
 	iwechat "github.com/takeaway1/chatlog-TCOTC/internal/wechat"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/config"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
@@ -474,6 +482,14 @@ func (m *Manager) StopAutoDecrypt() error {
 	return nil
 }
 
+// LastDecryptPass 返回最近一次解密处理的页数统计，尚未解密时为 nil
+func (m *Manager) LastDecryptPass() *wechat.DecryptPass {
+	if m.wechat == nil {
+		return nil
+	}
+	return m.wechat.LastDecryptPass()
+}
+
 func (m *Manager) SaveSpeechConfig(cfg *conf.SpeechConfig) error {
 	if cfg == nil {
 		return fmt.Errorf("speech config is nil")
@@ -569,6 +585,33 @@ func (m *Manager) CommandKey(configPath string, pid int, force bool, showXorKey
 	return "", fmt.Errorf("wechat process not found")
 }
 
+// CommandKeyFromDump 从内存转储文件中离线提取密钥，不依赖正在运行的微信进程
+// version 为 0 时根据数据目录结构判断
+func (m *Manager) CommandKeyFromDump(dumpPath, dataDir, platform string, version int, showXorKey bool) (string, error) {
+	if len(dataDir) == 0 {
+		return "", fmt.Errorf("dataDir is required")
+	}
+	if version == 0 {
+		version = 3
+		if _, err := os.Stat(filepath.Join(dataDir, "db_storage")); err == nil {
+			version = 4
+		}
+	}
+
+	key, imgKey, err := iwechat.GetKeyFromDump(context.Background(), dumpPath, dataDir, platform, version)
+	if err != nil {
+		return "", err
+	}
+
+	result := fmt.Sprintf("Data Key: [%s]\nImage Key: [%s]", key, imgKey)
+	if version == 4 && showXorKey {
+		if b, err := dat2img.ScanAndSetXorKey(dataDir); err == nil {
+			result += fmt.Sprintf("\nXor Key: [0x%X]", b)
+		}
+	}
+	return result, nil
+}
+
 func (m *Manager) CommandDecrypt(configPath string, cmdConf map[string]any) error {
 
 	var err error
@@ -604,6 +647,10 @@ func (m *Manager) CommandHTTPServer(configPath string, cmdConf map[string]any) e
 		return err
 	}
 
+	if m.sc.GetSnapshot() != "" {
+		return m.serveSnapshot()
+	}
+
 	dataDir := m.sc.GetDataDir()
 	workDir := m.sc.GetWorkDir()
 	if len(dataDir) == 0 && len(workDir) == 0 {
@@ -630,6 +677,10 @@ func (m *Manager) CommandHTTPServer(configPath string, cmdConf map[string]any) e
 
 	m.http = http.NewService(m.sc, m.db, m)
 
+	if err := m.mountAccounts(); err != nil {
+		return err
+	}
+
 	if m.sc.GetAutoDecrypt() {
 		if err := m.wechat.StartAutoDecrypt(); err != nil {
 			return err
@@ -669,3 +720,207 @@ func (m *Manager) CommandHTTPServer(configPath string, cmdConf map[string]any) e
 
 	return m.http.ListenAndServe()
 }
+
+// serveSnapshot 只读提供一个已解密的工作目录，不依赖微信进程与密钥。
+// 数据源类型根据快照中的数据库文件识别，索引与缓存写入单独的工作目录。
+func (m *Manager) serveSnapshot() error {
+	snapshot, err := filepath.Abs(m.sc.GetSnapshot())
+	if err != nil {
+		return err
+	}
+	flavor, err := datasource.Detect(snapshot)
+	if err != nil {
+		return err
+	}
+	m.sc.Snapshot = snapshot
+	m.sc.Platform, m.sc.Version = datasource.FlavorPlatform(flavor)
+	m.sc.AutoDecrypt = false
+	if m.sc.WorkDir == "" {
+		sum := sha1.Sum([]byte(snapshot))
+		m.sc.WorkDir = util.DefaultWorkDir(filepath.Join("snapshots", filepath.Base(snapshot)+"-"+hex.EncodeToString(sum[:4])))
+	}
+	if err := os.MkdirAll(m.sc.WorkDir, 0o755); err != nil {
+		return err
+	}
+	if m.sc.GetVersion() == 4 && m.sc.GetDataDir() != "" && m.sc.GetImgKey() != "" {
+		dat2img.SetAesKey(m.sc.GetImgKey())
+		go dat2img.ScanAndSetXorKey(m.sc.GetDataDir())
+	}
+	log.Info().Str("snapshot", snapshot).Str("flavor", flavor).Str("work_dir", m.sc.WorkDir).Msg("serving read-only snapshot")
+
+	m.db = database.NewService(m.sc)
+	// 快照模式没有解密与微信相关的操作，对应的管理接口返回 503
+	m.http = http.NewService(m.sc, m.db, nil)
+	if err := m.mountAccounts(); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := m.db.Start(); err != nil {
+			log.Err(err).Msg("open snapshot failed")
+			m.db.SetError(err.Error())
+		}
+	}()
+
+	return m.http.ListenAndServe()
+}
+
+// mountAccounts 为配置中的额外账号各自打开数据库并挂载到 HTTP 服务。
+// 额外账号只读取已解密的工作目录，不参与自动解密。
+func (m *Manager) mountAccounts() error {
+	accounts, err := m.sc.GetAccounts()
+	if err != nil {
+		return err
+	}
+	for _, acct := range accounts {
+		db := database.NewService(acct)
+		svc := http.NewService(acct, db, nil)
+		if acct.GetVersion() == 4 {
+			// 每个账号使用自己的图片密钥，不修改进程全局的密钥
+			keys, err := dat2img.NewKeys(acct.GetImgKey())
+			if err != nil {
+				return fmt.Errorf("account %s: %w", acct.ID, err)
+			}
+			if acct.GetImgKey() == "" {
+				log.Warn().Str("account", acct.ID).Msg("no image key in keystore for this data dir, v4 images of this account may fail to decode")
+			}
+			if dataDir := acct.GetDataDir(); dataDir != "" {
+				go keys.ScanXorKey(dataDir)
+			}
+			svc.SetImageKeys(keys)
+		}
+		if err := m.http.MountAccount(acct.ID, acct.Name, svc); err != nil {
+			return err
+		}
+		go func(acct *conf.AccountConfig) {
+			if err := db.Start(); err != nil {
+				log.Err(err).Str("account", acct.ID).Msg("start account db failed")
+				db.SetError(err.Error())
+				return
+			}
+			log.Info().Str("account", acct.ID).Str("work_dir", acct.GetWorkDir()).Msg("account mounted")
+		}(acct)
+	}
+	return nil
+}
+
+func (m *Manager) CommandExport(configPath string, cmdConf map[string]any, opts export.Options) (*export.Summary, error) {
+	db, err := m.openCommandDB(configPath, cmdConf)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts.DataDir = m.sc.GetDataDir()
+	return export.Run(ctx, db, opts)
+}
+
+// CommandSync 将消息、联系人、群聊同步到 SQL 镜像库；mirrorCfg 为 nil 时使用配置文件中的 mirror
+func (m *Manager) CommandSync(configPath string, cmdConf map[string]any, mirrorCfg *conf.MirrorConfig) (*mirror.Summary, error) {
+	db, err := m.openCommandDB(configPath, cmdConf)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if mirrorCfg == nil {
+		mirrorCfg = m.sc.GetMirror()
+	}
+	if mirrorCfg == nil || mirrorCfg.DSN == "" {
+		return nil, fmt.Errorf("mirror dsn is required")
+	}
+	mr, err := mirror.Open(mirrorCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer mr.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return mr.Sync(ctx, db)
+}
+
+// CommandMediaDecode 将数据目录中的 .dat 图片批量解码到工作目录的内容寻址缓存，返回进度与部分失败记录
+func (m *Manager) CommandMediaDecode(configPath string, cmdConf map[string]any, opts media.DecodeOptions, report func(media.DecodeProgress)) (media.DecodeProgress, []media.Failure, error) {
+	if err := m.prepareCommand(configPath, cmdConf); err != nil {
+		return media.DecodeProgress{}, nil, err
+	}
+	dataDir := m.sc.GetDataDir()
+	if len(dataDir) == 0 {
+		return media.DecodeProgress{}, nil, fmt.Errorf("dataDir is required")
+	}
+
+	cache, err := media.OpenDecodedCache(filepath.Join(m.sc.GetWorkDir(), media.CacheDirName), nil)
+	if err != nil {
+		return media.DecodeProgress{}, nil, err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	p, err := media.RunDecode(ctx, dataDir, cache, opts, report)
+	return p, cache.Failures(20), err
+}
+
+// CommandMCPStdio 通过标准输入输出提供 MCP 服务，stdout 专用于协议数据，日志需输出到 stderr
+func (m *Manager) CommandMCPStdio(configPath string, cmdConf map[string]any) error {
+	if err := m.prepareCommand(configPath, cmdConf); err != nil {
+		return err
+	}
+
+	m.db = database.NewService(m.sc)
+	if err := m.db.Start(); err != nil {
+		return err
+	}
+	defer m.db.Stop()
+
+	m.http = http.NewService(m.sc, m.db, m)
+	return m.http.ServeStdio()
+}
+
+// openCommandDB 为一次性命令加载配置并打开数据库，工作目录为空时先解密
+func (m *Manager) openCommandDB(configPath string, cmdConf map[string]any) (*wechatdb.DB, error) {
+	if err := m.prepareCommand(configPath, cmdConf); err != nil {
+		return nil, err
+	}
+
+	// 导出与同步只按时间读取消息，不需要全文索引
+	return wechatdb.NewWithoutIndex(m.sc.GetWorkDir(), m.sc.GetPlatform(), m.sc.GetVersion())
+}
+
+// prepareCommand 为一次性命令加载配置，工作目录为空时先解密
+func (m *Manager) prepareCommand(configPath string, cmdConf map[string]any) error {
+
+	var err error
+	m.sc, m.scm, err = conf.LoadServiceConfig(configPath, cmdConf)
+	if err != nil {
+		return err
+	}
+
+	dataDir := m.sc.GetDataDir()
+	workDir := m.sc.GetWorkDir()
+	if len(workDir) == 0 {
+		return fmt.Errorf("workDir is required")
+	}
+
+	// 工作目录为空时先解密
+	if entries, err := os.ReadDir(workDir); err != nil || len(entries) == 0 {
+		if len(dataDir) == 0 || len(m.sc.GetDataKey()) == 0 {
+			return fmt.Errorf("work dir is empty, dataDir and dataKey are required to decrypt")
+		}
+		m.wechat = wechat.NewService(m.sc)
+		if err := m.wechat.DecryptDBFiles(); err != nil {
+			return err
+		}
+	}
+
+	if m.sc.GetVersion() == 4 && len(dataDir) != 0 {
+		dat2img.SetAesKey(m.sc.GetImgKey())
+		dat2img.ScanAndSetXorKey(dataDir)
+	}
+	return nil
+}
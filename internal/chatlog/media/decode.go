@@ -0,0 +1,330 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
+)
+
+const (
+	decodedDir   = "decoded"
+	manifestName = "manifest.json"
+
+	// manifestCheckInterval 为检查 manifest 是否被其他进程更新的最小间隔
+	manifestCheckInterval = 5 * time.Second
+)
+
+// 解码失败原因
+const (
+	FailUnknownFormat = "unknown_format" // 解密后无法识别图片格式
+	FailMissingKey    = "missing_key"    // 微信 4.0 图片缺少 AES 密钥
+	FailReadError     = "read_error"     // 无法读取源文件或写入缓存
+)
+
+// DecodedEntry 记录一个 .dat 源文件的解码结果
+type DecodedEntry struct {
+	Hash    string `json:"hash,omitempty"` // 解码后内容的 SHA-256
+	Ext     string `json:"ext,omitempty"`
+	Size    int64  `json:"size"`     // 源文件大小
+	ModTime int64  `json:"mod_time"` // 源文件修改时间（UnixNano）
+	Error   string `json:"error,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// Manifest 以数据目录相对路径（/ 分隔）索引全部解码记录
+type Manifest struct {
+	UpdatedAt time.Time                `json:"updated_at"`
+	Entries   map[string]*DecodedEntry `json:"entries"`
+}
+
+// DecodedStats 汇总 manifest 中的解码结果
+type DecodedStats struct {
+	Total     int            `json:"total"`
+	Decoded   int            `json:"decoded"`
+	Failed    int            `json:"failed"`
+	Reasons   map[string]int `json:"reasons,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// DecodedCache 是按内容寻址的 .dat 图片解码缓存：
+// 解码结果保存为 <dir>/<hash[:2]>/<hash>.<ext>，manifest.json 记录源文件到内容的映射，
+// 相同的图片（原图与转发副本）只存一份
+type DecodedCache struct {
+	dir  string
+	keys *dat2img.Keys
+
+	mu        sync.RWMutex
+	manifest  *Manifest
+	loadedAt  time.Time // 已加载 manifest 文件的修改时间，其他进程更新后自动重新加载
+	checkedAt time.Time // 最近一次检查 manifest 文件的时间
+}
+
+// OpenDecodedCache 打开 cacheDir/decoded 下的解码缓存，keys 为 nil 时使用进程全局的图片密钥
+func OpenDecodedCache(cacheDir string, keys *dat2img.Keys) (*DecodedCache, error) {
+	c := &DecodedCache{dir: filepath.Join(cacheDir, decodedDir), keys: keys}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return nil, err
+	}
+	c.manifest = &Manifest{Entries: make(map[string]*DecodedEntry)}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *DecodedCache) manifestPath() string {
+	return filepath.Join(c.dir, manifestName)
+}
+
+// refresh 每隔 manifestCheckInterval 检查一次 manifest 文件，避免每个请求都访问磁盘
+func (c *DecodedCache) refresh() {
+	c.mu.Lock()
+	due := time.Since(c.checkedAt) >= manifestCheckInterval
+	if due {
+		c.checkedAt = time.Now()
+	}
+	c.mu.Unlock()
+	if due {
+		_ = c.reload()
+	}
+}
+
+// reload 在 manifest 文件比内存中的新时重新加载
+func (c *DecodedCache) reload() error {
+	info, err := os.Stat(c.manifestPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	c.mu.RLock()
+	fresh := !info.ModTime().After(c.loadedAt)
+	c.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	raw, err := os.ReadFile(c.manifestPath())
+	if err != nil {
+		return err
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return err
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]*DecodedEntry)
+	}
+
+	c.mu.Lock()
+	c.manifest = &m
+	c.loadedAt = info.ModTime()
+	c.mu.Unlock()
+	return nil
+}
+
+// Save 写入 manifest
+func (c *DecodedCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.manifest.UpdatedAt = time.Now()
+	raw, err := json.Marshal(c.manifest)
+	if err != nil {
+		return err
+	}
+	if err := writeAtomic(c.manifestPath(), raw); err != nil {
+		return err
+	}
+	if info, err := os.Stat(c.manifestPath()); err == nil {
+		c.loadedAt = info.ModTime()
+	}
+	return nil
+}
+
+// Lookup 返回 rel 对应的解码文件路径与扩展名；源文件变化或未解码成功时 ok 为 false
+func (c *DecodedCache) Lookup(rel string, info os.FileInfo) (path string, ext string, ok bool) {
+	c.refresh()
+
+	c.mu.RLock()
+	e := c.manifest.Entries[filepath.ToSlash(rel)]
+	c.mu.RUnlock()
+	if e == nil || e.Hash == "" || !e.matches(info) {
+		return "", "", false
+	}
+	path = c.blobPath(e.Hash, e.Ext)
+	if _, err := os.Stat(path); err != nil {
+		return "", "", false
+	}
+	return path, e.Ext, true
+}
+
+// Entry 返回 rel 的解码记录
+func (c *DecodedCache) Entry(rel string) *DecodedEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if e := c.manifest.Entries[filepath.ToSlash(rel)]; e != nil {
+		cp := *e
+		return &cp
+	}
+	return nil
+}
+
+// Failure 是一条解码失败记录
+type Failure struct {
+	Path   string `json:"path"`
+	Error  string `json:"error"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Failures 返回按路径排序的失败记录，limit <= 0 时返回全部
+func (c *DecodedCache) Failures(limit int) []Failure {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var out []Failure
+	for _, rel := range sortedKeys(c.manifest.Entries) {
+		e := c.manifest.Entries[rel]
+		if e.Error == "" {
+			continue
+		}
+		out = append(out, Failure{Path: rel, Error: e.Error, Detail: e.Detail})
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// Stats 汇总解码结果
+func (c *DecodedCache) Stats() DecodedStats {
+	c.refresh()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	stats := DecodedStats{Total: len(c.manifest.Entries), UpdatedAt: c.manifest.UpdatedAt}
+	for _, e := range c.manifest.Entries {
+		if e.Error == "" {
+			stats.Decoded++
+			continue
+		}
+		stats.Failed++
+		if stats.Reasons == nil {
+			stats.Reasons = make(map[string]int)
+		}
+		stats.Reasons[e.Error]++
+	}
+	return stats
+}
+
+// Decode 解码 dataDir 下的 rel 并写入缓存，失败原因记录在返回的 entry 中
+func (c *DecodedCache) Decode(dataDir, rel string) *DecodedEntry {
+	rel = filepath.ToSlash(rel)
+	e := &DecodedEntry{}
+	defer func() {
+		c.mu.Lock()
+		c.manifest.Entries[rel] = e
+		c.mu.Unlock()
+	}()
+
+	path := filepath.Join(dataDir, filepath.FromSlash(rel))
+	info, err := os.Stat(path)
+	if err != nil {
+		e.Error, e.Detail = FailReadError, err.Error()
+		return e
+	}
+	e.Size, e.ModTime = info.Size(), info.ModTime().UnixNano()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		e.Error, e.Detail = FailReadError, err.Error()
+		return e
+	}
+	out, ext, err := c.keys.Dat2Image(data)
+	if err != nil {
+		e.Error, e.Detail = classify(data, c.keys), err.Error()
+		return e
+	}
+
+	sum := sha256.Sum256(out)
+	e.Hash, e.Ext = hex.EncodeToString(sum[:]), ext
+	blob := c.blobPath(e.Hash, e.Ext)
+	if _, err := os.Stat(blob); err != nil {
+		if err := writeAtomic(blob, out); err != nil {
+			e.Hash, e.Ext = "", ""
+			e.Error, e.Detail = FailReadError, err.Error()
+		}
+	}
+	return e
+}
+
+// Fresh 判断 rel 是否已成功解码且源文件未变化
+func (c *DecodedCache) Fresh(rel string, info os.FileInfo) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e := c.manifest.Entries[filepath.ToSlash(rel)]
+	return e != nil && e.Hash != "" && e.matches(info)
+}
+
+// Prune 删除 keep 之外的记录，用于清理已从数据目录删除的源文件；
+// 不再被任何记录引用的解码文件一并删除
+func (c *DecodedCache) Prune(keep map[string]bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	orphans := make(map[string]string)
+	for rel, e := range c.manifest.Entries {
+		if !keep[rel] {
+			delete(c.manifest.Entries, rel)
+			removed++
+			if e.Hash != "" {
+				orphans[e.Hash] = e.Ext
+			}
+		}
+	}
+	for _, e := range c.manifest.Entries {
+		delete(orphans, e.Hash)
+	}
+	for hash, ext := range orphans {
+		if err := os.Remove(c.blobPath(hash, ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Debug().Err(err).Str("hash", hash).Msg("remove decoded blob failed")
+		}
+	}
+	return removed
+}
+
+func (c *DecodedCache) blobPath(hash, ext string) string {
+	return filepath.Join(c.dir, hash[:2], hash+"."+ext)
+}
+
+func (e *DecodedEntry) matches(info os.FileInfo) bool {
+	return info != nil && e.Size == info.Size() && e.ModTime == info.ModTime().UnixNano()
+}
+
+// classify 判断解码失败的原因
+func classify(data []byte, keys *dat2img.Keys) string {
+	if dat2img.IsV4Encrypted(data) && !keys.HasAesKey() {
+		return FailMissingKey
+	}
+	return FailUnknownFormat
+}
+
+// sortedKeys 返回 map 的有序 key，便于输出稳定的结果
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,195 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// 每解码多少个文件保存一次 manifest，任务中断时已完成的部分不会丢失
+const manifestSaveInterval = 500
+
+// AttachRoots 是数据目录中存放图片附件的目录：微信 4.0 的 msg/attach 与 3.x 的 FileStorage/MsgAttach
+var AttachRoots = []string{
+	filepath.Join("msg", "attach"),
+	filepath.Join("FileStorage", "MsgAttach"),
+}
+
+// ErrDecodeRunning 表示已有解码任务在运行
+var ErrDecodeRunning = errors.New("media decode job already running")
+
+// DecodeOptions 控制批量解码任务
+type DecodeOptions struct {
+	Force bool // 忽略已有结果，全部重新解码
+}
+
+// DecodeProgress 报告当前（或最近一次）解码任务的状态
+type DecodeProgress struct {
+	Running    bool      `json:"running"`
+	Total      int       `json:"total"`
+	Done       int       `json:"done"`
+	Decoded    int       `json:"decoded"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	Current    string    `json:"current,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// DecodeRunner 同一时间最多运行一个批量解码任务
+type DecodeRunner struct {
+	mu       sync.Mutex
+	progress DecodeProgress
+	cancel   context.CancelFunc
+}
+
+// Start 在后台解码 dataDir 中的全部 .dat 图片
+func (r *DecodeRunner) Start(dataDir string, cache *DecodedCache, opts DecodeOptions) error {
+	if dataDir == "" || cache == nil {
+		return errors.New("media decoding not available")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.progress.Running {
+		return ErrDecodeRunning
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.progress = DecodeProgress{Running: true, StartedAt: time.Now()}
+
+	go func() {
+		defer cancel()
+		_, err := RunDecode(ctx, dataDir, cache, opts, func(p DecodeProgress) {
+			r.mu.Lock()
+			p.Running = true
+			p.StartedAt = r.progress.StartedAt
+			r.progress = p
+			r.mu.Unlock()
+		})
+		r.mu.Lock()
+		r.progress.Running = false
+		r.progress.Current = ""
+		r.progress.FinishedAt = time.Now()
+		if err != nil {
+			r.progress.LastError = err.Error()
+		}
+		p := r.progress
+		r.mu.Unlock()
+		if err != nil {
+			log.Warn().Err(err).Msg("media decode job stopped")
+			return
+		}
+		log.Info().Int("total", p.Total).Int("decoded", p.Decoded).Int("failed", p.Failed).Msg("media decode job finished")
+	}()
+	return nil
+}
+
+// Cancel 停止正在运行的任务
+func (r *DecodeRunner) Cancel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+// Progress 返回当前（或最近一次）任务的快照
+func (r *DecodeRunner) Progress() DecodeProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.progress
+}
+
+// RunDecode 同步解码 dataDir 下附件目录中的全部 .dat 文件，每处理一个文件回调一次 report。
+// 已成功解码且源文件未变化的文件会被跳过；失败的文件每次都会重试，以便补上密钥后重新解码
+func RunDecode(ctx context.Context, dataDir string, cache *DecodedCache, opts DecodeOptions, report func(DecodeProgress)) (DecodeProgress, error) {
+	var p DecodeProgress
+	if report == nil {
+		report = func(DecodeProgress) {}
+	}
+
+	// 先收集全部文件，便于报告总数
+	var files []string
+	for _, root := range AttachRoots {
+		base := filepath.Join(dataDir, root)
+		if _, err := os.Stat(base); err != nil {
+			continue
+		}
+		err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if d.IsDir() || !strings.EqualFold(filepath.Ext(d.Name()), ".dat") {
+				return nil
+			}
+			if rel, err := filepath.Rel(dataDir, path); err == nil {
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if err != nil {
+			return p, err
+		}
+	}
+	p.Total = len(files)
+	report(p)
+
+	defer func() {
+		if err := cache.Save(); err != nil {
+			log.Warn().Err(err).Msg("save decoded media manifest failed")
+		}
+	}()
+
+	keep := make(map[string]bool, len(files))
+	for i, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return p, err
+		}
+		keep[rel] = true
+		p.Current = rel
+
+		info, err := os.Stat(filepath.Join(dataDir, filepath.FromSlash(rel)))
+		if err == nil && !opts.Force && cache.Fresh(rel, info) {
+			p.Done++
+			p.Skipped++
+			report(p)
+			continue
+		}
+
+		e := cache.Decode(dataDir, rel)
+		p.Done++
+		if e.Error != "" {
+			p.Failed++
+			p.LastError = rel + ": " + e.Error
+			log.Debug().Str("path", rel).Str("reason", e.Error).Str("detail", e.Detail).Msg("decode media failed")
+		} else {
+			p.Decoded++
+		}
+		report(p)
+
+		if (i+1)%manifestSaveInterval == 0 {
+			if err := cache.Save(); err != nil {
+				log.Warn().Err(err).Msg("save decoded media manifest failed")
+			}
+		}
+	}
+
+	// 完整扫描后清理已不存在的源文件
+	cache.Prune(keep)
+	p.Current = ""
+	return p, nil
+}
@@ -0,0 +1,192 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+
+	"github.com/Eyevinn/mp4ff/hevc"
+	"github.com/Eyevinn/mp4ff/mp4"
+
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
+)
+
+// Info 描述媒体文件的格式、编码、尺寸与时长
+type Info struct {
+	Format     string  `json:"format"`
+	MIME       string  `json:"mime"`
+	Codec      string  `json:"codec,omitempty"`
+	AudioCodec string  `json:"audio_codec,omitempty"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	Duration   float64 `json:"duration,omitempty"` // 秒
+	Size       int64   `json:"size"`
+	Playable   bool    `json:"playable"` // 浏览器无需转码即可显示或播放
+}
+
+var mimeTypes = map[string]string{
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"bmp":  "image/bmp",
+	"tiff": "image/tiff",
+	"mp4":  "video/mp4",
+	"wxgf": "application/octet-stream",
+	"hevc": "video/hevc",
+}
+
+// MIME 返回格式对应的 Content-Type
+func MIME(format string) string {
+	if t, ok := mimeTypes[format]; ok {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// Probe 识别 path 的格式并读取编码、尺寸与时长，.dat 文件会先解密
+func (p *Pipeline) Probe(path string) (*Info, error) {
+	src, err := p.open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Format: src.format, MIME: MIME(src.format), Size: src.info.Size()}
+	switch src.format {
+	case "jpg", "png", "gif":
+		data, err := src.bytes()
+		if err != nil {
+			return nil, err
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		info.Codec = src.format
+		info.Width, info.Height = cfg.Width, cfg.Height
+		info.Playable = true
+	case "bmp":
+		data, err := src.bytes()
+		if err != nil {
+			return nil, err
+		}
+		info.Codec = src.format
+		info.Playable = true
+		if len(data) >= 26 {
+			info.Width = int(int32(binary.LittleEndian.Uint32(data[18:])))
+			info.Height = abs(int(int32(binary.LittleEndian.Uint32(data[22:]))))
+		}
+	case "wxgf", "hevc":
+		data, err := src.bytes()
+		if err != nil {
+			return nil, err
+		}
+		if src.format == "wxgf" {
+			if data, err = dat2img.WxgfStream(data); err != nil {
+				return nil, err
+			}
+		}
+		info.Codec = "hevc"
+		info.Width, info.Height = hevcSize(data)
+	case "mp4":
+		var r io.ReadSeeker
+		if src.data != nil {
+			r = bytes.NewReader(src.data)
+		} else {
+			f, err := os.Open(src.path)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		if err := probeMP4(r, info); err != nil {
+			return nil, err
+		}
+		info.Playable = info.Codec == "h264" || info.Codec == ""
+	default:
+		return info, ErrUnsupported
+	}
+	return info, nil
+}
+
+// probeMP4 从 moov 中读取时长与各轨道的编码
+func probeMP4(r io.ReadSeeker, info *Info) error {
+	f, err := mp4.DecodeFile(r, mp4.WithDecodeMode(mp4.DecModeLazyMdat))
+	if err != nil {
+		return err
+	}
+	if f.Moov == nil {
+		return nil
+	}
+	if mvhd := f.Moov.Mvhd; mvhd != nil && mvhd.Timescale > 0 {
+		info.Duration = float64(mvhd.Duration) / float64(mvhd.Timescale)
+	}
+	for _, trak := range f.Moov.Traks {
+		if trak.Mdia == nil || trak.Mdia.Hdlr == nil || trak.Mdia.Minf == nil || trak.Mdia.Minf.Stbl == nil {
+			continue
+		}
+		stsd := trak.Mdia.Minf.Stbl.Stsd
+		if stsd == nil || len(stsd.Children) == 0 {
+			continue
+		}
+		switch trak.Mdia.Hdlr.HandlerType {
+		case "vide":
+			if info.Codec != "" {
+				continue
+			}
+			info.Codec = codecName(stsd.Children[0].Type())
+			if trak.Tkhd != nil {
+				info.Width = int(uint32(trak.Tkhd.Width) >> 16)
+				info.Height = int(uint32(trak.Tkhd.Height) >> 16)
+			}
+			if info.Width == 0 {
+				if vse, ok := stsd.Children[0].(*mp4.VisualSampleEntryBox); ok {
+					info.Width, info.Height = int(vse.Width), int(vse.Height)
+				}
+			}
+		case "soun":
+			if info.AudioCodec == "" {
+				info.AudioCodec = codecName(stsd.Children[0].Type())
+			}
+		}
+	}
+	return nil
+}
+
+func codecName(sampleEntry string) string {
+	switch sampleEntry {
+	case "avc1", "avc3":
+		return "h264"
+	case "hvc1", "hev1":
+		return "hevc"
+	case "mp4a":
+		return "aac"
+	}
+	return sampleEntry
+}
+
+// hevcSize 从 Annex-B 码流的 SPS 中读取画面尺寸
+func hevcSize(stream []byte) (int, int) {
+	_, spsNALUs, _ := hevc.GetParameterSetsFromByteStream(stream)
+	if len(spsNALUs) == 0 {
+		return 0, 0
+	}
+	sps, err := hevc.ParseSPSNALUnit(spsNALUs[0])
+	if err != nil {
+		return 0, 0
+	}
+	w, h := sps.ImageSize()
+	return int(w), int(h)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
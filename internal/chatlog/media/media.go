@@ -0,0 +1,196 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
+)
+
+// CacheDirName 是工作目录下存放缩略图、转码与解码结果的目录
+const CacheDirName = "media_cache"
+
+var (
+	// ErrUnsupported 表示无法处理该格式
+	ErrUnsupported = errors.New("unsupported media format")
+	// ErrNoFFmpeg 表示操作需要 ffmpeg 但当前环境不可用
+	ErrNoFFmpeg = errors.New("ffmpeg is not available")
+)
+
+// Pipeline 负责媒体文件的识别、缩略图与转码，生成的结果缓存在 cacheDir 下，
+// 以源文件路径、大小与修改时间为 key，源文件变化后自动失效
+type Pipeline struct {
+	cacheDir string
+	keys     *dat2img.Keys
+}
+
+// New 创建一个缓存在 cacheDir 的 Pipeline，.dat 文件使用 keys 解密，keys 为 nil 时使用进程全局的图片密钥
+func New(cacheDir string, keys *dat2img.Keys) *Pipeline {
+	return &Pipeline{cacheDir: cacheDir, keys: keys}
+}
+
+// source 是一个已识别格式的媒体文件；.dat 文件会被解密到 data 中
+type source struct {
+	path   string
+	format string
+	data   []byte
+	info   os.FileInfo
+}
+
+// open 识别 path 的格式，.dat 文件解密后保留原始 wxgf 载荷
+func (p *Pipeline) open(path string) (*source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrUnsupported
+	}
+	src := &source{path: path, info: info}
+
+	if strings.EqualFold(filepath.Ext(path), ".dat") {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data, ext, err := p.keys.DecryptDat(raw)
+		if err != nil {
+			return nil, err
+		}
+		src.data, src.format = data, ext
+		if src.format == "" {
+			src.format = sniff(data)
+		}
+		return src, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, 16)
+	n, _ := io.ReadFull(f, head)
+	src.format = sniff(head[:n])
+	return src, nil
+}
+
+// bytes 返回源文件的完整内容
+func (s *source) bytes() ([]byte, error) {
+	if s.data != nil {
+		return s.data, nil
+	}
+	return os.ReadFile(s.path)
+}
+
+// sniff 根据文件头判断格式
+func sniff(head []byte) string {
+	for _, format := range dat2img.Formats {
+		if bytes.HasPrefix(head, format.Header) {
+			return format.Ext
+		}
+	}
+	switch {
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return "mp4"
+	case bytes.HasPrefix(head, []byte{0, 0, 0, 1}), bytes.HasPrefix(head, []byte{0, 0, 1}):
+		return "hevc"
+	}
+	return ""
+}
+
+// cachePath 返回派生文件在缓存目录中的路径
+func (p *Pipeline) cachePath(kind string, src *source, variant, ext string) string {
+	raw := src.path + "|" + strconv.FormatInt(src.info.Size(), 10) + "|" +
+		strconv.FormatInt(src.info.ModTime().UnixNano(), 10) + "|" + variant
+	sum := sha1.Sum([]byte(raw))
+	return filepath.Join(p.cacheDir, kind, hex.EncodeToString(sum[:])+"."+ext)
+}
+
+// keyLock 为单个派生文件的锁，refs 为持有或等待该锁的请求数
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// inflight 保证同一个派生文件同时只生成一次，生成结束且无人等待时删除对应的锁
+var (
+	inflightMu sync.Mutex
+	inflight   = make(map[string]*keyLock)
+)
+
+func lockKey(key string) func() {
+	inflightMu.Lock()
+	l := inflight[key]
+	if l == nil {
+		l = &keyLock{}
+		inflight[key] = l
+	}
+	l.refs++
+	inflightMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		inflightMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(inflight, key)
+		}
+		inflightMu.Unlock()
+	}
+}
+
+// writeAtomic 先写临时文件再重命名，避免并发读取到半个文件
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ffmpeg 运行 ffmpeg 并返回标准输出
+func ffmpeg(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	if !dat2img.FFmpegMode {
+		return nil, ErrNoFFmpeg
+	}
+	base := []string{"-v", "error"}
+	if stdin == nil {
+		base = append(base, "-nostdin")
+	}
+	cmd := exec.CommandContext(ctx, dat2img.FFMpegPath, append(base, args...)...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
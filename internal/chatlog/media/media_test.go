@@ -0,0 +1,217 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePNG(t *testing.T, path string, w, h int, xor byte) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	for i := range data {
+		data[i] ^= xor
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProbeImages(t *testing.T) {
+	dir := t.TempDir()
+	p := New(filepath.Join(dir, "cache"), nil)
+
+	writePNG(t, filepath.Join(dir, "a.png"), 64, 32, 0)
+	info, err := p.Probe(filepath.Join(dir, "a.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "png" || info.Width != 64 || info.Height != 32 || !info.Playable || info.MIME != "image/png" {
+		t.Fatalf("png info = %+v", info)
+	}
+
+	// 旧版微信的 .dat 图片是整体异或加密的
+	writePNG(t, filepath.Join(dir, "b.dat"), 20, 40, 0x5a)
+	info, err = p.Probe(filepath.Join(dir, "b.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "png" || info.Width != 20 || info.Height != 40 {
+		t.Fatalf("dat info = %+v", info)
+	}
+
+	os.WriteFile(filepath.Join(dir, "c.bin"), []byte("plain text"), 0o644)
+	if _, err := p.Probe(filepath.Join(dir, "c.bin")); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	p := New(filepath.Join(dir, "cache"), nil)
+	src := filepath.Join(dir, "big.dat")
+	writePNG(t, src, 300, 150, 0x33)
+
+	data, err := p.Thumbnail(context.Background(), src, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Fatalf("thumbnail size = %v", b)
+	}
+
+	cached, _ := filepath.Glob(filepath.Join(dir, "cache", "thumbs", "*.jpg"))
+	if len(cached) != 1 {
+		t.Fatalf("cached thumbnails = %v", cached)
+	}
+	again, err := p.Thumbnail(context.Background(), src, 100)
+	if err != nil || !bytes.Equal(again, data) {
+		t.Fatalf("second call = %d bytes, %v", len(again), err)
+	}
+
+	// 小于目标尺寸的图片保持原尺寸
+	data, err = p.Thumbnail(context.Background(), src, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := jpeg.DecodeConfig(bytes.NewReader(data)); err != nil || cfg.Width != 300 {
+		t.Fatalf("unscaled thumbnail = %+v, %v", cfg, err)
+	}
+}
+
+func TestSniff(t *testing.T) {
+	cases := map[string][]byte{
+		"jpg":  {0xFF, 0xD8, 0xFF, 0xE0},
+		"mp4":  []byte("\x00\x00\x00\x18ftypmp42"),
+		"hevc": {0, 0, 0, 1, 0x40, 0x01},
+		"wxgf": []byte("wxgf\x00"),
+		"":     []byte("hello"),
+	}
+	for want, head := range cases {
+		if got := sniff(head); got != want {
+			t.Errorf("sniff(%q) = %q, want %q", head, got, want)
+		}
+	}
+}
+
+func TestRunDecode(t *testing.T) {
+	dataDir := t.TempDir()
+	attach := filepath.Join(dataDir, "msg", "attach", "abc", "2024-01", "Img")
+	if err := os.MkdirAll(attach, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePNG(t, filepath.Join(attach, "a.dat"), 8, 8, 0x21)
+	writePNG(t, filepath.Join(attach, "b.dat"), 8, 8, 0x42) // 内容与 a 相同
+	os.WriteFile(filepath.Join(attach, "bad.dat"), []byte{0x01, 0x02, 0x03, 0x04, 0x05}, 0o644)
+	// 微信 4.0 加密图片，未设置图片密钥时应记录为 missing_key
+	v4 := append([]byte{0x07, 0x08, 0x56, 0x32, 0, 0, 16, 0, 0, 0, 0, 0, 0, 0, 1}, make([]byte, 32)...)
+	os.WriteFile(filepath.Join(attach, "v4.dat"), v4, 0o644)
+
+	cache, err := OpenDecodedCache(filepath.Join(t.TempDir(), CacheDirName), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := RunDecode(context.Background(), dataDir, cache, DecodeOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Total != 4 || p.Decoded != 2 || p.Failed != 2 {
+		t.Fatalf("progress = %+v", p)
+	}
+
+	stats := cache.Stats()
+	if stats.Reasons[FailUnknownFormat] != 1 || stats.Reasons[FailMissingKey] != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	blobs, _ := filepath.Glob(filepath.Join(cache.dir, "*", "*.png"))
+	if len(blobs) != 1 {
+		t.Fatalf("content-addressed blobs = %v", blobs)
+	}
+
+	rel := "msg/attach/abc/2024-01/Img/a.dat"
+	info, _ := os.Stat(filepath.Join(dataDir, filepath.FromSlash(rel)))
+	path, ext, ok := cache.Lookup(rel, info)
+	if !ok || ext != "png" || path != blobs[0] {
+		t.Fatalf("Lookup = %q, %q, %v", path, ext, ok)
+	}
+
+	// manifest 持久化后可被其他实例读取，已解码的文件不再重复处理
+	reopened, err := OpenDecodedCache(filepath.Dir(cache.dir), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = RunDecode(context.Background(), dataDir, reopened, DecodeOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Skipped != 2 || p.Failed != 2 {
+		t.Fatalf("second run = %+v", p)
+	}
+	if failures := reopened.Failures(1); len(failures) != 1 || failures[0].Path != "msg/attach/abc/2024-01/Img/bad.dat" {
+		t.Fatalf("failures = %+v", failures)
+	}
+
+	// 源文件删除后记录被清理
+	os.Remove(filepath.Join(attach, "bad.dat"))
+	if _, err := RunDecode(context.Background(), dataDir, reopened, DecodeOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if st := reopened.Stats(); st.Total != 3 {
+		t.Fatalf("after prune = %+v", st)
+	}
+
+	// 解码文件在最后一个引用它的源文件删除后才被清理
+	os.Remove(filepath.Join(attach, "a.dat"))
+	if _, err := RunDecode(context.Background(), dataDir, reopened, DecodeOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(blobs[0]); err != nil {
+		t.Fatalf("blob still referenced by b.dat was removed: %v", err)
+	}
+	os.Remove(filepath.Join(attach, "b.dat"))
+	if _, err := RunDecode(context.Background(), dataDir, reopened, DecodeOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(blobs[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("orphaned blob not removed: %v", err)
+	}
+}
+
+func TestLockKeyReleases(t *testing.T) {
+	unlock := lockKey("a")
+	done := make(chan struct{})
+	go func() {
+		lockKey("a")()
+		close(done)
+	}()
+	unlock()
+	<-done
+
+	inflightMu.Lock()
+	n := len(inflight)
+	inflightMu.Unlock()
+	if n != 0 {
+		t.Fatalf("inflight keeps %d locks after all holders released", n)
+	}
+}
@@ -0,0 +1,103 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
+)
+
+// Playable 返回一个浏览器可播放的 MP4 文件路径。
+// H.264 的 MP4 原样返回；wxgf 与裸 HEVC 码流通过 Transmux2MP4 封装为 MP4，无需 ffmpeg；
+// HEVC 编码的 MP4 在 ffmpeg 可用时转码为 H.264。生成的文件缓存在 cacheDir/video 下
+func (p *Pipeline) Playable(ctx context.Context, path string) (string, error) {
+	info, err := p.Probe(path)
+	if err != nil {
+		return "", err
+	}
+	src, err := p.open(path)
+	if err != nil {
+		return "", err
+	}
+	if src.format == "mp4" && info.Playable && src.data == nil {
+		return path, nil
+	}
+
+	cached := p.cachePath("video", src, "mp4", "mp4")
+	if _, err := os.Stat(cached); err == nil {
+		return cached, nil
+	}
+	unlock := lockKey(cached)
+	defer unlock()
+	if _, err := os.Stat(cached); err == nil {
+		return cached, nil
+	}
+
+	var out []byte
+	switch {
+	case src.format == "wxgf":
+		data, err := src.bytes()
+		if err != nil {
+			return "", err
+		}
+		out, err = dat2img.Wxgf2MP4(data)
+		if err != nil {
+			return "", err
+		}
+	case src.format == "hevc":
+		data, err := src.bytes()
+		if err != nil {
+			return "", err
+		}
+		out, err = dat2img.Transmux2MP4(data)
+		if err != nil {
+			return "", err
+		}
+	case src.format == "mp4" && info.Playable:
+		// .dat 中解出的 MP4，写入缓存以便按 Range 读取
+		out = src.data
+	case src.format == "mp4":
+		if !dat2img.FFmpegMode {
+			return "", ErrNoFFmpeg
+		}
+		return cached, p.transcode(ctx, src, cached)
+	default:
+		return "", ErrUnsupported
+	}
+
+	if err := writeAtomic(cached, out); err != nil {
+		return "", err
+	}
+	return cached, nil
+}
+
+// transcode 使用 ffmpeg 把视频转码为 H.264/AAC，moov 前置以便边下边播
+func (p *Pipeline) transcode(ctx context.Context, src *source, dst string) error {
+	input := src.path
+	if src.data != nil {
+		tmp, err := os.CreateTemp("", "chatlog-media-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.Write(src.data)
+		tmp.Close()
+		if err != nil {
+			return err
+		}
+		input = tmp.Name()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp := dst + ".part.mp4"
+	defer os.Remove(tmp)
+	if _, err := ffmpeg(ctx, nil, "-y", "-i", input,
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "26", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-movflags", "+faststart", tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,144 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/dat2img"
+)
+
+const (
+	DefaultThumbSize = 240
+	MaxThumbSize     = 1024
+	thumbQuality     = 80
+)
+
+// Thumbnail 返回 path 的 JPEG 缩略图，长边不超过 size。
+// 图片在进程内缩放；视频优先使用微信生成的 _thumb.jpg，否则用 ffmpeg 截取首帧
+func (p *Pipeline) Thumbnail(ctx context.Context, path string, size int) ([]byte, error) {
+	if size <= 0 {
+		size = DefaultThumbSize
+	}
+	if size > MaxThumbSize {
+		size = MaxThumbSize
+	}
+
+	src, err := p.open(path)
+	if err != nil {
+		return nil, err
+	}
+	cached := p.cachePath("thumbs", src, strconv.Itoa(size), "jpg")
+	if data, err := os.ReadFile(cached); err == nil {
+		return data, nil
+	}
+
+	unlock := lockKey(cached)
+	defer unlock()
+	if data, err := os.ReadFile(cached); err == nil {
+		return data, nil
+	}
+
+	frame, err := p.frame(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(frame))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resize(img, size), &jpeg.Options{Quality: thumbQuality}); err != nil {
+		return nil, err
+	}
+	if err := writeAtomic(cached, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// frame 返回可以被 image.Decode 解码的一帧画面
+func (p *Pipeline) frame(ctx context.Context, src *source) ([]byte, error) {
+	switch src.format {
+	case "jpg", "png", "gif":
+		return src.bytes()
+	case "wxgf":
+		data, err := src.bytes()
+		if err != nil {
+			return nil, err
+		}
+		stream, err := dat2img.WxgfStream(data)
+		if err != nil {
+			return nil, err
+		}
+		return ffmpeg(ctx, stream, "-i", "-", "-frames:v", "1", "-c:v", "mjpeg", "-f", "image2", "-")
+	case "mp4", "hevc":
+		if thumb := siblingThumb(src.path); thumb != "" {
+			if t, err := p.open(thumb); err == nil && t.format == "jpg" {
+				return t.bytes()
+			}
+		}
+		if src.data != nil {
+			return ffmpeg(ctx, src.data, "-i", "-", "-frames:v", "1", "-c:v", "mjpeg", "-f", "image2", "-")
+		}
+		return ffmpeg(ctx, nil, "-i", src.path, "-frames:v", "1", "-c:v", "mjpeg", "-f", "image2", "-")
+	}
+	return nil, ErrUnsupported
+}
+
+// siblingThumb 返回微信为视频生成的封面 <name>_thumb.jpg
+func siblingThumb(path string) string {
+	thumb := strings.TrimSuffix(path, filepath.Ext(path)) + "_thumb.jpg"
+	if _, err := os.Stat(thumb); err == nil {
+		return thumb
+	}
+	return ""
+}
+
+// resize 按区域平均把 img 缩小到长边不超过 size，透明区域合成到白色背景上
+func resize(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if w > size || h > size {
+		if w >= h {
+			dw, dh = size, max(1, h*size/w)
+		} else {
+			dw, dh = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					// 预乘 alpha 的颜色加上白色背景
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					bl += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: 0xffff})
+		}
+	}
+	return dst
+}
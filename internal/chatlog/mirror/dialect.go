@@ -0,0 +1,96 @@
+package mirror
+
+import (
+	"strconv"
+	"strings"
+)
+
+// dialect 屏蔽 PostgreSQL 与 SQLite 在列类型与占位符上的差异，
+// 两者都支持 INSERT ... ON CONFLICT DO UPDATE，upsert 语句可以共用
+type dialect struct {
+	name       string
+	driverName string
+	bigint     string
+	boolean    string
+	timestamp  string
+	json       string
+	dollar     bool // 使用 $1, $2 ... 占位符
+}
+
+var postgresDialect = &dialect{
+	name:       "postgres",
+	driverName: "pgx",
+	bigint:     "BIGINT",
+	boolean:    "BOOLEAN",
+	timestamp:  "TIMESTAMPTZ",
+	json:       "JSONB",
+	dollar:     true,
+}
+
+var sqliteDialect = &dialect{
+	name:       "sqlite3",
+	driverName: "sqlite3",
+	bigint:     "INTEGER",
+	boolean:    "BOOLEAN",
+	timestamp:  "TIMESTAMP",
+	json:       "TEXT",
+}
+
+// rebind 将 ? 占位符替换为方言对应的形式
+func (d *dialect) rebind(query string) string {
+	if !d.dollar {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (d *dialect) schema() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS chatlog_messages (
+talker       TEXT NOT NULL,
+seq          ` + d.bigint + ` NOT NULL,
+time         ` + d.timestamp + ` NOT NULL,
+talker_name  TEXT NOT NULL,
+is_chat_room ` + d.boolean + ` NOT NULL,
+sender       TEXT NOT NULL,
+sender_name  TEXT NOT NULL,
+is_self      ` + d.boolean + ` NOT NULL,
+type         ` + d.bigint + ` NOT NULL,
+sub_type     ` + d.bigint + ` NOT NULL,
+content      TEXT NOT NULL,
+contents     ` + d.json + `,
+PRIMARY KEY (talker, seq)
+);`,
+		`CREATE INDEX IF NOT EXISTS idx_chatlog_messages_time ON chatlog_messages(time);`,
+		`CREATE INDEX IF NOT EXISTS idx_chatlog_messages_sender ON chatlog_messages(sender);`,
+		`CREATE TABLE IF NOT EXISTS chatlog_contacts (
+user_name TEXT PRIMARY KEY,
+alias     TEXT NOT NULL,
+remark    TEXT NOT NULL,
+nick_name TEXT NOT NULL,
+is_friend ` + d.boolean + ` NOT NULL
+);`,
+		`CREATE TABLE IF NOT EXISTS chatlog_chatrooms (
+name      TEXT PRIMARY KEY,
+owner     TEXT NOT NULL,
+remark    TEXT NOT NULL,
+nick_name TEXT NOT NULL,
+users     ` + d.json + `
+);`,
+		`CREATE TABLE IF NOT EXISTS chatlog_checkpoints (
+talker     TEXT PRIMARY KEY,
+last_seq   ` + d.bigint + ` NOT NULL,
+updated_at ` + d.timestamp + ` NOT NULL
+);`,
+	}
+}
@@ -0,0 +1,370 @@
+package mirror
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+)
+
+// Source 是同步所需的只读数据接口，由 *wechatdb.DB 实现
+type Source interface {
+	GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
+	GetContacts(key string, limit, offset int) (*wechatdb.GetContactsResp, error)
+	GetChatRooms(key string, limit, offset int) (*wechatdb.GetChatRoomsResp, error)
+	GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error)
+}
+
+type Summary struct {
+	Contacts  int `json:"contacts"`
+	ChatRooms int `json:"chatRooms"`
+	Talkers   int `json:"talkers"`
+	Messages  int `json:"messages"`
+}
+
+// Mirror 把消息、联系人、群聊以 upsert 方式写入外部 SQL 数据库，
+// 每个会话记录已同步的最大 seq（chatlog_checkpoints），重复同步不会产生重复数据
+type Mirror struct {
+	db        *sql.DB
+	dialect   *dialect
+	batchSize int
+
+	// 同一时刻只允许一个同步过程写入
+	mu sync.Mutex
+}
+
+func Open(cfg *conf.MirrorConfig) (*Mirror, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("mirror config is required")
+	}
+	cfg.Normalize()
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("mirror dsn is required")
+	}
+
+	var d *dialect
+	driverName := ""
+	switch cfg.Driver {
+	case "postgres":
+		d, driverName = postgresDialect, postgresDialect.driverName
+	case "sqlite3":
+		d, driverName = sqliteDialect, sqliteDialect.driverName
+	default:
+		return nil, fmt.Errorf("unsupported mirror driver: %s", cfg.Driver)
+	}
+
+	db, err := sql.Open(driverName, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if d == sqliteDialect {
+		// SQLite 不支持并发写入
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect mirror database: %w", err)
+	}
+
+	for _, stmt := range d.schema() {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("init mirror schema: %w", err)
+		}
+	}
+
+	return &Mirror{db: db, dialect: d, batchSize: cfg.BatchSize}, nil
+}
+
+func (m *Mirror) Close() error {
+	return m.db.Close()
+}
+
+// Sync 同步联系人、群聊以及全部会话的新消息
+func (m *Mirror) Sync(ctx context.Context, src Source) (*Summary, error) {
+	summary := &Summary{}
+	var err error
+	if summary.Contacts, err = m.SyncContacts(ctx, src); err != nil {
+		return summary, err
+	}
+	if summary.ChatRooms, err = m.SyncChatRooms(ctx, src); err != nil {
+		return summary, err
+	}
+	summary.Talkers, summary.Messages, err = m.SyncMessages(ctx, src, nil)
+	return summary, err
+}
+
+func (m *Mirror) SyncContacts(ctx context.Context, src Source) (int, error) {
+	resp, err := src.GetContacts("", 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := m.dialect.rebind(`
+INSERT INTO chatlog_contacts (user_name, alias, remark, nick_name, is_friend)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (user_name) DO UPDATE SET
+alias = excluded.alias,
+remark = excluded.remark,
+nick_name = excluded.nick_name,
+is_friend = excluded.is_friend`)
+
+	n := 0
+	err = m.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, c := range resp.Items {
+			if c == nil || c.UserName == "" {
+				continue
+			}
+			if _, err := stmt.ExecContext(ctx, c.UserName, c.Alias, c.Remark, c.NickName, c.IsFriend); err != nil {
+				return fmt.Errorf("upsert contact %s: %w", c.UserName, err)
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (m *Mirror) SyncChatRooms(ctx context.Context, src Source) (int, error) {
+	resp, err := src.GetChatRooms("", 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := m.dialect.rebind(`
+INSERT INTO chatlog_chatrooms (name, owner, remark, nick_name, users)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (name) DO UPDATE SET
+owner = excluded.owner,
+remark = excluded.remark,
+nick_name = excluded.nick_name,
+users = excluded.users`)
+
+	n := 0
+	err = m.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, c := range resp.Items {
+			if c == nil || c.Name == "" {
+				continue
+			}
+			users, err := jsonValue(c.Users)
+			if err != nil {
+				return err
+			}
+			if _, err := stmt.ExecContext(ctx, c.Name, c.Owner, c.Remark, c.NickName, users); err != nil {
+				return fmt.Errorf("upsert chatroom %s: %w", c.Name, err)
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// SyncMessages 从各会话的 checkpoint 继续同步消息，talkers 为空时同步全部会话；
+// 返回有新消息的会话数与写入的消息数
+func (m *Mirror) SyncMessages(ctx context.Context, src Source, talkers []string) (int, int, error) {
+	if len(talkers) == 0 {
+		sessions, err := src.GetSessions("", 0, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, sess := range sessions.Items {
+			if sess != nil && sess.UserName != "" {
+				talkers = append(talkers, sess.UserName)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	checkpoints, err := m.checkpoints(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	synced, total := 0, 0
+	for _, talker := range talkers {
+		if err := ctx.Err(); err != nil {
+			return synced, total, err
+		}
+		n, err := m.syncTalker(ctx, src, talker, checkpoints[talker])
+		if err != nil {
+			return synced, total, fmt.Errorf("sync %s: %w", talker, err)
+		}
+		if n > 0 {
+			synced++
+			total += n
+			log.Debug().Str("talker", talker).Int("messages", n).Msg("mirror synced")
+		}
+	}
+	return synced, total, nil
+}
+
+// syncTalker 读取 checkpoint 所在秒之后的消息，跳过 seq 不大于 checkpoint 的部分，
+// 按 batchSize 分批提交，每批与 checkpoint 在同一事务内更新
+func (m *Mirror) syncTalker(ctx context.Context, src Source, talker string, lastSeq int64) (int, error) {
+	// seq 为 10 位时间戳 + 3 位序号
+	start := time.Unix(lastSeq/1000, 0)
+	end := time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+	count := 0
+	for offset := 0; ; offset += m.batchSize {
+		messages, err := src.GetMessages(start, end, talker, "", "", m.batchSize, offset)
+		if err != nil {
+			return count, err
+		}
+		batch := make([]*model.Message, 0, len(messages))
+		for _, msg := range messages {
+			if msg != nil && msg.Seq > lastSeq {
+				batch = append(batch, msg)
+			}
+		}
+		if len(batch) > 0 {
+			maxSeq, err := m.writeMessages(ctx, talker, batch)
+			if err != nil {
+				return count, err
+			}
+			if maxSeq > lastSeq {
+				lastSeq = maxSeq
+			}
+			count += len(batch)
+		}
+		if len(messages) < m.batchSize {
+			break
+		}
+	}
+	return count, nil
+}
+
+func (m *Mirror) writeMessages(ctx context.Context, talker string, messages []*model.Message) (int64, error) {
+	query := m.dialect.rebind(`
+INSERT INTO chatlog_messages (talker, seq, time, talker_name, is_chat_room, sender, sender_name, is_self, type, sub_type, content, contents)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (talker, seq) DO UPDATE SET
+time = excluded.time,
+talker_name = excluded.talker_name,
+is_chat_room = excluded.is_chat_room,
+sender = excluded.sender,
+sender_name = excluded.sender_name,
+is_self = excluded.is_self,
+type = excluded.type,
+sub_type = excluded.sub_type,
+content = excluded.content,
+contents = excluded.contents`)
+
+	checkpoint := m.dialect.rebind(`
+INSERT INTO chatlog_checkpoints (talker, last_seq, updated_at)
+VALUES (?, ?, ?)
+ON CONFLICT (talker) DO UPDATE SET
+last_seq = excluded.last_seq,
+updated_at = excluded.updated_at`)
+
+	var maxSeq int64
+	err := m.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, msg := range messages {
+			contents, err := jsonValue(msg.Contents)
+			if err != nil {
+				return err
+			}
+			if _, err := stmt.ExecContext(ctx, talker, msg.Seq, msg.Time.UTC(), msg.TalkerName, msg.IsChatRoom,
+				msg.Sender, msg.SenderName, msg.IsSelf, msg.Type, msg.SubType, msg.Content, contents); err != nil {
+				return fmt.Errorf("upsert message %d: %w", msg.Seq, err)
+			}
+			if msg.Seq > maxSeq {
+				maxSeq = msg.Seq
+			}
+		}
+		_, err = tx.ExecContext(ctx, checkpoint, talker, maxSeq, time.Now().UTC())
+		return err
+	})
+	return maxSeq, err
+}
+
+// Checkpoints 返回各会话已同步的最大 seq
+func (m *Mirror) Checkpoints(ctx context.Context) (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.checkpoints(ctx)
+}
+
+func (m *Mirror) checkpoints(ctx context.Context) (map[string]int64, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT talker, last_seq FROM chatlog_checkpoints`)
+	if err != nil {
+		return nil, fmt.Errorf("read mirror checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	ret := make(map[string]int64)
+	for rows.Next() {
+		var talker string
+		var seq int64
+		if err := rows.Scan(&talker, &seq); err != nil {
+			return nil, err
+		}
+		ret[talker] = seq
+	}
+	return ret, rows.Err()
+}
+
+func (m *Mirror) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// jsonValue 将结构化字段序列化为 JSON 文本，空值写入 NULL
+func jsonValue(v any) (any, error) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if len(x) == 0 {
+			return nil, nil
+		}
+	case []model.ChatRoomUser:
+		if len(x) == 0 {
+			return nil, nil
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
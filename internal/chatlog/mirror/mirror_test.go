@@ -0,0 +1,185 @@
+package mirror
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+)
+
+type fakeSource struct {
+	messages map[string][]*model.Message
+}
+
+func (f *fakeSource) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	var msgs []*model.Message
+	for _, m := range f.messages[talker] {
+		if !m.Time.Before(start) && !m.Time.After(end) {
+			msgs = append(msgs, m)
+		}
+	}
+	if offset >= len(msgs) {
+		return nil, nil
+	}
+	msgs = msgs[offset:]
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+func (f *fakeSource) GetContacts(key string, limit, offset int) (*wechatdb.GetContactsResp, error) {
+	return &wechatdb.GetContactsResp{Items: []*model.Contact{{UserName: "alice", NickName: "Alice", IsFriend: true}}}, nil
+}
+
+func (f *fakeSource) GetChatRooms(key string, limit, offset int) (*wechatdb.GetChatRoomsResp, error) {
+	return &wechatdb.GetChatRoomsResp{Items: []*model.ChatRoom{{Name: "room@chatroom", Owner: "alice", Users: []model.ChatRoomUser{{UserName: "alice"}}}}}, nil
+}
+
+func (f *fakeSource) GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
+	return &wechatdb.GetSessionsResp{Items: []*model.Session{{UserName: "alice"}, {UserName: "room@chatroom"}}}, nil
+}
+
+func message(talker string, t time.Time, n int64, content string) *model.Message {
+	return &model.Message{Seq: t.Unix()*1000 + n, Time: t, Talker: talker, Sender: talker, Type: model.MessageTypeText, Content: content}
+}
+
+func TestSyncIncremental(t *testing.T) {
+	m, err := Open(&conf.MirrorConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "mirror.db"), BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	t0 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	src := &fakeSource{messages: map[string][]*model.Message{
+		"alice": {message("alice", t0, 0, "a"), message("alice", t0, 1, "b"), message("alice", t0.Add(time.Minute), 0, "c")},
+	}}
+	ctx := context.Background()
+
+	summary, err := m.Sync(ctx, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Contacts != 1 || summary.ChatRooms != 1 || summary.Talkers != 1 || summary.Messages != 3 {
+		t.Fatalf("first sync = %+v", summary)
+	}
+
+	// 无新消息时不写入；新消息与 checkpoint 同一秒时也不会遗漏
+	if _, n, err := m.SyncMessages(ctx, src, nil); err != nil || n != 0 {
+		t.Fatalf("resync = %d, %v", n, err)
+	}
+	src.messages["alice"] = append(src.messages["alice"], message("alice", t0.Add(time.Minute), 1, "d"))
+	src.messages["room@chatroom"] = []*model.Message{message("room@chatroom", t0, 0, "hi")}
+	talkers, n, err := m.SyncMessages(ctx, src, nil)
+	if err != nil || talkers != 2 || n != 2 {
+		t.Fatalf("incremental sync = %d talkers, %d messages, %v", talkers, n, err)
+	}
+
+	var count int
+	if err := m.db.QueryRow(`SELECT COUNT(*) FROM chatlog_messages`).Scan(&count); err != nil || count != 5 {
+		t.Fatalf("mirrored messages = %d, %v", count, err)
+	}
+	checkpoints, err := m.Checkpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := t0.Add(time.Minute).Unix()*1000 + 1; checkpoints["alice"] != want {
+		t.Fatalf("alice checkpoint = %d, want %d", checkpoints["alice"], want)
+	}
+}
+
+// darwinV3Source 与 darwinv3 数据源一样保存原始行，查询时在过滤前按同一秒内的顺序编号再 Wrap
+type darwinV3Source struct {
+	fakeSource
+	rows map[string][]model.MessageDarwinV3
+}
+
+func (d *darwinV3Source) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	var lastTime, index int64
+	d.messages = map[string][]*model.Message{}
+	for _, row := range d.rows[talker] {
+		if row.MsgCreateTime == lastTime {
+			index++
+		} else {
+			lastTime, index = row.MsgCreateTime, 0
+		}
+		row.Index = index
+		d.messages[talker] = append(d.messages[talker], row.Wrap(talker))
+	}
+	return d.fakeSource.GetMessages(start, end, talker, sender, keyword, limit, offset)
+}
+
+func TestSyncDarwinV3(t *testing.T) {
+	m, err := Open(&conf.MirrorConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "mirror.db"), BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	t0 := int64(1714557600)
+	src := &darwinV3Source{rows: map[string][]model.MessageDarwinV3{
+		"alice": {
+			{MsgCreateTime: t0, MsgContent: "a", MessageType: 1, MesDes: 1},
+			{MsgCreateTime: t0, MsgContent: "b", MessageType: 1, MesDes: 0},
+			{MsgCreateTime: t0 + 60, MsgContent: "c", MessageType: 1, MesDes: 1},
+		},
+	}}
+	ctx := context.Background()
+
+	if _, n, err := m.SyncMessages(ctx, src, []string{"alice"}); err != nil || n != 3 {
+		t.Fatalf("first sync = %d, %v", n, err)
+	}
+	// 与 checkpoint 同一秒的新消息也能同步
+	src.rows["alice"] = append(src.rows["alice"], model.MessageDarwinV3{MsgCreateTime: t0 + 60, MsgContent: "d", MessageType: 1, MesDes: 0})
+	if _, n, err := m.SyncMessages(ctx, src, []string{"alice"}); err != nil || n != 1 {
+		t.Fatalf("incremental sync = %d, %v", n, err)
+	}
+
+	rows, err := m.db.Query(`SELECT seq, content FROM chatlog_messages WHERE talker = 'alice' ORDER BY seq`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var seq int64
+		var content string
+		if err := rows.Scan(&seq, &content); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%d:%s", seq, content))
+	}
+	want := []string{
+		fmt.Sprintf("%d:a", t0*1000), fmt.Sprintf("%d:b", t0*1000+1),
+		fmt.Sprintf("%d:c", (t0+60)*1000), fmt.Sprintf("%d:d", (t0+60)*1000+1),
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("mirrored = %v, want %v", got, want)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	if got := postgresDialect.rebind("VALUES (?, ?)"); got != "VALUES ($1, $2)" {
+		t.Fatalf("rebind = %s", got)
+	}
+	if got := sqliteDialect.rebind("VALUES (?, ?)"); got != "VALUES (?, ?)" {
+		t.Fatalf("rebind = %s", got)
+	}
+}
+
+func TestDriversRegistered(t *testing.T) {
+	for _, d := range []*dialect{postgresDialect, sqliteDialect} {
+		if !slices.Contains(sql.Drivers(), d.driverName) {
+			t.Fatalf("%s driver %q not registered (drivers: %v)", d.name, d.driverName, sql.Drivers())
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package mirror
+
+import (
+	"context"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// Watcher 将数据库文件的 fsnotify 回调转换为后台同步，
+// 同步进行期间到达的多个事件合并为一次
+type Watcher struct {
+	ctx       context.Context
+	mirror    *Mirror
+	src       Source
+	messages  chan struct{}
+	directory chan struct{}
+}
+
+// NewWatcher 启动后台同步循环，并立即执行一次全量追赶
+func NewWatcher(ctx context.Context, mirror *Mirror, src Source) *Watcher {
+	w := &Watcher{
+		ctx:       ctx,
+		mirror:    mirror,
+		src:       src,
+		messages:  make(chan struct{}, 1),
+		directory: make(chan struct{}, 1),
+	}
+	w.directory <- struct{}{}
+	w.messages <- struct{}{}
+	go w.loop()
+	return w
+}
+
+// Callback 返回指定文件组的回调；message 组触发消息同步，contact/chatroom 组触发通讯录同步
+func (w *Watcher) Callback(group string) func(event fsnotify.Event) error {
+	ch := w.messages
+	if group != "message" {
+		ch = w.directory
+	}
+	return func(event fsnotify.Event) error {
+		if !(event.Op.Has(fsnotify.Create) || event.Op.Has(fsnotify.Write) || event.Op.Has(fsnotify.Rename)) {
+			return nil
+		}
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+}
+
+func (w *Watcher) loop() {
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-w.directory:
+			if _, err := w.mirror.SyncContacts(w.ctx, w.src); err != nil {
+				log.Warn().Err(err).Msg("mirror contacts failed")
+			}
+			if _, err := w.mirror.SyncChatRooms(w.ctx, w.src); err != nil {
+				log.Warn().Err(err).Msg("mirror chatrooms failed")
+			}
+		case <-w.messages:
+			talkers, n, err := w.mirror.SyncMessages(w.ctx, w.src, nil)
+			if err != nil {
+				log.Warn().Err(err).Msg("mirror messages failed")
+			}
+			if n > 0 {
+				log.Info().Int("talkers", talkers).Int("messages", n).Msg("mirrored new messages")
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package summarize
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/workdb"
+)
+
+const (
+	dirName  = "summaries"
+	fileName = "summaries.db"
+)
+
+// Cache keeps summaries in <work_dir>/summaries/summaries.db, keyed by
+// talker, time range and model, so repeated requests do not call the model again.
+type Cache struct {
+	db *sql.DB
+}
+
+// OpenCache opens or creates the summary cache under workDir.
+func OpenCache(workDir string) (*Cache, error) {
+	db, err := workdb.Open(workDir, dirName, fileName, `CREATE TABLE IF NOT EXISTS summaries (
+talker      TEXT NOT NULL,
+start_time  INTEGER NOT NULL,
+end_time    INTEGER NOT NULL,
+model       TEXT NOT NULL,
+fingerprint TEXT NOT NULL,
+result      TEXT NOT NULL,
+created_at  INTEGER NOT NULL,
+PRIMARY KEY (talker, start_time, end_time, model)
+);`)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{db: db}, nil
+}
+
+// Close releases the underlying database.
+func (c *Cache) Close() error {
+	if c == nil || c.db == nil {
+		return nil
+	}
+	return c.db.Close()
+}
+
+// Get returns the cached summary, or nil when none is stored or the messages
+// it was built from have changed.
+func (c *Cache) Get(talker string, start, end time.Time, model, fingerprint string) (*Result, error) {
+	var stored, data string
+	err := c.db.QueryRow(`SELECT fingerprint, result FROM summaries
+WHERE talker = ? AND start_time = ? AND end_time = ? AND model = ?`,
+		talker, start.Unix(), end.Unix(), model).Scan(&stored, &data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if stored != fingerprint {
+		return nil, nil
+	}
+	res := &Result{}
+	if err := json.Unmarshal([]byte(data), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Put stores or replaces a summary.
+func (c *Cache) Put(res *Result, fingerprint string) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.Exec(`INSERT INTO summaries (talker, start_time, end_time, model, fingerprint, result, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(talker, start_time, end_time, model) DO UPDATE SET
+fingerprint = excluded.fingerprint,
+result = excluded.result,
+created_at = excluded.created_at`,
+		res.Talker, res.Start.Unix(), res.End.Unix(), res.Model, fingerprint, string(data), res.CreatedAt.Unix())
+	return err
+}
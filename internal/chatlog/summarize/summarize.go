@@ -0,0 +1,262 @@
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/llm"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+const (
+	// DefaultChunkTokens 为未配置时单个分块的 token 预算
+	DefaultChunkTokens = 6000
+
+	// DefaultMaxChunks 为未配置时单次总结允许的最大分块数
+	DefaultMaxChunks = 20
+
+	// maxLineRunes 限制单条消息写入提示词的长度，避免一条长文本占满整个分块
+	maxLineRunes = 500
+
+	mapPrompt = `你会收到一段聊天记录，每行格式为 "[编号] 时间 发送者: 内容"。
+请用中文总结这段记录的主要话题、结论与待办事项。每个要点末尾用方括号标注依据的消息编号，例如 [123] 或 [123, 456]，只能引用记录中出现的编号。
+只依据记录中的事实，不要编造。`
+
+	reducePrompt = `你会收到同一会话按时间顺序排列的多段分段总结，其中方括号内是原始消息编号。
+请合并为一份完整的中文总结，按以下结构输出：
+1. 主要话题
+2. 结论与决定
+3. 待办事项与负责人（没有则写“无”）
+每个要点末尾保留方括号中的消息编号，不要新增或改写编号。`
+)
+
+// Citation 是总结中引用的原始消息
+type Citation struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"sender_name,omitempty"`
+	Content    string    `json:"content"`
+}
+
+// Result 是一次会话总结
+type Result struct {
+	Talker     string     `json:"talker"`
+	TalkerName string     `json:"talker_name,omitempty"`
+	Start      time.Time  `json:"start"`
+	End        time.Time  `json:"end"`
+	Model      string     `json:"model"`
+	Messages   int        `json:"messages"`
+	Chunks     int        `json:"chunks"`
+	Summary    string     `json:"summary"`
+	Citations  []Citation `json:"citations"`
+	Cached     bool       `json:"cached"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Summarizer 把消息按 token 预算分块，逐块总结后再合并（map-reduce），并按 (talker, 时间范围, 模型) 缓存结果
+type Summarizer struct {
+	client    llm.Client
+	budget    int
+	maxChunks int
+	cache     *Cache
+}
+
+// New 创建总结器，cache 为 nil 时不缓存；消息分块超过 maxChunks 时拒绝总结，避免一次请求发起大量模型调用
+func New(client llm.Client, chunkTokens, maxChunks int, cache *Cache) *Summarizer {
+	if chunkTokens <= 0 {
+		chunkTokens = DefaultChunkTokens
+	}
+	if maxChunks <= 0 {
+		maxChunks = DefaultMaxChunks
+	}
+	return &Summarizer{client: client, budget: chunkTokens, maxChunks: maxChunks, cache: cache}
+}
+
+// Summarize 总结 talker 在 [start, end] 内的消息；refresh 为 false 且消息未变化时返回缓存结果
+func (s *Summarizer) Summarize(ctx context.Context, talker string, start, end time.Time, msgs []*model.Message, refresh bool) (*Result, error) {
+	modelName := s.client.ModelName()
+	fingerprint := Fingerprint(msgs, s.budget)
+	if s.cache != nil && !refresh {
+		if cached, err := s.cache.Get(talker, start, end, modelName, fingerprint); err == nil && cached != nil {
+			cached.Cached = true
+			return cached, nil
+		}
+	}
+
+	res := &Result{
+		Talker:    talker,
+		Start:     start,
+		End:       end,
+		Model:     modelName,
+		Messages:  len(msgs),
+		Citations: []Citation{},
+		CreatedAt: time.Now(),
+	}
+	for _, m := range msgs {
+		if m.TalkerName != "" {
+			res.TalkerName = m.TalkerName
+			break
+		}
+	}
+	if len(msgs) == 0 {
+		return res, nil
+	}
+
+	lines := make([]string, len(msgs))
+	for i, m := range msgs {
+		lines[i] = FormatLine(m)
+	}
+	chunks := Chunk(lines, s.budget)
+	if len(chunks) > s.maxChunks {
+		return nil, errors.Newf(nil, http.StatusRequestEntityTooLarge,
+			"%d messages need %d chunks, exceeding the limit of %d; narrow the time range", len(msgs), len(chunks), s.maxChunks)
+	}
+	res.Chunks = len(chunks)
+
+	// map：逐块总结
+	partials := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		header := fmt.Sprintf("第 %d/%d 段聊天记录：\n", i+1, len(chunks))
+		out, err := s.client.Complete(ctx, mapPrompt, header+strings.Join(chunk, "\n"))
+		if err != nil {
+			return nil, err
+		}
+		partials = append(partials, strings.TrimSpace(out))
+	}
+
+	// reduce：分段总结超出预算时分组合并，直到只剩一份
+	for len(partials) > 1 {
+		groups := groupPartials(partials, s.budget)
+		next := make([]string, 0, len(groups))
+		for _, g := range groups {
+			out, err := s.client.Complete(ctx, reducePrompt, strings.Join(g, "\n\n---\n\n"))
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, strings.TrimSpace(out))
+		}
+		partials = next
+	}
+	res.Summary = partials[0]
+	res.Citations = Citations(res.Summary, msgs)
+
+	if s.cache != nil {
+		if err := s.cache.Put(res, fingerprint); err != nil {
+			log.Debug().Err(err).Msg("store summary cache failed")
+		}
+	}
+	return res, nil
+}
+
+// FormatLine 把消息格式化为提示词中的一行 "[seq] 时间 发送者: 内容"
+func FormatLine(m *model.Message) string {
+	sender := m.Sender
+	if m.IsSelf {
+		sender = "我"
+	} else if m.SenderName != "" {
+		sender = m.SenderName
+	}
+	content := strings.Join(strings.Fields(m.PlainTextContent()), " ")
+	if r := []rune(content); len(r) > maxLineRunes {
+		content = string(r[:maxLineRunes]) + "…"
+	}
+	return fmt.Sprintf("[%d] %s %s: %s", m.Seq, m.Time.Format("2006-01-02 15:04"), sender, content)
+}
+
+// Chunk 按 token 预算把行切分为若干块，单行超过预算时独占一块
+func Chunk(lines []string, budget int) [][]string {
+	var chunks [][]string
+	var cur []string
+	used := 0
+	for _, line := range lines {
+		n := llm.EstimateTokens(line) + 1
+		if len(cur) > 0 && used+n > budget {
+			chunks = append(chunks, cur)
+			cur, used = nil, 0
+		}
+		cur = append(cur, line)
+		used += n
+	}
+	if len(cur) > 0 {
+		chunks = append(chunks, cur)
+	}
+	return chunks
+}
+
+// groupPartials 按预算把分段总结分组，每组至少两份以保证每轮合并都能减少数量
+func groupPartials(partials []string, budget int) [][]string {
+	var groups [][]string
+	var cur []string
+	used := 0
+	for _, p := range partials {
+		n := llm.EstimateTokens(p)
+		if len(cur) >= 2 && used+n > budget {
+			groups = append(groups, cur)
+			cur, used = nil, 0
+		}
+		cur = append(cur, p)
+		used += n
+	}
+	if len(cur) == 1 && len(groups) > 0 {
+		groups[len(groups)-1] = append(groups[len(groups)-1], cur[0])
+	} else if len(cur) > 0 {
+		groups = append(groups, cur)
+	}
+	return groups
+}
+
+var (
+	citationGroup  = regexp.MustCompile(`\[([0-9][0-9,，、\s]*)\]`)
+	citationNumber = regexp.MustCompile(`[0-9]+`)
+)
+
+// Citations 提取总结中方括号引用的消息编号，返回对应的消息，忽略不存在的编号
+func Citations(summary string, msgs []*model.Message) []Citation {
+	bySeq := make(map[int64]*model.Message, len(msgs))
+	for _, m := range msgs {
+		bySeq[m.Seq] = m
+	}
+	seen := make(map[int64]bool)
+	out := []Citation{}
+	for _, group := range citationGroup.FindAllStringSubmatch(summary, -1) {
+		for _, num := range citationNumber.FindAllString(group[1], -1) {
+			seq, err := strconv.ParseInt(num, 10, 64)
+			if err != nil || seen[seq] {
+				continue
+			}
+			m, ok := bySeq[seq]
+			if !ok {
+				continue
+			}
+			seen[seq] = true
+			out = append(out, Citation{
+				Seq:        seq,
+				Time:       m.Time,
+				Sender:     m.Sender,
+				SenderName: m.SenderName,
+				Content:    m.PlainTextContent(),
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
+
+// Fingerprint 标识参与总结的消息与分块预算，消息增减或预算变化后缓存失效
+func Fingerprint(msgs []*model.Message, budget int) string {
+	var last int64
+	if len(msgs) > 0 {
+		last = msgs[len(msgs)-1].Seq
+	}
+	return fmt.Sprintf("%d:%d:%d", len(msgs), last, budget)
+}
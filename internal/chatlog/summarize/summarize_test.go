@@ -0,0 +1,159 @@
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// fakeClient 把每次请求记录下来，分段总结时引用该段第一条消息的编号
+type fakeClient struct {
+	calls []string
+}
+
+func (f *fakeClient) ModelName() string { return "fake" }
+
+func (f *fakeClient) Complete(ctx context.Context, system, user string) (string, error) {
+	f.calls = append(f.calls, system)
+	if system == mapPrompt {
+		i := strings.Index(user, "\n[")
+		seq := user[i+2 : i+2+strings.Index(user[i+2:], "]")]
+		return fmt.Sprintf("话题 [%s]", seq), nil
+	}
+	// 合并时保留全部编号，并带上一个不存在的编号
+	return strings.ReplaceAll(user, "\n\n---\n\n", "；") + " [999999]", nil
+}
+
+func messages(n int) []*model.Message {
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	msgs := make([]*model.Message, n)
+	for i := range msgs {
+		msgs[i] = &model.Message{
+			Seq:        int64(1000 + i),
+			Time:       base.Add(time.Duration(i) * time.Minute),
+			Talker:     "g@chatroom",
+			TalkerName: "项目群",
+			Sender:     "bob",
+			SenderName: "Bob",
+			Type:       model.MessageTypeText,
+			Content:    strings.Repeat("讨论发布计划", 5),
+		}
+	}
+	return msgs
+}
+
+func TestChunk(t *testing.T) {
+	lines := []string{"一二三四五", "六七八九十", "甲乙丙丁戊", strings.Repeat("长", 50)}
+	chunks := Chunk(lines, 12)
+	if len(chunks) != 3 || len(chunks[0]) != 2 || len(chunks[2]) != 1 {
+		t.Fatalf("chunks = %v", chunks)
+	}
+	if len(Chunk(nil, 10)) != 0 {
+		t.Fatal("empty input should yield no chunks")
+	}
+}
+
+func TestGroupPartials(t *testing.T) {
+	// 每份都超出预算时仍两两合并，保证收敛
+	groups := groupPartials([]string{"甲甲甲", "乙乙乙", "丙丙丙"}, 1)
+	if len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("groups = %v", groups)
+	}
+	groups = groupPartials([]string{"甲", "乙", "丙", "丁"}, 2)
+	if len(groups) != 2 || len(groups[0]) != 2 || len(groups[1]) != 2 {
+		t.Fatalf("groups = %v", groups)
+	}
+}
+
+func TestSummarizeMapReduce(t *testing.T) {
+	msgs := messages(10)
+	client := &fakeClient{}
+	budget := 2 * (len([]rune(FormatLine(msgs[0]))) + 1) // 每段约两条消息
+	s := New(client, budget, 0, nil)
+
+	start, end := msgs[0].Time, msgs[9].Time
+	res, err := s.Summarize(context.Background(), "g@chatroom", start, end, msgs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Chunks < 3 || res.Messages != 10 || res.TalkerName != "项目群" || res.Model != "fake" {
+		t.Fatalf("result = %+v", res)
+	}
+	maps := 0
+	for _, c := range client.calls {
+		if c == mapPrompt {
+			maps++
+		}
+	}
+	if maps != res.Chunks || len(client.calls) <= maps {
+		t.Fatalf("calls = %d map of %d", maps, len(client.calls))
+	}
+	if len(res.Citations) != res.Chunks || res.Citations[0].Seq != 1000 || res.Citations[0].SenderName != "Bob" {
+		t.Fatalf("citations = %+v", res.Citations)
+	}
+	for i := 1; i < len(res.Citations); i++ {
+		if res.Citations[i].Seq <= res.Citations[i-1].Seq {
+			t.Fatalf("citations not sorted: %+v", res.Citations)
+		}
+	}
+}
+
+func TestSummarizeMaxChunks(t *testing.T) {
+	msgs := messages(10)
+	client := &fakeClient{}
+	budget := 2 * (len([]rune(FormatLine(msgs[0]))) + 1)
+	s := New(client, budget, 2, nil)
+
+	_, err := s.Summarize(context.Background(), "g@chatroom", msgs[0].Time, msgs[9].Time, msgs, false)
+	if appErr, ok := err.(*errors.Error); !ok || appErr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("err = %v", err)
+	}
+	if len(client.calls) != 0 {
+		t.Fatalf("model called %d times over the limit", len(client.calls))
+	}
+}
+
+func TestSummarizeCache(t *testing.T) {
+	cache, err := OpenCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	msgs := messages(3)
+	client := &fakeClient{}
+	s := New(client, 0, 0, cache)
+	start, end := msgs[0].Time, msgs[2].Time.Add(time.Hour)
+
+	first, err := s.Summarize(context.Background(), "g@chatroom", start, end, msgs, false)
+	if err != nil || first.Cached || first.Chunks != 1 || len(client.calls) != 1 {
+		t.Fatalf("first = %+v, calls = %d, err = %v", first, len(client.calls), err)
+	}
+	second, err := s.Summarize(context.Background(), "g@chatroom", start, end, msgs, false)
+	if err != nil || !second.Cached || second.Summary != first.Summary || len(client.calls) != 1 {
+		t.Fatalf("second = %+v, calls = %d, err = %v", second, len(client.calls), err)
+	}
+
+	// 新消息使缓存失效，refresh 强制重新生成
+	more := append(msgs, messages(4)[3])
+	if res, _ := s.Summarize(context.Background(), "g@chatroom", start, end, more, false); res.Cached || len(client.calls) != 2 {
+		t.Fatalf("after new message: cached = %v, calls = %d", res.Cached, len(client.calls))
+	}
+	if res, _ := s.Summarize(context.Background(), "g@chatroom", start, end, more, true); res.Cached || len(client.calls) != 3 {
+		t.Fatalf("refresh: cached = %v, calls = %d", res.Cached, len(client.calls))
+	}
+}
+
+func TestCitations(t *testing.T) {
+	msgs := messages(3)
+	got := Citations("话题A [1002] 话题B [1000，1001、1002] 无效 [42] [abc]", msgs)
+	if len(got) != 3 || got[0].Seq != 1000 || got[2].Seq != 1002 {
+		t.Fatalf("citations = %+v", got)
+	}
+}
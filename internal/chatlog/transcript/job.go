@@ -0,0 +1,258 @@
+package transcript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+	"github.com/takeaway1/chatlog-TCOTC/internal/whisper"
+)
+
+const (
+	pageSize       = 1000
+	indexBatchSize = 50
+	itemTimeout    = 2 * time.Minute
+)
+
+// ErrJobRunning is returned when a batch job is started while another runs.
+var ErrJobRunning = errors.New("transcription job already running")
+
+// Source is the subset of the database service a batch job reads from.
+type Source interface {
+	GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error)
+	GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
+	GetMedia(_type string, key string) (*model.Media, error)
+	IndexMessages(messages []*model.Message) error
+}
+
+// JobOptions selects the voice messages a batch job transcribes. An empty
+// Talkers list covers every session; zero Start/End cover all time.
+type JobOptions struct {
+	Talkers []string
+	Start   time.Time
+	End     time.Time
+	Model   string
+	Options whisper.Options
+}
+
+// Progress reports the state of the current or last batch job.
+type Progress struct {
+	Running    bool      `json:"running"`
+	Talkers    []string  `json:"talkers,omitempty"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Total      int       `json:"total"`
+	Done       int       `json:"done"`
+	Cached     int       `json:"cached"`
+	Failed     int       `json:"failed"`
+	Current    string    `json:"current,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// Runner runs at most one batch transcription job at a time.
+type Runner struct {
+	mu       sync.Mutex
+	progress Progress
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// Start launches a background job transcribing the voice messages selected
+// by opts. Transcripts already in store are reused and only re-indexed.
+func (r *Runner) Start(src Source, store *Store, t whisper.Transcriber, opts JobOptions) error {
+	if src == nil || store == nil || t == nil {
+		return errors.New("transcription not available")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.progress.Running {
+		return ErrJobRunning
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.progress = Progress{
+		Running:   true,
+		Talkers:   opts.Talkers,
+		Start:     opts.Start,
+		End:       opts.End,
+		StartedAt: time.Now(),
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer cancel()
+		err := r.run(ctx, src, store, t, opts)
+		r.update(func(p *Progress) {
+			p.Running = false
+			p.Current = ""
+			p.FinishedAt = time.Now()
+			if err != nil {
+				p.LastError = err.Error()
+			}
+		})
+		if err != nil {
+			log.Warn().Err(err).Msg("voice transcription job stopped")
+			return
+		}
+		p := r.Progress()
+		log.Info().Int("total", p.Total).Int("cached", p.Cached).Int("failed", p.Failed).Msg("voice transcription job finished")
+	}()
+	return nil
+}
+
+// Cancel stops the running job, if any, and waits for it to exit so the
+// caller may close the transcriber afterwards.
+func (r *Runner) Cancel() {
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+// Progress returns a snapshot of the current or last job.
+func (r *Runner) Progress() Progress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.progress
+}
+
+func (r *Runner) update(fn func(p *Progress)) {
+	r.mu.Lock()
+	fn(&r.progress)
+	r.mu.Unlock()
+}
+
+func (r *Runner) run(ctx context.Context, src Source, store *Store, t whisper.Transcriber, opts JobOptions) error {
+	talkers := opts.Talkers
+	if len(talkers) == 0 {
+		sessions, err := src.GetSessions("", 0, 0)
+		if err != nil {
+			return err
+		}
+		for _, sess := range sessions.Items {
+			talkers = append(talkers, sess.UserName)
+		}
+	}
+
+	// 先收集全部语音消息，便于报告总数
+	var voices []*model.Message
+	for _, talker := range talkers {
+		for offset := 0; ; offset += pageSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			messages, err := src.GetMessages(opts.Start, opts.End, talker, "", "", pageSize, offset)
+			if err != nil {
+				return fmt.Errorf("list messages of %s: %w", talker, err)
+			}
+			for _, m := range messages {
+				if VoiceKey(m) != "" {
+					voices = append(voices, m)
+				}
+			}
+			if len(messages) < pageSize {
+				break
+			}
+		}
+	}
+	r.update(func(p *Progress) { p.Total = len(voices) })
+
+	optionsKey := OptionsKey(opts.Options)
+	batch := make([]*model.Message, 0, indexBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := src.IndexMessages(batch); err != nil {
+			log.Warn().Err(err).Msg("index voice transcripts failed")
+		}
+		batch = batch[:0]
+	}
+	defer flush()
+
+	for _, m := range voices {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := VoiceKey(m)
+		r.update(func(p *Progress) { p.Current = key })
+
+		cached, err := store.Get(key, opts.Model, optionsKey)
+		if err == nil && cached != nil {
+			r.update(func(p *Progress) { p.Done++; p.Cached++ })
+			batch = append(batch, m)
+			if len(batch) >= indexBatchSize {
+				flush()
+			}
+			continue
+		}
+
+		if err := transcribe(ctx, src, store, t, key, opts.Model, optionsKey, opts.Options); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Debug().Err(err).Str("talker", m.Talker).Int64("seq", m.Seq).Msg("transcribe voice failed")
+			r.update(func(p *Progress) { p.Done++; p.Failed++; p.LastError = err.Error() })
+			continue
+		}
+		r.update(func(p *Progress) { p.Done++ })
+		batch = append(batch, m)
+		if len(batch) >= indexBatchSize {
+			flush()
+		}
+	}
+	return nil
+}
+
+func transcribe(ctx context.Context, src Source, store *Store, t whisper.Transcriber, key, modelKey, optionsKey string, opts whisper.Options) error {
+	media, err := src.GetMedia("voice", key)
+	if err != nil {
+		return err
+	}
+	if len(media.Data) == 0 {
+		return errors.New("voice data unavailable")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, itemTimeout)
+	defer cancel()
+	res, err := t.TranscribeSilk(ctx, media.Data, opts)
+	if err != nil {
+		return err
+	}
+	return store.Put(NewEntry(key, modelKey, optionsKey, res))
+}
+
+// NewEntry builds a store entry from a transcription result.
+func NewEntry(key, modelKey, optionsKey string, res *whisper.Result) *Entry {
+	e := &Entry{Key: key, Model: modelKey, Options: optionsKey}
+	if res != nil {
+		e.Text = res.Text
+		e.Language = res.Language
+		e.Duration = res.Duration
+		e.Segments = res.Segments
+	}
+	return e
+}
+
+// VoiceKey returns the media key of a voice message, or "" for other messages.
+func VoiceKey(m *model.Message) string {
+	if m == nil || m.Type != model.MessageTypeVoice || m.Contents == nil {
+		return ""
+	}
+	key, _ := m.Contents["voice"].(string)
+	return key
+}
@@ -0,0 +1,138 @@
+package transcript
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/workdb"
+	"github.com/takeaway1/chatlog-TCOTC/internal/whisper"
+)
+
+const (
+	dirName  = "transcripts"
+	fileName = "transcripts.db"
+)
+
+// Entry is a stored transcription of one voice message.
+type Entry struct {
+	Key       string            `json:"key"`
+	Model     string            `json:"model"`
+	Options   string            `json:"options"`
+	Text      string            `json:"text"`
+	Language  string            `json:"language"`
+	Duration  time.Duration     `json:"duration"`
+	Segments  []whisper.Segment `json:"segments"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Store keeps transcripts in <work_dir>/transcripts/transcripts.db, keyed by
+// voice key, model and transcription options, so that reloads and index
+// rebuilds never re-run a backend for audio that has already been transcribed.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens or creates the transcript store under workDir.
+func Open(workDir string) (*Store, error) {
+	db, err := workdb.Open(workDir, dirName, fileName, `CREATE TABLE IF NOT EXISTS transcripts (
+voice_key   TEXT NOT NULL,
+model       TEXT NOT NULL,
+options     TEXT NOT NULL,
+text        TEXT NOT NULL,
+language    TEXT NOT NULL,
+duration_ms INTEGER NOT NULL,
+segments    TEXT NOT NULL,
+created_at  INTEGER NOT NULL,
+PRIMARY KEY (voice_key, model, options)
+);`)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close releases the underlying database.
+func (s *Store) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Get returns the transcript for key produced by model with options, or nil
+// when none has been stored.
+func (s *Store) Get(key, model, options string) (*Entry, error) {
+	row := s.db.QueryRow(`SELECT text, language, duration_ms, segments, created_at FROM transcripts
+WHERE voice_key = ? AND model = ? AND options = ?`, key, model, options)
+
+	e := &Entry{Key: key, Model: model, Options: options}
+	var durationMs, createdAt int64
+	var segments string
+	if err := row.Scan(&e.Text, &e.Language, &durationMs, &segments, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	e.Duration = time.Duration(durationMs) * time.Millisecond
+	e.CreatedAt = time.Unix(createdAt, 0)
+	if segments != "" {
+		_ = json.Unmarshal([]byte(segments), &e.Segments)
+	}
+	return e, nil
+}
+
+// Put stores or replaces a transcript.
+func (s *Store) Put(e *Entry) error {
+	if e == nil || e.Key == "" {
+		return errors.New("empty transcript key")
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	segments, err := json.Marshal(e.Segments)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO transcripts (voice_key, model, options, text, language, duration_ms, segments, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(voice_key, model, options) DO UPDATE SET
+text = excluded.text,
+language = excluded.language,
+duration_ms = excluded.duration_ms,
+segments = excluded.segments,
+created_at = excluded.created_at`,
+		e.Key, e.Model, e.Options, e.Text, e.Language, e.Duration.Milliseconds(), string(segments), e.CreatedAt.Unix())
+	return err
+}
+
+// Lookup returns the most recent transcript text for key regardless of model
+// and options; it is used to enrich voice messages when they are indexed.
+func (s *Store) Lookup(key string) string {
+	if s == nil || key == "" {
+		return ""
+	}
+	var text string
+	if err := s.db.QueryRow(`SELECT text FROM transcripts WHERE voice_key = ? AND text != ''
+ORDER BY created_at DESC LIMIT 1`, key).Scan(&text); err != nil {
+		return ""
+	}
+	return text
+}
+
+// OptionsKey condenses the options that change transcription output into a
+// short stable key. Threads only affect speed and are ignored.
+func OptionsKey(opts whisper.Options) string {
+	raw := "lang=" + opts.Language +
+		";translate=" + strconv.FormatBool(opts.Translate) +
+		";prompt=" + opts.InitialPrompt +
+		";temperature=" + strconv.FormatFloat(float64(opts.Temperature), 'f', 2, 32) +
+		";floor=" + strconv.FormatFloat(float64(opts.TemperatureFloor), 'f', 2, 32)
+	sum := sha1.Sum([]byte(raw))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,150 @@
+package transcript
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb"
+	"github.com/takeaway1/chatlog-TCOTC/internal/whisper"
+)
+
+type fakeSource struct {
+	messages []*model.Message
+	indexed  []*model.Message
+}
+
+func (f *fakeSource) GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
+	return &wechatdb.GetSessionsResp{Items: []*model.Session{{UserName: "alice"}}}, nil
+}
+
+func (f *fakeSource) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	if offset > 0 {
+		return nil, nil
+	}
+	return f.messages, nil
+}
+
+func (f *fakeSource) GetMedia(_type string, key string) (*model.Media, error) {
+	return &model.Media{Type: "voice", Data: []byte(key)}, nil
+}
+
+func (f *fakeSource) IndexMessages(messages []*model.Message) error {
+	f.indexed = append(f.indexed, messages...)
+	return nil
+}
+
+type fakeTranscriber struct {
+	calls atomic.Int32
+}
+
+func (f *fakeTranscriber) Close() {}
+
+func (f *fakeTranscriber) TranscribePCM(ctx context.Context, samples []float32, sampleRate int, opts whisper.Options) (*whisper.Result, error) {
+	return nil, nil
+}
+
+func (f *fakeTranscriber) TranscribeSilk(ctx context.Context, silkData []byte, opts whisper.Options) (*whisper.Result, error) {
+	f.calls.Add(1)
+	return &whisper.Result{Text: "text of " + string(silkData), Language: "zh"}, nil
+}
+
+func voice(seq int64, key string) *model.Message {
+	return &model.Message{Seq: seq, Talker: "alice", Type: model.MessageTypeVoice, Contents: map[string]interface{}{"voice": key}}
+}
+
+func TestRunnerCachesTranscripts(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	src := &fakeSource{messages: []*model.Message{
+		voice(1, "v1"),
+		{Seq: 2, Talker: "alice", Type: model.MessageTypeText, Content: "hello"},
+		voice(3, "v2"),
+	}}
+	tr := &fakeTranscriber{}
+	opts := JobOptions{Model: "openai:whisper-1", Options: whisper.Options{Language: "zh"}}
+
+	var r Runner
+	wait := func() Progress {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if p := r.Progress(); !p.Running {
+				return p
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("job did not finish")
+		return Progress{}
+	}
+
+	if err := r.Start(src, store, tr, opts); err != nil {
+		t.Fatal(err)
+	}
+	if p := wait(); p.Total != 2 || p.Done != 2 || p.Cached != 0 || p.Failed != 0 {
+		t.Fatalf("first run progress = %+v", p)
+	}
+	if len(src.indexed) != 2 {
+		t.Fatalf("indexed %d messages, want 2", len(src.indexed))
+	}
+	if got := store.Lookup("v2"); got != "text of v2" {
+		t.Fatalf("Lookup = %q", got)
+	}
+
+	// A second run must reuse the stored transcripts instead of the backend.
+	if err := r.Start(src, store, tr, opts); err != nil {
+		t.Fatal(err)
+	}
+	if p := wait(); p.Cached != 2 {
+		t.Fatalf("second run progress = %+v", p)
+	}
+	if n := tr.calls.Load(); n != 2 {
+		t.Fatalf("backend called %d times, want 2", n)
+	}
+
+	// Different options are cached separately.
+	other := OptionsKey(whisper.Options{Language: "en"})
+	if e, err := store.Get("v1", opts.Model, other); err != nil || e != nil {
+		t.Fatalf("Get with other options = %+v, %v", e, err)
+	}
+}
+
+// blockingTranscriber blocks until its context is cancelled.
+type blockingTranscriber struct {
+	fakeTranscriber
+	started chan struct{}
+	exited  atomic.Bool
+}
+
+func (b *blockingTranscriber) TranscribeSilk(ctx context.Context, silkData []byte, opts whisper.Options) (*whisper.Result, error) {
+	close(b.started)
+	<-ctx.Done()
+	time.Sleep(20 * time.Millisecond)
+	b.exited.Store(true)
+	return nil, ctx.Err()
+}
+
+func TestRunnerCancelWaitsForWorker(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	tr := &blockingTranscriber{started: make(chan struct{})}
+	var r Runner
+	if err := r.Start(&fakeSource{messages: []*model.Message{voice(1, "v1")}}, store, tr, JobOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	<-tr.started
+
+	r.Cancel()
+	if !tr.exited.Load() || r.Progress().Running {
+		t.Fatal("Cancel returned before the worker stopped using the transcriber")
+	}
+}
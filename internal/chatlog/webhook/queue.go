@@ -0,0 +1,535 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/chatlog/conf"
+)
+
+const (
+	DefaultMaxAttempts = 8
+
+	retryBaseDelay = 5 * time.Second
+	retryMaxDelay  = 30 * time.Minute
+
+	pendingDirName     = "pending"
+	deadLetterFileName = "dead-letter.jsonl"
+
+	HeaderDelivery  = "X-Chatlog-Delivery"
+	HeaderTimestamp = "X-Chatlog-Timestamp"
+	HeaderSignature = "X-Chatlog-Signature"
+)
+
+// Delivery is a single outbound webhook payload. It is persisted as JSON
+// so that payloads survive receiver outages and process restarts.
+type Delivery struct {
+	ID   string          `json:"id"`
+	URL  string          `json:"url"`
+	Body json.RawMessage `json:"body"`
+	// Hook identifies the configured hook whose secret signs the payload.
+	// The secret itself is looked up when sending and never persisted.
+	Hook        string     `json:"hook,omitempty"`
+	Attempts    int        `json:"attempts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	NextAttempt time.Time  `json:"next_attempt"`
+	LastError   string     `json:"last_error,omitempty"`
+	FailedAt    *time.Time `json:"failed_at,omitempty"`
+
+	// secret is the inline secret of a delivery queued by an older version.
+	// It is kept in memory only, after being stripped from the pending file.
+	secret string
+}
+
+// legacyDelivery reads the inline secret older versions persisted.
+type legacyDelivery struct {
+	Secret string `json:"secret"`
+}
+
+// HookID returns a stable identifier for a configured hook of the given kind.
+// It is derived from the hook's URL and filters but not from its secret.
+func HookID(kind string, item *conf.WebhookItem) string {
+	h := sha256.New()
+	for _, field := range []string{item.URL, item.Talker, item.Sender, item.Keyword} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return kind + ":" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Queue delivers webhook payloads in order per URL, retrying failures with
+// exponential backoff. Pending deliveries live one file each under
+// <work_dir>/webhook/pending; deliveries that exhaust their attempts are
+// appended to <work_dir>/webhook/dead-letter.jsonl.
+type Queue struct {
+	dir         string
+	client      *http.Client
+	maxAttempts int
+
+	mu      sync.Mutex
+	pending map[string]*Delivery
+	secrets map[string]string
+	wake    chan struct{}
+}
+
+// NewQueue opens the queue rooted at dir and reloads pending deliveries.
+func NewQueue(dir string, maxAttempts int) (*Queue, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if err := os.MkdirAll(filepath.Join(dir, pendingDirName), 0o755); err != nil {
+		return nil, fmt.Errorf("create webhook queue dir: %w", err)
+	}
+
+	q := &Queue{
+		dir:         dir,
+		client:      &http.Client{Timeout: time.Second * 10},
+		maxAttempts: maxAttempts,
+		pending:     make(map[string]*Delivery),
+		secrets:     make(map[string]string),
+		wake:        make(chan struct{}, 1),
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, pendingDirName))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, pendingDirName, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var d Delivery
+		if err := json.Unmarshal(data, &d); err != nil || d.ID == "" {
+			log.Warn().Err(err).Str("file", path).Msg("skip corrupted webhook delivery")
+			continue
+		}
+		var legacy legacyDelivery
+		if json.Unmarshal(data, &legacy) == nil && legacy.Secret != "" {
+			d.secret = legacy.Secret
+			if err := q.savePendingLocked(&d); err != nil {
+				return nil, err
+			}
+		}
+		q.pending[d.ID] = &d
+	}
+	if len(q.pending) > 0 {
+		log.Info().Int("pending", len(q.pending)).Msg("webhook queue restored pending deliveries")
+	}
+	if err := q.stripDeadLetterSecrets(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// SetSecret registers the signing secret of hook. Deliveries of a hook are
+// signed with its current secret; an empty secret sends them unsigned.
+func (q *Queue) SetSecret(hook, secret string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.secrets[hook] = secret
+}
+
+// Enqueue persists a payload for url and schedules immediate delivery.
+// Every attempt is signed with the secret registered for hook.
+func (q *Queue) Enqueue(url, hook string, body []byte) (*Delivery, error) {
+	now := time.Now()
+	d := &Delivery{
+		ID:          newDeliveryID(now),
+		URL:         url,
+		Body:        json.RawMessage(body),
+		Hook:        hook,
+		CreatedAt:   now,
+		NextAttempt: now,
+	}
+
+	q.mu.Lock()
+	err := q.savePendingLocked(d)
+	if err == nil {
+		q.pending[d.ID] = d
+	}
+	q.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	q.notify()
+	return d, nil
+}
+
+// Run delivers due payloads until ctx is cancelled.
+func (q *Queue) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		next := q.deliverDue(ctx)
+
+		wait := time.Until(next)
+		if next.IsZero() {
+			wait = time.Hour
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// Pending returns the number of deliveries waiting to be sent.
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// DeadLetters lists deliveries that exhausted their retries, oldest first.
+func (q *Queue) DeadLetters() ([]*Delivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.readDeadLettersLocked()
+}
+
+// Replay moves the dead letters with the given ids (all when ids is empty)
+// back into the pending queue with a fresh retry budget.
+func (q *Queue) Replay(ids []string) (int, error) {
+	want := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" {
+			want[id] = struct{}{}
+		}
+	}
+
+	q.mu.Lock()
+	letters, err := q.readDeadLettersLocked()
+	if err != nil {
+		q.mu.Unlock()
+		return 0, err
+	}
+
+	now := time.Now()
+	remaining := make([]*Delivery, 0, len(letters))
+	replayed := 0
+	for _, d := range letters {
+		if _, ok := want[d.ID]; len(want) > 0 && !ok {
+			remaining = append(remaining, d)
+			continue
+		}
+		d.Attempts = 0
+		d.NextAttempt = now
+		d.LastError = ""
+		d.FailedAt = nil
+		if err := q.savePendingLocked(d); err != nil {
+			q.mu.Unlock()
+			return replayed, err
+		}
+		q.pending[d.ID] = d
+		replayed++
+	}
+	if replayed > 0 {
+		err = q.writeDeadLettersLocked(remaining)
+	}
+	q.mu.Unlock()
+
+	if replayed > 0 {
+		q.notify()
+	}
+	return replayed, err
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue sends every due delivery and returns when the next one is due.
+// Deliveries to the same URL go out in creation order; a failure holds back
+// the later ones so receivers never see messages out of order.
+func (q *Queue) deliverDue(ctx context.Context) time.Time {
+	q.mu.Lock()
+	queue := make([]*Delivery, 0, len(q.pending))
+	for _, d := range q.pending {
+		queue = append(queue, d)
+	}
+	q.mu.Unlock()
+
+	sort.Slice(queue, func(i, j int) bool {
+		if !queue[i].CreatedAt.Equal(queue[j].CreatedAt) {
+			return queue[i].CreatedAt.Before(queue[j].CreatedAt)
+		}
+		return queue[i].ID < queue[j].ID
+	})
+
+	blocked := make(map[string]time.Time)
+	var next time.Time
+	for _, d := range queue {
+		if ctx.Err() != nil {
+			return time.Time{}
+		}
+		if until, ok := blocked[d.URL]; ok {
+			next = earliest(next, until)
+			continue
+		}
+		if time.Now().Before(d.NextAttempt) {
+			blocked[d.URL] = d.NextAttempt
+			next = earliest(next, d.NextAttempt)
+			continue
+		}
+
+		err := q.send(ctx, d)
+		if err == nil {
+			q.complete(d)
+			continue
+		}
+		if ctx.Err() != nil {
+			return time.Time{}
+		}
+		if q.fail(d, err) {
+			blocked[d.URL] = d.NextAttempt
+			next = earliest(next, d.NextAttempt)
+		}
+	}
+	return next
+}
+
+func (q *Queue) send(ctx context.Context, d *Delivery) error {
+	secret := d.secret
+	if d.Hook != "" {
+		q.mu.Lock()
+		s, ok := q.secrets[d.Hook]
+		q.mu.Unlock()
+		if !ok {
+			return fmt.Errorf("webhook %s is not configured", d.Hook)
+		}
+		secret = s
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, timestamp, d.Body))
+	}
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign computes the signature header value: hex HMAC-SHA256 over
+// "<timestamp>.<body>" prefixed with "sha256=".
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (q *Queue) complete(d *Delivery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, d.ID)
+	if err := os.Remove(q.pendingPath(d.ID)); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("id", d.ID).Msg("remove delivered webhook payload failed")
+	}
+	log.Debug().Str("id", d.ID).Str("url", d.URL).Int("attempts", d.Attempts+1).Msg("webhook delivered")
+}
+
+// fail records a failed attempt. It returns true when the delivery stays
+// queued and false when it has been moved to the dead-letter file.
+func (q *Queue) fail(d *Delivery, cause error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	d.Attempts++
+	d.LastError = cause.Error()
+
+	if d.Attempts >= q.maxAttempts {
+		now := time.Now()
+		d.FailedAt = &now
+		delete(q.pending, d.ID)
+		if err := q.appendDeadLetterLocked(d); err != nil {
+			log.Error().Err(err).Str("id", d.ID).Msg("write webhook dead letter failed")
+		}
+		_ = os.Remove(q.pendingPath(d.ID))
+		log.Error().Str("id", d.ID).Str("url", d.URL).Int("attempts", d.Attempts).Str("error", d.LastError).Msg("webhook delivery moved to dead letter")
+		return false
+	}
+
+	d.NextAttempt = time.Now().Add(backoff(d.Attempts))
+	if err := q.savePendingLocked(d); err != nil {
+		log.Warn().Err(err).Str("id", d.ID).Msg("persist webhook retry state failed")
+	}
+	log.Warn().Str("id", d.ID).Str("url", d.URL).Int("attempts", d.Attempts).Time("next_attempt", d.NextAttempt).Str("error", d.LastError).Msg("webhook delivery failed, will retry")
+	return true
+}
+
+func backoff(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= retryMaxDelay {
+			return retryMaxDelay
+		}
+	}
+	return delay
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func (q *Queue) pendingPath(id string) string {
+	return filepath.Join(q.dir, pendingDirName, id+".json")
+}
+
+func (q *Queue) savePendingLocked(d *Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	path := q.pendingPath(d.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (q *Queue) appendDeadLetterLocked(d *Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(q.dir, deadLetterFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (q *Queue) readDeadLettersLocked() ([]*Delivery, error) {
+	f, err := os.Open(filepath.Join(q.dir, deadLetterFileName))
+	if os.IsNotExist(err) {
+		return []*Delivery{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	letters := make([]*Delivery, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var d Delivery
+		if err := json.Unmarshal(line, &d); err != nil {
+			log.Warn().Err(err).Msg("skip corrupted webhook dead letter")
+			continue
+		}
+		letters = append(letters, &d)
+	}
+	return letters, scanner.Err()
+}
+
+// stripDeadLetterSecrets rewrites a dead-letter file written by an older
+// version without the inline secrets.
+func (q *Queue) stripDeadLetterSecrets() error {
+	data, err := os.ReadFile(filepath.Join(q.dir, deadLetterFileName))
+	if os.IsNotExist(err) || (err == nil && !bytes.Contains(data, []byte(`"secret":`))) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	letters, err := q.readDeadLettersLocked()
+	if err != nil {
+		return err
+	}
+	return q.writeDeadLettersLocked(letters)
+}
+
+func (q *Queue) writeDeadLettersLocked(letters []*Delivery) error {
+	var buf bytes.Buffer
+	for _, d := range letters {
+		data, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	path := filepath.Join(q.dir, deadLetterFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func newDeliveryID(now time.Time) string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%d-%s", now.UnixNano(), hex.EncodeToString(b[:]))
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueueDeadLetterAndReplay(t *testing.T) {
+	var healthy atomic.Bool
+	received := make(chan *http.Request, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(HeaderSignature), Sign("s3cret", r.Header.Get(HeaderTimestamp), body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		received <- r
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	q, err := NewQueue(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.SetSecret("message:a", "s3cret")
+	d, err := q.Enqueue(srv.URL, "message:a", []byte(`{"length":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.deliverDue(context.Background())
+
+	letters, err := q.DeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].ID != d.ID || q.Pending() != 0 {
+		t.Fatalf("expected one dead letter, got %d (pending %d)", len(letters), q.Pending())
+	}
+	if letters[0].Hook != "message:a" {
+		t.Fatalf("hook = %q", letters[0].Hook)
+	}
+	assertNoSecret(t, dir)
+
+	// A fresh queue must see the same dead letter on disk, and the replayed
+	// delivery is signed with the secret configured for its hook.
+	q, err = NewQueue(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.SetSecret("message:a", "s3cret")
+	healthy.Store(true)
+	if n, err := q.Replay(nil); err != nil || n != 1 {
+		t.Fatalf("Replay = %d, %v", n, err)
+	}
+	q.deliverDue(context.Background())
+
+	select {
+	case r := <-received:
+		if r.Header.Get(HeaderDelivery) != d.ID {
+			t.Errorf("delivery id = %q, want %q", r.Header.Get(HeaderDelivery), d.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("replayed delivery not received")
+	}
+	if letters, _ := q.DeadLetters(); len(letters) != 0 || q.Pending() != 0 {
+		t.Fatalf("queue not drained: %d dead letters, %d pending", len(letters), q.Pending())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if got := backoff(1); got != retryBaseDelay {
+		t.Errorf("backoff(1) = %v", got)
+	}
+	if got := backoff(3); got != 4*retryBaseDelay {
+		t.Errorf("backoff(3) = %v", got)
+	}
+	if got := backoff(30); got != retryMaxDelay {
+		t.Errorf("backoff(30) = %v", got)
+	}
+}
+
+// assertNoSecret fails when any file under dir contains the signing secret.
+func assertNoSecret(t *testing.T, dir string) {
+	t.Helper()
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte("s3cret")) {
+			t.Errorf("%s contains the signing secret", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueueSecretNotPersisted(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewQueue(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.SetSecret("message:a", "s3cret")
+	if _, err := q.Enqueue("http://127.0.0.1:0", "message:a", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	assertNoSecret(t, dir)
+
+	// Deliveries whose hook is no longer configured are retried, not sent unsigned.
+	q, err = NewQueue(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.deliverDue(context.Background())
+	var d *Delivery
+	for _, pending := range q.pending {
+		d = pending
+	}
+	if d == nil || d.Attempts != 1 || !strings.Contains(d.LastError, "not configured") {
+		t.Fatalf("delivery = %+v", d)
+	}
+
+	// Files written by older versions carried the secret inline; it is
+	// stripped on load and still signs the pending delivery.
+	legacy := `{"id":"1-legacy","url":"http://127.0.0.1:0","body":{},"secret":"s3cret"}`
+	if err := os.WriteFile(filepath.Join(dir, pendingDirName, "1-legacy.json"), []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, deadLetterFileName), []byte(legacy+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	q, err = NewQueue(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNoSecret(t, dir)
+	if d := q.pending["1-legacy"]; d == nil || d.secret != "s3cret" {
+		t.Fatalf("legacy delivery = %+v", d)
+	}
+	if letters, err := q.DeadLetters(); err != nil || len(letters) != 1 {
+		t.Fatalf("dead letters = %d, %v", len(letters), err)
+	}
+}
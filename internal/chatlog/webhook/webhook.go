@@ -1,10 +1,10 @@
 package webhook
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"net/http"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -16,6 +16,7 @@ import (
 
 type Config interface {
 	GetWebhook() *conf.Webhook
+	GetWorkDir() string
 }
 
 type Webhook interface {
@@ -23,12 +24,15 @@ type Webhook interface {
 }
 
 type Service struct {
+	conf   Config
 	config *conf.Webhook
 	hooks  map[string][]*conf.WebhookItem
+	queue  *Queue
 }
 
 func New(config Config) *Service {
 	s := &Service{
+		conf:   config,
 		config: config.GetWebhook(),
 	}
 
@@ -59,9 +63,42 @@ func New(config Config) *Service {
 	return s
 }
 
+// Queue returns the delivery queue, or nil when webhooks are not configured.
+func (s *Service) Queue() *Queue {
+	return s.queue
+}
+
+// startQueue opens the persistent delivery queue under <work_dir>/webhook.
+// It also runs without active hooks so earlier dead letters stay replayable.
+func (s *Service) startQueue(ctx context.Context) error {
+	if s.config == nil {
+		return nil
+	}
+	workDir := strings.TrimSpace(s.conf.GetWorkDir())
+	if workDir == "" {
+		return nil
+	}
+
+	queue, err := NewQueue(filepath.Join(workDir, "webhook"), s.config.MaxAttempts)
+	if err != nil {
+		return err
+	}
+	// 先登记各 hook 的签名密钥，重启后恢复的投递按当前配置签名
+	for _, item := range s.config.Items {
+		queue.SetSecret(HookID(item.Type, item), item.Secret)
+	}
+	s.queue = queue
+	go queue.Run(ctx)
+	return nil
+}
+
 func (s *Service) GetHooks(ctx context.Context, db *wechatdb.DB) []*Group {
+	if err := s.startQueue(ctx); err != nil {
+		log.Error().Err(err).Msg("start webhook queue failed")
+		return nil
+	}
 
-	if len(s.hooks) == 0 {
+	if len(s.hooks) == 0 || s.queue == nil {
 		return nil
 	}
 
@@ -69,7 +106,7 @@ func (s *Service) GetHooks(ctx context.Context, db *wechatdb.DB) []*Group {
 	for group, items := range s.hooks {
 		hooks := make([]Webhook, 0)
 		for _, item := range items {
-			hooks = append(hooks, NewMessageWebhook(item, db, s.config.Host))
+			hooks = append(hooks, NewMessageWebhook(item, db, s.config.Host, s.queue))
 		}
 		groups = append(groups, NewGroup(ctx, group, hooks, s.config.DelayMs))
 	}
@@ -139,16 +176,16 @@ func (g *Group) do(event fsnotify.Event) {
 type MessageWebhook struct {
 	host     string
 	conf     *conf.WebhookItem
-	client   *http.Client
+	queue    *Queue
 	db       *wechatdb.DB
 	lastTime time.Time
 }
 
-func NewMessageWebhook(conf *conf.WebhookItem, db *wechatdb.DB, host string) *MessageWebhook {
+func NewMessageWebhook(conf *conf.WebhookItem, db *wechatdb.DB, host string, queue *Queue) *MessageWebhook {
 	m := &MessageWebhook{
 		host:     host,
 		conf:     conf,
-		client:   &http.Client{Timeout: time.Second * 10},
+		queue:    queue,
 		db:       db,
 		lastTime: time.Now(),
 	}
@@ -185,18 +222,17 @@ func (m *MessageWebhook) Do(event fsnotify.Event) {
 		"length":   len(messages),
 		"messages": messages,
 	}
-	body, _ := json.Marshal(ret)
-	req, _ := http.NewRequest("POST", m.conf.URL, bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-
-	log.Info().Msgf("post messages to %s, body: %s", m.conf.URL, string(body))
-	resp, err := m.client.Do(req)
+	body, err := json.Marshal(ret)
 	if err != nil {
-		log.Error().Err(err).Msgf("post messages failed")
+		log.Error().Err(err).Msg("marshal webhook payload failed")
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Error().Msgf("post messages failed, status code: %d", resp.StatusCode)
+	// lastTime 已经前移，payload 必须先落盘再投递，接收方宕机时由队列负责重试
+	d, err := m.queue.Enqueue(m.conf.URL, HookID(m.conf.Type, m.conf), body)
+	if err != nil {
+		log.Error().Err(err).Msgf("enqueue messages for %s failed", m.conf.URL)
+		return
 	}
+	log.Info().Msgf("queued %d messages for %s, delivery: %s", len(messages), m.conf.URL, d.ID)
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechat"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechat/decrypt"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechat/decrypt/common"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/filemonitor"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
 )
@@ -27,10 +28,18 @@ type Service struct {
 	conf           Config
 	lastEvents     map[string]time.Time
 	pendingActions map[string]bool
+	lastPass       *DecryptPass
 	mutex          sync.Mutex
 	fm             *filemonitor.FileMonitor
 }
 
+// DecryptPass 描述一次数据库解密处理：文件名、完成时间与重写的页数
+type DecryptPass struct {
+	File string    `json:"file"`
+	Time time.Time `json:"time"`
+	common.DecryptStats
+}
+
 type Config interface {
 	GetDataKey() string
 	GetDataDir() string
@@ -155,34 +164,49 @@ func (s *Service) DecryptDBFile(dbFile string) error {
 		return err
 	}
 
-	outputTemp := output + ".tmp"
-	outputFile, err := os.Create(outputTemp)
+	stats, err := decryptor.DecryptIncremental(context.Background(), dbFile, s.conf.GetDataKey(), output)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
-	}
-	defer func() {
-		outputFile.Close()
-		if err := os.Rename(outputTemp, output); err != nil {
-			log.Debug().Err(err).Msgf("failed to rename %s to %s", outputTemp, output)
-		}
-	}()
-
-	if err := decryptor.Decrypt(context.Background(), dbFile, s.conf.GetDataKey(), outputFile); err != nil {
 		if err == errors.ErrAlreadyDecrypted {
-			if data, err := os.ReadFile(dbFile); err == nil {
-				outputFile.Write(data)
-			}
-			return nil
+			return copyPlainDB(dbFile, output)
 		}
 		log.Err(err).Msgf("failed to decrypt %s", dbFile)
 		return err
 	}
 
-	log.Debug().Msgf("Decrypted %s to %s", dbFile, output)
+	s.mutex.Lock()
+	s.lastPass = &DecryptPass{File: filepath.Base(dbFile), Time: time.Now(), DecryptStats: *stats}
+	s.mutex.Unlock()
+
+	log.Debug().Msgf("Decrypted %s to %s, %d/%d pages rewritten (full: %v)", dbFile, output, stats.ChangedPages, stats.TotalPages, stats.Full)
 
 	return nil
 }
 
+// LastDecryptPass 返回最近一次解密处理的统计，尚未解密时为 nil
+func (s *Service) LastDecryptPass() *DecryptPass {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.lastPass == nil {
+		return nil
+	}
+	pass := *s.lastPass
+	return &pass
+}
+
+// copyPlainDB 处理未加密的数据库文件，直接复制到工作目录
+func copyPlainDB(dbFile, output string) error {
+	data, err := os.ReadFile(dbFile)
+	if err != nil {
+		return err
+	}
+	outputTemp := output + ".tmp"
+	if err := os.WriteFile(outputTemp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	os.Remove(output + common.PageStateSuffix)
+	return os.Rename(outputTemp, output)
+}
+
 func (s *Service) DecryptDBFiles() error {
 	dbGroup, err := filemonitor.NewFileGroup("wechat", s.conf.GetDataDir(), `.*\.db$`, []string{"fts"})
 	if err != nil {
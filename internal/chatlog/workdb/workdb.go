@@ -0,0 +1,30 @@
+// Package workdb opens the small sqlite databases chatlog keeps under the
+// work directory, such as cached transcripts and summaries.
+package workdb
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open opens or creates <workDir>/<dir>/<file> in WAL mode and applies schema,
+// which must be idempotent (CREATE ... IF NOT EXISTS).
+func Open(workDir, dir, file, schema string) (*sql.DB, error) {
+	path := filepath.Join(workDir, dir)
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("create %s dir: %w", dir, err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(path, file)+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init %s schema: %w", dir, err)
+	}
+	return db, nil
+}
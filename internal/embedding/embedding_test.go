@@ -0,0 +1,77 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeAndDot(t *testing.T) {
+	v := Normalize([]float32{3, 4})
+	if math.Abs(float64(v[0])-0.6) > 1e-6 || math.Abs(float64(v[1])-0.8) > 1e-6 {
+		t.Fatalf("normalize = %v", v)
+	}
+	if zero := Normalize([]float32{0, 0}); zero[0] != 0 || zero[1] != 0 {
+		t.Fatalf("normalize zero = %v", zero)
+	}
+	if got := Dot(v, v); math.Abs(float64(got)-1) > 1e-6 {
+		t.Fatalf("dot = %v", got)
+	}
+	if got := Dot([]float32{1}, []float32{1, 2}); got != 0 {
+		t.Fatalf("dot mismatched = %v", got)
+	}
+}
+
+func TestWebServiceEmbedder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			http.NotFound(w, r)
+			return
+		}
+		var req webServiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "bge-m3" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		resp := webServiceResponse{}
+		for range req.Input {
+			resp.Embeddings = append(resp.Embeddings, []float32{0, 2})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	e, err := NewWebServiceEmbedder(WebServiceConfig{BaseURL: srv.URL + "/", Model: "bge-m3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || vectors[1][1] != 1 {
+		t.Fatalf("vectors = %v", vectors)
+	}
+
+	if _, err := NewWebServiceEmbedder(WebServiceConfig{BaseURL: srv.URL}); err == nil {
+		t.Fatal("expected error for missing model")
+	}
+}
+
+func TestWebServiceEmbedderCountMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(webServiceResponse{Embeddings: [][]float32{{1}}})
+	}))
+	defer srv.Close()
+
+	e, err := NewWebServiceEmbedder(WebServiceConfig{BaseURL: srv.URL, Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Embed(context.Background(), []string{"a", "b"}); err == nil {
+		t.Fatal("expected error when the service returns fewer vectors")
+	}
+}
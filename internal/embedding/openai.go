@@ -0,0 +1,121 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	openai "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+const defaultOpenAIModel = "text-embedding-3-small"
+
+// OpenAIConfig describes how to initialise an OpenAI-compatible embedder.
+type OpenAIConfig struct {
+	Model          string
+	APIKey         string
+	BaseURL        string
+	Organization   string
+	ProxyURL       string
+	Dimensions     int
+	RequestTimeout time.Duration
+}
+
+// OpenAIEmbedder calls the /embeddings endpoint of OpenAI or any compatible service.
+type OpenAIEmbedder struct {
+	client     *openai.Client
+	model      string
+	dimensions int
+}
+
+// NewOpenAIEmbedder builds a new instance of the OpenAI embedding backend.
+func NewOpenAIEmbedder(cfg OpenAIConfig) (*OpenAIEmbedder, error) {
+	model := strings.TrimSpace(cfg.Model)
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+
+	var opts []option.RequestOption
+	if cfg.APIKey != "" {
+		opts = append(opts, option.WithAPIKey(cfg.APIKey))
+	}
+	if cfg.Organization != "" {
+		opts = append(opts, option.WithOrganization(cfg.Organization))
+	}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+	if cfg.ProxyURL != "" {
+		parsed, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(parsed)
+		opts = append(opts, option.WithHTTPClient(&http.Client{Transport: transport, Timeout: cfg.RequestTimeout}))
+	} else if cfg.RequestTimeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(cfg.RequestTimeout))
+	}
+
+	client := openai.NewClient(opts...)
+	return &OpenAIEmbedder{
+		client:     &client,
+		model:      model,
+		dimensions: cfg.Dimensions,
+	}, nil
+}
+
+// Close releases resources held by the embedder. No-op for the OpenAI backend.
+func (e *OpenAIEmbedder) Close() {}
+
+// ModelName returns the embedding model identifier currently in use.
+func (e *OpenAIEmbedder) ModelName() string {
+	if e.dimensions > 0 {
+		return fmt.Sprintf("%s@%d", e.model, e.dimensions)
+	}
+	return e.model
+}
+
+// Embed sends texts in a single request and returns normalized vectors.
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	params := openai.EmbeddingNewParams{
+		Input:          openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+		Model:          openai.EmbeddingModel(e.model),
+		EncodingFormat: openai.EmbeddingNewParamsEncodingFormatFloat,
+	}
+	if e.dimensions > 0 {
+		params.Dimensions = openai.Int(int64(e.dimensions))
+	}
+
+	resp, err := e.client.Embeddings.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("openai embedding failed: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("openai embedding returned %d vectors for %d inputs", len(resp.Data), len(texts))
+	}
+
+	out := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || int(item.Index) >= len(out) {
+			return nil, fmt.Errorf("openai embedding returned out of range index %d", item.Index)
+		}
+		vec := make([]float32, len(item.Embedding))
+		for i, v := range item.Embedding {
+			vec[i] = float32(v)
+		}
+		out[item.Index] = Normalize(vec)
+	}
+	return out, nil
+}
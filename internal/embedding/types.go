@@ -0,0 +1,43 @@
+package embedding
+
+import (
+	"context"
+	"math"
+)
+
+// Embedder converts text into dense vectors for semantic search.
+type Embedder interface {
+	// Embed returns one vector per input, in the same order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// ModelName identifies the model; vectors from different models are not comparable.
+	ModelName() string
+	Close()
+}
+
+// Normalize scales v to unit length in place so cosine similarity reduces to a dot product.
+func Normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= norm
+	}
+	return v
+}
+
+// Dot returns the dot product of two vectors; mismatched lengths yield 0.
+func Dot(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,120 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebServiceConfig controls the local HTTP embedding backend.
+// The request/response shape follows Ollama's /api/embed endpoint, which is
+// also implemented by most self-hosted embedding servers.
+type WebServiceConfig struct {
+	BaseURL        string
+	Model          string
+	RequestTimeout time.Duration
+}
+
+// WebServiceEmbedder posts batches to a local embedding server.
+type WebServiceEmbedder struct {
+	client  *http.Client
+	baseURL string
+	model   string
+}
+
+// NewWebServiceEmbedder constructs an embedder for a local HTTP service.
+func NewWebServiceEmbedder(cfg WebServiceConfig) (*WebServiceEmbedder, error) {
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("embedding service URL cannot be empty")
+	}
+	model := strings.TrimSpace(cfg.Model)
+	if model == "" {
+		return nil, fmt.Errorf("embedding model cannot be empty")
+	}
+
+	httpClient := &http.Client{}
+	if cfg.RequestTimeout > 0 {
+		httpClient.Timeout = cfg.RequestTimeout
+	}
+
+	return &WebServiceEmbedder{
+		client:  httpClient,
+		baseURL: baseURL,
+		model:   model,
+	}, nil
+}
+
+// Close releases resources held by the webservice embedder. No-op for HTTP client.
+func (e *WebServiceEmbedder) Close() {}
+
+// ModelName returns the configured model identifier.
+func (e *WebServiceEmbedder) ModelName() string {
+	return e.model
+}
+
+type webServiceRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type webServiceResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Error      string      `json:"error"`
+}
+
+// Embed sends texts to <base>/api/embed and returns normalized vectors.
+func (e *WebServiceEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	payload, err := json.Marshal(webServiceRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/embed", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("embedding service error (%d): %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+
+	var decoded webServiceResponse
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("decode embedding response: %w", err)
+	}
+	if decoded.Error != "" {
+		return nil, fmt.Errorf("embedding service error: %s", decoded.Error)
+	}
+	if len(decoded.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedding service returned %d vectors for %d inputs", len(decoded.Embeddings), len(texts))
+	}
+
+	for i := range decoded.Embeddings {
+		decoded.Embeddings[i] = Normalize(decoded.Embeddings[i])
+	}
+	return decoded.Embeddings, nil
+}
@@ -73,3 +73,24 @@ func FileGroupNotFound(name string) *Error {
 func SearchNotSupported(platform string, version int) *Error {
 	return Newf(nil, http.StatusNotImplemented, "search not supported for %s v%d", platform, version).WithStack()
 }
+
+func SemanticSearchDisabled() *Error {
+	return New(nil, http.StatusBadRequest, "semantic search not enabled: configure embedding provider").WithStack()
+}
+
+func SearchOffsetOutsideWindow(offset, window int) *Error {
+	return Newf(nil, http.StatusBadRequest, "hybrid search offset %d is outside the first %d results", offset, window).WithStack()
+}
+
+// 快照目录相关错误
+func SnapshotFlavorUnknown(path string) *Error {
+	return Newf(nil, http.StatusBadRequest, "no wechat databases found in snapshot: %s", path).WithStack()
+}
+
+func SnapshotFlavorAmbiguous(path string, flavors []string) *Error {
+	return Newf(nil, http.StatusBadRequest, "snapshot %s contains databases of several flavors: %v", path, flavors).WithStack()
+}
+
+func SnapshotNotDecrypted(file string) *Error {
+	return Newf(nil, http.StatusBadRequest, "snapshot database is not decrypted: %s", file).WithStack()
+}
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	openai "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+const defaultOpenAIModel = "gpt-4o-mini"
+
+// OpenAIConfig describes how to initialise an OpenAI-compatible chat client.
+type OpenAIConfig struct {
+	Model          string
+	APIKey         string
+	BaseURL        string
+	Organization   string
+	ProxyURL       string
+	RequestTimeout time.Duration
+}
+
+// OpenAIClient calls the /chat/completions endpoint of OpenAI or any compatible service.
+type OpenAIClient struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAIClient builds a new chat client.
+func NewOpenAIClient(cfg OpenAIConfig) (*OpenAIClient, error) {
+	model := strings.TrimSpace(cfg.Model)
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+
+	var opts []option.RequestOption
+	if cfg.APIKey != "" {
+		opts = append(opts, option.WithAPIKey(cfg.APIKey))
+	}
+	if cfg.Organization != "" {
+		opts = append(opts, option.WithOrganization(cfg.Organization))
+	}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+	if cfg.ProxyURL != "" {
+		parsed, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(parsed)
+		opts = append(opts, option.WithHTTPClient(&http.Client{Transport: transport, Timeout: cfg.RequestTimeout}))
+	} else if cfg.RequestTimeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(cfg.RequestTimeout))
+	}
+
+	client := openai.NewClient(opts...)
+	return &OpenAIClient{client: &client, model: model}, nil
+}
+
+// ModelName returns the chat model identifier currently in use.
+func (c *OpenAIClient) ModelName() string {
+	return c.model
+}
+
+// Complete sends the system instruction and user message and returns the first choice.
+func (c *OpenAIClient) Complete(ctx context.Context, system, user string) (string, error) {
+	resp, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(system),
+			openai.UserMessage(user),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("openai chat completion failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("openai chat completion returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,28 @@
+package llm
+
+import (
+	"context"
+	"unicode"
+)
+
+// Client sends a single-turn chat completion request.
+type Client interface {
+	// Complete returns the assistant reply for a system instruction and a user message.
+	Complete(ctx context.Context, system, user string) (string, error)
+	// ModelName identifies the model; cached outputs from different models are not interchangeable.
+	ModelName() string
+}
+
+// EstimateTokens approximates the token count of s without a tokenizer:
+// each CJK character counts as one token, other text as one token per four bytes.
+func EstimateTokens(s string) int {
+	cjk, other := 0, 0
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other += len(string(r))
+		}
+	}
+	return cjk + (other+3)/4
+}
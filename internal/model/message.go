@@ -381,39 +381,11 @@ func (m *Message) PlainTextContent() string {
 		}
 		return fmt.Sprintf("![图片](http://%s/image/%s)", host, strings.Join(keylist, ","))
 	case MessageTypeVoice:
-		if voice, ok := m.Contents["voice"]; ok {
-			// 可选时长字段（可能来源于不同表：voicelength/voiceduration/length 秒）
-			var durStr string
-			if d, ok2 := m.Contents["voicelength"]; ok2 {
-				durStr = fmt.Sprint(d)
-			}
-			if d, ok2 := m.Contents["voiceduration"]; ok2 {
-				durStr = fmt.Sprint(d)
-			}
-			if d, ok2 := m.Contents["length"]; ok2 && durStr == "" {
-				durStr = fmt.Sprint(d)
-			}
-			if durStr != "" {
-				// 规范成整数秒显示
-				if strings.Contains(durStr, ".") {
-					if f, err := strconv.ParseFloat(durStr, 64); err == nil {
-						durStr = fmt.Sprint(int(f + 0.5))
-					}
-				}
-				// >=60s 转换为 XmYYs 形式
-				if secInt, err := strconv.Atoi(durStr); err == nil {
-					if secInt >= 60 {
-						min := secInt / 60
-						sec := secInt % 60
-						fmtDur := fmt.Sprintf("%dm%02ds", min, sec)
-						return fmt.Sprintf("[语音(%s)](http://%s/voice/%s)", fmtDur, host, voice)
-					}
-				}
-				return fmt.Sprintf("[语音(%ss)](http://%s/voice/%s)", durStr, host, voice)
-			}
-			return fmt.Sprintf("[语音](http://%s/voice/%s)", host, voice)
+		text := m.voiceText(host)
+		if transcript, ok := m.Contents["transcript"].(string); ok && transcript != "" {
+			text += " " + transcript
 		}
-		return "[语音]"
+		return text
 	case MessageTypeCard:
 		return "[名片]"
 	case MessageTypeVideo:
@@ -617,3 +589,40 @@ func (m *Message) CSV(host string) []string {
 		m.PlainTextContent(),
 	}
 }
+
+// voiceText 返回语音消息的链接文本，带时长时一并展示
+func (m *Message) voiceText(host string) string {
+	if voice, ok := m.Contents["voice"]; ok {
+		// 可选时长字段（可能来源于不同表：voicelength/voiceduration/length 秒）
+		var durStr string
+		if d, ok2 := m.Contents["voicelength"]; ok2 {
+			durStr = fmt.Sprint(d)
+		}
+		if d, ok2 := m.Contents["voiceduration"]; ok2 {
+			durStr = fmt.Sprint(d)
+		}
+		if d, ok2 := m.Contents["length"]; ok2 && durStr == "" {
+			durStr = fmt.Sprint(d)
+		}
+		if durStr != "" {
+			// 规范成整数秒显示
+			if strings.Contains(durStr, ".") {
+				if f, err := strconv.ParseFloat(durStr, 64); err == nil {
+					durStr = fmt.Sprint(int(f + 0.5))
+				}
+			}
+			// >=60s 转换为 XmYYs 形式
+			if secInt, err := strconv.Atoi(durStr); err == nil {
+				if secInt >= 60 {
+					min := secInt / 60
+					sec := secInt % 60
+					fmtDur := fmt.Sprintf("%dm%02ds", min, sec)
+					return fmt.Sprintf("[语音(%s)](http://%s/voice/%s)", fmtDur, host, voice)
+				}
+			}
+			return fmt.Sprintf("[语音(%ss)](http://%s/voice/%s)", durStr, host, voice)
+		}
+		return fmt.Sprintf("[语音](http://%s/voice/%s)", host, voice)
+	}
+	return "[语音]"
+}
@@ -27,6 +27,10 @@ type MessageDarwinV3 struct {
 	MsgContent    string `json:"msgContent"`
 	MessageType   int64  `json:"messageType"`
 	MesDes        int    `json:"mesDes"` // 0: 发送, 1: 接收
+
+	// Index 为同一秒内按 mesLocalID 排列的序号，表中没有可用的 seq，
+	// Wrap 据此按 10 位时间戳 + 3 位序号生成 Seq
+	Index int64 `json:"-"`
 }
 
 func (m *MessageDarwinV3) Wrap(talker string) *Message {
@@ -38,6 +42,7 @@ func (m *MessageDarwinV3) Wrap(talker string) *Message {
 		IsChatRoom: strings.HasSuffix(talker, "@chatroom"),
 		IsSelf:     m.MesDes == 0,
 		Version:    WeChatDarwinV3,
+		Seq:        m.MsgCreateTime*1000 + m.Index,
 	}
 
 	content := m.MsgContent
@@ -15,8 +15,16 @@ type SearchRequest struct {
 	End    time.Time `json:"end"`
 	Limit  int       `json:"limit"`
 	Offset int       `json:"offset"`
+	// Mode 选择检索方式：keyword（默认，BM25）、semantic（向量）或 hybrid（两者融合）
+	Mode string `json:"mode,omitempty"`
 }
 
+const (
+	SearchModeKeyword  = "keyword"
+	SearchModeSemantic = "semantic"
+	SearchModeHybrid   = "hybrid"
+)
+
 // Clone 生成请求的浅拷贝，便于在不同层级添加额外参数
 func (r *SearchRequest) Clone() *SearchRequest {
 	if r == nil {
@@ -27,29 +35,45 @@ func (r *SearchRequest) Clone() *SearchRequest {
 }
 
 // SearchHit 表示一次搜索命中的消息及其高亮片段
-// Score 使用 SQLite FTS5 的 bm25 分值，越小代表相关度越高
+// Score 越小代表相关度越高：keyword 模式为 bm25 分值，semantic 模式为负的余弦相似度，
+// hybrid 模式为负的 RRF 融合分值
+// Range 仅在命中一段对话（semantic/hybrid）时返回，Message 为该段中的代表消息
 type SearchHit struct {
-	Message *Message `json:"message"`
-	Snippet string   `json:"snippet"`
-	Score   float64  `json:"score"`
+	Message *Message        `json:"message"`
+	Snippet string          `json:"snippet"`
+	Score   float64         `json:"score"`
+	Range   *SearchHitRange `json:"range,omitempty"`
+}
+
+// SearchHitRange 描述语义命中覆盖的同一会话内的连续消息区间
+type SearchHitRange struct {
+	StartSeq int64     `json:"start_seq"`
+	EndSeq   int64     `json:"end_seq"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
 }
 
 // SearchResponse 汇总搜索结果
 // DurationMs 统计搜索耗时（毫秒），仅供参考
 // Limit / Offset 为实际生效的分页参数
 // Hits 序列按相关度排序，命中数可能小于 limit（例如过滤后不足）
+// 混合检索时 KeywordTotal / SemanticTotal 为两路各自的命中数，
+// Total 为两者之和，关键词命中落在语义片段内会被合并，因此只是上限
 type SearchResponse struct {
-	Total      int                `json:"total"`
-	Hits       []*SearchHit       `json:"hits"`
-	DurationMs int64              `json:"duration_ms"`
-	Limit      int                `json:"limit"`
-	Offset     int                `json:"offset"`
-	Query      string             `json:"query"`
-	Talker     string             `json:"talker"`
-	Sender     string             `json:"sender"`
-	Start      time.Time          `json:"start"`
-	End        time.Time          `json:"end"`
-	Index      *SearchIndexStatus `json:"index_status,omitempty"`
+	Total         int                `json:"total"`
+	KeywordTotal  int                `json:"keyword_total,omitempty"`
+	SemanticTotal int                `json:"semantic_total,omitempty"`
+	Hits          []*SearchHit       `json:"hits"`
+	DurationMs    int64              `json:"duration_ms"`
+	Limit         int                `json:"limit"`
+	Offset        int                `json:"offset"`
+	Query         string             `json:"query"`
+	Talker        string             `json:"talker"`
+	Sender        string             `json:"sender"`
+	Start         time.Time          `json:"start"`
+	End           time.Time          `json:"end"`
+	Mode          string             `json:"mode,omitempty"`
+	Index         *SearchIndexStatus `json:"index_status,omitempty"`
 }
 
 // SearchIndexStatus 表示全文索引的构建状态
@@ -60,4 +84,17 @@ type SearchIndexStatus struct {
 	LastStartedAt   time.Time `json:"last_started_at"`
 	LastCompletedAt time.Time `json:"last_completed_at"`
 	LastError       string    `json:"last_error,omitempty"`
+	Tokenizer       string    `json:"tokenizer,omitempty"`
+
+	Vector *VectorIndexStatus `json:"vector,omitempty"`
+}
+
+// VectorIndexStatus 表示语义检索向量库的嵌入进度
+// Pending 为已切分但尚未完成嵌入的对话片段数
+type VectorIndexStatus struct {
+	Model          string    `json:"model"`
+	Chunks         int       `json:"chunks"`
+	Pending        int       `json:"pending"`
+	LastEmbeddedAt time.Time `json:"last_embedded_at"`
+	LastError      string    `json:"last_error,omitempty"`
 }
@@ -16,3 +16,131 @@ type MonthlyTrend struct {
 	Sent     int64  `json:"sent"`
 	Received int64  `json:"received"`
 }
+
+// TalkerAggregates 是单个会话在时间范围内的 SQL 聚合结果，由各数据源实现，
+// 再由 repository 汇总为 TalkerStats。日期与小时均按本地时区计算
+type TalkerAggregates struct {
+	Senders   []SenderCount // 按发送者聚合
+	Types     []TypeCount   // 按 (Type, SubType) 聚合
+	Slots     []SlotCount   // 按 (日期, 小时) 聚合
+	FirstUnix int64
+	LastUnix  int64
+}
+
+// SenderCount 是一个发送者的消息数；自己发送的消息 IsSelf 为 true，Sender 可能为空
+type SenderCount struct {
+	Sender string
+	IsSelf bool
+	Count  int64
+}
+
+// TypeCount 是一种消息类型的消息数，SubType 只对分享类消息（49）有意义
+type TypeCount struct {
+	Type    int64
+	SubType int64
+	Count   int64
+}
+
+// SlotCount 是某天某个小时内的收发消息数
+type SlotCount struct {
+	Day      string // YYYY-MM-DD
+	Hour     int
+	Sent     int64
+	Received int64
+}
+
+// TalkerStats 单个会话（联系人或群聊）的统计
+type TalkerStats struct {
+	Talker      string           `json:"talker"`
+	TalkerName  string           `json:"talker_name,omitempty"`
+	IsChatRoom  bool             `json:"is_chatroom"`
+	Total       int64            `json:"total"`
+	Sent        int64            `json:"sent"`
+	Received    int64            `json:"received"`
+	First       string           `json:"first,omitempty"` // 第一条消息时间，RFC3339
+	Last        string           `json:"last,omitempty"`
+	ActiveDays  int              `json:"active_days"`
+	TopSenders  []SenderStat     `json:"top_senders"`
+	ByType      map[string]int64 `json:"by_type"`
+	Media       MediaCounts      `json:"media"`
+	Heatmap     [24][7]int64     `json:"heatmap"` // [小时][星期]，0=Sunday
+	Hourly      [24]int64        `json:"hourly"`
+	Weekday     [7]int64         `json:"weekday"` // 0=Sunday
+	Monthly     []MonthlyTrend   `json:"monthly"`
+	BusiestDays []DayCount       `json:"busiest_days"`
+}
+
+// SenderStat 是发送者排行中的一项
+type SenderStat struct {
+	Sender  string  `json:"sender"`
+	Name    string  `json:"name,omitempty"`
+	IsSelf  bool    `json:"is_self,omitempty"`
+	Count   int64   `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// MediaCounts 各类媒体消息数
+type MediaCounts struct {
+	Image    int64 `json:"image"`
+	Video    int64 `json:"video"`
+	Voice    int64 `json:"voice"`
+	Emoji    int64 `json:"emoji"`
+	File     int64 `json:"file"`
+	Link     int64 `json:"link"`
+	Location int64 `json:"location"`
+}
+
+// DayCount 某一天的消息数
+type DayCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int64  `json:"count"`
+}
+
+// MessageTypeLabel 返回 (Type, SubType) 对应的统计分类名称，各数据源共用
+func MessageTypeLabel(t, subType int64) string {
+	switch t {
+	case MessageTypeText:
+		return "文本消息"
+	case MessageTypeImage:
+		return "图片消息"
+	case MessageTypeVoice:
+		return "语音消息"
+	case 37:
+		return "好友验证消息"
+	case MessageTypeCard:
+		return "好友推荐消息"
+	case MessageTypeVideo:
+		return "视频消息"
+	case MessageTypeAnimation:
+		return "聊天表情"
+	case MessageTypeLocation:
+		return "位置消息"
+	case MessageTypeShare:
+		switch subType {
+		case MessageSubTypeFile:
+			return "文件消息"
+		case MessageSubTypeLink, MessageSubTypeLink2:
+			return "链接消息"
+		case MessageSubTypeMiniProgram, MessageSubTypeMiniProgram2:
+			return "小程序"
+		case MessageSubTypeMergeForward:
+			return "合并转发"
+		case MessageSubTypeQuote:
+			return "引用消息"
+		case MessageSubTypePay:
+			return "转账"
+		case MessageSubTypeRedEnvelope:
+			return "红包"
+		}
+		return "XML消息"
+	case MessageTypeVOIP:
+		return "音视频通话"
+	case 51:
+		return "手机端操作消息"
+	case MessageTypeSystem:
+		return "系统通知"
+	case 10002:
+		return "撤回消息"
+	}
+	return "其他消息"
+}
@@ -0,0 +1,313 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+)
+
+const (
+	// 每页只保存存储 HMAC 的前 8 字节作为指纹，HMAC 已覆盖页内容与页号
+	fingerprintSize = 8
+	pageStateMagic  = "CLPGv001"
+	PageStateSuffix = ".pages"
+)
+
+// PageCipher 描述解密单页所需的密钥与参数
+type PageCipher struct {
+	EncKey   []byte
+	MacKey   []byte
+	HashFunc func() hash.Hash
+	HMACSize int
+	Reserve  int
+	PageSize int
+}
+
+// PageDecryptor 是各平台解密器中增量解密所需的部分
+type PageDecryptor interface {
+	Validate(page1 []byte, key []byte) bool
+	GetPageSize() int
+	PageCipher(key []byte, salt []byte) PageCipher
+}
+
+// DecryptStats 记录一次解密处理的页数
+type DecryptStats struct {
+	TotalPages   int64 `json:"totalPages"`
+	ChangedPages int64 `json:"changedPages"`
+	Full         bool  `json:"full"`
+}
+
+// pageState 是明文文件旁的 <output>.pages，保存上次解密时的盐与逐页指纹
+type pageState struct {
+	pageSize     int
+	salt         []byte
+	fingerprints []byte
+}
+
+func (s *pageState) count() int64 {
+	return int64(len(s.fingerprints) / fingerprintSize)
+}
+
+func (s *pageState) fingerprint(page int64) []byte {
+	return s.fingerprints[page*fingerprintSize : (page+1)*fingerprintSize]
+}
+
+func loadPageState(path string) *pageState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	head := len(pageStateMagic) + 4 + SaltSize
+	if len(data) < head || string(data[:len(pageStateMagic)]) != pageStateMagic || (len(data)-head)%fingerprintSize != 0 {
+		return nil
+	}
+	return &pageState{
+		pageSize:     int(binary.LittleEndian.Uint32(data[len(pageStateMagic):])),
+		salt:         data[len(pageStateMagic)+4 : head],
+		fingerprints: data[head:],
+	}
+}
+
+func (s *pageState) save(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(pageStateMagic)
+	binary.Write(w, binary.LittleEndian, uint32(s.pageSize))
+	w.Write(s.salt)
+	w.Write(s.fingerprints)
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// DecryptFileIncremental 校验 hexKey 后用 d 的参数将 dbfile 增量解密到 output
+func DecryptFileIncremental(ctx context.Context, d PageDecryptor, dbfile string, hexKey string, output string) (*DecryptStats, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, errors.DecodeKeyFailed(err)
+	}
+
+	dbInfo, err := OpenDBFile(dbfile, d.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+
+	if !d.Validate(dbInfo.FirstPage, key) {
+		return nil, errors.ErrDecryptIncorrectKey
+	}
+
+	return DecryptIncremental(ctx, dbInfo, d.PageCipher(key, dbInfo.Salt), output)
+}
+
+// DecryptIncremental 将 dbInfo 解密到 output。若存在与当前盐、页大小匹配的页指纹，
+// 只重写指纹变化的页；页数减少、盐变化或状态缺失时整体重新解密。
+func DecryptIncremental(ctx context.Context, dbInfo *DBFile, pc PageCipher, output string) (*DecryptStats, error) {
+	statePath := output + PageStateSuffix
+	state := loadPageState(statePath)
+
+	full := state == nil ||
+		state.pageSize != pc.PageSize ||
+		!bytes.Equal(state.salt, dbInfo.Salt) ||
+		dbInfo.TotalPages < state.count()
+	if !full {
+		info, err := os.Stat(output)
+		full = err != nil || info.Size() != state.count()*int64(pc.PageSize)
+	}
+
+	// 先删除状态文件作为写入标记：处理中途失败或崩溃时状态缺失，下次整体重新解密
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return nil, errors.WriteOutputFailed(err)
+	}
+
+	var stats *DecryptStats
+	var fingerprints []byte
+	var err error
+	if full {
+		stats, fingerprints, err = decryptFull(ctx, dbInfo, pc, output)
+	} else {
+		stats, fingerprints, err = decryptChanged(ctx, dbInfo, pc, output, state)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	next := &pageState{pageSize: pc.PageSize, salt: dbInfo.Salt, fingerprints: fingerprints}
+	if err := next.save(statePath); err != nil {
+		return stats, errors.WriteOutputFailed(err)
+	}
+	return stats, nil
+}
+
+func decryptFull(ctx context.Context, dbInfo *DBFile, pc PageCipher, output string) (*DecryptStats, []byte, error) {
+	tmp := output + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return nil, nil, errors.WriteOutputFailed(err)
+	}
+	w := bufio.NewWriterSize(out, 1<<20)
+
+	stats := &DecryptStats{Full: true}
+	fingerprints := make([]byte, 0, dbInfo.TotalPages*fingerprintSize)
+	err = walkPages(ctx, dbInfo, pc, func(page int64, fp []byte, plain []byte) error {
+		fingerprints = append(fingerprints, fp...)
+		stats.TotalPages++
+		stats.ChangedPages++
+		if _, err := w.Write(plain); err != nil {
+			return errors.WriteOutputFailed(err)
+		}
+		return nil
+	}, nil)
+	if err == nil {
+		if ferr := w.Flush(); ferr != nil {
+			err = errors.WriteOutputFailed(ferr)
+		} else if serr := out.Sync(); serr != nil {
+			err = errors.WriteOutputFailed(serr)
+		}
+	}
+	if cerr := out.Close(); err == nil && cerr != nil {
+		err = errors.WriteOutputFailed(cerr)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, nil, err
+	}
+	if err := os.Rename(tmp, output); err != nil {
+		os.Remove(tmp)
+		return nil, nil, errors.WriteOutputFailed(err)
+	}
+	return stats, fingerprints, nil
+}
+
+// decryptChanged 把变化的页原地写回 output，不复制整个文件也不替换 inode，
+// 已打开 output 的读取方能直接看到新页。写入期间状态文件已删除，中途失败时下次整体重新解密
+func decryptChanged(ctx context.Context, dbInfo *DBFile, pc PageCipher, output string, state *pageState) (*DecryptStats, []byte, error) {
+	out, err := os.OpenFile(output, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, errors.OpenFileFailed(output, err)
+	}
+
+	stats := &DecryptStats{}
+	fingerprints := make([]byte, 0, dbInfo.TotalPages*fingerprintSize)
+	unchanged := func(page int64, fp []byte) bool {
+		return page < state.count() && bytes.Equal(state.fingerprint(page), fp)
+	}
+	err = walkPages(ctx, dbInfo, pc, func(page int64, fp []byte, plain []byte) error {
+		fingerprints = append(fingerprints, fp...)
+		stats.TotalPages++
+		if plain == nil {
+			return nil
+		}
+		stats.ChangedPages++
+		if _, err := out.WriteAt(plain, page*int64(pc.PageSize)); err != nil {
+			return errors.WriteOutputFailed(err)
+		}
+		return nil
+	}, unchanged)
+	if err == nil {
+		if serr := out.Sync(); serr != nil {
+			err = errors.WriteOutputFailed(serr)
+		}
+	}
+	if cerr := out.Close(); err == nil && cerr != nil {
+		err = errors.WriteOutputFailed(cerr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return stats, fingerprints, nil
+}
+
+// walkPages 顺序读取加密页并回调明文页；skip 返回 true 时不解密，回调中 plain 为 nil
+func walkPages(ctx context.Context, dbInfo *DBFile, pc PageCipher, fn func(page int64, fp []byte, plain []byte) error, skip func(page int64, fp []byte) bool) error {
+	dbFile, err := os.Open(dbInfo.Path)
+	if err != nil {
+		return errors.OpenFileFailed(dbInfo.Path, err)
+	}
+	defer dbFile.Close()
+
+	r := bufio.NewReaderSize(dbFile, 1<<20)
+	pageBuf := make([]byte, pc.PageSize)
+	macStart := pc.PageSize - pc.Reserve + IVSize
+	zeroFP := make([]byte, fingerprintSize)
+
+	for curPage := int64(0); curPage < dbInfo.TotalPages; curPage++ {
+		select {
+		case <-ctx.Done():
+			return errors.ErrDecryptOperationCanceled
+		default:
+		}
+
+		n, err := io.ReadFull(r, pageBuf)
+		if err != nil {
+			if (err == io.EOF || err == io.ErrUnexpectedEOF) && n > 0 {
+				// 与 Decrypt 一致，忽略不完整的尾页
+				break
+			}
+			return errors.ReadFileFailed(dbInfo.Path, err)
+		}
+
+		allZeros := true
+		for _, b := range pageBuf {
+			if b != 0 {
+				allZeros = false
+				break
+			}
+		}
+
+		fp := zeroFP
+		if !allZeros {
+			fp = append([]byte(nil), pageBuf[macStart:macStart+fingerprintSize]...)
+		}
+		if skip != nil && skip(curPage, fp) {
+			if err := fn(curPage, fp, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var plain []byte
+		switch {
+		case allZeros:
+			plain = pageBuf
+		default:
+			decrypted, err := DecryptPage(pageBuf, pc.EncKey, pc.MacKey, curPage, pc.HashFunc, pc.HMACSize, pc.Reserve, pc.PageSize)
+			if err != nil {
+				return err
+			}
+			plain = decrypted
+			if curPage == 0 {
+				plain = append([]byte(SQLiteHeader), decrypted...)
+			}
+		}
+		if len(plain) != pc.PageSize {
+			return errors.WriteOutputFailed(fmt.Errorf("page %d decrypted to %d bytes, expected %d", curPage, len(plain), pc.PageSize))
+		}
+		if err := fn(curPage, fp, plain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
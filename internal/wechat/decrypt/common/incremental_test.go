@@ -0,0 +1,209 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPageSize = 1024
+
+func testCipher() PageCipher {
+	return PageCipher{
+		EncKey:   bytes.Repeat([]byte{1}, KeySize),
+		MacKey:   bytes.Repeat([]byte{2}, KeySize),
+		HashFunc: sha256.New,
+		HMACSize: sha256.Size,
+		Reserve:  IVSize + sha256.Size,
+		PageSize: testPageSize,
+	}
+}
+
+// encryptPage 是 DecryptPage 的逆过程，plain 为页内需加密的部分
+func encryptPage(t *testing.T, pc PageCipher, page int64, salt, plain []byte) []byte {
+	t.Helper()
+	offset := 0
+	if page == 0 {
+		offset = SaltSize
+	}
+	buf := make([]byte, pc.PageSize)
+	copy(buf, salt)
+	body := make([]byte, pc.PageSize-pc.Reserve-offset)
+	copy(body, plain)
+
+	iv := bytes.Repeat([]byte{byte(page + 7)}, IVSize)
+	block, err := aes.NewCipher(pc.EncKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(buf[offset:pc.PageSize-pc.Reserve], body)
+	copy(buf[pc.PageSize-pc.Reserve:], iv)
+
+	mac := hmac.New(pc.HashFunc, pc.MacKey)
+	mac.Write(buf[offset : pc.PageSize-pc.Reserve+IVSize])
+	pageNo := make([]byte, 4)
+	binary.LittleEndian.PutUint32(pageNo, uint32(page+1))
+	mac.Write(pageNo)
+	copy(buf[pc.PageSize-pc.Reserve+IVSize:], mac.Sum(nil))
+	return buf
+}
+
+func writeEncryptedDB(t *testing.T, pc PageCipher, path string, salt []byte, pages []string) *DBFile {
+	t.Helper()
+	var data []byte
+	for i, p := range pages {
+		data = append(data, encryptPage(t, pc, int64(i), salt, []byte(p))...)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &DBFile{Path: path, Salt: salt, TotalPages: int64(len(pages))}
+}
+
+func TestDecryptIncremental(t *testing.T) {
+	dir := t.TempDir()
+	pc := testCipher()
+	src := filepath.Join(dir, "enc.db")
+	output := filepath.Join(dir, "plain.db")
+	salt := bytes.Repeat([]byte{9}, SaltSize)
+	ctx := context.Background()
+
+	db := writeEncryptedDB(t, pc, src, salt, []string{"p0", "p1", "p2"})
+	stats, err := DecryptIncremental(ctx, db, pc, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Full || stats.ChangedPages != 3 {
+		t.Fatalf("first pass = %+v", stats)
+	}
+
+	// 修改一页并追加一页，只应重写这两页
+	db = writeEncryptedDB(t, pc, src, salt, []string{"p0", "p1-changed", "p2", "p3"})
+	stats, err = DecryptIncremental(ctx, db, pc, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Full || stats.ChangedPages != 2 || stats.TotalPages != 4 {
+		t.Fatalf("incremental pass = %+v", stats)
+	}
+	plain, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(plain, []byte(SQLiteHeader+"p0")) ||
+		!bytes.HasPrefix(plain[testPageSize:], []byte("p1-changed")) ||
+		!bytes.HasPrefix(plain[3*testPageSize:], []byte("p3")) {
+		t.Fatal("output does not match source pages")
+	}
+
+	// 页数减少时整体重新解密
+	db = writeEncryptedDB(t, pc, src, salt, []string{"p0", "p1"})
+	if stats, err = DecryptIncremental(ctx, db, pc, output); err != nil || !stats.Full {
+		t.Fatalf("shrink pass = %+v, %v", stats, err)
+	}
+
+	// 盐变化时整体重新解密
+	db = writeEncryptedDB(t, pc, src, bytes.Repeat([]byte{8}, SaltSize), []string{"p0", "p1"})
+	if stats, err = DecryptIncremental(ctx, db, pc, output); err != nil || !stats.Full {
+		t.Fatalf("salt pass = %+v, %v", stats, err)
+	}
+	if info, _ := os.Stat(output); info.Size() != 2*testPageSize {
+		t.Fatalf("output size = %d", info.Size())
+	}
+}
+
+func TestDecryptIncrementalInPlace(t *testing.T) {
+	dir := t.TempDir()
+	pc := testCipher()
+	src := filepath.Join(dir, "enc.db")
+	output := filepath.Join(dir, "plain.db")
+	salt := bytes.Repeat([]byte{9}, SaltSize)
+	ctx := context.Background()
+
+	db := writeEncryptedDB(t, pc, src, salt, []string{"p0", "p1", "p2"})
+	if _, err := DecryptIncremental(ctx, db, pc, output); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已打开的读取方应直接看到新页
+	reader, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	before, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db = writeEncryptedDB(t, pc, src, salt, []string{"p0", "p1-changed", "p2"})
+	if stats, err := DecryptIncremental(ctx, db, pc, output); err != nil || stats.Full {
+		t.Fatalf("incremental pass = %+v, %v", stats, err)
+	}
+	after, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Fatal("incremental pass replaced the output file")
+	}
+	page := make([]byte, len("p1-changed"))
+	if _, err := reader.ReadAt(page, testPageSize); err != nil || string(page) != "p1-changed" {
+		t.Fatalf("open reader sees %q, %v", page, err)
+	}
+	if _, err := os.Stat(output + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary copy left behind: %v", err)
+	}
+}
+
+func TestDecryptIncrementalFailureForcesFull(t *testing.T) {
+	dir := t.TempDir()
+	pc := testCipher()
+	src := filepath.Join(dir, "enc.db")
+	output := filepath.Join(dir, "plain.db")
+	salt := bytes.Repeat([]byte{9}, SaltSize)
+	ctx := context.Background()
+
+	db := writeEncryptedDB(t, pc, src, salt, []string{"p0", "p1", "p2"})
+	if _, err := DecryptIncremental(ctx, db, pc, output); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第 1 页正常变化，第 2 页 HMAC 损坏：解密在写入第 1 页之后失败
+	db = writeEncryptedDB(t, pc, src, salt, []string{"p0", "p1-changed", "p2"})
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[2*testPageSize+pc.PageSize-pc.Reserve+IVSize] ^= 0xff
+	if err := os.WriteFile(src, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptIncremental(ctx, db, pc, output); err == nil {
+		t.Fatal("expected error for corrupted page")
+	}
+	if _, err := os.Stat(output + PageStateSuffix); !os.IsNotExist(err) {
+		t.Fatalf("page state should be invalidated after a failed pass: %v", err)
+	}
+
+	// 状态缺失时整体重新解密
+	db = writeEncryptedDB(t, pc, src, salt, []string{"p0", "p1-changed", "p2"})
+	stats, err := DecryptIncremental(ctx, db, pc, output)
+	if err != nil || !stats.Full {
+		t.Fatalf("recovery pass = %+v, %v", stats, err)
+	}
+	plain, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(plain[testPageSize:], []byte("p1-changed")) || !bytes.HasPrefix(plain[2*testPageSize:], []byte("p2")) {
+		t.Fatal("output does not match source pages")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechat/decrypt/common"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechat/decrypt/darwin"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechat/decrypt/windows"
 )
@@ -14,6 +15,9 @@ type Decryptor interface {
 	// Decrypt 解密数据库
 	Decrypt(ctx context.Context, dbfile string, key string, output io.Writer) error
 
+	// DecryptIncremental 解密数据库到 output 文件，仅重写变化的页
+	DecryptIncremental(ctx context.Context, dbfile string, key string, output string) (*common.DecryptStats, error)
+
 	// Validate 验证密钥是否有效
 	Validate(page1 []byte, key []byte) bool
 
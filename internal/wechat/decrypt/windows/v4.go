@@ -165,6 +165,24 @@ func (d *V4Decryptor) Decrypt(ctx context.Context, dbfile string, hexKey string,
 	return nil
 }
 
+// DecryptIncremental 解密数据库到 output 文件，仅重写自上次解密后发生变化的页
+func (d *V4Decryptor) DecryptIncremental(ctx context.Context, dbfile string, hexKey string, output string) (*common.DecryptStats, error) {
+	return common.DecryptFileIncremental(ctx, d, dbfile, hexKey, output)
+}
+
+// PageCipher 返回用 key 与数据库盐派生出的单页解密参数
+func (d *V4Decryptor) PageCipher(key []byte, salt []byte) common.PageCipher {
+	encKey, macKey := d.deriveKeys(key, salt)
+	return common.PageCipher{
+		EncKey:   encKey,
+		MacKey:   macKey,
+		HashFunc: d.hashFunc,
+		HMACSize: d.hmacSize,
+		Reserve:  d.reserve,
+		PageSize: d.pageSize,
+	}
+}
+
 // GetPageSize 返回页面大小
 func (d *V4Decryptor) GetPageSize() int {
 	return d.pageSize
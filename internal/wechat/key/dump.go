@@ -0,0 +1,213 @@
+package key
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+)
+
+// 转储文件按块流式读取，相邻块之间保留重叠区域，避免密钥特征跨块时漏检
+var (
+	dumpChunkSize    = 16 * 1024 * 1024 // 16MB
+	dumpOverlapBytes = 4 * 1024         // 大于所有特征偏移
+)
+
+// ImgKeySearcher 由支持图片密钥的提取器实现
+type ImgKeySearcher interface {
+	// SearchImgKey 在内存中搜索图片密钥
+	SearchImgKey(ctx context.Context, memory []byte) (string, bool)
+}
+
+// ScanDump 在内存转储文件中搜索数据密钥和图片密钥
+// 支持 dumpmemory 生成的 zip 包（扫描其中除数据库外的所有文件），以及原始的 core / minidump 镜像
+// 提取器需已通过 SetValidate 设置验证器；提取器未实现 ImgKeySearcher 时只搜索数据密钥
+// dataKey, imgKey, error
+func ScanDump(ctx context.Context, extractor Extractor, path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", errors.OpenFileFailed(path, err)
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return "", "", errors.ReadFileFailed(path, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", "", errors.ReadFileFailed(path, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := newDumpScanner(ctx, cancel, extractor)
+
+	if !bytes.Equal(magic, []byte("PK\x03\x04")) {
+		log.Info().Str("path", path).Msg("scanning memory dump")
+		err = s.scan(f)
+	} else {
+		err = s.scanZip(path)
+	}
+	dataKey, imgKey := s.wait()
+
+	// 找到全部密钥后会取消读取，此时的错误可以忽略
+	if err != nil && dataKey == "" && imgKey == "" {
+		return "", "", err
+	}
+	if dataKey == "" && imgKey == "" {
+		return "", "", errors.ErrNoValidKey
+	}
+	return dataKey, imgKey, nil
+}
+
+// dumpScanner 把读取到的内存块分发给多个 worker 并汇总结果
+type dumpScanner struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	extractor Extractor
+	imgSearch ImgKeySearcher
+	chunks    chan []byte
+	wg        sync.WaitGroup
+
+	mu      sync.Mutex
+	dataKey string
+	imgKey  string
+}
+
+func newDumpScanner(ctx context.Context, cancel context.CancelFunc, extractor Extractor) *dumpScanner {
+	s := &dumpScanner{
+		ctx:       ctx,
+		cancel:    cancel,
+		extractor: extractor,
+		chunks:    make(chan []byte, 2),
+	}
+	s.imgSearch, _ = extractor.(ImgKeySearcher)
+
+	workers := runtime.NumCPU()
+	if workers > 8 {
+		workers = 8
+	}
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+func (s *dumpScanner) worker() {
+	defer s.wg.Done()
+	for memory := range s.chunks {
+		if s.ctx.Err() != nil {
+			continue
+		}
+		dataKey, imgKey := s.keys()
+		if dataKey == "" {
+			if key, ok := s.extractor.SearchKey(s.ctx, memory); ok {
+				s.found(key, "")
+			}
+		}
+		if imgKey == "" && s.imgSearch != nil {
+			if key, ok := s.imgSearch.SearchImgKey(s.ctx, memory); ok {
+				s.found("", key)
+			}
+		}
+	}
+}
+
+func (s *dumpScanner) keys() (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dataKey, s.imgKey
+}
+
+func (s *dumpScanner) found(dataKey, imgKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dataKey != "" && s.dataKey == "" {
+		s.dataKey = dataKey
+		log.Debug().Msg("Data key found: " + dataKey)
+	}
+	if imgKey != "" && s.imgKey == "" {
+		s.imgKey = imgKey
+		log.Debug().Msg("Image key found: " + imgKey)
+	}
+	if s.dataKey != "" && (s.imgKey != "" || s.imgSearch == nil) {
+		s.cancel()
+	}
+}
+
+// scanZip 依次扫描 zip 包中的内存文件，跳过一同打包的数据库文件
+func (s *dumpScanner) scanZip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return errors.OpenFileFailed(path, err)
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() || strings.EqualFold(filepath.Ext(zf.Name), ".db") {
+			continue
+		}
+		log.Info().Str("path", path).Str("entry", zf.Name).Msg("scanning memory dump")
+		r, err := zf.Open()
+		if err != nil {
+			return errors.OpenFileFailed(zf.Name, err)
+		}
+		err = s.scan(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan 按块读取 r，每块带上前一块末尾的重叠区域
+func (s *dumpScanner) scan(r io.Reader) error {
+	var tail []byte
+	for {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+
+		buf := make([]byte, len(tail)+dumpChunkSize)
+		copy(buf, tail)
+		n, err := io.ReadFull(r, buf[len(tail):])
+		if n > 0 {
+			chunk := buf[:len(tail)+n]
+			select {
+			case s.chunks <- chunk:
+			case <-s.ctx.Done():
+				return s.ctx.Err()
+			}
+			overlap := dumpOverlapBytes
+			if overlap > len(chunk) {
+				overlap = len(chunk)
+			}
+			tail = chunk[len(chunk)-overlap:]
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return errors.ReadFileFailed("memory dump", err)
+		}
+	}
+}
+
+// wait 结束分发并等待所有 worker 退出，返回找到的密钥
+func (s *dumpScanner) wait() (string, string) {
+	close(s.chunks)
+	s.wg.Wait()
+	return s.keys()
+}
@@ -0,0 +1,111 @@
+package key
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechat/decrypt"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechat/model"
+)
+
+// fakeExtractor 把 "DATA=" / "IMG=" 之后的 8 个字节当作密钥
+type fakeExtractor struct {
+	img bool
+}
+
+func (f *fakeExtractor) Extract(ctx context.Context, proc *model.Process) (string, string, error) {
+	return "", "", nil
+}
+
+func (f *fakeExtractor) SetValidate(validator *decrypt.Validator) {}
+
+func (f *fakeExtractor) SearchKey(ctx context.Context, memory []byte) (string, bool) {
+	return search(memory, "DATA=")
+}
+
+func search(memory []byte, marker string) (string, bool) {
+	i := bytes.Index(memory, []byte(marker))
+	if i == -1 || i+len(marker)+8 > len(memory) {
+		return "", false
+	}
+	return string(memory[i+len(marker) : i+len(marker)+8]), true
+}
+
+// fakeImgExtractor 额外支持图片密钥
+type fakeImgExtractor struct{ fakeExtractor }
+
+func (f *fakeImgExtractor) SearchImgKey(ctx context.Context, memory []byte) (string, bool) {
+	return search(memory, "IMG=")
+}
+
+func smallChunks(t *testing.T) {
+	size, overlap := dumpChunkSize, dumpOverlapBytes
+	dumpChunkSize, dumpOverlapBytes = 64, 16
+	t.Cleanup(func() { dumpChunkSize, dumpOverlapBytes = size, overlap })
+}
+
+func dumpData() []byte {
+	data := bytes.Repeat([]byte{0xAA}, 1000)
+	// 两个特征都跨越块边界
+	copy(data[60:], "DATA=datakey1")
+	copy(data[700:], "IMG=imgkey01")
+	return data
+}
+
+func TestScanDumpRaw(t *testing.T) {
+	smallChunks(t)
+	path := filepath.Join(t.TempDir(), "core")
+	if err := os.WriteFile(path, dumpData(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dataKey, imgKey, err := ScanDump(context.Background(), &fakeImgExtractor{}, path)
+	if err != nil || dataKey != "datakey1" || imgKey != "imgkey01" {
+		t.Fatalf("got %q %q %v", dataKey, imgKey, err)
+	}
+
+	// 不支持图片密钥的提取器只返回数据密钥
+	dataKey, imgKey, err = ScanDump(context.Background(), &fakeExtractor{}, path)
+	if err != nil || dataKey != "datakey1" || imgKey != "" {
+		t.Fatalf("got %q %q %v", dataKey, imgKey, err)
+	}
+}
+
+func TestScanDumpZip(t *testing.T) {
+	smallChunks(t)
+	path := filepath.Join(t.TempDir(), "wechat_4.0.0_1_20240501.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	// 数据库文件不参与扫描
+	w, _ := zw.Create("wechat_4.0.0_1_session.db")
+	w.Write([]byte("DATA=fromdb00"))
+	w, _ = zw.Create("wechat_4.0.0_1_20240501.bin")
+	w.Write(dumpData())
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	dataKey, imgKey, err := ScanDump(context.Background(), &fakeImgExtractor{}, path)
+	if err != nil || dataKey != "datakey1" || imgKey != "imgkey01" {
+		t.Fatalf("got %q %q %v", dataKey, imgKey, err)
+	}
+}
+
+func TestScanDumpNoKey(t *testing.T) {
+	smallChunks(t)
+	path := filepath.Join(t.TempDir(), "core")
+	if err := os.WriteFile(path, bytes.Repeat([]byte{0xAA}, 500), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ScanDump(context.Background(), &fakeImgExtractor{}, path); err == nil {
+		t.Fatal("expected error when no key is found")
+	}
+}
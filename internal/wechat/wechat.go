@@ -139,3 +139,20 @@ func (a *Account) DecryptDatabase(ctx context.Context, dbPath, outputPath string
 	// 解密数据库
 	return decryptor.Decrypt(ctx, dbPath, hexKey, output)
 }
+
+// GetKeyFromDump 从内存转储文件中离线提取密钥，dataDir 为对应账号的数据目录，用于验证候选密钥
+func GetKeyFromDump(ctx context.Context, dumpPath, dataDir, platform string, version int) (string, string, error) {
+	extractor, err := key.NewExtractor(platform, version)
+	if err != nil {
+		return "", "", err
+	}
+
+	validator, err := decrypt.NewValidator(platform, version, dataDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	extractor.SetValidate(validator)
+
+	return key.ScanDump(ctx, extractor, dumpPath)
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
 	"github.com/takeaway1/chatlog-TCOTC/internal/model"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/dbm"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/sqlagg"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/msgstore"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
 )
@@ -73,9 +74,18 @@ type DataSource struct {
 }
 
 func New(path string) (*DataSource, error) {
+	return newDataSource(path, dbm.NewDBManager(path))
+}
+
+// NewReadOnly 以只读方式打开已解密的快照目录，不监听文件变化
+func NewReadOnly(path string) (*DataSource, error) {
+	return newDataSource(path, dbm.NewReadOnlyDBManager(path))
+}
+
+func newDataSource(path string, m *dbm.DBManager) (*DataSource, error) {
 	ds := &DataSource{
 		path:               path,
-		dbm:                dbm.NewDBManager(path),
+		dbm:                m,
 		talkerDBMap:        make(map[string]string),
 		user2DisplayName:   make(map[string]string),
 		messageStores:      make([]*msgstore.Store, 0),
@@ -200,7 +210,7 @@ func (ds *DataSource) initMessageDbs() error {
 			ID:        id,
 			FilePath:  filePath,
 			FileName:  baseName,
-			IndexPath: filepath.Join(ds.path, "indexes", "messages", id+".fts.db"),
+			IndexPath: id + ".fts.db",
 			Talkers:   make(map[string]struct{}),
 		}
 
@@ -274,10 +284,26 @@ func (ds *DataSource) initMessageDbs() error {
 	return nil
 }
 
-// IntimacyBase 统计按联系人（非群聊）聚合的亲密度基础数据（darwin v3）
+// IntimacyBase 统计按联系人（非群聊）聚合的亲密度基础数据（darwin v3）。
+// 每个会话一张 Chat_<md5(username)> 表，通过 WCContact 还原 username；mesDes=0 为自己发送，与 MessageDarwinV3.Wrap 一致
 func (ds *DataSource) IntimacyBase(ctx context.Context) (map[string]*model.IntimacyBase, error) {
 	result := make(map[string]*model.IntimacyBase)
 
+	md5ToUser := make(map[string]string)
+	if cdb, err := ds.dbm.GetDB(Contact); err == nil && cdb != nil {
+		rows, err := cdb.QueryContext(ctx, `SELECT m_nsUsrName FROM WCContact`)
+		if err == nil {
+			for rows.Next() {
+				var uname string
+				if rows.Scan(&uname) == nil && uname != "" {
+					sum := md5.Sum([]byte(uname))
+					md5ToUser[hex.EncodeToString(sum[:])] = uname
+				}
+			}
+			rows.Close()
+		}
+	}
+
 	dbs, err := ds.dbm.GetDBs(Message)
 	if err != nil {
 		return result, nil
@@ -285,8 +311,9 @@ func (ds *DataSource) IntimacyBase(ctx context.Context) (map[string]*model.Intim
 
 	var maxCT int64
 	type tbl struct {
-		db   *sql.DB
-		name string
+		db     *sql.DB
+		name   string
+		talker string
 	}
 	var tables []tbl
 	for _, db := range dbs {
@@ -294,9 +321,14 @@ func (ds *DataSource) IntimacyBase(ctx context.Context) (map[string]*model.Intim
 		if err == nil {
 			for rows.Next() {
 				var name string
-				if err := rows.Scan(&name); err == nil {
-					tables = append(tables, tbl{db: db, name: name})
+				if err := rows.Scan(&name); err != nil {
+					continue
+				}
+				talker := md5ToUser[extractTalkerFromTableName(name)]
+				if talker == "" || strings.HasSuffix(talker, "@chatroom") {
+					continue
 				}
+				tables = append(tables, tbl{db: db, name: name, talker: talker})
 			}
 			rows.Close()
 		}
@@ -315,121 +347,35 @@ func (ds *DataSource) IntimacyBase(ctx context.Context) (map[string]*model.Intim
 	since7 := maxCT - 7*86400
 
 	for _, t := range tables {
-		// 基础统计：total, sent, recv, min, max；darwin v3: mesDes: 1=接收? 2=发送? 需按现有实现参考：在其它地方通常 IsSender=1 v3 windows；v3 darwin字段不同，这里按 status 字段近似处理：当 mesDes=2 视为发送
-		q := `SELECT COALESCE(n.user_name,''),
-			COUNT(*) AS msg_count,
-			MIN(m.msgCreateTime) AS minct,
-			MAX(m.msgCreateTime) AS maxct,
-			SUM(CASE WHEN m.mesDes=0 THEN 1 ELSE 0 END) AS sent,
-			SUM(CASE WHEN m.mesDes!=0 THEN 1 ELSE 0 END) AS recv
-			FROM ` + t.name + ` m
-			LEFT JOIN Name2Id n ON m.realChatUsrNameId = n.rowid
-			WHERE (n.user_name IS NULL OR n.user_name NOT LIKE '%@chatroom')
-			GROUP BY COALESCE(n.user_name,'')`
-		rows, err := t.db.QueryContext(ctx, q)
-		if err == nil {
-			for rows.Next() {
-				var uname string
-				var cnt, minct, maxct, sent, recv int64
-				if err := rows.Scan(&uname, &cnt, &minct, &maxct, &sent, &recv); err == nil {
-					if strings.TrimSpace(uname) == "" {
-						continue
-					}
-					base := result[uname]
-					if base == nil {
-						base = &model.IntimacyBase{UserName: uname}
-						result[uname] = base
-					}
-					base.MsgCount += cnt
-					base.SentCount += sent
-					base.ReceivedCount += recv
-					if base.MinCreateUnix == 0 || (minct > 0 && minct < base.MinCreateUnix) {
-						base.MinCreateUnix = minct
-					}
-					if maxct > base.MaxCreateUnix {
-						base.MaxCreateUnix = maxct
-					}
-				}
-			}
-			rows.Close()
+		row := t.db.QueryRowContext(ctx, `SELECT COUNT(*),
+			SUM(CASE WHEN mesDes=0 THEN 1 ELSE 0 END),
+			MIN(msgCreateTime),
+			MAX(msgCreateTime),
+			COUNT(DISTINCT date(datetime(msgCreateTime,'unixepoch'))),
+			SUM(CASE WHEN msgCreateTime>=? THEN 1 ELSE 0 END),
+			SUM(CASE WHEN msgCreateTime>=? AND mesDes=0 THEN 1 ELSE 0 END)
+			FROM `+t.name, since90, since7)
+		var cnt, sent, minct, maxct, days, c90, s7 sql.NullInt64
+		if err := row.Scan(&cnt, &sent, &minct, &maxct, &days, &c90, &s7); err != nil || cnt.Int64 == 0 {
+			continue
 		}
-
-		// 活跃天数
-		q2 := `SELECT COALESCE(n.user_name,''), COUNT(DISTINCT date(datetime(m.msgCreateTime,'unixepoch'))) AS days
-			FROM ` + t.name + ` m LEFT JOIN Name2Id n ON m.realChatUsrNameId = n.rowid
-			WHERE (n.user_name IS NULL OR n.user_name NOT LIKE '%@chatroom')
-			GROUP BY COALESCE(n.user_name,'')`
-		rows2, err := t.db.QueryContext(ctx, q2)
-		if err == nil {
-			for rows2.Next() {
-				var uname string
-				var days int64
-				if err := rows2.Scan(&uname, &days); err == nil {
-					if strings.TrimSpace(uname) == "" {
-						continue
-					}
-					base := result[uname]
-					if base == nil {
-						base = &model.IntimacyBase{UserName: uname}
-						result[uname] = base
-					}
-					base.MessagingDays += days
-				}
-			}
-			rows2.Close()
+		base := result[t.talker]
+		if base == nil {
+			base = &model.IntimacyBase{UserName: t.talker}
+			result[t.talker] = base
 		}
-
-		// 最近90天消息
-		q3 := `SELECT COALESCE(n.user_name,''), COUNT(*)
-			FROM ` + t.name + ` m LEFT JOIN Name2Id n ON m.realChatUsrNameId = n.rowid
-			WHERE m.msgCreateTime>=? AND (n.user_name IS NULL OR n.user_name NOT LIKE '%@chatroom')
-			GROUP BY COALESCE(n.user_name,'')`
-		rows3, err := t.db.QueryContext(ctx, q3, since90)
-		if err == nil {
-			for rows3.Next() {
-				var uname string
-				var cnt int64
-				if err := rows3.Scan(&uname, &cnt); err == nil {
-					if strings.TrimSpace(uname) == "" {
-						continue
-					}
-					base := result[uname]
-					if base == nil {
-						base = &model.IntimacyBase{UserName: uname}
-						result[uname] = base
-					}
-					base.Last90DaysMsg += cnt
-				}
-			}
-			rows3.Close()
+		base.MsgCount += cnt.Int64
+		base.SentCount += sent.Int64
+		base.ReceivedCount += cnt.Int64 - sent.Int64
+		if base.MinCreateUnix == 0 || (minct.Int64 > 0 && minct.Int64 < base.MinCreateUnix) {
+			base.MinCreateUnix = minct.Int64
 		}
-
-		// 过去7天发送
-		q4 := `SELECT COALESCE(n.user_name,''), SUM(CASE WHEN m.mesDes=0 THEN 1 ELSE 0 END)
-			FROM ` + t.name + ` m LEFT JOIN Name2Id n ON m.realChatUsrNameId = n.rowid
-			WHERE m.msgCreateTime>=? AND (n.user_name IS NULL OR n.user_name NOT LIKE '%@chatroom')
-			GROUP BY COALESCE(n.user_name,'')`
-		rows4, err := t.db.QueryContext(ctx, q4, since7)
-		if err == nil {
-			for rows4.Next() {
-				var uname string
-				var cnt sql.NullInt64
-				if err := rows4.Scan(&uname, &cnt); err == nil {
-					if strings.TrimSpace(uname) == "" {
-						continue
-					}
-					base := result[uname]
-					if base == nil {
-						base = &model.IntimacyBase{UserName: uname}
-						result[uname] = base
-					}
-					if cnt.Valid {
-						base.Past7DaysSentMsg += cnt.Int64
-					}
-				}
-			}
-			rows4.Close()
+		if maxct.Int64 > base.MaxCreateUnix {
+			base.MaxCreateUnix = maxct.Int64
 		}
+		base.MessagingDays += days.Int64
+		base.Last90DaysMsg += c90.Int64
+		base.Past7DaysSentMsg += s7.Int64
 	}
 
 	return result, nil
@@ -523,7 +469,7 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 			SELECT msgCreateTime, msgContent, messageType, mesDes
 			FROM %s
 			WHERE msgCreateTime >= ? AND msgCreateTime <= ?
-			ORDER BY msgCreateTime ASC
+			ORDER BY msgCreateTime ASC, mesLocalID ASC
 		`, tableName)
 
 		// 执行查询
@@ -538,6 +484,8 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 		}
 
 		// 处理查询结果，在读取时进行过滤
+		// 时间范围以秒为边界，同一秒的消息总是全部读出，序号在过滤前计算，结果稳定
+		var lastTime, index int64
 		for rows.Next() {
 			var msg model.MessageDarwinV3
 			err := rows.Scan(
@@ -551,6 +499,12 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 				log.Err(err).Msgf("扫描消息行失败")
 				continue
 			}
+			if msg.MsgCreateTime == lastTime {
+				index++
+			} else {
+				lastTime, index = msg.MsgCreateTime, 0
+			}
+			msg.Index = index
 
 			// 将消息包装为通用模型
 			message := msg.Wrap(talkerItem)
@@ -1514,3 +1468,46 @@ func mapV4TypeToLabel(t int64) string {
 		return ""
 	}
 }
+
+// TalkerAggregates 按发送者、消息类型与时段聚合单个会话的消息（darwin v3）。
+// 群聊发送者是消息内容中 "sender:\n" 前缀；分享类消息的子类型取 XML 中第一个 <type> 的值
+func (ds *DataSource) TalkerAggregates(ctx context.Context, talker string, startTime, endTime time.Time) (*model.TalkerAggregates, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	agg := &model.TalkerAggregates{}
+
+	md5sum := md5.Sum([]byte(talker))
+	talkerMd5 := hex.EncodeToString(md5sum[:])
+	ds.messageStoreMu.RLock()
+	dbPath, ok := ds.talkerDBMap[talkerMd5]
+	ds.messageStoreMu.RUnlock()
+	if !ok {
+		return agg, nil
+	}
+	db, err := ds.dbm.OpenDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	q := sqlagg.Query{
+		From:    "Chat_" + talkerMd5,
+		Where:   `msgCreateTime >= ? AND msgCreateTime <= ?`,
+		Args:    []any{startTime.Unix(), endTime.Unix()},
+		Time:    `msgCreateTime`,
+		Sender:  `''`,
+		Self:    `mesDes = 0`,
+		Type:    `messageType`,
+		SubType: `CASE WHEN messageType = 49 AND instr(msgContent, '<type>') > 0 THEN CAST(substr(msgContent, instr(msgContent, '<type>') + 6, 8) AS INTEGER) ELSE 0 END`,
+	}
+	if strings.HasSuffix(talker, "@chatroom") {
+		q.Sender = `CASE WHEN instr(msgContent, char(58, 10)) > 0 THEN substr(msgContent, 1, instr(msgContent, char(58, 10)) - 1) ELSE '' END`
+	}
+	if err := sqlagg.Collect(ctx, db, q, agg); err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return agg, nil
+		}
+		return nil, errors.QueryFailed("", err)
+	}
+	return agg, nil
+}
@@ -0,0 +1,55 @@
+package darwinv3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetMessagesSeq(t *testing.T) {
+	dir := t.TempDir()
+	execSQL(t, filepath.Join(dir, "wccontact_new2.db"),
+		`CREATE TABLE WCContact (m_nsUsrName TEXT, nickname TEXT, m_nsRemark TEXT, m_uiSex INTEGER, m_nsAliasName TEXT)`,
+		`INSERT INTO WCContact (m_nsUsrName) VALUES ('alice')`)
+	sum := md5.Sum([]byte("alice"))
+	table := "Chat_" + hex.EncodeToString(sum[:])
+	execSQL(t, filepath.Join(dir, "msg_0.db"),
+		`CREATE TABLE `+table+` (mesLocalID INTEGER PRIMARY KEY, msgCreateTime INTEGER, msgContent TEXT, messageType INTEGER, mesDes INTEGER)`,
+		// mesLocalID 与时间顺序不一致时，同一秒内按 mesLocalID 编号
+		`INSERT INTO `+table+` (mesLocalID, msgCreateTime, msgContent, messageType, mesDes) VALUES
+			(1, 1700000000, 'a', 1, 0), (3, 1700000000, 'c', 1, 1), (2, 1700000000, 'b', 1, 1), (4, 1700000001, 'd', 1, 0)`)
+
+	ds, err := NewReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+
+	ctx := context.Background()
+	start, end := time.Unix(1700000000, 0), time.Unix(1700000001, 0)
+	msgs, err := ds.GetMessages(ctx, start, end, "alice", "", "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		content string
+		seq     int64
+	}{{"a", 1700000000000}, {"b", 1700000000001}, {"c", 1700000000002}, {"d", 1700000001000}}
+	if len(msgs) != len(want) {
+		t.Fatalf("messages = %d", len(msgs))
+	}
+	for i, w := range want {
+		if msgs[i].Content != w.content || msgs[i].Seq != w.seq {
+			t.Fatalf("message %d = %s/%d, want %s/%d", i, msgs[i].Content, msgs[i].Seq, w.content, w.seq)
+		}
+	}
+
+	// 序号在关键词过滤前计算，过滤后不变
+	msgs, err = ds.GetMessages(ctx, start, end, "alice", "", "c", 0, 0)
+	if err != nil || len(msgs) != 1 || msgs[0].Seq != 1700000000002 {
+		t.Fatalf("filtered = %+v, %v", msgs, err)
+	}
+}
@@ -58,6 +58,8 @@ type DataSource interface {
 
 	// 亲密度基础统计（按联系人/会话聚合）
 	IntimacyBase(ctx context.Context) (map[string]*model.IntimacyBase, error)
+	// 单个会话在时间范围内按发送者、消息类型、日期与小时的聚合
+	TalkerAggregates(ctx context.Context, talker string, startTime, endTime time.Time) (*model.TalkerAggregates, error)
 
 	// 设置回调函数
 	SetCallback(group string, callback func(event fsnotify.Event) error) error
@@ -79,3 +81,17 @@ func New(path string, platform string, version int) (DataSource, error) {
 		return nil, errors.PlatformUnsupported(platform, version)
 	}
 }
+
+// NewReadOnly 以只读方式打开已解密的快照目录，不创建临时拷贝、不监听文件变化
+func NewReadOnly(path string, platform string, version int) (DataSource, error) {
+	switch {
+	case platform == "windows" && version == 3:
+		return windowsv3.NewReadOnly(path)
+	case (platform == "windows" || platform == "darwin") && version == 4:
+		return v4.NewReadOnly(path)
+	case platform == "darwin" && version == 3:
+		return darwinv3.NewReadOnly(path)
+	default:
+		return nil, errors.PlatformUnsupported(platform, version)
+	}
+}
@@ -24,6 +24,9 @@ type DBManager struct {
 	dbs     map[string]*sql.DB
 	dbPaths map[string][]string
 	mutex   sync.RWMutex
+
+	// readOnly 时以只读方式打开数据库，不创建临时拷贝也不监听文件变化
+	readOnly bool
 }
 
 func NewDBManager(path string) *DBManager {
@@ -37,6 +40,14 @@ func NewDBManager(path string) *DBManager {
 	}
 }
 
+// NewReadOnlyDBManager 用于打开已解密的快照目录：目录内容不会再变化，
+// 因此跳过文件监听与 Windows 下的临时拷贝，数据库以 immutable 只读方式打开。
+func NewReadOnlyDBManager(path string) *DBManager {
+	d := NewDBManager(path)
+	d.readOnly = true
+	return d
+}
+
 func (d *DBManager) AddGroup(g *Group) error {
 	fg, err := filemonitor.NewFileGroup(g.Name, d.path, g.Pattern, g.BlackList)
 	if err != nil {
@@ -120,7 +131,9 @@ func (d *DBManager) OpenDB(path string) (*sql.DB, error) {
 	}
 	var err error
 	tempPath := path
-	if runtime.GOOS == "windows" {
+	if d.readOnly {
+		tempPath = "file:" + filepath.ToSlash(path) + "?mode=ro&immutable=1"
+	} else if runtime.GOOS == "windows" {
 		tempPath, err = filecopy.GetTempCopy(d.id, path)
 		if err != nil {
 			log.Err(err).Msgf("获取临时拷贝文件 %s 失败", path)
@@ -158,10 +171,16 @@ func (d *DBManager) Callback(event fsnotify.Event) error {
 }
 
 func (d *DBManager) Start() error {
+	if d.readOnly {
+		return nil
+	}
 	return d.fm.Start()
 }
 
 func (d *DBManager) Stop() error {
+	if d.readOnly {
+		return nil
+	}
 	return d.fm.Stop()
 }
 
@@ -169,5 +188,5 @@ func (d *DBManager) Close() error {
 	for _, db := range d.dbs {
 		db.Close()
 	}
-	return d.fm.Stop()
+	return d.Stop()
 }
@@ -0,0 +1,112 @@
+package datasource
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/darwinv3"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/dbm"
+	v4 "github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/v4"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/windowsv3"
+)
+
+// 数据源类型，对应 datasource 下的实现
+const (
+	FlavorV4        = "v4"
+	FlavorWindowsV3 = "windowsv3"
+	FlavorDarwinV3  = "darwinv3"
+)
+
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// flavors 以各实现的消息库文件名识别数据源类型
+var flavors = []struct {
+	name     string
+	platform string
+	version  int
+	groups   []*dbm.Group
+	message  string
+}{
+	{FlavorV4, "windows", 4, v4.Groups, v4.Message},
+	{FlavorWindowsV3, "windows", 3, windowsv3.Groups, windowsv3.Message},
+	{FlavorDarwinV3, "darwin", 3, darwinv3.Groups, darwinv3.Message},
+}
+
+// FlavorPlatform 返回数据源类型对应的 platform 与 version。
+// v4 在 Windows 与 macOS 上的数据库结构相同，统一返回 windows。
+func FlavorPlatform(flavor string) (string, int) {
+	for _, f := range flavors {
+		if f.name == flavor {
+			return f.platform, f.version
+		}
+	}
+	return "", 0
+}
+
+// Detect 根据目录中存在的消息库文件判断快照的数据源类型，
+// 并检查消息库是否已经解密。indexes 等 chatlog 自身生成的目录会被跳过。
+func Detect(path string) (string, error) {
+	patterns := make([]*regexp.Regexp, len(flavors))
+	for i, f := range flavors {
+		for _, g := range f.groups {
+			if g.Name == f.message {
+				patterns[i] = regexp.MustCompile(g.Pattern)
+			}
+		}
+	}
+
+	found := make([]string, len(flavors))
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && (d.Name() == "indexes" || strings.HasPrefix(d.Name(), ".")) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		for i, re := range patterns {
+			if re != nil && found[i] == "" && re.MatchString(d.Name()) {
+				found[i] = p
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errors.OpenFileFailed(path, err)
+	}
+
+	var names []string
+	var file, flavor string
+	for i, p := range found {
+		if p != "" {
+			names = append(names, flavors[i].name)
+			file, flavor = p, flavors[i].name
+		}
+	}
+	switch len(names) {
+	case 0:
+		return "", errors.SnapshotFlavorUnknown(path)
+	case 1:
+	default:
+		return "", errors.SnapshotFlavorAmbiguous(path, names)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", errors.OpenFileFailed(file, err)
+	}
+	defer f.Close()
+	header := make([]byte, len(sqliteHeader))
+	if _, err := io.ReadFull(f, header); err != nil || !bytes.Equal(header, sqliteHeader) {
+		return "", errors.SnapshotNotDecrypted(file)
+	}
+	return flavor, nil
+}
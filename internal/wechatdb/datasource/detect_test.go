@@ -0,0 +1,62 @@
+package datasource
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeDB(t *testing.T, path string, header string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(header+"rest of the page"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		file   string
+		flavor string
+	}{
+		{"db_storage/message/message_0.db", FlavorV4},
+		{"Msg/Multi/MSG0.db", FlavorWindowsV3},
+		{"Message/msg_1.db", FlavorDarwinV3},
+	}
+	for _, tc := range cases {
+		dir := t.TempDir()
+		writeDB(t, filepath.Join(dir, tc.file), string(sqliteHeader))
+		// chatlog 生成的索引不参与识别
+		writeDB(t, filepath.Join(dir, "indexes", "messages", "MSG0.db"), string(sqliteHeader))
+
+		flavor, err := Detect(dir)
+		if err != nil || flavor != tc.flavor {
+			t.Fatalf("%s: flavor = %q, err = %v", tc.file, flavor, err)
+		}
+	}
+
+	if platform, version := FlavorPlatform(FlavorDarwinV3); platform != "darwin" || version != 3 {
+		t.Fatalf("darwinv3 = %s v%d", platform, version)
+	}
+}
+
+func TestDetectErrors(t *testing.T) {
+	if _, err := Detect(t.TempDir()); err == nil {
+		t.Fatal("expected error for empty dir")
+	}
+
+	dir := t.TempDir()
+	writeDB(t, filepath.Join(dir, "message_0.db"), "encrypted bytes!")
+	if _, err := Detect(dir); err == nil {
+		t.Fatal("expected error for encrypted database")
+	}
+
+	dir = t.TempDir()
+	writeDB(t, filepath.Join(dir, "message_0.db"), string(sqliteHeader))
+	writeDB(t, filepath.Join(dir, "MSG0.db"), string(sqliteHeader))
+	if _, err := Detect(dir); err == nil {
+		t.Fatal("expected error for mixed flavors")
+	}
+}
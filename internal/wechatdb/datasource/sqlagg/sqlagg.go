@@ -0,0 +1,103 @@
+package sqlagg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// Query 描述如何在一张消息表上聚合单个会话的消息，各表达式由数据源按自身表结构给出，
+// 保证三种数据源使用同一套分组口径
+type Query struct {
+	From     string // FROM 之后的表与 JOIN
+	Where    string // 会话与时间范围条件
+	Args     []any  // Where 的参数
+	Time     string // 秒级时间戳表达式
+	Sender   string // 发送者 username 表达式；为空时不按发送者聚合，由数据源自行补充
+	Self     string // 是否为自己发送的布尔表达式
+	SelfArgs []any  // Self 中的参数
+	Type     string // 消息类型表达式
+	SubType  string // 消息子类型表达式
+}
+
+// Collect 执行聚合查询并把结果追加到 agg，同一会话分布在多个库时可多次调用
+func Collect(ctx context.Context, db *sql.DB, q Query, agg *model.TalkerAggregates) error {
+	self := "CASE WHEN " + q.Self + " THEN 1 ELSE 0 END"
+
+	var first, last sql.NullInt64
+	err := db.QueryRowContext(ctx, fmt.Sprintf(`SELECT MIN(%s), MAX(%s) FROM %s WHERE %s`, q.Time, q.Time, q.From, q.Where), q.Args...).
+		Scan(&first, &last)
+	if err != nil {
+		return err
+	}
+	if !first.Valid {
+		return nil
+	}
+	if agg.FirstUnix == 0 || first.Int64 < agg.FirstUnix {
+		agg.FirstUnix = first.Int64
+	}
+	if last.Int64 > agg.LastUnix {
+		agg.LastUnix = last.Int64
+	}
+
+	if q.Sender != "" {
+		rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT COALESCE(%s, ''), %s, COUNT(*) FROM %s WHERE %s GROUP BY 1, 2`,
+			q.Sender, self, q.From, q.Where), append(append([]any{}, q.SelfArgs...), q.Args...)...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var s model.SenderCount
+			var isSelf int
+			if err := rows.Scan(&s.Sender, &isSelf, &s.Count); err != nil {
+				rows.Close()
+				return err
+			}
+			s.IsSelf = isSelf == 1
+			agg.Senders = append(agg.Senders, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`SELECT %s, %s, COUNT(*) FROM %s WHERE %s GROUP BY 1, 2`,
+		q.Type, q.SubType, q.From, q.Where), q.Args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var t model.TypeCount
+		var sub sql.NullInt64
+		if err := rows.Scan(&t.Type, &sub, &t.Count); err != nil {
+			rows.Close()
+			return err
+		}
+		t.SubType = sub.Int64
+		agg.Types = append(agg.Types, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = db.QueryContext(ctx, fmt.Sprintf(`SELECT strftime('%%Y-%%m-%%d', %[1]s, 'unixepoch', 'localtime'),
+		CAST(strftime('%%H', %[1]s, 'unixepoch', 'localtime') AS INTEGER), SUM(%[2]s), COUNT(*) - SUM(%[2]s)
+		FROM %[3]s WHERE %[4]s GROUP BY 1, 2`, q.Time, self, q.From, q.Where),
+		append(append(append([]any{}, q.SelfArgs...), q.SelfArgs...), q.Args...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s model.SlotCount
+		if err := rows.Scan(&s.Day, &s.Hour, &s.Sent, &s.Received); err != nil {
+			return err
+		}
+		agg.Slots = append(agg.Slots, s)
+	}
+	return rows.Err()
+}
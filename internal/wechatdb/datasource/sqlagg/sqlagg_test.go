@@ -0,0 +1,174 @@
+package sqlagg
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+func openDB(t *testing.T, schema string, inserts ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range append([]string{schema}, inserts...) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+func TestCollectV4Layout(t *testing.T) {
+	db := openDB(t, `CREATE TABLE Name2Id (user_name TEXT)`,
+		`INSERT INTO Name2Id (rowid, user_name) VALUES (1, 'me'), (2, 'alice'), (3, 'bob')`,
+		`CREATE TABLE Msg_x (local_type INTEGER, create_time INTEGER, real_sender_id INTEGER, status INTEGER)`,
+	)
+
+	base := time.Date(2024, 3, 4, 9, 30, 0, 0, time.Local) // 星期一
+	rows := []struct {
+		localType int64
+		at        time.Time
+		sender    int
+		status    int
+	}{
+		{1, base, 1, 2},
+		{1, base.Add(time.Minute), 2, 4},
+		{3, base.Add(2 * time.Minute), 2, 4},
+		{6<<32 | 49, base.Add(25 * time.Hour), 3, 4},
+		{1, base.AddDate(0, 1, 0), 2, 4},
+	}
+	for _, r := range rows {
+		if _, err := db.Exec(`INSERT INTO Msg_x VALUES (?, ?, ?, ?)`, r.localType, r.at.Unix(), r.sender, r.status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := Query{
+		From:    `Msg_x m LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid`,
+		Where:   `m.create_time >= ? AND m.create_time <= ?`,
+		Args:    []any{base.Unix(), base.Add(48 * time.Hour).Unix()},
+		Time:    `m.create_time`,
+		Sender:  `n.user_name`,
+		Self:    `m.status = 2`,
+		Type:    `m.local_type & 4294967295`,
+		SubType: `m.local_type >> 32`,
+	}
+	agg := &model.TalkerAggregates{}
+	if err := Collect(context.Background(), db, q, agg); err != nil {
+		t.Fatal(err)
+	}
+
+	if agg.FirstUnix != base.Unix() || agg.LastUnix != base.Add(25*time.Hour).Unix() {
+		t.Fatalf("range = %d..%d", agg.FirstUnix, agg.LastUnix)
+	}
+
+	senders := map[string]model.SenderCount{}
+	for _, s := range agg.Senders {
+		senders[s.Sender] = s
+	}
+	if s := senders["me"]; !s.IsSelf || s.Count != 1 {
+		t.Fatalf("me = %+v", s)
+	}
+	if s := senders["alice"]; s.IsSelf || s.Count != 2 {
+		t.Fatalf("alice = %+v", s)
+	}
+
+	types := map[[2]int64]int64{}
+	for _, tc := range agg.Types {
+		types[[2]int64{tc.Type, tc.SubType}] = tc.Count
+	}
+	if types[[2]int64{1, 0}] != 2 || types[[2]int64{3, 0}] != 1 || types[[2]int64{49, 6}] != 1 {
+		t.Fatalf("types = %v", types)
+	}
+
+	sort.Slice(agg.Slots, func(i, j int) bool { return agg.Slots[i].Day < agg.Slots[j].Day })
+	want := []model.SlotCount{
+		{Day: "2024-03-04", Hour: 9, Sent: 1, Received: 2},
+		{Day: "2024-03-05", Hour: 10, Received: 1},
+	}
+	if len(agg.Slots) != len(want) {
+		t.Fatalf("slots = %+v", agg.Slots)
+	}
+	for i := range want {
+		if agg.Slots[i] != want[i] {
+			t.Fatalf("slot %d = %+v, want %+v", i, agg.Slots[i], want[i])
+		}
+	}
+}
+
+func TestCollectDarwinLayout(t *testing.T) {
+	db := openDB(t, `CREATE TABLE Chat_x (msgCreateTime INTEGER, msgContent TEXT, messageType INTEGER, mesDes INTEGER)`)
+	at := time.Date(2024, 3, 4, 20, 0, 0, 0, time.Local).Unix()
+	for _, r := range []struct {
+		content string
+		typ     int
+		des     int
+	}{
+		{"alice:\nhello", 1, 1},
+		{"alice:\n<msg><appmsg><title>t</title><type>5</type></appmsg></msg>", 49, 1},
+		{"hi", 1, 0},
+	} {
+		if _, err := db.Exec(`INSERT INTO Chat_x VALUES (?, ?, ?, ?)`, at, r.content, r.typ, r.des); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := Query{
+		From:    "Chat_x",
+		Where:   `msgCreateTime >= ? AND msgCreateTime <= ?`,
+		Args:    []any{at, at},
+		Time:    `msgCreateTime`,
+		Sender:  `CASE WHEN instr(msgContent, char(58, 10)) > 0 THEN substr(msgContent, 1, instr(msgContent, char(58, 10)) - 1) ELSE '' END`,
+		Self:    `mesDes = 0`,
+		Type:    `messageType`,
+		SubType: `CASE WHEN messageType = 49 AND instr(msgContent, '<type>') > 0 THEN CAST(substr(msgContent, instr(msgContent, '<type>') + 6, 8) AS INTEGER) ELSE 0 END`,
+	}
+	agg := &model.TalkerAggregates{}
+	if err := Collect(context.Background(), db, q, agg); err != nil {
+		t.Fatal(err)
+	}
+
+	var alice int64
+	for _, s := range agg.Senders {
+		if s.Sender == "alice" && !s.IsSelf {
+			alice = s.Count
+		}
+	}
+	if alice != 2 {
+		t.Fatalf("senders = %+v", agg.Senders)
+	}
+	found := false
+	for _, tc := range agg.Types {
+		if tc.Type == 49 && tc.SubType == 5 && tc.Count == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("types = %+v", agg.Types)
+	}
+	if len(agg.Slots) != 1 || agg.Slots[0].Sent != 1 || agg.Slots[0].Received != 2 || agg.Slots[0].Hour != 20 {
+		t.Fatalf("slots = %+v", agg.Slots)
+	}
+}
+
+func TestCollectEmpty(t *testing.T) {
+	db := openDB(t, `CREATE TABLE MSG (CreateTime INTEGER, IsSender INTEGER, Type INTEGER, SubType INTEGER)`)
+	q := Query{From: "MSG", Where: "1", Time: "CreateTime", Self: "IsSender = 1", Type: "Type", SubType: "SubType"}
+	agg := &model.TalkerAggregates{}
+	if err := Collect(context.Background(), db, q, agg); err != nil {
+		t.Fatal(err)
+	}
+	if agg.FirstUnix != 0 || len(agg.Slots) != 0 || len(agg.Types) != 0 {
+		t.Fatalf("agg = %+v", agg)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
 	"github.com/takeaway1/chatlog-TCOTC/internal/model"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/dbm"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/sqlagg"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/msgstore"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
 )
@@ -86,10 +87,19 @@ type DataSource struct {
 }
 
 func New(path string) (*DataSource, error) {
+	return newDataSource(path, dbm.NewDBManager(path))
+}
+
+// NewReadOnly 以只读方式打开已解密的快照目录，不监听文件变化
+func NewReadOnly(path string) (*DataSource, error) {
+	return newDataSource(path, dbm.NewReadOnlyDBManager(path))
+}
+
+func newDataSource(path string, m *dbm.DBManager) (*DataSource, error) {
 
 	ds := &DataSource{
 		path:               path,
-		dbm:                dbm.NewDBManager(path),
+		dbm:                m,
 		messageInfos:       make([]MessageDBInfo, 0),
 		talkerDBMap:        make(map[string]string),
 		messageStores:      make([]*msgstore.Store, 0),
@@ -273,7 +283,7 @@ func (ds *DataSource) initMessageDbs() error {
 			ID:        id,
 			FilePath:  info.FilePath,
 			FileName:  filename,
-			IndexPath: filepath.Join(ds.path, "indexes", "messages", id+".fts.db"),
+			IndexPath: id + ".fts.db",
 			StartTime: info.StartTime,
 			EndTime:   info.EndTime,
 			Talkers:   talkerMap,
@@ -1333,7 +1343,12 @@ func (ds *DataSource) Heatmap(ctx context.Context) ([24][7]int64, error) {
 	return grid, nil
 }
 
-// IntimacyBase 统计按联系人（非群聊）聚合的亲密度基础数据（v4）
+// privateSelfExpr 判断私聊消息是否为自己发送，与 MessageV4.Wrap 一致：
+// status=2（已发送）或发送者不是对方。单看 status 会漏掉部分自己发送的消息。
+// 需要 Msg 表别名 m、Name2Id 表别名 n，参数为对方的 username
+const privateSelfExpr = `(m.status = 2 OR COALESCE(n.user_name, '') != ?)`
+
+// IntimacyBase 统计按联系人（非群聊）聚合的亲密度基础数据（v4），发送数按 privateSelfExpr 统计
 func (ds *DataSource) IntimacyBase(ctx context.Context) (map[string]*model.IntimacyBase, error) {
 	result := make(map[string]*model.IntimacyBase)
 
@@ -1406,7 +1421,8 @@ func (ds *DataSource) IntimacyBase(ctx context.Context) (map[string]*model.Intim
 		}
 
 		// total, sent, min, max
-		row := t.db.QueryRowContext(ctx, `SELECT COUNT(*), SUM(CASE WHEN status=2 THEN 1 ELSE 0 END), MIN(create_time), MAX(create_time) FROM `+t.name)
+		row := t.db.QueryRowContext(ctx, `SELECT COUNT(*), SUM(CASE WHEN `+privateSelfExpr+` THEN 1 ELSE 0 END), MIN(m.create_time), MAX(m.create_time)
+			FROM `+t.name+` m LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid`, talker)
 		var total, sent, minct, maxct sql.NullInt64
 		if row.Scan(&total, &sent, &minct, &maxct) == nil {
 			base := result[talker]
@@ -1463,7 +1479,8 @@ func (ds *DataSource) IntimacyBase(ctx context.Context) (map[string]*model.Intim
 		}
 
 		// 过去7天发送
-		row4 := t.db.QueryRowContext(ctx, `SELECT SUM(CASE WHEN status=2 THEN 1 ELSE 0 END) FROM `+t.name+` WHERE create_time>=?`, since7)
+		row4 := t.db.QueryRowContext(ctx, `SELECT SUM(CASE WHEN `+privateSelfExpr+` THEN 1 ELSE 0 END)
+			FROM `+t.name+` m LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid WHERE m.create_time>=?`, talker, since7)
 		var s7 sql.NullInt64
 		if row4.Scan(&s7) == nil && s7.Valid {
 			base := result[talker]
@@ -1806,3 +1823,47 @@ func (ds *DataSource) GroupMessageTypeStats(ctx context.Context) (map[string]int
 	}
 	return result, nil
 }
+
+// TalkerAggregates 按发送者、消息类型与时段聚合单个会话的消息（v4）。
+// local_type 低 32 位为消息类型、高 32 位为子类型；IsSelf 的判断与 MessageV4.Wrap 一致
+func (ds *DataSource) TalkerAggregates(ctx context.Context, talker string, startTime, endTime time.Time) (*model.TalkerAggregates, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	agg := &model.TalkerAggregates{}
+
+	md5sum := md5.Sum([]byte(talker))
+	tbl := "Msg_" + hex.EncodeToString(md5sum[:])
+	q := sqlagg.Query{
+		From:    tbl + ` m LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid`,
+		Where:   `m.create_time >= ? AND m.create_time <= ?`,
+		Args:    []any{startTime.Unix(), endTime.Unix()},
+		Time:    `m.create_time`,
+		Sender:  `n.user_name`,
+		Self:    `m.status = 2`,
+		Type:    `m.local_type & 4294967295`,
+		SubType: `m.local_type >> 32`,
+	}
+	if !strings.HasSuffix(talker, "@chatroom") {
+		q.Self = privateSelfExpr
+		q.SelfArgs = []any{talker}
+	}
+
+	for _, info := range ds.getDBInfosForTimeRange(startTime, endTime) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		db, err := ds.dbm.OpenDB(info.FilePath)
+		if err != nil {
+			continue
+		}
+		var name string
+		if err := db.QueryRowContext(ctx, `SELECT name FROM sqlite_master WHERE type='table' AND name=?`, tbl).Scan(&name); err != nil {
+			continue
+		}
+		if err := sqlagg.Collect(ctx, db, q, agg); err != nil {
+			return nil, errors.QueryFailed("", err)
+		}
+	}
+	return agg, nil
+}
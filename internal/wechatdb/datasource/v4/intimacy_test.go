@@ -0,0 +1,107 @@
+package v4
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// 三个数据源的亲密度测试使用相同的时间线，期望得到相同的统计
+const (
+	fixtureLatest = int64(1_700_000_000)
+	fixtureDay    = int64(86400)
+)
+
+var wantIntimacy = model.IntimacyBase{
+	UserName:         "alice",
+	MsgCount:         6,
+	SentCount:        3,
+	ReceivedCount:    3,
+	MinCreateUnix:    fixtureLatest - 100*fixtureDay,
+	MaxCreateUnix:    fixtureLatest,
+	MessagingDays:    5,
+	Last90DaysMsg:    4,
+	Past7DaysSentMsg: 2,
+}
+
+var fixtureTimeline = []struct {
+	createTime int64
+	self       bool
+}{
+	{fixtureLatest - 100*fixtureDay, true},
+	{fixtureLatest - 100*fixtureDay + 60, false},
+	{fixtureLatest - 30*fixtureDay, false},
+	{fixtureLatest - 3*fixtureDay, true},
+	{fixtureLatest - fixtureDay, true},
+	{fixtureLatest, false},
+}
+
+func execSQL(t *testing.T, path string, stmts ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+func TestIntimacyBase(t *testing.T) {
+	dir := t.TempDir()
+	execSQL(t, filepath.Join(dir, "contact.db"),
+		`CREATE TABLE contact (username TEXT)`,
+		`INSERT INTO contact (username) VALUES ('alice'), ('g@chatroom')`)
+
+	table := func(talker string) string {
+		sum := md5.Sum([]byte(talker))
+		return "Msg_" + hex.EncodeToString(sum[:])
+	}
+	schema := ` (local_id INTEGER PRIMARY KEY, local_type INTEGER, real_sender_id INTEGER, create_time INTEGER, status INTEGER, message_content TEXT)`
+	db := execSQL(t, filepath.Join(dir, "message_0.db"),
+		`CREATE TABLE Timestamp (timestamp INTEGER)`,
+		`INSERT INTO Timestamp VALUES (0)`,
+		`CREATE TABLE Name2Id (user_name TEXT)`,
+		`INSERT INTO Name2Id (rowid, user_name) VALUES (1, 'alice'), (2, 'me')`,
+		`CREATE TABLE `+table("alice")+schema,
+		`CREATE TABLE `+table("g@chatroom")+schema)
+	for i, m := range fixtureTimeline {
+		senderID, status := 1, 4
+		if m.self {
+			senderID, status = 2, 2
+			if i == 0 {
+				// status 不是 2 的自己发送的消息，需要根据发送者判断
+				status = 3
+			}
+		}
+		if _, err := db.Exec(`INSERT INTO `+table("alice")+` (local_type, real_sender_id, create_time, status, message_content) VALUES (1, ?, ?, ?, 'hi')`, senderID, m.createTime, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO `+table("g@chatroom")+` (local_type, real_sender_id, create_time, status, message_content) VALUES (1, 2, ?, 2, 'hi')`, fixtureLatest); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := NewReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+
+	bases, err := ds.IntimacyBase(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bases) != 1 || bases["alice"] == nil || *bases["alice"] != wantIntimacy {
+		t.Fatalf("bases = %+v", bases["alice"])
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
 	"github.com/takeaway1/chatlog-TCOTC/internal/model"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/dbm"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource/sqlagg"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/msgstore"
 	"github.com/takeaway1/chatlog-TCOTC/pkg/util"
 )
@@ -89,13 +90,26 @@ type DataSource struct {
 	talkerCacheMu     sync.RWMutex
 	talkerCache       []string
 	talkerCacheExpiry time.Time
+
+	// 群聊发送者索引，key 为 数据库路径 + talker
+	groupSenderMu    sync.Mutex
+	groupSenderCache map[string]*groupSenders
 }
 
 // New 创建一个新的 WindowsV3DataSource
 func New(path string) (*DataSource, error) {
+	return newDataSource(path, dbm.NewDBManager(path))
+}
+
+// NewReadOnly 以只读方式打开已解密的快照目录，不监听文件变化
+func NewReadOnly(path string) (*DataSource, error) {
+	return newDataSource(path, dbm.NewReadOnlyDBManager(path))
+}
+
+func newDataSource(path string, m *dbm.DBManager) (*DataSource, error) {
 	ds := &DataSource{
 		path:          path,
-		dbm:           dbm.NewDBManager(path),
+		dbm:           m,
 		messageInfos:  make([]MessageDBInfo, 0),
 		messageStores: make([]*msgstore.Store, 0),
 	}
@@ -250,7 +264,7 @@ func (ds *DataSource) initMessageDbs() error {
 			ID:        id,
 			FilePath:  info.FilePath,
 			FileName:  filename,
-			IndexPath: filepath.Join(ds.path, "indexes", "messages", id+".fts.db"),
+			IndexPath: id + ".fts.db",
 			StartTime: info.StartTime,
 			EndTime:   info.EndTime,
 			Talkers:   talkers,
@@ -1582,3 +1596,72 @@ func mapV3TypeToLabel(t int64, st int64) string {
 		return ""
 	}
 }
+
+// TalkerAggregates 按发送者、消息类型与时段聚合单个会话的消息（Windows v3）。
+// 群聊发送者保存在 BytesExtra 的 protobuf 中，无法在 SQL 中分组，只读取该列在内存中计数
+func (ds *DataSource) TalkerAggregates(ctx context.Context, talker string, startTime, endTime time.Time) (*model.TalkerAggregates, error) {
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	agg := &model.TalkerAggregates{}
+	isChatRoom := strings.HasSuffix(talker, "@chatroom")
+	groupSenders := make(map[string]int64)
+	var selfCount int64
+
+	for _, info := range ds.getDBInfosForTimeRange(startTime, endTime) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		db, err := ds.dbm.OpenDB(info.FilePath)
+		if err != nil {
+			continue
+		}
+
+		talkerWhere, talkerArgs := `StrTalker = ?`, []any{talker}
+		if talkerID, ok := info.TalkerMap[talker]; ok {
+			talkerWhere, talkerArgs = `TalkerId = ?`, []any{talkerID}
+		}
+		q := sqlagg.Query{
+			From:    `MSG`,
+			Where:   `CreateTime >= ? AND CreateTime <= ? AND ` + talkerWhere,
+			Args:    append([]any{startTime.Unix(), endTime.Unix()}, talkerArgs...),
+			Time:    `CreateTime`,
+			Self:    `IsSender = 1`,
+			Type:    `Type`,
+			SubType: `SubType`,
+		}
+		if !isChatRoom {
+			q.Sender = `StrTalker`
+		}
+		if err := sqlagg.Collect(ctx, db, q, agg); err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			return nil, errors.QueryFailed("", err)
+		}
+		if !isChatRoom {
+			continue
+		}
+
+		// 群聊发送者保存在 BytesExtra 中，无法在 SQL 中聚合：自己发送的消息直接计数，
+		// 其余消息的发送者按检查点缓存，见 groupSenderIndex
+		var n int64
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM MSG WHERE `+q.Where+` AND IsSender = 1`, q.Args...).Scan(&n); err != nil {
+			return nil, errors.QueryFailed("", err)
+		}
+		selfCount += n
+		idx, err := ds.groupSenderIndex(ctx, db, info.FilePath, talker, talkerWhere, talkerArgs)
+		if err != nil {
+			return nil, err
+		}
+		idx.count(startTime.Unix(), endTime.Unix(), groupSenders)
+	}
+
+	if selfCount > 0 {
+		agg.Senders = append(agg.Senders, model.SenderCount{IsSelf: true, Count: selfCount})
+	}
+	for sender, count := range groupSenders {
+		agg.Senders = append(agg.Senders, model.SenderCount{Sender: sender, Count: count})
+	}
+	return agg, nil
+}
@@ -0,0 +1,110 @@
+package windowsv3
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// groupSenderCacheSize 为缓存的 (数据库, 群聊) 个数上限，超出时淘汰最久未使用的
+const groupSenderCacheSize = 16
+
+// groupSenders 是一个群聊在单个消息库中他人发送的消息，按时间排序。
+// 发送者需要从 BytesExtra 中解析，每个检查点（数据库文件变化）只扫描一次，
+// 之后任意时间范围的统计只在内存中计数
+type groupSenders struct {
+	stamp   string
+	times   []int64
+	senders []int32 // names 的下标
+	names   []string
+	usedAt  time.Time
+}
+
+// count 把 [start, end] 内各发送者的消息数累加到 out
+func (g *groupSenders) count(start, end int64, out map[string]int64) {
+	lo := sort.Search(len(g.times), func(i int) bool { return g.times[i] >= start })
+	hi := sort.Search(len(g.times), func(i int) bool { return g.times[i] > end })
+	for i := lo; i < hi; i++ {
+		out[g.names[g.senders[i]]]++
+	}
+}
+
+// groupSenderIndex 返回 talker 在 path 中的发送者索引，数据库文件未变化时复用缓存
+func (ds *DataSource) groupSenderIndex(ctx context.Context, db *sql.DB, path, talker, where string, args []any) (*groupSenders, error) {
+	key := path + "\x00" + talker
+	stamp := fileStamp(path)
+
+	ds.groupSenderMu.Lock()
+	if g := ds.groupSenderCache[key]; g != nil && g.stamp == stamp {
+		g.usedAt = time.Now()
+		ds.groupSenderMu.Unlock()
+		return g, nil
+	}
+	ds.groupSenderMu.Unlock()
+
+	rows, err := db.QueryContext(ctx, `SELECT CreateTime, BytesExtra FROM MSG WHERE `+where+` AND IFNULL(IsSender, 0) != 1 ORDER BY CreateTime`, args...)
+	if err != nil {
+		return nil, errors.QueryFailed("", err)
+	}
+	defer rows.Close()
+
+	g := &groupSenders{stamp: stamp, usedAt: time.Now()}
+	lookup := make(map[string]int32)
+	for rows.Next() {
+		var ts int64
+		var extra []byte
+		if err := rows.Scan(&ts, &extra); err != nil {
+			return nil, errors.ScanRowFailed(err)
+		}
+		sender := ""
+		if parsed := model.ParseBytesExtra(extra); parsed != nil {
+			sender = parsed[1]
+		}
+		id, ok := lookup[sender]
+		if !ok {
+			id = int32(len(g.names))
+			lookup[sender] = id
+			g.names = append(g.names, sender)
+		}
+		g.times = append(g.times, ts)
+		g.senders = append(g.senders, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.QueryFailed("", err)
+	}
+
+	ds.groupSenderMu.Lock()
+	defer ds.groupSenderMu.Unlock()
+	if ds.groupSenderCache == nil {
+		ds.groupSenderCache = make(map[string]*groupSenders)
+	}
+	ds.groupSenderCache[key] = g
+	for len(ds.groupSenderCache) > groupSenderCacheSize {
+		oldest := ""
+		for k, v := range ds.groupSenderCache {
+			if oldest == "" || v.usedAt.Before(ds.groupSenderCache[oldest].usedAt) {
+				oldest = k
+			}
+		}
+		delete(ds.groupSenderCache, oldest)
+	}
+	return g, nil
+}
+
+// fileStamp 以数据库文件及其 WAL 的大小与修改时间标识一个检查点
+func fileStamp(path string) string {
+	var b strings.Builder
+	for _, p := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(p); err == nil {
+			fmt.Fprintf(&b, "%d/%d;", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package windowsv3
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model/wxproto"
+)
+
+func senderExtra(t *testing.T, sender string) []byte {
+	t.Helper()
+	b, err := proto.Marshal(&wxproto.BytesExtra{Items: []*wxproto.BytesExtraItem{{Type: 1, Value: sender}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func senderCounts(agg *model.TalkerAggregates) map[string]int64 {
+	out := make(map[string]int64)
+	for _, s := range agg.Senders {
+		if s.IsSelf {
+			out["self"] += s.Count
+			continue
+		}
+		out[s.Sender] += s.Count
+	}
+	return out
+}
+
+func TestTalkerAggregatesChatRoomSenders(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "MSG0.db")
+	db := execSQL(t, path,
+		`CREATE TABLE DBInfo (tableIndex INTEGER, tableVersion INTEGER, tableDesc TEXT)`,
+		`INSERT INTO DBInfo VALUES (0, 0, 'Start Time')`,
+		`CREATE TABLE Name2ID (UsrName TEXT)`,
+		`INSERT INTO Name2ID VALUES ('g@chatroom')`,
+		`CREATE TABLE MSG (localId INTEGER PRIMARY KEY, TalkerId INTEGER, StrTalker TEXT, CreateTime INTEGER,
+			IsSender INTEGER, Type INTEGER, SubType INTEGER, BytesExtra BLOB)`)
+	insert := func(at int64, isSender int, sender string) {
+		t.Helper()
+		if _, err := db.Exec(`INSERT INTO MSG (TalkerId, StrTalker, CreateTime, IsSender, Type, SubType, BytesExtra) VALUES (1, 'g@chatroom', ?, ?, 1, 0, ?)`,
+			at, isSender, senderExtra(t, sender)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert(fixtureLatest-2*fixtureDay, 1, "")
+	insert(fixtureLatest-2*fixtureDay, 0, "alice")
+	insert(fixtureLatest-fixtureDay, 0, "bob")
+	insert(fixtureLatest, 0, "alice")
+	insert(fixtureLatest, 1, "")
+
+	ds, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+
+	ctx := context.Background()
+	all, err := ds.TalkerAggregates(ctx, "g@chatroom", time.Unix(0, 0), time.Unix(fixtureLatest, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := senderCounts(all); got["self"] != 2 || got["alice"] != 2 || got["bob"] != 1 {
+		t.Fatalf("senders = %v", got)
+	}
+
+	// 同一检查点内的其他时间范围复用缓存的发送者索引
+	cached := ds.groupSenderCache[path+"\x00g@chatroom"]
+	recent, err := ds.TalkerAggregates(ctx, "g@chatroom", time.Unix(fixtureLatest-fixtureDay, 0), time.Unix(fixtureLatest, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := senderCounts(recent); got["self"] != 1 || got["alice"] != 1 || got["bob"] != 1 {
+		t.Fatalf("recent senders = %v", got)
+	}
+	if ds.groupSenderCache[path+"\x00g@chatroom"] != cached {
+		t.Fatal("sender index rebuilt without a database change")
+	}
+
+	// 数据库变化后重新扫描
+	time.Sleep(10 * time.Millisecond)
+	insert(fixtureLatest, 0, "carol")
+	updated, err := ds.TalkerAggregates(ctx, "g@chatroom", time.Unix(0, 0), time.Unix(fixtureLatest, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := senderCounts(updated); got["carol"] != 1 {
+		t.Fatalf("senders after insert = %v", got)
+	}
+}
@@ -0,0 +1,87 @@
+package windowsv3
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+// 三个数据源的亲密度测试使用相同的时间线，期望得到相同的统计
+const (
+	fixtureLatest = int64(1_700_000_000)
+	fixtureDay    = int64(86400)
+)
+
+var wantIntimacy = model.IntimacyBase{
+	UserName:         "alice",
+	MsgCount:         6,
+	SentCount:        3,
+	ReceivedCount:    3,
+	MinCreateUnix:    fixtureLatest - 100*fixtureDay,
+	MaxCreateUnix:    fixtureLatest,
+	MessagingDays:    5,
+	Last90DaysMsg:    4,
+	Past7DaysSentMsg: 2,
+}
+
+var fixtureTimeline = []struct {
+	createTime int64
+	self       bool
+}{
+	{fixtureLatest - 100*fixtureDay, true},
+	{fixtureLatest - 100*fixtureDay + 60, false},
+	{fixtureLatest - 30*fixtureDay, false},
+	{fixtureLatest - 3*fixtureDay, true},
+	{fixtureLatest - fixtureDay, true},
+	{fixtureLatest, false},
+}
+
+func execSQL(t *testing.T, path string, stmts ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+func TestIntimacyBase(t *testing.T) {
+	dir := t.TempDir()
+	db := execSQL(t, filepath.Join(dir, "MSG0.db"),
+		`CREATE TABLE MSG (localId INTEGER PRIMARY KEY, StrTalker TEXT, CreateTime INTEGER, IsSender INTEGER)`)
+	for _, m := range fixtureTimeline {
+		isSender := 0
+		if m.self {
+			isSender = 1
+		}
+		if _, err := db.Exec(`INSERT INTO MSG (StrTalker, CreateTime, IsSender) VALUES ('alice', ?, ?)`, m.createTime, isSender); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO MSG (StrTalker, CreateTime, IsSender) VALUES ('g@chatroom', ?, 1)`, fixtureLatest); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := NewReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+
+	bases, err := ds.IntimacyBase(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bases) != 1 || bases["alice"] == nil || *bases["alice"] != wantIntimacy {
+		t.Fatalf("bases = %+v", bases["alice"])
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,12 +15,15 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/takeaway1/chatlog-TCOTC/internal/embedding"
 	"github.com/takeaway1/chatlog-TCOTC/internal/model"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/msgstore"
 )
 
 const (
-	runtimeIndexVersion = "3"
+	runtimeIndexVersion = "4"
+
+	snippetWindow = 48
 )
 
 var (
@@ -29,28 +31,48 @@ var (
 )
 
 type metadata struct {
-	Version     string `json:"version"`
-	Fingerprint string `json:"fingerprint"`
-	LastBuilt   int64  `json:"last_built"`
+	Version     string    `json:"version"`
+	Tokenizer   Tokenizer `json:"tokenizer"`
+	Fingerprint string    `json:"fingerprint"`
+	LastBuilt   int64     `json:"last_built"`
 }
 
 type storeIndex struct {
-	mu   sync.RWMutex
-	db   *sql.DB
-	path string
+	mu        sync.RWMutex
+	db        *sql.DB
+	path      string
+	tokenizer Tokenizer
+	vectors   *vectorStore
+}
+
+// Options tunes how an Index materialises its per-store databases.
+type Options struct {
+	// Tokenizer controls CJK segmentation; empty selects DefaultTokenizer.
+	Tokenizer string
+
+	// Embedder enables the chunk vector store used by semantic and hybrid
+	// search. Nil keeps the index keyword-only.
+	Embedder embedding.Embedder
+
+	// Transcript returns the stored transcript of a voice message by its
+	// media key, so that voice messages are indexed by what was said.
+	Transcript func(voiceKey string) string
 }
 
 // Index coordinates a set of per-store SQLite FTS indices.
 type Index struct {
-	mu       sync.RWMutex
-	basePath string
-	metaPath string
-	meta     metadata
-	stores   map[string]*storeIndex
+	mu         sync.RWMutex
+	basePath   string
+	metaPath   string
+	meta       metadata
+	tokenizer  Tokenizer
+	embedder   embedding.Embedder
+	transcript func(voiceKey string) string
+	stores     map[string]*storeIndex
 }
 
 // Open prepares an Index rooted at basePath.
-func Open(basePath string) (*Index, error) {
+func Open(basePath string, opts Options) (*Index, error) {
 	if err := os.MkdirAll(basePath, 0o755); err != nil {
 		return nil, fmt.Errorf("create index base dir: %w", err)
 	}
@@ -61,14 +83,40 @@ func Open(basePath string) (*Index, error) {
 		return nil, fmt.Errorf("load index metadata: %w", err)
 	}
 
+	tokenizer, err := resolveTokenizer(opts.Tokenizer, meta)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Index{
-		basePath: basePath,
-		metaPath: metaPath,
-		meta:     meta,
-		stores:   make(map[string]*storeIndex),
+		basePath:   basePath,
+		metaPath:   metaPath,
+		meta:       meta,
+		tokenizer:  tokenizer,
+		embedder:   opts.Embedder,
+		transcript: opts.Transcript,
+		stores:     make(map[string]*storeIndex),
 	}, nil
 }
 
+// resolveTokenizer picks the tokenizer for an index. An explicit value always
+// wins. An index at the current version keeps the tokenizer it was built
+// with; anything else is about to be rebuilt anyway, so it gets the default.
+func resolveTokenizer(value string, meta metadata) (Tokenizer, error) {
+	if strings.TrimSpace(value) != "" || meta.Version != runtimeIndexVersion || meta.Tokenizer == "" {
+		return ParseTokenizer(value)
+	}
+	return ParseTokenizer(string(meta.Tokenizer))
+}
+
+// Tokenizer returns the tokenizer new documents are indexed with.
+func (i *Index) Tokenizer() Tokenizer {
+	if i == nil {
+		return ""
+	}
+	return i.tokenizer
+}
+
 // Close releases all opened store indices.
 func (i *Index) Close() error {
 	if i == nil {
@@ -88,7 +136,8 @@ func (i *Index) Close() error {
 	return firstErr
 }
 
-// Reset removes all materialised indices.
+// Reset removes all materialised FTS indices. Vector stores are kept since
+// their chunks are keyed by talker and seq, which a rebuild reproduces.
 func (i *Index) Reset() error {
 	if i == nil {
 		return nil
@@ -140,7 +189,8 @@ func (i *Index) SyncStores(stores []*msgstore.Store) error {
 	return nil
 }
 
-// EnsureVersion guarantees the on-disk metadata matches the runtime version.
+// EnsureVersion guarantees the on-disk metadata matches the runtime version
+// and tokenizer. A mismatch means every store has to be rebuilt.
 func (i *Index) EnsureVersion() (bool, error) {
 	if i == nil {
 		return false, nil
@@ -149,11 +199,12 @@ func (i *Index) EnsureVersion() (bool, error) {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	if i.meta.Version == runtimeIndexVersion {
+	if i.meta.Version == runtimeIndexVersion && i.meta.Tokenizer == i.tokenizer {
 		return true, nil
 	}
 
 	i.meta.Version = runtimeIndexVersion
+	i.meta.Tokenizer = i.tokenizer
 	if err := i.saveMetadataLocked(); err != nil {
 		return false, err
 	}
@@ -258,16 +309,42 @@ func (i *Index) IndexStoreMessages(store *msgstore.Store, messages []*model.Mess
 	if err != nil {
 		return err
 	}
+	i.attachTranscripts(messages)
 	return si.indexMessages(messages)
 }
 
+// attachTranscripts copies stored transcripts into voice messages so that
+// both the indexed content and the returned message carry the text.
+func (i *Index) attachTranscripts(messages []*model.Message) {
+	if i.transcript == nil {
+		return
+	}
+	for _, msg := range messages {
+		if msg == nil || msg.Type != model.MessageTypeVoice || msg.Contents == nil {
+			continue
+		}
+		key, _ := msg.Contents["voice"].(string)
+		if key == "" {
+			continue
+		}
+		if text := i.transcript(key); text != "" {
+			msg.Contents["transcript"] = text
+		}
+	}
+}
+
 // Search performs a federated search across all store indices.
 func (i *Index) Search(req *model.SearchRequest, talkers []string, senders []string, startUnix, endUnix int64, offset, limit int) ([]*SearchHit, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("search request is nil")
 	}
+	limit, offset = clampPage(limit, offset)
+	return i.keywordSearch(req, talkers, senders, startUnix, endUnix, offset, limit)
+}
 
-	match, err := buildFTSQuery(req.Query)
+// keywordSearch is Search without the page size cap.
+func (i *Index) keywordSearch(req *model.SearchRequest, talkers []string, senders []string, startUnix, endUnix int64, offset, limit int) ([]*SearchHit, int, error) {
+	match, err := buildFTSQuery(req.Query, i.tokenizer)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -278,22 +355,7 @@ func (i *Index) Search(req *model.SearchRequest, talkers []string, senders []str
 	talkers = dedupeStrings(talkers)
 	senders = dedupeStrings(senders)
 
-	if limit <= 0 {
-		limit = 20
-	}
-	if limit > 200 {
-		limit = 200
-	}
-	if offset < 0 {
-		offset = 0
-	}
-
-	i.mu.RLock()
-	stores := make([]*storeIndex, 0, len(i.stores))
-	for _, si := range i.stores {
-		stores = append(stores, si)
-	}
-	i.mu.RUnlock()
+	stores := i.snapshotStores()
 
 	if len(stores) == 0 {
 		return []*SearchHit{}, 0, nil
@@ -304,10 +366,12 @@ func (i *Index) Search(req *model.SearchRequest, talkers []string, senders []str
 		perStoreLimit = limit
 	}
 
+	terms := queryTerms(req.Query)
+
 	combined := make([]*SearchHit, 0, len(stores)*limit)
 	total := 0
 	for _, si := range stores {
-		hits, count, err := si.search(match, talkers, senders, startUnix, endUnix, 0, perStoreLimit)
+		hits, count, err := si.search(match, terms, talkers, senders, startUnix, endUnix, 0, perStoreLimit)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -319,33 +383,8 @@ func (i *Index) Search(req *model.SearchRequest, talkers []string, senders []str
 		return []*SearchHit{}, total, nil
 	}
 
-	sort.Slice(combined, func(a, b int) bool {
-		ha := combined[a]
-		hb := combined[b]
-		if ha == nil || hb == nil {
-			return ha != nil
-		}
-		if ha.Score != hb.Score {
-			return ha.Score < hb.Score
-		}
-		ta := ha.Message.Time.Unix()
-		tb := hb.Message.Time.Unix()
-		if ta != tb {
-			return ta > tb
-		}
-		return ha.Message.Seq > hb.Message.Seq
-	})
-
-	if offset >= len(combined) {
-		return []*SearchHit{}, total, nil
-	}
-
-	end := offset + limit
-	if end > len(combined) {
-		end = len(combined)
-	}
-
-	return combined[offset:end], total, nil
+	sortHits(combined)
+	return pageHits(combined, offset, limit), total, nil
 }
 
 func (i *Index) ensureStoreIndex(store *msgstore.Store) (*storeIndex, error) {
@@ -377,10 +416,18 @@ func (i *Index) ensureStoreIndexLocked(store *msgstore.Store) (*storeIndex, erro
 		_ = existing.close()
 	}
 
-	si, err := newStoreIndex(path)
+	si, err := newStoreIndex(path, i.tokenizer)
 	if err != nil {
 		return nil, err
 	}
+	if i.embedder != nil {
+		vectors, err := openVectorStore(vectorPathFor(path), i.embedder.ModelName())
+		if err != nil {
+			_ = si.close()
+			return nil, err
+		}
+		si.vectors = vectors
+	}
 
 	i.stores[id] = si
 	return si, nil
@@ -401,7 +448,7 @@ func (i *Index) resolveStorePath(store *msgstore.Store) string {
 	return filepath.Join(i.basePath, id+".fts.db")
 }
 
-func newStoreIndex(path string) (*storeIndex, error) {
+func newStoreIndex(path string, tokenizer Tokenizer) (*storeIndex, error) {
 	parent := filepath.Dir(path)
 	if err := os.MkdirAll(parent, 0o755); err != nil {
 		return nil, fmt.Errorf("create store index dir: %w", err)
@@ -413,12 +460,12 @@ func newStoreIndex(path string) (*storeIndex, error) {
 		return nil, fmt.Errorf("open store index: %w", err)
 	}
 
-	if err := initSchema(db); err != nil {
+	if err := initSchema(db, tokenizer); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
 
-	return &storeIndex{db: db, path: path}, nil
+	return &storeIndex{db: db, path: path, tokenizer: tokenizer}, nil
 }
 
 func (s *storeIndex) close() error {
@@ -432,12 +479,13 @@ func (s *storeIndex) close() error {
 	if s.db == nil {
 		return nil
 	}
+	_ = s.vectors.close()
 	err := s.db.Close()
 	s.db = nil
 	return err
 }
 
-func initSchema(db *sql.DB) error {
+func initSchema(db *sql.DB, tokenizer Tokenizer) error {
 	pragmas := []string{
 		"PRAGMA foreign_keys = ON;",
 		"PRAGMA journal_mode = WAL;",
@@ -450,11 +498,39 @@ func initSchema(db *sql.DB) error {
 		}
 	}
 
-	statements := []string{
-		`CREATE TABLE IF NOT EXISTS metadata (
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS metadata (
 key   TEXT PRIMARY KEY,
 value TEXT NOT NULL
-);`,
+);`); err != nil {
+		return fmt.Errorf("init schema metadata: %w", err)
+	}
+
+	// Store files may outlive index-meta.json (custom IndexPath, partial
+	// resets), so each one records the schema it was built with and drops
+	// stale tables instead of mixing token layouts.
+	schema := runtimeIndexVersion + "/" + string(tokenizer)
+	var current string
+	err := db.QueryRow(`SELECT value FROM metadata WHERE key = 'schema'`).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("read schema metadata: %w", err)
+	}
+	if current != schema {
+		drops := []string{
+			`DROP TRIGGER IF EXISTS messages_ai;`,
+			`DROP TRIGGER IF EXISTS messages_ad;`,
+			`DROP TRIGGER IF EXISTS messages_au;`,
+			`DROP TABLE IF EXISTS messages_fts;`,
+			`DROP TABLE IF EXISTS messages;`,
+			`DROP TABLE IF EXISTS checkpoints;`,
+		}
+		for _, stmt := range drops {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("drop stale schema: %w", err)
+			}
+		}
+	}
+
+	statements := []string{
 		`CREATE TABLE IF NOT EXISTS messages (
 doc_id       TEXT NOT NULL UNIQUE,
 talker       TEXT NOT NULL,
@@ -462,6 +538,7 @@ sender       TEXT NOT NULL,
 unix         INTEGER NOT NULL,
 seq          INTEGER NOT NULL,
 content      TEXT NOT NULL,
+tokens       TEXT NOT NULL,
 message_json TEXT NOT NULL
 );`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_talker ON messages(talker);`,
@@ -472,21 +549,23 @@ talker   TEXT PRIMARY KEY,
 last_seq INTEGER NOT NULL
 );`,
 		`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
-content,
+tokens,
 content='messages',
 content_rowid='rowid',
 tokenize='unicode61 remove_diacritics 2'
 );`,
 		`CREATE TRIGGER IF NOT EXISTS messages_ai AFTER INSERT ON messages BEGIN
-INSERT INTO messages_fts(rowid, content) VALUES (new.rowid, new.content);
+INSERT INTO messages_fts(rowid, tokens) VALUES (new.rowid, new.tokens);
 END;`,
 		`CREATE TRIGGER IF NOT EXISTS messages_ad AFTER DELETE ON messages BEGIN
-INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+INSERT INTO messages_fts(messages_fts, rowid, tokens) VALUES ('delete', old.rowid, old.tokens);
 END;`,
 		`CREATE TRIGGER IF NOT EXISTS messages_au AFTER UPDATE ON messages BEGIN
-INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
-INSERT INTO messages_fts(rowid, content) VALUES (new.rowid, new.content);
+INSERT INTO messages_fts(messages_fts, rowid, tokens) VALUES ('delete', old.rowid, old.tokens);
+INSERT INTO messages_fts(rowid, tokens) VALUES (new.rowid, new.tokens);
 END;`,
+		`INSERT INTO metadata (key, value) VALUES ('schema', '` + schema + `')
+ON CONFLICT(key) DO UPDATE SET value = excluded.value;`,
 	}
 
 	for _, stmt := range statements {
@@ -510,7 +589,7 @@ func (s *storeIndex) indexMessages(messages []*model.Message) error {
 		if msg == nil {
 			continue
 		}
-		doc, err := newDocument(msg)
+		doc, err := newDocument(msg, s.tokenizer)
 		if err != nil {
 			return err
 		}
@@ -541,14 +620,15 @@ func (s *storeIndex) indexMessages(messages []*model.Message) error {
 	}()
 
 	insertStmt, err := tx.Prepare(`
-INSERT INTO messages (doc_id, talker, sender, unix, seq, content, message_json)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO messages (doc_id, talker, sender, unix, seq, content, tokens, message_json)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(doc_id) DO UPDATE SET
 talker = excluded.talker,
 sender = excluded.sender,
 unix = excluded.unix,
 seq = excluded.seq,
 content = excluded.content,
+tokens = excluded.tokens,
 message_json = excluded.message_json
 `)
 	if err != nil {
@@ -557,7 +637,7 @@ message_json = excluded.message_json
 	defer insertStmt.Close()
 
 	for _, doc := range docs {
-		if _, err = insertStmt.Exec(doc.ID, doc.Talker, doc.Sender, doc.Unix, doc.Seq, doc.Content, doc.MessageJSON); err != nil {
+		if _, err = insertStmt.Exec(doc.ID, doc.Talker, doc.Sender, doc.Unix, doc.Seq, doc.Content, doc.Tokens, doc.MessageJSON); err != nil {
 			return fmt.Errorf("insert message %s: %w", doc.ID, err)
 		}
 	}
@@ -585,7 +665,7 @@ ON CONFLICT(talker) DO UPDATE SET last_seq = CASE WHEN excluded.last_seq > last_
 	return nil
 }
 
-func (s *storeIndex) search(match string, talkers []string, senders []string, startUnix, endUnix int64, offset, limit int) ([]*SearchHit, int, error) {
+func (s *storeIndex) search(match string, terms []string, talkers []string, senders []string, startUnix, endUnix int64, offset, limit int) ([]*SearchHit, int, error) {
 	if s == nil {
 		return nil, 0, errIndexNotInitialized
 	}
@@ -636,8 +716,15 @@ WHERE messages_fts MATCH ?
 
 	countQuery := "SELECT COUNT(*) " + baseQuery.String()
 
+	// snippet() works on the indexed column; bigram tokens are unreadable, so
+	// that mode returns the original content and highlights it in Go.
+	snippetExpr := "COALESCE(snippet(messages_fts, 0, '<mark>', '</mark>', '...', 16), '')"
+	if s.tokenizer == TokenizerBigram {
+		snippetExpr = "m.content"
+	}
+
 	dataQuery := "SELECT m.message_json, " +
-		"COALESCE(snippet(messages_fts, 0, '<mark>', '</mark>', '...', 16), '') AS snippet, " +
+		snippetExpr + " AS snippet, " +
 		"COALESCE(bm25(messages_fts), 0.0) AS score " +
 		baseQuery.String() +
 		" ORDER BY score ASC, m.unix DESC, m.seq DESC LIMIT ? OFFSET ?"
@@ -673,9 +760,14 @@ WHERE messages_fts MATCH ?
 			return nil, 0, fmt.Errorf("decode message: %w", err)
 		}
 
+		snippetText := snippet.String
+		if s.tokenizer == TokenizerBigram {
+			snippetText = buildSnippet(snippetText, terms, snippetWindow)
+		}
+
 		hits = append(hits, &SearchHit{
 			Message: &msg,
-			Snippet: snippet.String,
+			Snippet: snippetText,
 			Score:   score.Float64,
 		})
 	}
@@ -687,10 +779,12 @@ WHERE messages_fts MATCH ?
 }
 
 // SearchHit represents a single FTS search hit mapped to the domain model.
+// Range is set for semantic hits, which cover a chunk of the conversation.
 type SearchHit struct {
 	Message *model.Message
 	Snippet string
 	Score   float64
+	Range   *model.SearchHitRange
 }
 
 func loadMetadata(path string) (metadata, error) {
@@ -730,7 +824,7 @@ func (i *Index) saveMetadataLocked() error {
 	return os.Rename(tmp, i.metaPath)
 }
 
-func buildFTSQuery(input string) (string, error) {
+func buildFTSQuery(input string, tokenizer Tokenizer) (string, error) {
 	s := strings.TrimSpace(input)
 	if s == "" {
 		return "", nil
@@ -742,7 +836,7 @@ func buildFTSQuery(input string) (string, error) {
 		strings.Contains(upper, " OR ") ||
 		strings.HasPrefix(upper, "NOT ")
 	if advanced {
-		return s, nil
+		return tokenizer.rewriteAdvanced(s), nil
 	}
 
 	tokens := strings.Fields(s)
@@ -752,12 +846,9 @@ func buildFTSQuery(input string) (string, error) {
 
 	escaped := make([]string, 0, len(tokens))
 	for _, token := range tokens {
-		t := strings.TrimSpace(token)
-		if t == "" {
-			continue
+		if t := tokenizer.matchTerm(token); t != "" {
+			escaped = append(escaped, t)
 		}
-		t = strings.ReplaceAll(t, "\"", "\"\"")
-		escaped = append(escaped, fmt.Sprintf("\"%s\"", t))
 	}
 
 	if len(escaped) == 0 {
@@ -799,10 +890,11 @@ type document struct {
 	Unix        int64
 	Seq         int64
 	Content     string
+	Tokens      string
 	MessageJSON string
 }
 
-func newDocument(msg *model.Message) (*document, error) {
+func newDocument(msg *model.Message, tokenizer Tokenizer) (*document, error) {
 	if msg == nil {
 		return nil, errors.New("nil message")
 	}
@@ -820,6 +912,7 @@ func newDocument(msg *model.Message) (*document, error) {
 		Unix:        msg.Time.Unix(),
 		Seq:         msg.Seq,
 		Content:     content,
+		Tokens:      tokenizer.tokenize(content),
 		MessageJSON: string(messageJSON),
 	}, nil
 }
@@ -0,0 +1,347 @@
+package indexer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer selects how message content is split before it reaches FTS5.
+type Tokenizer string
+
+const (
+	// TokenizerUnicode61 feeds normalized content straight to the unicode61
+	// tokenizer. A run of Han characters ends up as a single token.
+	TokenizerUnicode61 Tokenizer = "unicode61"
+
+	// TokenizerBigram rewrites CJK runs into overlapping bigrams so any
+	// substring of two or more characters can be matched as a phrase.
+	TokenizerBigram Tokenizer = "bigram"
+
+	DefaultTokenizer = TokenizerBigram
+)
+
+// ParseTokenizer maps a configuration value onto a supported tokenizer.
+// Empty values fall back to DefaultTokenizer.
+func ParseTokenizer(value string) (Tokenizer, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return DefaultTokenizer, nil
+	case "unicode61", "unicode", "default":
+		return TokenizerUnicode61, nil
+	case "bigram", "cjk", "ngram":
+		return TokenizerBigram, nil
+	default:
+		return "", fmt.Errorf("unsupported fts tokenizer %q", value)
+	}
+}
+
+// isCJK reports whether r belongs to a script that is written without spaces
+// between words and therefore needs n-gram segmentation.
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// tokenize converts normalized content into the text stored in the FTS column.
+func (t Tokenizer) tokenize(content string) string {
+	if t != TokenizerBigram || content == "" {
+		return content
+	}
+
+	var b strings.Builder
+	b.Grow(len(content) * 3)
+
+	run := make([]rune, 0, 16)
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strings.Join(bigrams(run), " "))
+		// The trailing unigram lets a single-character prefix query find
+		// characters that only ever appear at the end of a run.
+		b.WriteByte(' ')
+		b.WriteRune(run[len(run)-1])
+		b.WriteByte(' ')
+		run = run[:0]
+	}
+
+	for _, r := range content {
+		if isCJK(r) {
+			run = append(run, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func bigrams(run []rune) []string {
+	if len(run) < 2 {
+		return nil
+	}
+	out := make([]string, 0, len(run)-1)
+	for i := 0; i+1 < len(run); i++ {
+		out = append(out, string(run[i:i+2]))
+	}
+	return out
+}
+
+// splitScript breaks a term into alternating CJK and non-CJK segments.
+func splitScript(term string) []string {
+	segments := make([]string, 0, 2)
+	var cur strings.Builder
+	prevCJK := false
+	for i, r := range term {
+		cjk := isCJK(r)
+		if i > 0 && cjk != prevCJK && cur.Len() > 0 {
+			segments = append(segments, cur.String())
+			cur.Reset()
+		}
+		cur.WriteRune(r)
+		prevCJK = cjk
+	}
+	if cur.Len() > 0 {
+		segments = append(segments, cur.String())
+	}
+	return segments
+}
+
+// matchTerm renders a single user-supplied term as an FTS5 expression.
+func (t Tokenizer) matchTerm(term string) string {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return ""
+	}
+	if t != TokenizerBigram {
+		return quoteFTS(term)
+	}
+
+	parts := make([]string, 0, 2)
+	for _, seg := range splitScript(normalizeContent(term)) {
+		seg = strings.TrimSpace(seg)
+		if seg == "" {
+			continue
+		}
+		first, _ := utf8.DecodeRuneInString(seg)
+		if !isCJK(first) {
+			for _, word := range strings.Fields(seg) {
+				parts = append(parts, quoteFTS(word))
+			}
+			continue
+		}
+		runes := []rune(seg)
+		if len(runes) == 1 {
+			parts = append(parts, quoteFTS(seg)+"*")
+			continue
+		}
+		parts = append(parts, quoteFTS(strings.Join(bigrams(runes), " ")))
+	}
+
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		return parts[0]
+	default:
+		return "(" + strings.Join(parts, " AND ") + ")"
+	}
+}
+
+// rewriteAdvanced keeps FTS5 operators intact while expanding quoted strings
+// and bare words that contain CJK characters into bigram phrases.
+func (t Tokenizer) rewriteAdvanced(query string) string {
+	query = mapColumnFilters(query)
+	if t != TokenizerBigram {
+		return query
+	}
+
+	var out strings.Builder
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '"':
+			j := i + 1
+			var phrase strings.Builder
+			for j < len(runes) {
+				if runes[j] == '"' {
+					if j+1 < len(runes) && runes[j+1] == '"' {
+						phrase.WriteRune('"')
+						j += 2
+						continue
+					}
+					break
+				}
+				phrase.WriteRune(runes[j])
+				j++
+			}
+			out.WriteString(t.matchTerm(phrase.String()))
+			i = j + 1
+		case unicode.IsSpace(r) || strings.ContainsRune("()*", r):
+			out.WriteRune(r)
+			i++
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()*\"", runes[j]) {
+				j++
+			}
+			word := string(runes[i:j])
+			if strings.HasPrefix(word, ftsColumn+":") {
+				out.WriteString(ftsColumn + ":")
+				word = strings.TrimPrefix(word, ftsColumn+":")
+			}
+			switch strings.ToUpper(word) {
+			case "AND", "OR", "NOT", "NEAR":
+				out.WriteString(strings.ToUpper(word))
+			default:
+				out.WriteString(t.matchTerm(word))
+			}
+			i = j
+		}
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// ftsColumn is the only indexed column of messages_fts.
+const ftsColumn = "tokens"
+
+// mapColumnFilters rewrites "content:" column filters, which targeted the
+// indexed column before it was renamed, to the current column name.
+// Quoted strings are left untouched.
+func mapColumnFilters(query string) string {
+	var out strings.Builder
+	quoted := false
+	wordStart := true
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		if r == '"' {
+			quoted = !quoted
+		}
+		if !quoted && wordStart {
+			for _, col := range []string{"content", ftsColumn} {
+				rest := query[i:]
+				if len(rest) <= len(col) || !strings.EqualFold(rest[:len(col)], col) {
+					continue
+				}
+				after := strings.TrimLeft(rest[len(col):], " \t")
+				if strings.HasPrefix(after, ":") {
+					out.WriteString(ftsColumn + ":")
+					i = len(query) - len(after) + 1
+					size = 0
+				}
+				break
+			}
+			if size == 0 {
+				wordStart = true
+				continue
+			}
+		}
+		out.WriteRune(r)
+		wordStart = !quoted && (unicode.IsSpace(r) || strings.ContainsRune("(-", r))
+		i += size
+	}
+	return out.String()
+}
+
+func quoteFTS(s string) string {
+	return "\"" + strings.ReplaceAll(s, "\"", "\"\"") + "\""
+}
+
+// queryTerms extracts the literal fragments of a query for snippet highlighting.
+func queryTerms(query string) []string {
+	fields := strings.FieldsFunc(query, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("\"'*()", r)
+	})
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		switch strings.ToUpper(f) {
+		case "AND", "OR", "NOT", "NEAR":
+			continue
+		}
+		if norm := strings.TrimSpace(normalizeContent(f)); norm != "" {
+			terms = append(terms, strings.Fields(norm)...)
+		}
+	}
+	return dedupeStrings(terms)
+}
+
+// buildSnippet mimics FTS5 snippet() for tokenizers whose indexed text is
+// not suitable for display. It highlights every term occurrence inside a
+// window around the first hit.
+func buildSnippet(content string, terms []string, window int) string {
+	if content == "" {
+		return ""
+	}
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+
+	highlighted := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		tr := []rune(strings.ToLower(term))
+		if len(tr) == 0 {
+			continue
+		}
+		for i := 0; i+len(tr) <= len(lower); i++ {
+			if string(lower[i:i+len(tr)]) != string(tr) {
+				continue
+			}
+			for k := i; k < i+len(tr); k++ {
+				highlighted[k] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+			i += len(tr) - 1
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	from := first - window/3
+	if from < 0 {
+		from = 0
+	}
+	to := from + window
+	if to > len(runes) {
+		to = len(runes)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("...")
+	}
+	open := false
+	for i := from; i < to; i++ {
+		if highlighted[i] != open {
+			if open {
+				b.WriteString("</mark>")
+			} else {
+				b.WriteString("<mark>")
+			}
+			open = highlighted[i]
+		}
+		b.WriteRune(runes[i])
+	}
+	if open {
+		b.WriteString("</mark>")
+	}
+	if to < len(runes) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
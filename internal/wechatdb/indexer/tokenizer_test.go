@@ -0,0 +1,92 @@
+package indexer
+
+import "testing"
+
+func TestBigramTokenize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "empty", input: "", want: ""},
+		{name: "ascii only", input: "release plan", want: "release plan"},
+		{name: "single han", input: "好", want: "好"},
+		{name: "han run", input: "发布日期", want: "发布 布日 日期 期"},
+		{name: "mixed", input: "明天 release 发布", want: "明天 天 release 发布 布"},
+		{name: "punctuation splits runs", input: "好的，收到", want: "好的 的 ， 收到 到"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TokenizerBigram.tokenize(tt.input); got != tt.want {
+				t.Errorf("tokenize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	if got := TokenizerUnicode61.tokenize("发布日期"); got != "发布日期" {
+		t.Errorf("unicode61 tokenize changed content: %q", got)
+	}
+}
+
+func TestBuildFTSQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		tokenizer Tokenizer
+		input     string
+		want      string
+	}{
+		{name: "unicode61 keeps terms", tokenizer: TokenizerUnicode61, input: "发布日期 plan", want: `"发布日期" AND "plan"`},
+		{name: "bigram phrase", tokenizer: TokenizerBigram, input: "发布日期", want: `"发布 布日 日期"`},
+		{name: "bigram single char prefix", tokenizer: TokenizerBigram, input: "好", want: `"好"*`},
+		{name: "bigram mixed term", tokenizer: TokenizerBigram, input: "v2发布", want: `("v2" AND "发布")`},
+		{name: "bigram advanced", tokenizer: TokenizerBigram, input: `"发布日期" OR release`, want: `"发布 布日 日期" OR "release"`},
+		{name: "blank", tokenizer: TokenizerBigram, input: "   ", want: ""},
+		{name: "unicode61 content filter", tokenizer: TokenizerUnicode61, input: `content:plan OR "content: x"`, want: `tokens:plan OR "content: x"`},
+		{name: "bigram content filter", tokenizer: TokenizerBigram, input: `(content:发布日期 OR Content : "上线")`, want: `(tokens:"发布 布日 日期" OR tokens: "上线")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildFTSQuery(tt.input, tt.tokenizer)
+			if err != nil {
+				t.Fatalf("buildFTSQuery(%q) error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("buildFTSQuery(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTokenizer(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		meta  metadata
+		want  Tokenizer
+	}{
+		{name: "new index", want: DefaultTokenizer},
+		{name: "legacy index rebuilt", meta: metadata{Version: "3"}, want: DefaultTokenizer},
+		{name: "existing bigram", meta: metadata{Version: runtimeIndexVersion, Tokenizer: TokenizerBigram}, want: TokenizerBigram},
+		{name: "existing unicode61", meta: metadata{Version: runtimeIndexVersion, Tokenizer: TokenizerUnicode61}, want: TokenizerUnicode61},
+		{name: "explicit wins", value: "unicode61", meta: metadata{Version: "3"}, want: TokenizerUnicode61},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveTokenizer(tt.value, tt.meta)
+			if err != nil || got != tt.want {
+				t.Errorf("resolveTokenizer(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSnippet(t *testing.T) {
+	got := buildSnippet("我们讨论一下发布日期", []string{"发布"}, 48)
+	want := "我们讨论一下<mark>发布</mark>日期"
+	if got != want {
+		t.Errorf("buildSnippet = %q, want %q", got, want)
+	}
+}
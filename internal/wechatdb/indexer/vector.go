@@ -0,0 +1,763 @@
+package indexer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/embedding"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+const (
+	// 同一会话中相邻消息间隔超过 chunkGap 即视为新一段对话
+	chunkGap         = 30 * time.Minute
+	chunkMaxMessages = 24
+	chunkMaxRunes    = 1200
+	chunkLineRunes   = 300
+
+	// chunkScanLimit bounds how many indexed messages are read per talker
+	// per pass; the last (possibly unfinished) chunk of a full page is
+	// held back until the next pass.
+	chunkScanLimit = 2000
+
+	// rrfK is the rank constant of reciprocal rank fusion.
+	rrfK = 60
+
+	// HybridWindow is how many fused hits a hybrid search can page
+	// through; each ranking contributes at most this many candidates.
+	HybridWindow = 1000
+)
+
+// ErrSemanticDisabled is returned when semantic search is requested without an embedder.
+var ErrSemanticDisabled = errors.New("semantic search is not enabled")
+
+// ErrOutsideHybridWindow is returned when a hybrid search offset lies beyond HybridWindow.
+var ErrOutsideHybridWindow = errors.New("offset is outside the hybrid search window")
+
+// vectorStore keeps conversation chunks and their embeddings next to a
+// store's FTS database. It survives Reset so that a keyword reindex does
+// not pay for re-embedding the whole history.
+type vectorStore struct {
+	db   *sql.DB
+	path string
+}
+
+// VectorStatus summarises the embedding backlog across all stores.
+type VectorStatus struct {
+	Model   string
+	Chunks  int
+	Pending int
+}
+
+type chunk struct {
+	talker    string
+	startUnix int64
+	endUnix   int64
+	firstSeq  int64
+	lastSeq   int64
+	senders   map[string]struct{}
+	text      strings.Builder
+	messages  int
+}
+
+func vectorPathFor(ftsPath string) string {
+	if strings.HasSuffix(ftsPath, ".fts.db") {
+		return strings.TrimSuffix(ftsPath, ".fts.db") + ".vec.db"
+	}
+	return ftsPath + ".vec"
+}
+
+func openVectorStore(path, modelName string) (*vectorStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create vector store dir: %w", err)
+	}
+
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=5000&_journal=WAL&_synchronous=NORMAL", filepath.ToSlash(path))
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open vector store: %w", err)
+	}
+	if err := initVectorSchema(db, modelName); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &vectorStore{db: db, path: path}, nil
+}
+
+func initVectorSchema(db *sql.DB, modelName string) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS metadata (
+key   TEXT PRIMARY KEY,
+value TEXT NOT NULL
+);`); err != nil {
+		return fmt.Errorf("init vector metadata: %w", err)
+	}
+
+	// 向量只能与同一模型产生的向量比较，模型变化时全部重新切片与嵌入
+	var current string
+	err := db.QueryRow(`SELECT value FROM metadata WHERE key = 'model'`).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("read vector metadata: %w", err)
+	}
+	if current != modelName {
+		for _, stmt := range []string{`DROP TABLE IF EXISTS chunks;`, `DROP TABLE IF EXISTS checkpoints;`} {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("drop stale vectors: %w", err)
+			}
+		}
+	}
+
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS chunks (
+id         INTEGER PRIMARY KEY,
+talker     TEXT NOT NULL,
+start_unix INTEGER NOT NULL,
+end_unix   INTEGER NOT NULL,
+first_seq  INTEGER NOT NULL,
+last_seq   INTEGER NOT NULL,
+senders    TEXT NOT NULL,
+text       TEXT NOT NULL,
+vector     BLOB
+);`,
+		`CREATE INDEX IF NOT EXISTS idx_chunks_talker ON chunks(talker, first_seq);`,
+		`CREATE INDEX IF NOT EXISTS idx_chunks_pending ON chunks(id) WHERE vector IS NULL;`,
+		`CREATE TABLE IF NOT EXISTS checkpoints (
+talker   TEXT PRIMARY KEY,
+last_seq INTEGER NOT NULL
+);`,
+		`INSERT INTO metadata (key, value) VALUES ('model', ?)
+ON CONFLICT(key) DO UPDATE SET value = excluded.value;`,
+	}
+	for i, stmt := range statements {
+		var err error
+		if i == len(statements)-1 {
+			_, err = db.Exec(stmt, modelName)
+		} else {
+			_, err = db.Exec(stmt)
+		}
+		if err != nil {
+			return fmt.Errorf("init vector schema: %w", err)
+		}
+	}
+	return nil
+}
+
+func (v *vectorStore) close() error {
+	if v == nil || v.db == nil {
+		return nil
+	}
+	err := v.db.Close()
+	v.db = nil
+	return err
+}
+
+// EmbedPending chunks newly indexed messages and embeds up to batchSize
+// chunks per store. It returns how many chunks were embedded so callers can
+// loop until the backlog is drained.
+func (i *Index) EmbedPending(ctx context.Context, batchSize int) (int, error) {
+	if i == nil || i.embedder == nil {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = 32
+	}
+
+	embedded := 0
+	for _, si := range i.snapshotStores() {
+		if err := ctx.Err(); err != nil {
+			return embedded, err
+		}
+		if err := si.chunkPending(ctx); err != nil {
+			return embedded, err
+		}
+		n, err := si.embedPending(ctx, i.embedder, batchSize)
+		embedded += n
+		if err != nil {
+			return embedded, err
+		}
+	}
+	return embedded, nil
+}
+
+// VectorStatus reports chunk counts for the status endpoint.
+func (i *Index) VectorStatus() *VectorStatus {
+	if i == nil || i.embedder == nil {
+		return nil
+	}
+
+	status := &VectorStatus{Model: i.embedder.ModelName()}
+	for _, si := range i.snapshotStores() {
+		si.mu.RLock()
+		if si.vectors != nil && si.vectors.db != nil {
+			var total, pending int
+			if err := si.vectors.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(vector IS NULL), 0) FROM chunks`).Scan(&total, &pending); err == nil {
+				status.Chunks += total
+				status.Pending += pending
+			}
+		}
+		si.mu.RUnlock()
+	}
+	return status
+}
+
+// SemanticSearch ranks conversation chunks by cosine similarity to query.
+// Score is the negated similarity so that, like bm25, smaller is better.
+func (i *Index) SemanticSearch(ctx context.Context, query string, talkers []string, senders []string, startUnix, endUnix int64, offset, limit int) ([]*SearchHit, int, error) {
+	if i == nil || i.embedder == nil {
+		return nil, 0, ErrSemanticDisabled
+	}
+	limit, offset = clampPage(limit, offset)
+	return i.vectorSearch(ctx, query, talkers, senders, startUnix, endUnix, offset, limit)
+}
+
+// vectorSearch is SemanticSearch without the page size cap.
+func (i *Index) vectorSearch(ctx context.Context, query string, talkers []string, senders []string, startUnix, endUnix int64, offset, limit int) ([]*SearchHit, int, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []*SearchHit{}, 0, nil
+	}
+
+	vectors, err := i.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(vectors) != 1 || len(vectors[0]) == 0 {
+		return nil, 0, errors.New("embedder returned empty query vector")
+	}
+	qv := vectors[0]
+
+	talkers = dedupeStrings(talkers)
+	senders = dedupeStrings(senders)
+
+	combined := make([]*SearchHit, 0)
+	total := 0
+	for _, si := range i.snapshotStores() {
+		hits, count, err := si.semanticSearch(ctx, qv, talkers, senders, startUnix, endUnix, offset+limit)
+		if err != nil {
+			return nil, 0, err
+		}
+		total += count
+		combined = append(combined, hits...)
+	}
+
+	sortHits(combined)
+	return pageHits(combined, offset, limit), total, nil
+}
+
+// HybridSearch merges BM25 and vector rankings with reciprocal rank fusion.
+// A keyword hit that falls inside a semantic chunk is folded into that
+// chunk, keeping the keyword message and snippet as the representative.
+// The fused ranking only covers the first HybridWindow hits: offsets past
+// it fail with ErrOutsideHybridWindow and pages crossing it are cut short.
+// The returned totals are the keyword and semantic match counts.
+func (i *Index) HybridSearch(ctx context.Context, req *model.SearchRequest, talkers []string, senders []string, startUnix, endUnix int64, offset, limit int) ([]*SearchHit, int, int, error) {
+	if req == nil {
+		return nil, 0, 0, errors.New("search request is nil")
+	}
+	if i == nil || i.embedder == nil {
+		return nil, 0, 0, ErrSemanticDisabled
+	}
+	limit, offset = clampPage(limit, offset)
+	if offset >= HybridWindow {
+		return nil, 0, 0, ErrOutsideHybridWindow
+	}
+	if offset+limit > HybridWindow {
+		limit = HybridWindow - offset
+	}
+
+	candidates := (offset + limit) * 2
+	if candidates < 50 {
+		candidates = 50
+	}
+	if candidates > HybridWindow {
+		candidates = HybridWindow
+	}
+
+	keywordHits, keywordTotal, err := i.keywordSearch(req, talkers, senders, startUnix, endUnix, 0, candidates)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	semanticHits, semanticTotal, err := i.vectorSearch(ctx, req.Query, talkers, senders, startUnix, endUnix, 0, candidates)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	fused := make([]*SearchHit, 0, len(keywordHits)+len(semanticHits))
+	for rank, hit := range semanticHits {
+		hit.Score = 1 / float64(rrfK+rank+1)
+		fused = append(fused, hit)
+	}
+	for rank, hit := range keywordHits {
+		score := 1 / float64(rrfK+rank+1)
+		if owner := findChunk(semanticHits, hit.Message); owner != nil {
+			owner.Score += score
+			owner.Message = hit.Message
+			owner.Snippet = hit.Snippet
+			continue
+		}
+		hit.Score = score
+		fused = append(fused, hit)
+	}
+	for _, hit := range fused {
+		hit.Score = -hit.Score
+	}
+
+	sortHits(fused)
+	return pageHits(fused, offset, limit), keywordTotal, semanticTotal, nil
+}
+
+func findChunk(chunks []*SearchHit, msg *model.Message) *SearchHit {
+	if msg == nil {
+		return nil
+	}
+	for _, c := range chunks {
+		if c.Range == nil || c.Message == nil || c.Message.Talker != msg.Talker {
+			continue
+		}
+		if msg.Seq >= c.Range.StartSeq && msg.Seq <= c.Range.EndSeq {
+			return c
+		}
+	}
+	return nil
+}
+
+func (i *Index) snapshotStores() []*storeIndex {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	stores := make([]*storeIndex, 0, len(i.stores))
+	for _, si := range i.stores {
+		stores = append(stores, si)
+	}
+	return stores
+}
+
+// chunkPending groups messages indexed since the last pass into conversation
+// chunks. Only the FTS checkpoints are read under the store lock; chunking
+// itself runs without it so that searches and incremental indexing are not
+// blocked while a large backlog is processed.
+func (s *storeIndex) chunkPending(ctx context.Context) error {
+	s.mu.RLock()
+	db, vectors := s.db, s.vectors
+	if db == nil || vectors == nil || vectors.db == nil {
+		s.mu.RUnlock()
+		return nil
+	}
+	progress, err := readCheckpoints(ctx, db)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	for talker, indexed := range progress {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var chunked int64
+		err := vectors.db.QueryRowContext(ctx, `SELECT last_seq FROM checkpoints WHERE talker = ?`, talker).Scan(&chunked)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		for chunked < indexed {
+			next, err := chunkTalker(ctx, db, vectors, talker, chunked)
+			if err != nil {
+				return err
+			}
+			if next <= chunked {
+				break
+			}
+			chunked = next
+		}
+	}
+	return nil
+}
+
+func readCheckpoints(ctx context.Context, db *sql.DB) (map[string]int64, error) {
+	rows, err := db.QueryContext(ctx, `SELECT talker, last_seq FROM checkpoints`)
+	if err != nil {
+		return nil, fmt.Errorf("read fts checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	progress := make(map[string]int64)
+	for rows.Next() {
+		var talker string
+		var seq int64
+		if err := rows.Scan(&talker, &seq); err != nil {
+			return nil, err
+		}
+		progress[talker] = seq
+	}
+	return progress, rows.Err()
+}
+
+// chunkTalker reads one page of messages after afterSeq and stores the
+// resulting chunks. It returns the new checkpoint.
+func chunkTalker(ctx context.Context, db *sql.DB, vectors *vectorStore, talker string, afterSeq int64) (int64, error) {
+	rows, err := db.QueryContext(ctx, `SELECT seq, unix, message_json FROM messages WHERE talker = ? AND seq > ? ORDER BY seq ASC LIMIT ?`, talker, afterSeq, chunkScanLimit)
+	if err != nil {
+		return afterSeq, fmt.Errorf("read messages for chunking: %w", err)
+	}
+
+	chunks := make([]*chunk, 0)
+	var cur *chunk
+	read := 0
+	for rows.Next() {
+		var seq, unix int64
+		var messageJSON string
+		if err := rows.Scan(&seq, &unix, &messageJSON); err != nil {
+			rows.Close()
+			return afterSeq, err
+		}
+		read++
+
+		var msg model.Message
+		if err := json.Unmarshal([]byte(messageJSON), &msg); err != nil {
+			rows.Close()
+			return afterSeq, fmt.Errorf("decode message: %w", err)
+		}
+		line := chunkLine(&msg)
+
+		if cur == nil || cur.full(unix, line) {
+			cur = &chunk{talker: talker, startUnix: unix, firstSeq: seq, senders: make(map[string]struct{})}
+			chunks = append(chunks, cur)
+		}
+		cur.add(seq, unix, msg.Sender, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return afterSeq, err
+	}
+
+	// 整页读满时最后一段可能尚未结束，留到下一页再切
+	if read == chunkScanLimit && len(chunks) > 1 {
+		chunks = chunks[:len(chunks)-1]
+	}
+	if len(chunks) == 0 {
+		return afterSeq, nil
+	}
+
+	tx, err := vectors.db.BeginTx(ctx, nil)
+	if err != nil {
+		return afterSeq, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, c := range chunks {
+		if c.text.Len() == 0 {
+			continue
+		}
+		if _, err = tx.Exec(`INSERT INTO chunks (talker, start_unix, end_unix, first_seq, last_seq, senders, text) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			c.talker, c.startUnix, c.endUnix, c.firstSeq, c.lastSeq, c.sendersField(), c.text.String()); err != nil {
+			return afterSeq, fmt.Errorf("insert chunk: %w", err)
+		}
+	}
+
+	checkpoint := chunks[len(chunks)-1].lastSeq
+	if _, err = tx.Exec(`INSERT INTO checkpoints (talker, last_seq) VALUES (?, ?)
+ON CONFLICT(talker) DO UPDATE SET last_seq = excluded.last_seq`, talker, checkpoint); err != nil {
+		return afterSeq, err
+	}
+	if err = tx.Commit(); err != nil {
+		return afterSeq, err
+	}
+	return checkpoint, nil
+}
+
+func chunkLine(msg *model.Message) string {
+	text := strings.TrimSpace(msg.PlainTextContent())
+	if text == "" {
+		return ""
+	}
+	if runes := []rune(text); len(runes) > chunkLineRunes {
+		text = string(runes[:chunkLineRunes]) + "..."
+	}
+	name := msg.SenderName
+	if name == "" {
+		name = msg.Sender
+	}
+	if msg.IsSelf {
+		name = "我"
+	}
+	if name == "" {
+		return text
+	}
+	return name + ": " + text
+}
+
+func (c *chunk) full(unix int64, line string) bool {
+	if c.messages >= chunkMaxMessages {
+		return true
+	}
+	if unix-c.endUnix > int64(chunkGap/time.Second) {
+		return true
+	}
+	return c.text.Len() > 0 && len([]rune(c.text.String()))+len([]rune(line)) > chunkMaxRunes
+}
+
+func (c *chunk) add(seq, unix int64, sender, line string) {
+	c.lastSeq = seq
+	c.endUnix = unix
+	c.messages++
+	if sender != "" {
+		c.senders[sender] = struct{}{}
+	}
+	if line == "" {
+		return
+	}
+	if c.text.Len() > 0 {
+		c.text.WriteByte('\n')
+	}
+	c.text.WriteString(line)
+}
+
+// sendersField stores senders as ",a,b," so a LIKE filter can match whole ids.
+func (c *chunk) sendersField() string {
+	senders := make([]string, 0, len(c.senders))
+	for s := range c.senders {
+		senders = append(senders, s)
+	}
+	sort.Strings(senders)
+	return "," + strings.Join(senders, ",") + ","
+}
+
+func (s *storeIndex) embedPending(ctx context.Context, embedder embedding.Embedder, batchSize int) (int, error) {
+	s.mu.RLock()
+	vectors := s.vectors
+	s.mu.RUnlock()
+	if vectors == nil || vectors.db == nil {
+		return 0, nil
+	}
+
+	rows, err := vectors.db.QueryContext(ctx, `SELECT id, text FROM chunks WHERE vector IS NULL ORDER BY id LIMIT ?`, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("read pending chunks: %w", err)
+	}
+	ids := make([]int64, 0, batchSize)
+	texts := make([]string, 0, batchSize)
+	for rows.Next() {
+		var id int64
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		texts = append(texts, text)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	embeddings, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return 0, err
+	}
+	if len(embeddings) != len(ids) {
+		return 0, fmt.Errorf("embedder returned %d vectors for %d chunks", len(embeddings), len(ids))
+	}
+
+	tx, err := vectors.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	for idx, id := range ids {
+		if _, err := tx.Exec(`UPDATE chunks SET vector = ? WHERE id = ?`, encodeVector(embeddings[idx]), id); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func (s *storeIndex) semanticSearch(ctx context.Context, qv []float32, talkers []string, senders []string, startUnix, endUnix int64, limit int) ([]*SearchHit, int, error) {
+	s.mu.RLock()
+	db := s.db
+	vectors := s.vectors
+	s.mu.RUnlock()
+	if db == nil || vectors == nil || vectors.db == nil {
+		return nil, 0, nil
+	}
+
+	whereClauses := []string{"vector IS NOT NULL"}
+	args := []interface{}{}
+	if len(talkers) > 0 {
+		whereClauses = append(whereClauses, fmt.Sprintf("talker IN (%s)", strings.TrimSuffix(strings.Repeat("?,", len(talkers)), ",")))
+		for _, t := range talkers {
+			args = append(args, t)
+		}
+	}
+	if len(senders) > 0 {
+		likes := make([]string, 0, len(senders))
+		for _, sender := range senders {
+			likes = append(likes, "senders LIKE ?")
+			args = append(args, "%,"+sender+",%")
+		}
+		whereClauses = append(whereClauses, "("+strings.Join(likes, " OR ")+")")
+	}
+	if startUnix > 0 {
+		whereClauses = append(whereClauses, "end_unix >= ?")
+		args = append(args, startUnix)
+	}
+	if endUnix > 0 {
+		whereClauses = append(whereClauses, "start_unix <= ?")
+		args = append(args, endUnix)
+	}
+
+	rows, err := vectors.db.QueryContext(ctx, `SELECT talker, start_unix, end_unix, first_seq, last_seq, text, vector FROM chunks WHERE `+strings.Join(whereClauses, " AND "), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("execute semantic query: %w", err)
+	}
+	defer rows.Close()
+
+	type scored struct {
+		talker     string
+		start, end int64
+		first      int64
+		last       int64
+		text       string
+		similarity float32
+	}
+	candidates := make([]scored, 0)
+	for rows.Next() {
+		var c scored
+		var blob []byte
+		if err := rows.Scan(&c.talker, &c.start, &c.end, &c.first, &c.last, &c.text, &blob); err != nil {
+			return nil, 0, fmt.Errorf("scan chunk: %w", err)
+		}
+		c.similarity = embedding.Dot(qv, decodeVector(blob))
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate chunks: %w", err)
+	}
+
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].similarity > candidates[b].similarity })
+	total := len(candidates)
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	hits := make([]*SearchHit, 0, len(candidates))
+	for _, c := range candidates {
+		msg, err := loadMessage(ctx, db, c.talker, c.first)
+		if err != nil {
+			return nil, 0, err
+		}
+		if msg == nil {
+			continue
+		}
+		hits = append(hits, &SearchHit{
+			Message: msg,
+			Snippet: truncateRunes(c.text, snippetWindow*4),
+			Score:   -float64(c.similarity),
+			Range: &model.SearchHitRange{
+				StartSeq: c.first,
+				EndSeq:   c.last,
+				Start:    time.Unix(c.start, 0),
+				End:      time.Unix(c.end, 0),
+			},
+		})
+	}
+	return hits, total, nil
+}
+
+func loadMessage(ctx context.Context, db *sql.DB, talker string, seq int64) (*model.Message, error) {
+	var messageJSON string
+	err := db.QueryRowContext(ctx, `SELECT message_json FROM messages WHERE talker = ? AND seq = ?`, talker, seq).Scan(&messageJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load chunk message: %w", err)
+	}
+	var msg model.Message
+	if err := json.Unmarshal([]byte(messageJSON), &msg); err != nil {
+		return nil, fmt.Errorf("decode message: %w", err)
+	}
+	return &msg, nil
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return v
+}
+
+func clampPage(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+func sortHits(hits []*SearchHit) {
+	sort.Slice(hits, func(a, b int) bool {
+		ha := hits[a]
+		hb := hits[b]
+		if ha.Score != hb.Score {
+			return ha.Score < hb.Score
+		}
+		ta := ha.Message.Time.Unix()
+		tb := hb.Message.Time.Unix()
+		if ta != tb {
+			return ta > tb
+		}
+		return ha.Message.Seq > hb.Message.Seq
+	})
+}
+
+func pageHits(hits []*SearchHit, offset, limit int) []*SearchHit {
+	if offset >= len(hits) {
+		return []*SearchHit{}
+	}
+	end := offset + limit
+	if end > len(hits) {
+		end = len(hits)
+	}
+	return hits[offset:end]
+}
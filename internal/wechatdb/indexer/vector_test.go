@@ -0,0 +1,210 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/embedding"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/msgstore"
+)
+
+// stubEmbedder maps text onto a tiny fixed vocabulary so similarities are predictable.
+type stubEmbedder struct{}
+
+var stubVocabulary = []string{"猫", "天气", "会议"}
+
+func (e *stubEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, len(stubVocabulary)+1)
+		v[len(stubVocabulary)] = 0.01
+		for d, word := range stubVocabulary {
+			v[d] = float32(strings.Count(text, word))
+		}
+		out[i] = embedding.Normalize(v)
+	}
+	return out, nil
+}
+
+func (e *stubEmbedder) ModelName() string { return "stub" }
+
+func (e *stubEmbedder) Close() {}
+
+var vectorBase = time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+
+func textMessage(talker string, seq int64, at time.Duration, content string) *model.Message {
+	return &model.Message{
+		Seq:     seq,
+		Time:    vectorBase.Add(at),
+		Talker:  talker,
+		Sender:  talker,
+		Type:    model.MessageTypeText,
+		Content: content,
+	}
+}
+
+func openTestIndex(t *testing.T) (*Index, *msgstore.Store) {
+	t.Helper()
+	idx, err := Open(t.TempDir(), Options{Tokenizer: "bigram", Embedder: &stubEmbedder{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = idx.Close() })
+	return idx, &msgstore.Store{ID: "message_0"}
+}
+
+func drain(t *testing.T, idx *Index) {
+	t.Helper()
+	for {
+		n, err := idx.EmbedPending(context.Background(), 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			return
+		}
+	}
+}
+
+func TestChunkBoundaries(t *testing.T) {
+	idx, store := openTestIndex(t)
+
+	msgs := []*model.Message{
+		textMessage("alice", 1, 0, "早"),
+		textMessage("alice", 2, time.Minute, "早上好"),
+		textMessage("alice", 3, 2*time.Minute, "吃了吗"),
+	}
+	// 间隔超过 chunkGap 开始新的一段，之后连续 30 条按 chunkMaxMessages 切分
+	for seq := int64(4); seq <= 33; seq++ {
+		msgs = append(msgs, textMessage("alice", seq, 3*time.Hour+time.Duration(seq)*time.Minute, "继续聊"))
+	}
+	if err := idx.IndexStoreMessages(store, msgs); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, idx)
+
+	rows, err := idx.stores[store.ID].vectors.db.Query(`SELECT first_seq, last_seq FROM chunks ORDER BY first_seq`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var first, last int64
+		if err := rows.Scan(&first, &last); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%d-%d", first, last))
+	}
+	if want := "1-3,4-27,28-33"; strings.Join(got, ",") != want {
+		t.Fatalf("chunks = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	if status := idx.VectorStatus(); status.Chunks != 3 || status.Pending != 0 {
+		t.Fatalf("status = %+v", status)
+	}
+}
+
+func TestChunkFullByRunes(t *testing.T) {
+	c := &chunk{senders: make(map[string]struct{})}
+	c.add(1, 0, "a", strings.Repeat("字", 1000))
+	if c.full(0, strings.Repeat("字", 100)) {
+		t.Fatal("chunk should accept a line within the rune budget")
+	}
+	if !c.full(0, strings.Repeat("字", 300)) {
+		t.Fatal("chunk should close before exceeding chunkMaxRunes")
+	}
+}
+
+func TestSemanticSearch(t *testing.T) {
+	idx, store := openTestIndex(t)
+	msgs := []*model.Message{
+		textMessage("cat", 1, 0, "猫在睡觉"),
+		textMessage("cat", 2, time.Minute, "猫又醒了"),
+		textMessage("weather", 1, 0, "明天天气怎么样"),
+	}
+	if err := idx.IndexStoreMessages(store, msgs); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, idx)
+
+	hits, total, err := idx.SemanticSearch(context.Background(), "天气", nil, nil, 0, 0, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(hits) != 2 || hits[0].Message.Talker != "weather" {
+		t.Fatalf("hits = %d/%d first = %+v", len(hits), total, hits[0].Message)
+	}
+	if r := hits[1].Range; r == nil || r.StartSeq != 1 || r.EndSeq != 2 {
+		t.Fatalf("range = %+v", hits[1].Range)
+	}
+
+	hits, _, err = idx.SemanticSearch(context.Background(), "天气", []string{"cat"}, nil, 0, 0, 0, 10)
+	if err != nil || len(hits) != 1 || hits[0].Message.Talker != "cat" {
+		t.Fatalf("talker filter = %v, %v", hits, err)
+	}
+}
+
+func TestHybridSearchRRF(t *testing.T) {
+	idx, store := openTestIndex(t)
+	msgs := []*model.Message{
+		textMessage("a", 1, 0, "猫在睡觉"),
+		textMessage("b", 1, 0, "天气很好"),
+		textMessage("c", 1, 0, "猫和天气以及其他很多很多很多的内容"),
+	}
+	if err := idx.IndexStoreMessages(store, msgs); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, idx)
+
+	// 语义排序 b > c > a，关键词只命中 b 与 c（b 更短，bm25 更靠前），
+	// 融合后 b、c 各得两份分数，a 只有语义的一份
+	hits, keywordTotal, semanticTotal, err := idx.HybridSearch(context.Background(), &model.SearchRequest{Query: "天气"}, nil, nil, 0, 0, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, h := range hits {
+		order = append(order, h.Message.Talker)
+	}
+	if strings.Join(order, ",") != "b,c,a" || keywordTotal != 2 || semanticTotal != 3 {
+		t.Fatalf("order = %v (totals %d/%d)", order, keywordTotal, semanticTotal)
+	}
+	if want := -2.0 / float64(rrfK+1); hits[0].Score != want {
+		t.Fatalf("score = %v, want %v", hits[0].Score, want)
+	}
+	if !strings.Contains(hits[0].Snippet, "<mark>") || hits[0].Range == nil {
+		t.Fatalf("fused hit should keep keyword snippet and chunk range: %+v", hits[0])
+	}
+}
+
+func TestHybridSearchWindow(t *testing.T) {
+	idx, store := openTestIndex(t)
+	msgs := make([]*model.Message, 0, 300)
+	for n := 0; n < 300; n++ {
+		msgs = append(msgs, textMessage(fmt.Sprintf("t%03d", n), 1, 0, "天气"))
+	}
+	if err := idx.IndexStoreMessages(store, msgs); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, idx)
+
+	// 候选数超过单页上限 200 时仍能翻到后面的结果
+	req := &model.SearchRequest{Query: "天气"}
+	hits, keywordTotal, semanticTotal, err := idx.HybridSearch(context.Background(), req, nil, nil, 0, 0, 250, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 20 || keywordTotal != 300 || semanticTotal != 300 {
+		t.Fatalf("hits = %d (totals %d/%d)", len(hits), keywordTotal, semanticTotal)
+	}
+
+	if _, _, _, err := idx.HybridSearch(context.Background(), req, nil, nil, 0, 0, HybridWindow, 20); !errors.Is(err, ErrOutsideHybridWindow) {
+		t.Fatalf("err = %v, want ErrOutsideHybridWindow", err)
+	}
+}
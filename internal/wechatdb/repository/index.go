@@ -13,6 +13,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 
+	errs "github.com/takeaway1/chatlog-TCOTC/internal/errors"
 	"github.com/takeaway1/chatlog-TCOTC/internal/model"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/indexer"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/msgstore"
@@ -29,13 +30,18 @@ func (r *Repository) initIndex() error {
 		return nil
 	}
 
-	idx, err := indexer.Open(r.indexPath)
+	idx, err := indexer.Open(r.indexPath, r.indexOpts)
 	if err != nil {
 		return err
 	}
 
 	r.index = idx
+	r.indexStatus.Tokenizer = string(idx.Tokenizer())
 	r.indexCtx, r.indexCancel = context.WithCancel(context.Background())
+	if r.indexOpts.Embedder != nil {
+		r.embedWake = make(chan struct{}, 1)
+		go r.runEmbedding(r.indexCtx, idx)
+	}
 
 	go func() {
 		ready, err := r.ensureIndex(r.indexCtx)
@@ -45,6 +51,7 @@ func (r *Repository) initIndex() error {
 		}
 		if ready {
 			log.Info().Msg("fts index ready")
+			r.wakeEmbedding()
 		}
 	}()
 
@@ -66,6 +73,7 @@ func (r *Repository) ensureIndex(ctx context.Context) (bool, error) {
 	}
 
 	if !versionMatched {
+		log.Info().Str("tokenizer", string(r.index.Tokenizer())).Msg("fts index layout changed, rebuilding full index")
 		r.indexMu.Lock()
 		r.indexStatus.Ready = false
 		r.indexStatus.Progress = 0
@@ -125,6 +133,7 @@ func (r *Repository) ensureIndex(ctx context.Context) (bool, error) {
 	r.indexStatus.LastCompletedAt = time.Now()
 	r.indexMu.Unlock()
 
+	r.flushPendingIndex(ctx)
 	return true, nil
 }
 
@@ -328,6 +337,7 @@ func (r *Repository) indexStatusSnapshot() *model.SearchIndexStatus {
 	r.indexMu.Unlock()
 
 	copied := status
+	copied.Vector = r.vectorStatusSnapshot()
 	return &copied
 }
 
@@ -379,8 +389,29 @@ func (r *Repository) searchMessagesWithIndex(ctx context.Context, req *model.Sea
 		startUnix, endUnix = endUnix, startUnix
 	}
 
+	mode := req.Mode
+	if mode == "" {
+		mode = model.SearchModeKeyword
+	}
+
 	begin := time.Now()
-	hits, total, err := r.index.Search(req, talkers, senders, startUnix, endUnix, req.Offset, req.Limit)
+	var hits []*indexer.SearchHit
+	var total, keywordTotal, semanticTotal int
+	switch mode {
+	case model.SearchModeSemantic:
+		hits, total, err = r.index.SemanticSearch(ctx, req.Query, talkers, senders, startUnix, endUnix, req.Offset, req.Limit)
+	case model.SearchModeHybrid:
+		hits, keywordTotal, semanticTotal, err = r.index.HybridSearch(ctx, req, talkers, senders, startUnix, endUnix, req.Offset, req.Limit)
+		total = keywordTotal + semanticTotal
+	default:
+		hits, total, err = r.index.Search(req, talkers, senders, startUnix, endUnix, req.Offset, req.Limit)
+	}
+	if errors.Is(err, indexer.ErrSemanticDisabled) {
+		return nil, errs.SemanticSearchDisabled()
+	}
+	if errors.Is(err, indexer.ErrOutsideHybridWindow) {
+		return nil, errs.SearchOffsetOutsideWindow(req.Offset, indexer.HybridWindow)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -394,21 +425,25 @@ func (r *Repository) searchMessagesWithIndex(ctx context.Context, req *model.Sea
 			Message: hit.Message,
 			Snippet: hit.Snippet,
 			Score:   hit.Score,
+			Range:   hit.Range,
 		})
 	}
 
 	resp := &model.SearchResponse{
-		Total:      total,
-		Hits:       mapped,
-		DurationMs: time.Since(begin).Milliseconds(),
-		Limit:      req.Limit,
-		Offset:     req.Offset,
-		Query:      req.Query,
-		Talker:     req.Talker,
-		Sender:     req.Sender,
-		Start:      req.Start,
-		End:        req.End,
-		Index:      r.indexStatusSnapshot(),
+		Total:         total,
+		KeywordTotal:  keywordTotal,
+		SemanticTotal: semanticTotal,
+		Hits:          mapped,
+		DurationMs:    time.Since(begin).Milliseconds(),
+		Limit:         req.Limit,
+		Offset:        req.Offset,
+		Query:         req.Query,
+		Talker:        req.Talker,
+		Sender:        req.Sender,
+		Start:         req.Start,
+		End:           req.End,
+		Mode:          mode,
+		Index:         r.indexStatusSnapshot(),
 	}
 
 	return resp, nil
@@ -11,6 +11,9 @@ import (
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/msgstore"
 )
 
+// maxPendingIndex bounds the messages queued while the full index is building.
+const maxPendingIndex = 100000
+
 // IndexMessages incrementally indexes the provided messages into the FTS cache.
 func (r *Repository) IndexMessages(ctx context.Context, messages []*model.Message) error {
 	if len(messages) == 0 || r == nil {
@@ -23,11 +26,12 @@ func (r *Repository) IndexMessages(ctx context.Context, messages []*model.Messag
 
 	r.indexMu.Lock()
 	status := r.indexStatus
-	r.indexMu.Unlock()
-
 	if status.InProgress || !status.Ready {
+		r.queuePendingIndex(messages)
+		r.indexMu.Unlock()
 		return nil
 	}
+	r.indexMu.Unlock()
 
 	batches := make(map[string][]*model.Message)
 	stores := make(map[string]*msgstore.Store)
@@ -86,5 +90,32 @@ func (r *Repository) IndexMessages(ctx context.Context, messages []*model.Messag
 	r.indexStatus.LastCompletedAt = time.Now()
 	r.indexMu.Unlock()
 
+	r.wakeEmbedding()
 	return nil
 }
+
+// queuePendingIndex remembers messages that arrive while the full index is
+// being built; callers hold indexMu.
+func (r *Repository) queuePendingIndex(messages []*model.Message) {
+	if len(r.indexPending)+len(messages) > maxPendingIndex {
+		log.Warn().Int("pending", len(r.indexPending)).Msg("too many messages queued for the fts index, dropping")
+		return
+	}
+	r.indexPending = append(r.indexPending, messages...)
+}
+
+// flushPendingIndex indexes the messages queued during a full rebuild, so
+// content updated meanwhile (e.g. voice transcripts) is not lost.
+func (r *Repository) flushPendingIndex(ctx context.Context) {
+	r.indexMu.Lock()
+	pending := r.indexPending
+	r.indexPending = nil
+	r.indexMu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+	if err := r.IndexMessages(ctx, pending); err != nil {
+		log.Warn().Err(err).Int("count", len(pending)).Msg("index queued messages failed")
+	}
+}
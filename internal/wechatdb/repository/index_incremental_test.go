@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/indexer"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/msgstore"
+)
+
+type storeLocator struct {
+	datasource.DataSource
+}
+
+func (storeLocator) LocateMessageStore(msg *model.Message) (*msgstore.Store, error) {
+	return &msgstore.Store{ID: "message_0"}, nil
+}
+
+func (storeLocator) GetDatasetFingerprint(ctx context.Context) (string, error) {
+	return "fp", nil
+}
+
+func TestIndexMessagesQueuedDuringRebuild(t *testing.T) {
+	idx, err := indexer.Open(t.TempDir(), indexer.Options{Tokenizer: "bigram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	r := &Repository{ds: storeLocator{}, index: idx}
+	r.indexStatus.InProgress = true
+
+	msg := &model.Message{Seq: 1, Time: time.Now(), Talker: "alice", Sender: "alice", Type: model.MessageTypeText, Content: "语音转写的内容"}
+	if err := r.IndexMessages(context.Background(), []*model.Message{msg}); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.indexPending) != 1 {
+		t.Fatalf("pending = %d, want the message queued while the index builds", len(r.indexPending))
+	}
+
+	r.indexStatus = model.SearchIndexStatus{Ready: true}
+	r.flushPendingIndex(context.Background())
+	if len(r.indexPending) != 0 {
+		t.Fatalf("pending = %d after flush", len(r.indexPending))
+	}
+	_, total, err := idx.Search(&model.SearchRequest{Query: "转写"}, nil, nil, 0, 0, 0, 10)
+	if err != nil || total != 1 {
+		t.Fatalf("search after flush = %d, %v", total, err)
+	}
+}
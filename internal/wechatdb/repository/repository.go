@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog/log"
@@ -18,6 +19,7 @@ type Repository struct {
 	ds datasource.DataSource
 
 	indexPath        string
+	indexOpts        indexer.Options
 	index            *indexer.Index
 	indexMu          sync.Mutex
 	indexStatus      model.SearchIndexStatus
@@ -25,6 +27,14 @@ type Repository struct {
 	indexCtx         context.Context
 	indexCancel      context.CancelFunc
 
+	// 全量索引构建期间到达的增量消息（新消息、语音转写），构建完成后补写
+	indexPending []*model.Message
+
+	// 语义检索：后台嵌入任务的唤醒信号与最近一次结果
+	embedWake          chan struct{}
+	vectorLastEmbedded time.Time
+	vectorLastError    string
+
 	// Cache for contact
 	contactCache      map[string]*model.Contact
 	aliasToContact    map[string][]*model.Contact
@@ -49,10 +59,11 @@ type Repository struct {
 }
 
 // New 创建一个新的 Repository
-func New(ds datasource.DataSource, indexPath string) (*Repository, error) {
+func New(ds datasource.DataSource, indexPath string, indexOpts indexer.Options) (*Repository, error) {
 	r := &Repository{
 		ds:                 ds,
 		indexPath:          indexPath,
+		indexOpts:          indexOpts,
 		contactCache:       make(map[string]*model.Contact),
 		aliasToContact:     make(map[string][]*model.Contact),
 		remarkToContact:    make(map[string][]*model.Contact),
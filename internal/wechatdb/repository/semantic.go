@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/indexer"
+)
+
+const (
+	embedBatchSize = 32
+	embedInterval  = time.Minute
+)
+
+// runEmbedding drains the chunk embedding backlog whenever the FTS index is
+// ready, then waits for new messages or the next tick.
+func (r *Repository) runEmbedding(ctx context.Context, idx *indexer.Index) {
+	ticker := time.NewTicker(embedInterval)
+	defer ticker.Stop()
+
+	for {
+		r.indexMu.Lock()
+		ready := r.indexStatus.Ready && !r.indexStatus.InProgress
+		r.indexMu.Unlock()
+
+		if ready {
+			r.drainEmbedding(ctx, idx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.embedWake:
+		}
+	}
+}
+
+func (r *Repository) drainEmbedding(ctx context.Context, idx *indexer.Index) {
+	for {
+		n, err := idx.EmbedPending(ctx, embedBatchSize)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			log.Warn().Err(err).Msg("embed pending chunks failed")
+			r.indexMu.Lock()
+			r.vectorLastError = err.Error()
+			r.indexMu.Unlock()
+			return
+		}
+		if n == 0 {
+			return
+		}
+		r.indexMu.Lock()
+		r.vectorLastError = ""
+		r.vectorLastEmbedded = time.Now()
+		r.indexMu.Unlock()
+	}
+}
+
+// wakeEmbedding schedules an embedding pass without blocking the caller.
+func (r *Repository) wakeEmbedding() {
+	if r.embedWake == nil {
+		return
+	}
+	select {
+	case r.embedWake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Repository) vectorStatusSnapshot() *model.VectorIndexStatus {
+	vs := r.index.VectorStatus()
+	if vs == nil {
+		return nil
+	}
+
+	r.indexMu.Lock()
+	defer r.indexMu.Unlock()
+	return &model.VectorIndexStatus{
+		Model:          vs.Model,
+		Chunks:         vs.Chunks,
+		Pending:        vs.Pending,
+		LastEmbeddedAt: r.vectorLastEmbedded,
+		LastError:      r.vectorLastError,
+	}
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/indexer"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/msgstore"
+)
+
+type stubEmbedder struct {
+	err error
+}
+
+func (e *stubEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = []float32{1, 0}
+	}
+	return out, nil
+}
+
+func (e *stubEmbedder) ModelName() string { return "stub" }
+
+func (e *stubEmbedder) Close() {}
+
+func indexWithEmbedder(t *testing.T, e *stubEmbedder) *indexer.Index {
+	t.Helper()
+	idx, err := indexer.Open(t.TempDir(), indexer.Options{Embedder: e})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = idx.Close() })
+
+	now := time.Now()
+	msgs := []*model.Message{
+		{Seq: 1, Time: now, Talker: "alice", Sender: "alice", Type: model.MessageTypeText, Content: "周末去爬山"},
+		{Seq: 2, Time: now.Add(time.Minute), Talker: "alice", Sender: "alice", Type: model.MessageTypeText, Content: "好啊"},
+	}
+	if err := idx.IndexStoreMessages(&msgstore.Store{ID: "message_0"}, msgs); err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func TestDrainEmbedding(t *testing.T) {
+	idx := indexWithEmbedder(t, &stubEmbedder{})
+	r := &Repository{index: idx, vectorLastError: "stale"}
+
+	r.drainEmbedding(context.Background(), idx)
+
+	status := r.vectorStatusSnapshot()
+	if status == nil || status.Model != "stub" || status.Chunks != 1 || status.Pending != 0 {
+		t.Fatalf("status = %+v", status)
+	}
+	if status.LastEmbeddedAt.IsZero() || status.LastError != "" {
+		t.Fatalf("status = %+v", status)
+	}
+}
+
+func TestDrainEmbeddingError(t *testing.T) {
+	idx := indexWithEmbedder(t, &stubEmbedder{err: errors.New("service down")})
+	r := &Repository{index: idx}
+
+	r.drainEmbedding(context.Background(), idx)
+
+	status := r.vectorStatusSnapshot()
+	if status.Pending != 1 || status.LastError != "service down" {
+		t.Fatalf("status = %+v", status)
+	}
+}
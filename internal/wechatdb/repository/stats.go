@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/errors"
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+const (
+	topSenderLimit  = 10
+	busiestDayLimit = 10
+)
+
+// TalkerStats 汇总单个会话（联系人或群聊）在时间范围内的统计，talker 支持 ID、备注或昵称
+func (r *Repository) TalkerStats(ctx context.Context, talker string, startTime, endTime time.Time) (*model.TalkerStats, error) {
+	talker = strings.TrimSpace(talker)
+	if talker == "" {
+		return nil, errors.ErrTalkerEmpty
+	}
+	if contact, _ := r.GetContact(ctx, talker); contact != nil {
+		talker = contact.UserName
+	} else if chatRoom, _ := r.GetChatRoom(ctx, talker); chatRoom != nil {
+		talker = chatRoom.Name
+	}
+
+	agg, err := r.ds.TalkerAggregates(ctx, talker, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	stats := buildTalkerStats(talker, agg)
+
+	var chatRoom *model.ChatRoom
+	if stats.IsChatRoom {
+		if room, ok := r.chatRoomCache[talker]; ok {
+			chatRoom = room
+			stats.TalkerName = room.DisplayName()
+		}
+	} else if contact := r.getFullContact(talker); contact != nil {
+		stats.TalkerName = contact.DisplayName()
+	}
+	for i := range stats.TopSenders {
+		s := &stats.TopSenders[i]
+		if s.IsSelf || s.Sender == "" {
+			continue
+		}
+		if chatRoom != nil {
+			if name, ok := chatRoom.User2DisplayName[s.Sender]; ok && name != "" {
+				s.Name = name
+				continue
+			}
+		}
+		if contact := r.getFullContact(s.Sender); contact != nil {
+			s.Name = contact.DisplayName()
+		}
+	}
+	return stats, nil
+}
+
+// buildTalkerStats 把数据源返回的聚合结果汇总为 TalkerStats，三种数据源共用同一套口径
+func buildTalkerStats(talker string, agg *model.TalkerAggregates) *model.TalkerStats {
+	stats := &model.TalkerStats{
+		Talker:      talker,
+		IsChatRoom:  strings.HasSuffix(talker, "@chatroom"),
+		TopSenders:  []model.SenderStat{},
+		ByType:      make(map[string]int64),
+		Monthly:     []model.MonthlyTrend{},
+		BusiestDays: []model.DayCount{},
+	}
+	if agg == nil {
+		return stats
+	}
+	if agg.FirstUnix > 0 {
+		stats.First = time.Unix(agg.FirstUnix, 0).Format(time.RFC3339)
+		stats.Last = time.Unix(agg.LastUnix, 0).Format(time.RFC3339)
+	}
+
+	// 时段：热力图、月度趋势与最活跃的日期
+	days := make(map[string]int64)
+	months := make(map[string]*model.MonthlyTrend)
+	for _, slot := range agg.Slots {
+		n := slot.Sent + slot.Received
+		stats.Total += n
+		stats.Sent += slot.Sent
+		stats.Received += slot.Received
+		days[slot.Day] += n
+		if slot.Hour >= 0 && slot.Hour < 24 {
+			stats.Hourly[slot.Hour] += n
+			if d, err := time.ParseInLocation("2006-01-02", slot.Day, time.Local); err == nil {
+				stats.Heatmap[slot.Hour][d.Weekday()] += n
+				stats.Weekday[d.Weekday()] += n
+			}
+		}
+		if len(slot.Day) >= 7 {
+			ym := slot.Day[:7]
+			m := months[ym]
+			if m == nil {
+				m = &model.MonthlyTrend{Date: ym}
+				months[ym] = m
+			}
+			m.Sent += slot.Sent
+			m.Received += slot.Received
+		}
+	}
+	stats.ActiveDays = len(days)
+	for _, ym := range sortedMapKeys(months) {
+		stats.Monthly = append(stats.Monthly, *months[ym])
+	}
+	for day, n := range days {
+		stats.BusiestDays = append(stats.BusiestDays, model.DayCount{Date: day, Count: n})
+	}
+	sort.Slice(stats.BusiestDays, func(i, j int) bool {
+		a, b := stats.BusiestDays[i], stats.BusiestDays[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Date > b.Date
+	})
+	if len(stats.BusiestDays) > busiestDayLimit {
+		stats.BusiestDays = stats.BusiestDays[:busiestDayLimit]
+	}
+
+	// 消息类型与媒体
+	for _, t := range agg.Types {
+		stats.ByType[model.MessageTypeLabel(t.Type, t.SubType)] += t.Count
+		switch t.Type {
+		case model.MessageTypeImage:
+			stats.Media.Image += t.Count
+		case model.MessageTypeVideo:
+			stats.Media.Video += t.Count
+		case model.MessageTypeVoice:
+			stats.Media.Voice += t.Count
+		case model.MessageTypeAnimation:
+			stats.Media.Emoji += t.Count
+		case model.MessageTypeLocation:
+			stats.Media.Location += t.Count
+		case model.MessageTypeShare:
+			switch t.SubType {
+			case model.MessageSubTypeFile:
+				stats.Media.File += t.Count
+			case model.MessageSubTypeLink, model.MessageSubTypeLink2:
+				stats.Media.Link += t.Count
+			}
+		}
+	}
+
+	// 发送者：自己发送的消息合并为一项（Sender 为空），私聊中对方的消息归到 talker
+	senders := make(map[string]*model.SenderStat)
+	for _, s := range agg.Senders {
+		key, sender := s.Sender, s.Sender
+		switch {
+		case s.IsSelf:
+			key, sender = "\x00self", ""
+		case sender == "" && !stats.IsChatRoom:
+			key, sender = talker, talker
+		}
+		st := senders[key]
+		if st == nil {
+			st = &model.SenderStat{Sender: sender, IsSelf: s.IsSelf}
+			senders[key] = st
+		}
+		st.Count += s.Count
+	}
+	for _, st := range senders {
+		if stats.Total > 0 {
+			st.Percent = math.Round(float64(st.Count)*10000/float64(stats.Total)) / 100
+		}
+		stats.TopSenders = append(stats.TopSenders, *st)
+	}
+	sort.Slice(stats.TopSenders, func(i, j int) bool {
+		a, b := stats.TopSenders[i], stats.TopSenders[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Sender < b.Sender
+	})
+	if len(stats.TopSenders) > topSenderLimit {
+		stats.TopSenders = stats.TopSenders[:topSenderLimit]
+	}
+	return stats
+}
+
+func sortedMapKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/takeaway1/chatlog-TCOTC/internal/model"
+)
+
+func TestBuildTalkerStats(t *testing.T) {
+	agg := &model.TalkerAggregates{
+		Senders: []model.SenderCount{
+			{Sender: "me", IsSelf: true, Count: 3},
+			{Sender: "", IsSelf: true, Count: 1},
+			{Sender: "", Count: 4}, // 私聊中对方的消息没有发送者时归到 talker
+			{Sender: "alice", Count: 2},
+		},
+		Types: []model.TypeCount{
+			{Type: 1, Count: 6},
+			{Type: 3, Count: 2},
+			{Type: 49, SubType: 6, Count: 1},
+			{Type: 49, SubType: 5, Count: 1},
+		},
+		Slots: []model.SlotCount{
+			{Day: "2024-03-04", Hour: 9, Sent: 2, Received: 3}, // 星期一
+			{Day: "2024-03-04", Hour: 21, Sent: 1, Received: 1},
+			{Day: "2024-04-06", Hour: 9, Sent: 1, Received: 2}, // 星期六
+		},
+	}
+
+	stats := buildTalkerStats("alice", agg)
+	if stats.Total != 10 || stats.Sent != 4 || stats.Received != 6 || stats.ActiveDays != 2 {
+		t.Fatalf("totals = %d/%d/%d days=%d", stats.Total, stats.Sent, stats.Received, stats.ActiveDays)
+	}
+	if len(stats.TopSenders) != 2 || stats.TopSenders[0].Sender != "alice" || stats.TopSenders[0].Count != 6 ||
+		!stats.TopSenders[1].IsSelf || stats.TopSenders[1].Count != 4 || stats.TopSenders[1].Percent != 40 {
+		t.Fatalf("senders = %+v", stats.TopSenders)
+	}
+	if stats.ByType["文本消息"] != 6 || stats.ByType["文件消息"] != 1 || stats.ByType["链接消息"] != 1 {
+		t.Fatalf("types = %v", stats.ByType)
+	}
+	if stats.Media.Image != 2 || stats.Media.File != 1 || stats.Media.Link != 1 {
+		t.Fatalf("media = %+v", stats.Media)
+	}
+	if stats.Heatmap[9][1] != 5 || stats.Heatmap[9][6] != 3 || stats.Hourly[21] != 2 || stats.Weekday[1] != 7 {
+		t.Fatalf("heatmap = %v", stats.Heatmap)
+	}
+	if len(stats.Monthly) != 2 || stats.Monthly[0].Date != "2024-03" || stats.Monthly[0].Sent != 3 || stats.Monthly[1].Received != 2 {
+		t.Fatalf("monthly = %+v", stats.Monthly)
+	}
+	if len(stats.BusiestDays) != 2 || stats.BusiestDays[0].Date != "2024-03-04" || stats.BusiestDays[0].Count != 7 {
+		t.Fatalf("busiest = %+v", stats.BusiestDays)
+	}
+}
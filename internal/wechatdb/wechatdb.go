@@ -12,23 +12,33 @@ import (
 
 	"github.com/takeaway1/chatlog-TCOTC/internal/model"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/datasource"
+	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/indexer"
 	"github.com/takeaway1/chatlog-TCOTC/internal/wechatdb/repository"
 )
 
 type DB struct {
-	path     string
-	platform string
-	version  int
-	ds       datasource.DataSource
-	repo     *repository.Repository
+	path      string
+	platform  string
+	version   int
+	indexOpts indexer.Options
+	ds        datasource.DataSource
+	repo      *repository.Repository
+
+	// readOnly 时 path 为只读快照，全文索引写入 indexPath
+	readOnly  bool
+	indexPath string
+
+	// noIndex 时不打开全文索引，也不会在后台构建
+	noIndex bool
 }
 
-func New(path string, platform string, version int) (*DB, error) {
+func New(path string, platform string, version int, indexOpts indexer.Options) (*DB, error) {
 
 	w := &DB{
-		path:     path,
-		platform: platform,
-		version:  version,
+		path:      path,
+		platform:  platform,
+		version:   version,
+		indexOpts: indexOpts,
 	}
 
 	// 初始化，加载数据库文件信息
@@ -39,6 +49,38 @@ func New(path string, platform string, version int) (*DB, error) {
 	return w, nil
 }
 
+// NewReadOnly 以只读方式打开已解密的快照目录，全文索引保存在 indexPath 中，
+// 快照目录本身不会被写入
+func NewReadOnly(path, indexPath string, platform string, version int, indexOpts indexer.Options) (*DB, error) {
+	w := &DB{
+		path:      path,
+		platform:  platform,
+		version:   version,
+		indexOpts: indexOpts,
+		readOnly:  true,
+		indexPath: indexPath,
+	}
+	if err := w.Initialize(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewWithoutIndex 打开数据库但不加载全文索引，供导出、同步等一次性命令使用，
+// 避免在后台触发索引构建
+func NewWithoutIndex(path string, platform string, version int) (*DB, error) {
+	w := &DB{
+		path:     path,
+		platform: platform,
+		version:  version,
+		noIndex:  true,
+	}
+	if err := w.Initialize(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
 func (w *DB) Close() error {
 	if w.repo != nil {
 		return w.repo.Close()
@@ -48,16 +90,27 @@ func (w *DB) Close() error {
 
 func (w *DB) Initialize() error {
 	var err error
-	w.ds, err = datasource.New(w.path, w.platform, w.version)
+	if w.readOnly {
+		w.ds, err = datasource.NewReadOnly(w.path, w.platform, w.version)
+	} else {
+		w.ds, err = datasource.New(w.path, w.platform, w.version)
+	}
 	if err != nil {
 		return err
 	}
 
-	indexPath := filepath.Join(w.path, "indexes", "messages")
-	if err := os.MkdirAll(indexPath, 0o755); err != nil {
-		return fmt.Errorf("prepare index directory: %w", err)
+	// repository 在 indexPath 为空时不启用全文索引
+	indexPath := ""
+	if !w.noIndex {
+		indexPath = w.indexPath
+		if indexPath == "" {
+			indexPath = filepath.Join(w.path, "indexes", "messages")
+		}
+		if err := os.MkdirAll(indexPath, 0o755); err != nil {
+			return fmt.Errorf("prepare index directory: %w", err)
+		}
 	}
-	w.repo, err = repository.New(w.ds, indexPath)
+	w.repo, err = repository.New(w.ds, indexPath, w.indexOpts)
 	if err != nil {
 		return err
 	}
@@ -175,6 +228,11 @@ func (w *DB) IntimacyBase() (map[string]*model.IntimacyBase, error) {
 	return w.repo.IntimacyBase(context.Background())
 }
 
+// TalkerStats 返回单个会话在时间范围内的统计
+func (w *DB) TalkerStats(talker string, start, end time.Time) (*model.TalkerStats, error) {
+	return w.repo.TalkerStats(context.Background(), talker, start, end)
+}
+
 func (w *DB) GroupTodayMessageCounts() (map[string]int64, error) {
 	return w.repo.GroupTodayMessageCounts(context.Background())
 }
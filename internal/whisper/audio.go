@@ -0,0 +1,59 @@
+package whisper
+
+import "math"
+
+// resampleIfNeeded linearly interpolates samples from fromRate to toRate and
+// always returns a copy, so callers may keep mutating the input.
+func resampleIfNeeded(samples []float32, fromRate, toRate int) []float32 {
+	if fromRate <= 0 {
+		fromRate = toRate
+	}
+	if fromRate == toRate || len(samples) == 0 {
+		dst := make([]float32, len(samples))
+		copy(dst, samples)
+		return dst
+	}
+
+	ratio := float64(fromRate) / float64(toRate)
+	if ratio <= 0 {
+		dst := make([]float32, len(samples))
+		copy(dst, samples)
+		return dst
+	}
+
+	outLen := int(math.Ceil(float64(len(samples)) / ratio))
+	if outLen <= 0 {
+		outLen = len(samples)
+	}
+
+	dst := make([]float32, outLen)
+	for i := range dst {
+		srcPos := float64(i) * ratio
+		idx := int(math.Floor(srcPos))
+		frac := srcPos - float64(idx)
+
+		if idx >= len(samples)-1 {
+			dst[i] = samples[len(samples)-1]
+			continue
+		}
+
+		a := samples[idx]
+		b := samples[idx+1]
+		dst[i] = float32(float64(a)*(1-frac) + float64(b)*frac)
+	}
+
+	return dst
+}
+
+// pcm16ToFloat32 scales signed 16-bit samples into the [-1, 1) range.
+func pcm16ToFloat32(src []int16) []float32 {
+	if len(src) == 0 {
+		return nil
+	}
+	dst := make([]float32, len(src))
+	const scale = 1.0 / 32768.0
+	for i, sample := range src {
+		dst[i] = float32(float64(sample) * scale)
+	}
+	return dst
+}
@@ -0,0 +1,328 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/silk"
+)
+
+// testSilk builds a deterministic SILK payload of 50 frames (1 second of
+// 20 ms frames) so the suite needs no binary fixtures.
+func testSilk() []byte {
+	buf := bytes.NewBufferString("#!SILK_V3")
+	for f := 0; f < 50; f++ {
+		frame := make([]byte, 40)
+		for i := range frame {
+			frame[i] = byte((f*31 + i*7) & 0xff)
+		}
+		_ = binary.Write(buf, binary.LittleEndian, uint16(len(frame)))
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+func testTone(sampleRate int, d time.Duration) []float32 {
+	n := int(float64(sampleRate) * d.Seconds())
+	samples := make([]float32, n)
+	for i := range samples {
+		samples[i] = float32(0.5 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+	}
+	return samples
+}
+
+// wavDuration reads the length of a mono PCM16 WAV upload.
+func wavDuration(t *testing.T, r *http.Request, field string) float64 {
+	t.Helper()
+	f, _, err := r.FormFile(field)
+	if err != nil {
+		t.Errorf("read upload: %v", err)
+		return 0
+	}
+	defer f.Close()
+	wav, _ := io.ReadAll(f)
+	if len(wav) < 44 {
+		return 0
+	}
+	rate := binary.LittleEndian.Uint32(wav[24:])
+	return float64(len(wav)-44) / 2 / float64(rate)
+}
+
+// halves splits d into two back-to-back segments, like a real backend would.
+func halves(d float64) []map[string]any {
+	return []map[string]any{
+		{"id": 0, "start": 0, "end": d / 2, "text": " first"},
+		{"id": 1, "start": d / 2, "end": d, "text": " second"},
+	}
+}
+
+type conformanceBackend struct {
+	name string
+	// open returns a transcriber with DefaultOptions {Language: "zh"}.
+	open func(t *testing.T) Transcriber
+	// exact reports whether Duration must equal the audio length; backends
+	// that take the duration from their service only need to be close.
+	exact bool
+}
+
+func conformanceBackends() []conformanceBackend {
+	defaults := Options{Language: "zh", LanguageSet: true}
+	return []conformanceBackend{
+		{
+			name:  "replay",
+			exact: true,
+			open: func(t *testing.T) Transcriber {
+				dir := t.TempDir()
+				tr, err := NewReplayTranscriber(ReplayConfig{Dir: dir, DefaultOptions: defaults})
+				if err != nil {
+					t.Fatal(err)
+				}
+				// 为套件使用的每段音频录制 fixture
+				silk16, rate, err := decodeTestSilk(testSilk())
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, audio := range [][]float32{
+					resampleIfNeeded(silk16, rate, ReplaySampleRate),
+					resampleIfNeeded(testTone(48000, time.Second), 48000, ReplaySampleRate),
+				} {
+					d := float64(len(audio)) / ReplaySampleRate
+					raw, _ := json.Marshal(map[string]any{"text": "first second", "segments": halves(d)})
+					if err := os.WriteFile(filepath.Join(dir, ReplayKey(audio)+".json"), raw, 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return tr
+			},
+		},
+		{
+			name: "openai",
+			open: func(t *testing.T) Transcriber {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					d := wavDuration(t, r, "file")
+					resp := map[string]any{"text": "first second", "duration": d, "segments": halves(d)}
+					if lang := r.FormValue("language"); lang != "" {
+						resp["language"] = lang
+					}
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(resp)
+				}))
+				t.Cleanup(srv.Close)
+				tr, err := NewOpenAITranscriber(OpenAIConfig{APIKey: "test", BaseURL: srv.URL, DefaultOptions: defaults})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return tr
+			},
+		},
+		{
+			name: "webservice",
+			open: func(t *testing.T) Transcriber {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					d := wavDuration(t, r, "audio_file")
+					resp := map[string]any{"text": " first second ", "segments": halves(d)}
+					if lang := r.URL.Query().Get("language"); lang != "" {
+						resp["language"] = lang
+					}
+					_ = json.NewEncoder(w).Encode(resp)
+				}))
+				t.Cleanup(srv.Close)
+				tr, err := NewWebServiceTranscriber(WebServiceConfig{BaseURL: srv.URL, OutputFormat: "json", DefaultOptions: defaults})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return tr
+			},
+		},
+		{
+			name:  "whispercpp",
+			exact: true,
+			open: func(t *testing.T) Transcriber {
+				model := os.Getenv("CHATLOG_TEST_WHISPER_MODEL")
+				if model == "" {
+					t.Skip("set CHATLOG_TEST_WHISPER_MODEL to run against whisper.cpp")
+				}
+				tr, err := NewWhisperCPPTranscriber(WhisperCPPConfig{ModelPath: model, DefaultOptions: defaults})
+				if err != nil {
+					t.Skip(err)
+				}
+				return tr
+			},
+		},
+	}
+}
+
+func decodeTestSilk(data []byte) ([]float32, int, error) {
+	samples, rate, err := silk.Silk2PCM16(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return pcm16ToFloat32(samples), rate, nil
+}
+
+// TestTranscriberConformance runs the behaviour every backend must share.
+func TestTranscriberConformance(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			tr := backend.open(t)
+			defer tr.Close()
+			ctx := context.Background()
+
+			t.Run("empty input", func(t *testing.T) {
+				if res, err := tr.TranscribeSilk(ctx, nil, Options{}); res != nil || err != nil {
+					t.Fatalf("TranscribeSilk(nil) = %+v, %v", res, err)
+				}
+				if res, err := tr.TranscribePCM(ctx, nil, 16000, Options{}); res != nil || err != nil {
+					t.Fatalf("TranscribePCM(nil) = %+v, %v", res, err)
+				}
+			})
+
+			t.Run("silk", func(t *testing.T) {
+				samples, rate, err := decodeTestSilk(testSilk())
+				if err != nil {
+					t.Fatal(err)
+				}
+				res, err := tr.TranscribeSilk(ctx, testSilk(), Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkResult(t, res, pcmDuration(len(samples), rate), backend.exact)
+			})
+
+			t.Run("resampled pcm", func(t *testing.T) {
+				res, err := tr.TranscribePCM(ctx, testTone(48000, time.Second), 48000, Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkResult(t, res, time.Second, backend.exact)
+			})
+
+			t.Run("option merging", func(t *testing.T) {
+				m, ok := tr.(interface{ mergeOptions(Options) Options })
+				if !ok {
+					t.Fatal("backend does not merge options")
+				}
+				merged := m.mergeOptions(Options{Temperature: 0.4, TemperatureSet: true, Threads: 8})
+				if merged.Language != "zh" || !merged.LanguageSet {
+					t.Fatalf("defaults lost: %+v", merged)
+				}
+				if merged.Temperature != 0.4 || merged.ThreadsSet || merged.Threads != 0 {
+					t.Fatalf("overrides not applied: %+v", merged)
+				}
+
+				res, err := tr.TranscribePCM(ctx, testTone(48000, time.Second), 48000, Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Language != "zh" {
+					t.Fatalf("default language = %q, want zh", res.Language)
+				}
+				res, err = tr.TranscribePCM(ctx, testTone(48000, time.Second), 48000, Options{Language: "fr", LanguageSet: true})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Language != "fr" {
+					t.Fatalf("overridden language = %q, want fr", res.Language)
+				}
+			})
+		})
+	}
+}
+
+func checkResult(t *testing.T, res *Result, want time.Duration, exact bool) {
+	t.Helper()
+	if res == nil {
+		t.Fatal("nil result")
+	}
+	if res.Text == "" {
+		t.Fatal("empty text")
+	}
+	tolerance := 5 * time.Millisecond
+	if !exact {
+		tolerance = 50 * time.Millisecond
+	}
+	if diff := res.Duration - want; diff > tolerance || diff < -tolerance {
+		t.Fatalf("duration = %v, want %v", res.Duration, want)
+	}
+	if len(res.Segments) == 0 {
+		t.Fatal("no segments")
+	}
+	var prev time.Duration
+	for i, seg := range res.Segments {
+		if seg.Start < prev || seg.End < seg.Start || seg.End > res.Duration+tolerance {
+			t.Fatalf("segment %d out of order: %+v (duration %v)", i, seg, res.Duration)
+		}
+		prev = seg.Start
+	}
+}
+
+func TestResampleIfNeeded(t *testing.T) {
+	in := testTone(48000, time.Second)
+
+	out := resampleIfNeeded(in, 48000, 16000)
+	if len(out) != 16000 {
+		t.Fatalf("resampled %d samples, want 16000", len(out))
+	}
+	for i := 0; i < len(out); i += 997 {
+		if diff := out[i] - in[i*3]; diff > 1e-6 || diff < -1e-6 {
+			t.Fatalf("sample %d = %v, want %v", i, out[i], in[i*3])
+		}
+	}
+
+	same := resampleIfNeeded(in, 0, 48000)
+	if len(same) != len(in) {
+		t.Fatalf("unknown rate changed length to %d", len(same))
+	}
+	same[0] = 2
+	if in[0] == 2 {
+		t.Fatal("resampleIfNeeded must copy its input")
+	}
+
+	up := resampleIfNeeded([]float32{0, 1}, 8000, 16000)
+	if len(up) != 4 || up[1] != 0.5 || up[3] != 1 {
+		t.Fatalf("upsampled = %v", up)
+	}
+}
+
+func TestReplayMissingFixture(t *testing.T) {
+	tr, err := NewReplayTranscriber(ReplayConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.TranscribePCM(context.Background(), testTone(16000, time.Second), 16000, Options{}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("err = %v, want os.ErrNotExist", err)
+	}
+	if _, err := NewReplayTranscriber(ReplayConfig{Dir: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Fatal("expected error for missing fixture directory")
+	}
+}
+
+func TestReplayTextFixture(t *testing.T) {
+	dir := t.TempDir()
+	audio := testTone(16000, 2*time.Second)
+	if err := os.WriteFile(filepath.Join(dir, ReplayKey(audio)+".txt"), []byte(" 你好 \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := NewReplayTranscriber(ReplayConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tr.TranscribePCM(context.Background(), audio, 16000, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "你好" || len(res.Segments) != 1 || res.Segments[0].End != 2*time.Second {
+		t.Fatalf("result = %+v", res)
+	}
+}
@@ -0,0 +1,214 @@
+package whisper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/takeaway1/chatlog-TCOTC/pkg/util/silk"
+)
+
+// ReplaySampleRate is the rate audio is resampled to before a replay key is
+// computed, so the same recording yields the same key whatever its source rate.
+const ReplaySampleRate = 16000
+
+// ReplayConfig controls the fixture-backed replay transcriber.
+type ReplayConfig struct {
+	Dir            string
+	DefaultOptions Options
+}
+
+// ReplayTranscriber serves transcripts from a fixture directory instead of
+// running a model. It is pure Go, needs no network and is deterministic, which
+// makes it suitable for CI and for headless boxes without a speech backend.
+//
+// For every request the audio is resampled to ReplaySampleRate and hashed with
+// ReplayKey; the transcript is read from <dir>/<key>.json or <dir>/<key>.txt.
+type ReplayTranscriber struct {
+	dir            string
+	defaultOptions Options
+}
+
+// replayFixture is the on-disk format of <key>.json. Times are in seconds.
+type replayFixture struct {
+	Text        string          `json:"text"`
+	Language    string          `json:"language"`
+	Translation string          `json:"translation"`
+	Segments    []replaySegment `json:"segments"`
+}
+
+type replaySegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// NewReplayTranscriber builds a replay backend reading fixtures from cfg.Dir.
+func NewReplayTranscriber(cfg ReplayConfig) (*ReplayTranscriber, error) {
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		return nil, errors.New("replay fixture directory is empty")
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open replay fixture directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("replay fixture path %s is not a directory", dir)
+	}
+	return &ReplayTranscriber{dir: dir, defaultOptions: cfg.DefaultOptions}, nil
+}
+
+// Close releases resources held by the transcriber. No-op for the replay backend.
+func (t *ReplayTranscriber) Close() {}
+
+// Dir returns the fixture directory.
+func (t *ReplayTranscriber) Dir() string {
+	return t.dir
+}
+
+// TranscribePCM looks up the fixture recorded for the given samples.
+func (t *ReplayTranscriber) TranscribePCM(ctx context.Context, samples []float32, sampleRate int, opts Options) (*Result, error) {
+	merged := t.mergeOptions(opts)
+
+	if len(samples) == 0 {
+		return nil, nil
+	}
+	if ctx != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	processed := resampleIfNeeded(samples, sampleRate, ReplaySampleRate)
+	key := ReplayKey(processed)
+	duration := pcmDuration(len(processed), ReplaySampleRate)
+
+	fixture, err := t.load(key)
+	if err != nil {
+		return nil, err
+	}
+	return fixture.result(merged, duration), nil
+}
+
+// TranscribeSilk decodes SILK payloads before looking up the fixture.
+func (t *ReplayTranscriber) TranscribeSilk(ctx context.Context, silkData []byte, opts Options) (*Result, error) {
+	if len(silkData) == 0 {
+		return nil, nil
+	}
+	samples16, sampleRate, err := silk.Silk2PCM16(silkData)
+	if err != nil {
+		return nil, err
+	}
+	return t.TranscribePCM(ctx, pcm16ToFloat32(samples16), sampleRate, opts)
+}
+
+func (t *ReplayTranscriber) mergeOptions(overrides Options) Options {
+	return t.defaultOptions.Merge(overrides)
+}
+
+func (t *ReplayTranscriber) load(key string) (*replayFixture, error) {
+	base := filepath.Join(t.dir, key)
+
+	raw, err := os.ReadFile(base + ".json")
+	if err == nil {
+		var fixture replayFixture
+		if err := json.Unmarshal(raw, &fixture); err != nil {
+			return nil, fmt.Errorf("decode replay fixture %s.json: %w", key, err)
+		}
+		return &fixture, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	raw, err = os.ReadFile(base + ".txt")
+	if err == nil {
+		return &replayFixture{Text: string(raw)}, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		// 记录缺失的 key，方便补录 fixture
+		log.Warn().Str("key", key).Str("dir", t.dir).Msg("no replay fixture for audio")
+		return nil, fmt.Errorf("replay fixture %s not found: %w", key, os.ErrNotExist)
+	}
+	return nil, err
+}
+
+func (f *replayFixture) result(opts Options, duration time.Duration) *Result {
+	translated := opts.TranslateSet && opts.Translate
+
+	res := &Result{
+		Text:     strings.TrimSpace(f.Text),
+		Language: strings.TrimSpace(f.Language),
+		Duration: duration,
+	}
+	if translated && strings.TrimSpace(f.Translation) != "" {
+		// 译文没有分段时间，整体作为一个分段返回
+		res.Text = strings.TrimSpace(f.Translation)
+		res.Language = "en"
+	} else {
+		for _, seg := range f.Segments {
+			start := clampDuration(secondsToDuration(seg.Start), duration)
+			end := clampDuration(secondsToDuration(seg.End), duration)
+			if end < start {
+				end = start
+			}
+			res.Segments = append(res.Segments, Segment{Start: start, End: end, Text: strings.TrimSpace(seg.Text)})
+		}
+		sort.SliceStable(res.Segments, func(i, j int) bool {
+			return res.Segments[i].Start < res.Segments[j].Start
+		})
+	}
+	if len(res.Segments) == 0 && res.Text != "" {
+		res.Segments = []Segment{{Start: 0, End: duration, Text: res.Text}}
+	}
+	for i := range res.Segments {
+		res.Segments[i].ID = i
+	}
+	if res.Text == "" {
+		parts := make([]string, 0, len(res.Segments))
+		for _, seg := range res.Segments {
+			if seg.Text != "" {
+				parts = append(parts, seg.Text)
+			}
+		}
+		res.Text = strings.Join(parts, " ")
+	}
+	if res.Language == "" {
+		res.Language = fallbackLanguage(opts, translated)
+	}
+	return res
+}
+
+func clampDuration(d, limit time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	if d > limit {
+		return limit
+	}
+	return d
+}
+
+// ReplayKey returns the fixture key for samples already at ReplaySampleRate:
+// the first 16 bytes of the SHA-256 of their little-endian PCM16 encoding.
+func ReplayKey(samples []float32) string {
+	pcm := float32ToPCM16(samples)
+	buf := make([]byte, len(pcm)*2)
+	for i, sample := range pcm {
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(sample))
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:16])
+}
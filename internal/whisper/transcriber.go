@@ -231,34 +231,7 @@ func (t *OpenAITranscriber) sendTranslation(ctx context.Context, wav []byte, opt
 }
 
 func (t *OpenAITranscriber) mergeOptions(overrides Options) Options {
-	merged := t.defaultOptions
-
-	if overrides.LanguageSet {
-		merged.Language = overrides.Language
-		merged.LanguageSet = true
-	}
-	if overrides.TranslateSet {
-		merged.Translate = overrides.Translate
-		merged.TranslateSet = true
-	}
-	if overrides.ThreadsSet {
-		merged.Threads = overrides.Threads
-		merged.ThreadsSet = true
-	}
-	if overrides.InitialPromptSet {
-		merged.InitialPrompt = overrides.InitialPrompt
-		merged.InitialPromptSet = true
-	}
-	if overrides.TemperatureSet {
-		merged.Temperature = overrides.Temperature
-		merged.TemperatureSet = true
-	}
-	if overrides.TemperatureFloorSet {
-		merged.TemperatureFloor = overrides.TemperatureFloor
-		merged.TemperatureFloorSet = true
-	}
-
-	return merged
+	return t.defaultOptions.Merge(overrides)
 }
 
 func buildResultFromTranscription(tr *openai.Transcription, opts Options, fallbackDuration time.Duration) (*Result, error) {
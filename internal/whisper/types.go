@@ -21,6 +21,38 @@ type Options struct {
 	TemperatureFloorSet bool    // true when TemperatureFloor should override defaults
 }
 
+// Merge returns o with every field marked as set in overrides replaced.
+func (o Options) Merge(overrides Options) Options {
+	merged := o
+
+	if overrides.LanguageSet {
+		merged.Language = overrides.Language
+		merged.LanguageSet = true
+	}
+	if overrides.TranslateSet {
+		merged.Translate = overrides.Translate
+		merged.TranslateSet = true
+	}
+	if overrides.ThreadsSet {
+		merged.Threads = overrides.Threads
+		merged.ThreadsSet = true
+	}
+	if overrides.InitialPromptSet {
+		merged.InitialPrompt = overrides.InitialPrompt
+		merged.InitialPromptSet = true
+	}
+	if overrides.TemperatureSet {
+		merged.Temperature = overrides.Temperature
+		merged.TemperatureSet = true
+	}
+	if overrides.TemperatureFloorSet {
+		merged.TemperatureFloor = overrides.TemperatureFloor
+		merged.TemperatureFloorSet = true
+	}
+
+	return merged
+}
+
 // Segment represents a portion of transcribed text with timestamps.
 type Segment struct {
 	ID    int           `json:"id"`
@@ -261,34 +261,7 @@ func (t *WebServiceTranscriber) buildRequestURL(opts Options) (string, error) {
 }
 
 func (t *WebServiceTranscriber) mergeOptions(overrides Options) Options {
-	merged := t.cfg.DefaultOptions
-
-	if overrides.LanguageSet {
-		merged.Language = overrides.Language
-		merged.LanguageSet = true
-	}
-	if overrides.TranslateSet {
-		merged.Translate = overrides.Translate
-		merged.TranslateSet = true
-	}
-	if overrides.ThreadsSet {
-		merged.Threads = overrides.Threads
-		merged.ThreadsSet = true
-	}
-	if overrides.InitialPromptSet {
-		merged.InitialPrompt = overrides.InitialPrompt
-		merged.InitialPromptSet = true
-	}
-	if overrides.TemperatureSet {
-		merged.Temperature = overrides.Temperature
-		merged.TemperatureSet = true
-	}
-	if overrides.TemperatureFloorSet {
-		merged.TemperatureFloor = overrides.TemperatureFloor
-		merged.TemperatureFloorSet = true
-	}
-
-	return merged
+	return t.cfg.DefaultOptions.Merge(overrides)
 }
 
 // webServiceResponse models the JSON payload returned by whisper-asr-webservice.
@@ -195,73 +195,5 @@ func (t *WhisperCPPTranscriber) TranscribeSilk(ctx context.Context, silkData []b
 }
 
 func (t *WhisperCPPTranscriber) mergeOptions(overrides Options) Options {
-	merged := t.defaultOptions
-
-	if overrides.LanguageSet {
-		merged.Language = overrides.Language
-		merged.LanguageSet = true
-	}
-	if overrides.TranslateSet {
-		merged.Translate = overrides.Translate
-		merged.TranslateSet = true
-	}
-	if overrides.ThreadsSet {
-		merged.Threads = overrides.Threads
-		merged.ThreadsSet = true
-	}
-	if overrides.InitialPromptSet {
-		merged.InitialPrompt = overrides.InitialPrompt
-		merged.InitialPromptSet = true
-	}
-	if overrides.TemperatureSet {
-		merged.Temperature = overrides.Temperature
-		merged.TemperatureSet = true
-	}
-	if overrides.TemperatureFloorSet {
-		merged.TemperatureFloor = overrides.TemperatureFloor
-		merged.TemperatureFloorSet = true
-	}
-
-	return merged
-}
-
-func resampleIfNeeded(samples []float32, fromRate, toRate int) []float32 {
-	if fromRate <= 0 {
-		fromRate = toRate
-	}
-	if fromRate == toRate || len(samples) == 0 {
-		dst := make([]float32, len(samples))
-		copy(dst, samples)
-		return dst
-	}
-
-	ratio := float64(fromRate) / float64(toRate)
-	if ratio <= 0 {
-		dst := make([]float32, len(samples))
-		copy(dst, samples)
-		return dst
-	}
-
-	outLen := int(math.Ceil(float64(len(samples)) / ratio))
-	if outLen <= 0 {
-		outLen = len(samples)
-	}
-
-	dst := make([]float32, outLen)
-	for i := range dst {
-		srcPos := float64(i) * ratio
-		idx := int(math.Floor(srcPos))
-		frac := srcPos - float64(idx)
-
-		if idx >= len(samples)-1 {
-			dst[i] = samples[len(samples)-1]
-			continue
-		}
-
-		a := samples[idx]
-		b := samples[idx+1]
-		dst[i] = float32(float64(a)*(1-frac) + float64(b)*frac)
-	}
-
-	return dst
+	return t.defaultOptions.Merge(overrides)
 }
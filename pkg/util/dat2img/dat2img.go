@@ -41,6 +41,8 @@ var (
 	// WeChat v4 related constants
 	V4XorKey byte = 0x37               // Default XOR key for WeChat v4 dat files
 	JpgTail       = []byte{0xFF, 0xD9} // JPG file tail marker
+
+	aesKeySet bool // Whether SetAesKey has been called with a valid key
 )
 
 // Dat2Image converts WeChat dat file data to image data
@@ -59,6 +61,30 @@ func Dat2Image(data []byte) ([]byte, string, error) {
 		}
 	}
 
+	return decryptXor(data)
+}
+
+// DecryptDat decrypts WeChat dat file data without converting wxgf payloads,
+// so callers can choose how to render them (see Wxgf2MP4).
+func DecryptDat(data []byte) ([]byte, string, error) {
+	if len(data) < 4 {
+		return nil, "", fmt.Errorf("data length is too short: %d", len(data))
+	}
+
+	if len(data) >= 6 {
+		for _, format := range V4Formats {
+			if bytes.Equal(data[:4], format.Header) {
+				return decryptV4(data, format.AesKey, V4XorKey)
+			}
+		}
+	}
+
+	return decryptXor(data)
+}
+
+// decryptXor handles dat files of older WeChat versions
+func decryptXor(data []byte) ([]byte, string, error) {
+
 	// For older WeChat versions, use XOR decryption
 	findFormat := func(data []byte, header []byte) bool {
 		xorBit := data[0] ^ header[0]
@@ -123,6 +149,19 @@ func calculateXorKeyV4(data []byte) (byte, error) {
 // the global XOR key for WeChat v4 dat files
 // Returns the found key and any error encountered
 func ScanAndSetXorKey(dirPath string) (byte, error) {
+	key, found, err := scanXorKey(dirPath)
+	if found {
+		V4XorKey = key
+	}
+	return V4XorKey, err
+}
+
+// scanXorKey calculates the WeChat v4 XOR key from the first usable "_t.dat"
+// file under dirPath, reporting whether one was found
+func scanXorKey(dirPath string) (byte, bool, error) {
+	var found bool
+	var xorKey byte
+
 	// Walk the directory recursively
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -175,18 +214,17 @@ func ScanAndSetXorKey(dirPath string) (byte, error) {
 			return nil
 		}
 
-		// Set global XOR key
-		V4XorKey = key
+		xorKey, found = key, true
 
 		// Stop traversal after finding a valid key
 		return filepath.SkipAll
 	})
 
 	if err != nil && err != filepath.SkipAll {
-		return V4XorKey, fmt.Errorf("error scanning directory: %v", err)
+		return xorKey, found, fmt.Errorf("error scanning directory: %v", err)
 	}
 
-	return V4XorKey, nil
+	return xorKey, found, nil
 }
 
 func SetAesKey(key string) {
@@ -199,11 +237,40 @@ func SetAesKey(key string) {
 		return
 	}
 	V4Format2.AesKey = decoded
+	aesKeySet = true
+}
+
+// HasAesKey reports whether SetAesKey has configured the WeChat v4 image key
+func HasAesKey() bool {
+	return aesKeySet
+}
+
+// IsV4Encrypted reports whether data is a WeChat v4 dat file that needs the image AES key
+func IsV4Encrypted(data []byte) bool {
+	return len(data) >= 4 && bytes.Equal(data[:4], V4Format2.Header)
 }
 
 // Dat2ImageV4 processes WeChat v4 dat image files
 // WeChat v4 uses a combination of AES-ECB and XOR encryption
 func Dat2ImageV4(data []byte, aeskey []byte) ([]byte, string, error) {
+	return dat2ImageV4(data, aeskey, V4XorKey)
+}
+
+func dat2ImageV4(data []byte, aeskey []byte, xorKey byte) ([]byte, string, error) {
+	result, imgType, err := decryptV4(data, aeskey, xorKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if imgType == WXGF.Ext {
+		return Wxam2pic(result)
+	}
+
+	return result, imgType, nil
+}
+
+// decryptV4 decrypts a WeChat v4 dat file and identifies its payload type
+func decryptV4(data []byte, aeskey []byte, xorKey byte) ([]byte, string, error) {
 	if len(data) < 15 {
 		return nil, "", fmt.Errorf("data length is too short for WeChat v4 format: %d", len(data))
 	}
@@ -256,10 +323,10 @@ func Dat2ImageV4(data []byte, aeskey []byte) ([]byte, string, error) {
 	if xorEncryptLen > 0 && middleEnd < uint32(len(fileData)) {
 		xorData := fileData[middleEnd:]
 
-		// Apply XOR decryption using global key
+		// Apply XOR decryption
 		xorDecrypted := make([]byte, len(xorData))
 		for i := range xorData {
-			xorDecrypted[i] = xorData[i] ^ V4XorKey
+			xorDecrypted[i] = xorData[i] ^ xorKey
 		}
 
 		result = append(result, xorDecrypted...)
@@ -274,10 +341,6 @@ func Dat2ImageV4(data []byte, aeskey []byte) ([]byte, string, error) {
 		}
 	}
 
-	if imgType == "wxgf" {
-		return Wxam2pic(result)
-	}
-
 	if imgType == "" {
 		return nil, "", fmt.Errorf("unknown image type after decryption")
 	}
@@ -0,0 +1,103 @@
+package dat2img
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+)
+
+// Keys holds the WeChat v4 image keys of a single account, so that several
+// accounts served by one process can decode their images independently.
+// A nil *Keys falls back to the process-wide keys set by SetAesKey and
+// ScanAndSetXorKey.
+type Keys struct {
+	aesKey []byte
+	xorKey atomic.Uint32
+}
+
+// NewKeys creates per-account keys from a hex encoded image AES key. An empty
+// key is allowed; files that need it then fail to decode.
+func NewKeys(aesKey string) (*Keys, error) {
+	k := &Keys{}
+	k.xorKey.Store(0x37)
+	if aesKey == "" {
+		return k, nil
+	}
+	decoded, err := hex.DecodeString(aesKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid aes key: %w", err)
+	}
+	k.aesKey = decoded
+	return k, nil
+}
+
+// ScanXorKey calculates the XOR key from the thumbnails under dirPath and
+// keeps the default when none is usable.
+func (k *Keys) ScanXorKey(dirPath string) error {
+	if k == nil {
+		_, err := ScanAndSetXorKey(dirPath)
+		return err
+	}
+	key, found, err := scanXorKey(dirPath)
+	if found {
+		k.xorKey.Store(uint32(key))
+	}
+	return err
+}
+
+// HasAesKey reports whether the image AES key is configured
+func (k *Keys) HasAesKey() bool {
+	if k == nil {
+		return HasAesKey()
+	}
+	return len(k.aesKey) > 0
+}
+
+// Dat2Image converts WeChat dat file data to image data using these keys
+func (k *Keys) Dat2Image(data []byte) ([]byte, string, error) {
+	if k == nil {
+		return Dat2Image(data)
+	}
+	if len(data) < 4 {
+		return nil, "", fmt.Errorf("data length is too short: %d", len(data))
+	}
+	if aesKey, ok := k.v4Key(data); ok {
+		return dat2ImageV4(data, aesKey, byte(k.xorKey.Load()))
+	}
+	return decryptXor(data)
+}
+
+// DecryptDat decrypts WeChat dat file data using these keys without
+// converting wxgf payloads
+func (k *Keys) DecryptDat(data []byte) ([]byte, string, error) {
+	if k == nil {
+		return DecryptDat(data)
+	}
+	if len(data) < 4 {
+		return nil, "", fmt.Errorf("data length is too short: %d", len(data))
+	}
+	if aesKey, ok := k.v4Key(data); ok {
+		return decryptV4(data, aesKey, byte(k.xorKey.Load()))
+	}
+	return decryptXor(data)
+}
+
+// v4Key returns the AES key for a WeChat v4 dat file, or false for older files
+func (k *Keys) v4Key(data []byte) ([]byte, bool) {
+	if len(data) < 6 {
+		return nil, false
+	}
+	switch {
+	case bytes.Equal(data[:4], V4Format1.Header):
+		return V4Format1.AesKey, true
+	case bytes.Equal(data[:4], V4Format2.Header):
+		if len(k.aesKey) == 0 {
+			// Same placeholder as the process-wide default; decryption fails
+			// and callers report the missing key
+			return []byte("0000000000000000"), true
+		}
+		return k.aesKey, true
+	}
+	return nil, false
+}
@@ -0,0 +1,64 @@
+package dat2img
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// v4Dat builds a minimal WeChat v4 dat file whose AES part is encrypted with key
+func v4Dat(t *testing.T, key []byte, xorKey byte) []byte {
+	t.Helper()
+	plain := append([]byte{0xFF, 0xD8, 0xFF, 0xE0}, bytes.Repeat([]byte{0x11}, 12)...)
+	padded := append(plain, bytes.Repeat([]byte{aes.BlockSize}, aes.BlockSize)...)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := make([]byte, len(padded))
+	for i := 0; i < len(padded); i += aes.BlockSize {
+		block.Encrypt(enc[i:i+aes.BlockSize], padded[i:i+aes.BlockSize])
+	}
+
+	data := append([]byte{}, V4Format2.Header...)
+	data = append(data, 0x00, 0x00)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(plain)))
+	data = binary.LittleEndian.AppendUint32(data, 2)
+	data = append(data, 0x01)
+	data = append(data, enc...)
+	return append(data, JpgTail[0]^xorKey, JpgTail[1]^xorKey)
+}
+
+func TestKeysPerAccount(t *testing.T) {
+	keyA, keyB := []byte("aaaaaaaaaaaaaaaa"), []byte("bbbbbbbbbbbbbbbb")
+	a, err := NewKeys(hex.EncodeToString(keyA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewKeys(hex.EncodeToString(keyB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := v4Dat(t, keyA, 0x37)
+
+	out, ext, err := a.Dat2Image(data)
+	if err != nil || ext != "jpg" || !bytes.HasSuffix(out, JpgTail) {
+		t.Fatalf("account a: ext = %q, err = %v", ext, err)
+	}
+	if _, _, err := b.Dat2Image(data); err == nil {
+		t.Fatal("account b should not decode images encrypted with a's key")
+	}
+	if !a.HasAesKey() || bytes.Equal(V4Format2.AesKey, keyA) {
+		t.Fatal("per-account keys must not change the process-wide key")
+	}
+
+	empty, err := NewKeys("")
+	if err != nil || empty.HasAesKey() {
+		t.Fatalf("empty keys: %v", err)
+	}
+	if _, err := NewKeys("not-hex"); err == nil {
+		t.Fatal("expected error for invalid key")
+	}
+}
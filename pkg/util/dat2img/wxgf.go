@@ -49,15 +49,7 @@ func Wxam2pic(data []byte) ([]byte, string, error) {
 
 	if partitions.LikeAnime() {
 		// FIXME mask frame not work
-		animeFrames := make([][]byte, 0)
-		maskFrames := make([][]byte, 0)
-		for i, partition := range partitions.Partitions {
-			if i%2 == 0 {
-				maskFrames = append(maskFrames, data[partition.Offset:partition.Offset+partition.Size])
-			} else {
-				animeFrames = append(animeFrames, data[partition.Offset:partition.Offset+partition.Size])
-			}
-		}
+		animeFrames, maskFrames := partitions.Frames(data)
 		if FFmpegMode {
 			mp4Data, err := ConvertAnime2GIF(animeFrames, maskFrames)
 			if err != nil {
@@ -90,12 +82,61 @@ func Wxam2pic(data []byte) ([]byte, string, error) {
 	return mp4Data, "mp4", nil
 }
 
+// Wxgf2MP4 transmuxes the HEVC stream inside a wxgf payload into a
+// fragmented MP4 without ffmpeg, for clients that can play HEVC directly.
+func Wxgf2MP4(data []byte) ([]byte, error) {
+	if len(data) < 15 || !bytes.Equal(data[0:4], WXGF.Header) {
+		return nil, fmt.Errorf("invalid wxgf")
+	}
+
+	partitions, err := findDataPartition(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if partitions.LikeAnime() {
+		animeFrames, maskFrames := partitions.Frames(data)
+		return TransmuxAnime2MP4(animeFrames, maskFrames)
+	}
+
+	offset := partitions.Partitions[partitions.MaxIndex].Offset
+	size := partitions.Partitions[partitions.MaxIndex].Size
+	return Transmux2MP4(data[offset : offset+size])
+}
+
+// WxgfStream returns the HEVC Annex-B stream of the main wxgf partition.
+func WxgfStream(data []byte) ([]byte, error) {
+	if len(data) < 15 || !bytes.Equal(data[0:4], WXGF.Header) {
+		return nil, fmt.Errorf("invalid wxgf")
+	}
+
+	partitions, err := findDataPartition(data)
+	if err != nil {
+		return nil, err
+	}
+	offset := partitions.Partitions[partitions.MaxIndex].Offset
+	size := partitions.Partitions[partitions.MaxIndex].Size
+	return data[offset : offset+size], nil
+}
+
 type Partitions struct {
 	Partitions []Partition
 	MaxRatio   float64
 	MaxIndex   int
 }
 
+// Frames splits an animated wxgf into its anime and mask frames.
+func (p *Partitions) Frames(data []byte) (animeFrames [][]byte, maskFrames [][]byte) {
+	for i, partition := range p.Partitions {
+		if i%2 == 0 {
+			maskFrames = append(maskFrames, data[partition.Offset:partition.Offset+partition.Size])
+		} else {
+			animeFrames = append(animeFrames, data[partition.Offset:partition.Offset+partition.Size])
+		}
+	}
+	return animeFrames, maskFrames
+}
+
 func (p *Partitions) LikeAnime() bool {
 	return len(p.Partitions) > 1 && p.MaxRatio < MinRatio
 }